@@ -120,6 +120,66 @@ func (w *WorkScheduleConfig) GetWorkingHoursInPeriod(start, end time.Time) float
 	return totalHours
 }
 
+// WorkingHoursBetween calculates effective working hours in a time period measured in the
+// schedule time zone, counting partial days at both ends and excluding the lunch break.
+// AddWorkingHours is its inverse; SLA clocks and HR reports rely on the pair agreeing.
+func (w *WorkScheduleConfig) WorkingHoursBetween(start, end time.Time) float64 {
+	if start.After(end) {
+		return 0
+	}
+
+	loc := w.Location()
+	start, end = start.In(loc), end.In(loc)
+	totalHours := 0.0
+
+	// Walk calendar days from midnight of the first day so partial days at both ends are counted
+	for day := startOfDay(start); day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !w.IsWorkingDay(day) {
+			continue
+		}
+
+		// Find overlap between [start, end] and each working period of the day
+		for _, period := range w.workingPeriods(day) {
+			overlapStart := maxTime(start, period[0])
+			overlapEnd := minTime(end, period[1])
+			if overlapStart.Before(overlapEnd) {
+				totalHours += overlapEnd.Sub(overlapStart).Hours()
+			}
+		}
+	}
+
+	return totalHours
+}
+
+// AddWorkingHours returns the moment at which the given number of working hours will have
+// elapsed after start, skipping non-working days, out-of-hours time and the lunch break
+func (w *WorkScheduleConfig) AddWorkingHours(start time.Time, hours float64) time.Time {
+	remaining := time.Duration(hours * float64(time.Hour))
+	if remaining <= 0 || len(w.WorkingDays) == 0 {
+		return start.Add(remaining)
+	}
+
+	cursor := start.In(w.Location())
+	for day := startOfDay(cursor); ; day = day.AddDate(0, 0, 1) {
+		if !w.IsWorkingDay(day) {
+			continue
+		}
+
+		for _, period := range w.workingPeriods(day) {
+			from := maxTime(cursor, period[0])
+			if !from.Before(period[1]) {
+				continue
+			}
+
+			available := period[1].Sub(from)
+			if remaining <= available {
+				return from.Add(remaining)
+			}
+			remaining -= available
+		}
+	}
+}
+
 // GetNextWorkingDay returns the next working day after the given date
 func (w *WorkScheduleConfig) GetNextWorkingDay(date time.Time) time.Time {
 	next := date.AddDate(0, 0, 1)
@@ -189,7 +249,34 @@ func (w *WorkScheduleConfig) GetOptimalTaskSchedule(estimatedHours float64, dead
 	return startOfWorkDay
 }
 
+// Location returns the schedule time zone, falling back to UTC when it cannot be loaded
+func (w *WorkScheduleConfig) Location() *time.Location {
+	loc, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// workingPeriods returns the morning and afternoon working periods around the 12:00-13:00 lunch break
+func (w *WorkScheduleConfig) workingPeriods(day time.Time) [][2]time.Time {
+	year, month, date := day.Date()
+	at := func(hour int) time.Time {
+		return time.Date(year, month, date, hour, 0, 0, 0, day.Location())
+	}
+
+	return [][2]time.Time{
+		{at(9), at(12)},
+		{at(13), at(16)},
+	}
+}
+
 // Helper functions
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
 func maxTime(a, b time.Time) time.Time {
 	if a.After(b) {
 		return a
@@ -261,3 +261,39 @@ func (h *AdminHandler) GetChecklistStats(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"stats": stats})
 }
+
+// GetAIUsage returns LLM token usage, latency and estimated cost broken down by feature, model,
+// user and day, with this month's budget status
+func (h *AdminHandler) GetAIUsage(c *gin.Context) {
+	startDate := time.Now().AddDate(0, 0, -30)
+	endDate := time.Now()
+
+	if startDateStr := c.Query("start_date"); startDateStr != "" {
+		parsed, err := time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format. Use YYYY-MM-DD"})
+			return
+		}
+		startDate = time.Date(parsed.Year(), parsed.Month(), parsed.Day(), 0, 0, 0, 0, time.Local)
+	}
+	if endDateStr := c.Query("end_date"); endDateStr != "" {
+		parsed, err := time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format. Use YYYY-MM-DD"})
+			return
+		}
+		endDate = time.Date(parsed.Year(), parsed.Month(), parsed.Day(), 23, 59, 59, 0, time.Local)
+	}
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+		return
+	}
+
+	report, err := services.NewAIUsageService(h.DB).GetUsageReport(startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch AI usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"usage": report})
+}
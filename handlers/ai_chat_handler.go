@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"project-x/services"
 	"strconv"
@@ -84,6 +85,114 @@ func (h *AIChatHandler) ProcessAIMessage(c *gin.Context) {
 			"data":        aiResponse.Data,
 			"suggestions": aiResponse.Suggestions,
 			"language":    aiResponse.Language,
+			"cancelled":   aiResponse.Cancelled,
+		},
+	})
+}
+
+// CancelAIGeneration stops the AI answer being generated for the user in a room
+func (h *AIChatHandler) CancelAIGeneration(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	if !h.AIChatService.CancelGeneration(userID.(uint), uint(roomID)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No AI response is being generated in this room"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "AI response cancelled"})
+}
+
+// GetPendingAIActions lists the changes the assistant proposed in a room that still wait for confirmation
+func (h *AIChatHandler) GetPendingAIActions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room ID"})
+		return
+	}
+
+	actions, err := h.AIChatService.GetPendingActions(userID.(uint), uint(roomID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get pending actions"})
+		return
+	}
+
+	pending := make([]gin.H, len(actions))
+	for i, action := range actions {
+		pending[i] = gin.H{
+			"action_id":  action.ID,
+			"tool":       action.Tool,
+			"summary":    action.Summary,
+			"status":     action.Status,
+			"expires_at": action.ExpiresAt,
+			"created_at": action.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"actions": pending, "count": len(pending)})
+}
+
+// ConfirmAIAction carries out a change the assistant proposed
+func (h *AIChatHandler) ConfirmAIAction(c *gin.Context) {
+	h.resolveAIAction(c, h.AIChatService.ConfirmAction)
+}
+
+// CancelAIAction drops a change the assistant proposed
+func (h *AIChatHandler) CancelAIAction(c *gin.Context) {
+	h.resolveAIAction(c, h.AIChatService.CancelAction)
+}
+
+func (h *AIChatHandler) resolveAIAction(c *gin.Context, resolve func(userID, actionID uint) (*services.AIResponse, error)) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	actionID, err := strconv.ParseUint(c.Param("actionId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action ID"})
+		return
+	}
+
+	aiResponse, err := resolve(userID.(uint), uint(actionID))
+	switch {
+	case errors.Is(err, services.ErrChatActionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrChatActionResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrChatActionExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process action", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Action processed",
+		"response": gin.H{
+			"message":  aiResponse.Message,
+			"action":   aiResponse.Action,
+			"data":     aiResponse.Data,
+			"language": aiResponse.Language,
 		},
 	})
 }
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"project-x/models"
+	"project-x/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AIDigestHandler struct {
+	DB            *gorm.DB
+	DigestService *services.AIDigestService
+}
+
+func NewAIDigestHandler(db *gorm.DB, digestService *services.AIDigestService) *AIDigestHandler {
+	return &AIDigestHandler{
+		DB:            db,
+		DigestService: digestService,
+	}
+}
+
+// GetMyStandups returns the user's own daily standups of the past days
+func (h *AIDigestHandler) GetMyStandups(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "14"))
+	digests, err := h.DigestService.GetUserStandups(userID.(uint), days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get standups"})
+		return
+	}
+
+	standups := make([]gin.H, len(digests))
+	for i, digest := range digests {
+		standups[i] = digestResponse(digest)
+	}
+	c.JSON(http.StatusOK, gin.H{"standups": standups, "count": len(standups)})
+}
+
+// GetProjectDigests returns a project's archive of weekly digests and its members' standups
+// (project members, Manager+, Admin)
+func (h *AIDigestHandler) GetProjectDigests(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userRole, _ := c.Get("userRole")
+
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	filter := services.AIDigestFilter{
+		Type:     models.AIDigestType(c.Query("type")),
+		Language: c.Query("language"),
+	}
+	if filter.Type != "" && filter.Type != models.AIDigestDailyStandup && filter.Type != models.AIDigestWeeklyProject {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be 'daily_standup' or 'weekly_project'"})
+		return
+	}
+	if from := c.Query("from"); from != "" {
+		date, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, use YYYY-MM-DD"})
+			return
+		}
+		filter.From = &date
+	}
+	if to := c.Query("to"); to != "" {
+		date, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, use YYYY-MM-DD"})
+			return
+		}
+		end := date.AddDate(0, 0, 1) // Include the whole last day
+		filter.To = &end
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	digests, err := h.DigestService.GetProjectDigests(uint(projectID), userID.(uint), userRole.(models.Role), filter)
+	switch {
+	case errors.Is(err, services.ErrDigestProjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrDigestAccessDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get digests"})
+		return
+	}
+
+	response := make([]gin.H, len(digests))
+	for i, digest := range digests {
+		response[i] = digestResponse(digest)
+	}
+	c.JSON(http.StatusOK, gin.H{"digests": response, "count": len(response)})
+}
+
+// GenerateProjectDigest writes a digest of the project's past week now (Manager/Admin only)
+func (h *AIDigestHandler) GenerateProjectDigest(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	digest, err := h.DigestService.GenerateProjectDigest(uint(projectID), userID.(uint))
+	var validationErr *services.AIValidationError
+	switch {
+	case errors.Is(err, services.ErrDigestProjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrAIBudgetExceeded):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to generate digest: the AI's answer failed validation", "validation_issues": validationErr.Issues})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate digest", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Digest generated", "digest": digestResponse(*digest)})
+}
+
+func digestResponse(digest models.AIDigest) gin.H {
+	response := gin.H{
+		"id":           digest.ID,
+		"type":         digest.Type,
+		"project_id":   digest.ProjectID,
+		"user_id":      digest.UserID,
+		"period_start": digest.PeriodStart,
+		"period_end":   digest.PeriodEnd,
+		"language":     digest.Language,
+		"content":      digest.Content,
+		"created_at":   digest.CreatedAt,
+	}
+	if digest.User != nil {
+		response["username"] = digest.User.Username
+	}
+	if digest.Project != nil {
+		response["project_title"] = digest.Project.Title
+	}
+	return response
+}
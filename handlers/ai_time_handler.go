@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 	"project-x/config"
+	"project-x/models"
 	"project-x/services"
 	"strconv"
 	"time"
@@ -39,7 +40,7 @@ func (h *AITimeHandler) GetTimeAnalysis(c *gin.Context) {
 		return
 	}
 
-	analyses, err := h.AIOptimizer.AnalyzeTaskTimeRisks()
+	analyses, failures, err := h.AIOptimizer.AnalyzeTaskTimeRisks()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to analyze time risks", "details": err.Error()})
 		return
@@ -48,7 +49,8 @@ func (h *AITimeHandler) GetTimeAnalysis(c *gin.Context) {
 	// Add Arabic working schedule context
 	now := time.Now()
 	response := gin.H{
-		"analyses": analyses,
+		"analyses":        analyses,
+		"failed_analyses": failures,
 		"working_schedule": gin.H{
 			"working_days":    []string{"Saturday", "Sunday", "Monday", "Tuesday", "Wednesday", "Thursday"},
 			"working_hours":   "9:00 AM - 4:00 PM",
@@ -65,6 +67,7 @@ func (h *AITimeHandler) GetTimeAnalysis(c *gin.Context) {
 			"high_risk_tasks":   h.countTasksByRisk(analyses, "high"),
 			"medium_risk_tasks": h.countTasksByRisk(analyses, "medium"),
 			"low_risk_tasks":    h.countTasksByRisk(analyses, "low"),
+			"failed_tasks":      len(failures),
 		},
 		"generated_at": now,
 	}
@@ -88,33 +91,17 @@ func (h *AITimeHandler) GetMyTimeAnalysis(c *gin.Context) {
 	}
 
 	// Get user's tasks analysis
-	allAnalyses, err := h.AIOptimizer.AnalyzeTaskTimeRisks()
+	userAnalyses, failures, err := h.AIOptimizer.AnalyzeUserTaskTimeRisks(userID.(uint))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to analyze task risks", "details": err.Error()})
 		return
 	}
 
-	// Filter analyses for current user
-	var userAnalyses []services.TimeAnalysis
-	for _, analysis := range allAnalyses {
-		// Get task to check if it belongs to current user
-		var task struct {
-			UserID uint
-		}
-		if err := h.DB.Model(&struct {
-			ID     uint
-			UserID uint
-		}{}).Where("id = ?", analysis.TaskID).First(&task).Error; err == nil {
-			if task.UserID == userID.(uint) {
-				userAnalyses = append(userAnalyses, analysis)
-			}
-		}
-	}
-
 	now := time.Now()
 	response := gin.H{
 		"workload_analysis": workloadAnalysis,
 		"task_analyses":     userAnalyses,
+		"failed_analyses":   failures,
 		"working_schedule": gin.H{
 			"working_days":    []string{"Saturday", "Sunday", "Monday", "Tuesday", "Wednesday", "Thursday"},
 			"working_hours":   "9:00 AM - 4:00 PM",
@@ -299,7 +286,7 @@ func (h *AITimeHandler) GetTimeOptimizationDashboard(c *gin.Context) {
 	}
 
 	// Get comprehensive analysis
-	taskAnalyses, err := h.AIOptimizer.AnalyzeTaskTimeRisks()
+	taskAnalyses, _, err := h.AIOptimizer.AnalyzeTaskTimeRisks()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to analyze tasks", "details": err.Error()})
 		return
@@ -410,7 +397,7 @@ func (h *AITimeHandler) GetTimeOptimizationRecommendations(c *gin.Context) {
 	}
 
 	// Get task analyses
-	taskAnalyses, err := h.AIOptimizer.AnalyzeTaskTimeRisks()
+	taskAnalyses, _, err := h.AIOptimizer.AnalyzeTaskTimeRisks()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to analyze tasks", "details": err.Error()})
 		return
@@ -513,11 +500,172 @@ func (h *AITimeHandler) GetAIPerformanceMetrics(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"metrics":      metrics,
+		"usage_budget": services.NewAIUsageService(h.DB).GetBudgetStatus(),
 		"generated_at": time.Now(),
 		"message":      "AI performance metrics retrieved successfully",
 	})
 }
 
+// GetTaskDurationEstimate returns the local duration estimates for a task without calling the AI
+// (the task's assignee, or Manager+, HR, Admin)
+func (h *AITimeHandler) GetTaskDurationEstimate(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	userRole, _ := c.Get("userRole")
+
+	taskID, err := strconv.ParseUint(c.Param("taskId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var task models.Task
+	if err := h.DB.First(&task, taskID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	if task.UserID != userID.(uint) && userRole != "admin" && userRole != "manager" && userRole != "hr" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied. Only the task assignee, Manager+, HR, or Admin can view duration estimates"})
+		return
+	}
+
+	estimates, err := services.NewDurationEstimator(h.DB).EstimateTask(&task)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to estimate task duration", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"estimates":    estimates,
+		"mode":         services.DurationEstimatorMode(),
+		"generated_at": time.Now(),
+	})
+}
+
+// GetDurationEstimatorSummary returns the duration estimator's fitted models and accuracy
+// (Manager+, HR, Admin only)
+func (h *AITimeHandler) GetDurationEstimatorSummary(c *gin.Context) {
+	userRole, exists := c.Get("userRole")
+	if !exists || (userRole != "admin" && userRole != "manager" && userRole != "hr") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied. Only Manager+, HR, or Admin can access duration estimator metrics"})
+		return
+	}
+
+	summary, err := services.NewDurationEstimator(h.DB).Summary()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load duration estimator", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"estimator":    summary,
+		"generated_at": time.Now(),
+	})
+}
+
+// GetAnalysisVariants returns the model and prompt variants task analyses are split between
+func (h *AITimeHandler) GetAnalysisVariants(c *gin.Context) {
+	userRole, exists := c.Get("userRole")
+	if !exists || (userRole != "admin" && userRole != "manager" && userRole != "hr") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied. Only Manager+, HR, or Admin can view analysis variants"})
+		return
+	}
+
+	variants, err := services.NewAIExperimentService(h.DB).GetVariants()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load analysis variants", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"variants":        variants,
+		"prompt_versions": services.TaskAnalysisPromptVersions(),
+	})
+}
+
+// UpdateAnalysisVariants replaces the A/B variants (Admin only)
+func (h *AITimeHandler) UpdateAnalysisVariants(c *gin.Context) {
+	userRole, exists := c.Get("userRole")
+	if !exists || userRole != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied. Only Admin can configure analysis variants"})
+		return
+	}
+
+	var request struct {
+		Variants []services.AnalysisVariantRequest `json:"variants" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variants, err := services.NewAIExperimentService(h.DB).SetVariants(request.Variants)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Analysis variants updated successfully",
+		"variants": variants,
+	})
+}
+
+// ResetAnalysisVariants removes the A/B variants so every task uses the default (Admin only)
+func (h *AITimeHandler) ResetAnalysisVariants(c *gin.Context) {
+	userRole, exists := c.Get("userRole")
+	if !exists || userRole != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied. Only Admin can configure analysis variants"})
+		return
+	}
+
+	if err := services.NewAIExperimentService(h.DB).ResetVariants(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset analysis variants", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Analysis variants reset to the default"})
+}
+
+// GetAIEvaluationReport compares the accuracy of the analysis variants on completed tasks
+func (h *AITimeHandler) GetAIEvaluationReport(c *gin.Context) {
+	userRole, exists := c.Get("userRole")
+	if !exists || (userRole != "admin" && userRole != "manager" && userRole != "hr") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied. Only Manager+, HR, or Admin can access AI evaluation"})
+		return
+	}
+
+	startDate := time.Now().AddDate(0, 0, -90)
+	endDate := time.Now()
+	if startDateStr := c.Query("start_date"); startDateStr != "" {
+		parsed, err := time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date format. Use YYYY-MM-DD"})
+			return
+		}
+		startDate = parsed
+	}
+	if endDateStr := c.Query("end_date"); endDateStr != "" {
+		parsed, err := time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date format. Use YYYY-MM-DD"})
+			return
+		}
+		endDate = parsed.Add(24*time.Hour - time.Second)
+	}
+
+	report, err := services.NewAIExperimentService(h.DB).EvaluateVariants(startDate, endDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate analysis variants", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"evaluation":   report,
+		"generated_at": time.Now(),
+	})
+}
+
 // Helper functions
 
 func (h *AITimeHandler) countTasksByRisk(analyses []services.TimeAnalysis, risk string) int {
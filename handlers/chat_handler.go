@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"project-x/services"
 	"strconv"
@@ -54,6 +55,41 @@ func (h *ChatHandler) GetOrCreateTeamChat(c *gin.Context) {
 	})
 }
 
+// GetOrCreateProjectChat creates or returns a project's team room and joins the user to it
+func (h *ChatHandler) GetOrCreateProjectChat(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("projectId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	room, err := h.ChatService.GetOrCreateProjectChat(uint(projectID), userID.(uint))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrProjectNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrNotProjectMember):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open project chat"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Project chat ready",
+		"room": gin.H{
+			"id":          room.ID,
+			"name":        room.Name,
+			"description": room.Description,
+			"project_id":  room.ProjectID,
+			"created_at":  room.CreatedAt,
+		},
+	})
+}
+
 // SendMessage sends a message to team chat
 func (h *ChatHandler) SendMessage(c *gin.Context) {
 	roomID, err := strconv.ParseUint(c.Param("roomId"), 10, 32)
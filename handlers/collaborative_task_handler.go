@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"project-x/models"
 	"project-x/services"
 	"strconv"
 	"time"
@@ -11,11 +13,12 @@ import (
 )
 
 type CollaborativeTaskHandler struct {
-	DB *gorm.DB
+	DB                  *gorm.DB
+	NotificationService *services.NotificationService
 }
 
-func NewCollaborativeTaskHandler(db *gorm.DB) *CollaborativeTaskHandler {
-	return &CollaborativeTaskHandler{DB: db}
+func NewCollaborativeTaskHandler(db *gorm.DB, notificationService *services.NotificationService) *CollaborativeTaskHandler {
+	return &CollaborativeTaskHandler{DB: db, NotificationService: notificationService}
 }
 
 // CreateCollaborativeTask creates a new collaborative task (Head/Manager/Admin only)
@@ -139,8 +142,15 @@ func (h *CollaborativeTaskHandler) UpdateTaskProgress(c *gin.Context) {
 		return
 	}
 
+	userID, _ := c.Get("userID")
+
 	collaborativeTaskService := services.NewCollaborativeTaskService(h.DB)
-	err = collaborativeTaskService.UpdateTaskProgress(uint(taskID), updateRequest.Progress)
+	collaborativeTaskService.SetNotificationService(h.NotificationService)
+	err = collaborativeTaskService.UpdateTaskProgress(uint(taskID), updateRequest.Progress, userID.(uint))
+	if errors.Is(err, services.ErrNotTaskContributor) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -164,17 +174,34 @@ func (h *CollaborativeTaskHandler) GetCollaborativeTaskDetails(c *gin.Context) {
 		return
 	}
 
+	// Count each participant's checklist items
+	itemTotals := make(map[uint]int)
+	itemsCompleted := make(map[uint]int)
+	var items []gin.H
+	for _, item := range task.Items {
+		if item.AssigneeID != nil {
+			itemTotals[*item.AssigneeID]++
+			if item.IsCompleted {
+				itemsCompleted[*item.AssigneeID]++
+			}
+		}
+		items = append(items, taskItemResponse(item))
+	}
+
 	// Build participants list
 	var participants []gin.H
 	for _, participant := range task.Participants {
 		participants = append(participants, gin.H{
-			"id":           participant.ID,
-			"user_id":      participant.UserID,
-			"username":     participant.User.Username,
-			"role":         participant.Role,
-			"status":       participant.Status,
-			"contribution": participant.Contribution,
-			"assigned_at":  participant.AssignedAt,
+			"id":              participant.ID,
+			"user_id":         participant.UserID,
+			"username":        participant.User.Username,
+			"role":            participant.Role,
+			"status":          participant.Status,
+			"contribution":    participant.Contribution,
+			"assigned_at":     participant.AssignedAt,
+			"completed_at":    participant.CompletedAt,
+			"items_total":     itemTotals[participant.UserID],
+			"items_completed": itemsCompleted[participant.UserID],
 		})
 	}
 
@@ -195,7 +222,10 @@ func (h *CollaborativeTaskHandler) GetCollaborativeTaskDetails(c *gin.Context) {
 			"assigned_at":  task.AssignedAt,
 			"due_date":     task.DueDate,
 			"created_at":   task.CreatedAt,
+			"review_round": task.ReviewRound,
+			"submitted_at": task.SubmittedAt,
 			"participants": participants,
+			"items":        items,
 		},
 	})
 }
@@ -252,3 +282,288 @@ func (h *CollaborativeTaskHandler) GetUserCollaborativeTasks(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"collaborative_tasks": taskList})
 }
+
+// GetTaskItems returns the checklist items of a collaborative task
+func (h *CollaborativeTaskHandler) GetTaskItems(c *gin.Context) {
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	collaborativeTaskService := services.NewCollaborativeTaskService(h.DB)
+	items, err := collaborativeTaskService.GetTaskItems(uint(taskID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	var itemList []gin.H
+	for _, item := range items {
+		itemList = append(itemList, taskItemResponse(item))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": itemList})
+}
+
+// AddTaskItem adds a sub-task or checklist item to a collaborative task (lead only)
+func (h *CollaborativeTaskHandler) AddTaskItem(c *gin.Context) {
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var itemRequest struct {
+		Title       string     `json:"title" binding:"required"`
+		Description string     `json:"description"`
+		AssigneeID  *uint      `json:"assignee_id"`
+		DueDate     *time.Time `json:"due_date"`
+		SortOrder   int        `json:"sort_order"`
+	}
+
+	if err := c.ShouldBindJSON(&itemRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	userRole, _ := c.Get("userRole")
+
+	collaborativeTaskService := services.NewCollaborativeTaskService(h.DB)
+	collaborativeTaskService.SetNotificationService(h.NotificationService)
+	item, err := collaborativeTaskService.AddTaskItem(
+		uint(taskID),
+		userID.(uint),
+		userRole.(models.Role),
+		itemRequest.Title,
+		itemRequest.Description,
+		itemRequest.AssigneeID,
+		itemRequest.DueDate,
+		itemRequest.SortOrder,
+	)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Checklist item added successfully",
+		"item":    taskItemResponse(*item),
+	})
+}
+
+// UpdateTaskItem changes a checklist item's details or assignee (lead only)
+func (h *CollaborativeTaskHandler) UpdateTaskItem(c *gin.Context) {
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("itemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var itemRequest struct {
+		Title       string     `json:"title" binding:"required"`
+		Description string     `json:"description"`
+		AssigneeID  *uint      `json:"assignee_id"`
+		DueDate     *time.Time `json:"due_date"`
+		SortOrder   int        `json:"sort_order"`
+	}
+
+	if err := c.ShouldBindJSON(&itemRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	userRole, _ := c.Get("userRole")
+
+	collaborativeTaskService := services.NewCollaborativeTaskService(h.DB)
+	collaborativeTaskService.SetNotificationService(h.NotificationService)
+	item, err := collaborativeTaskService.UpdateTaskItem(
+		uint(taskID),
+		uint(itemID),
+		userID.(uint),
+		userRole.(models.Role),
+		itemRequest.Title,
+		itemRequest.Description,
+		itemRequest.AssigneeID,
+		itemRequest.DueDate,
+		itemRequest.SortOrder,
+	)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Checklist item updated successfully",
+		"item":    taskItemResponse(*item),
+	})
+}
+
+// SetTaskItemCompleted checks or unchecks a checklist item (assignee or lead)
+func (h *CollaborativeTaskHandler) SetTaskItemCompleted(c *gin.Context) {
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("itemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var completeRequest struct {
+		Completed *bool `json:"completed" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&completeRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	userRole, _ := c.Get("userRole")
+
+	collaborativeTaskService := services.NewCollaborativeTaskService(h.DB)
+	collaborativeTaskService.SetNotificationService(h.NotificationService)
+	item, err := collaborativeTaskService.SetTaskItemCompleted(uint(taskID), uint(itemID), userID.(uint), userRole.(models.Role), *completeRequest.Completed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := collaborativeTaskService.GetCollaborativeTaskWithDetails(uint(taskID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Checklist item updated successfully",
+		"item":     taskItemResponse(*item),
+		"progress": task.Progress,
+		"status":   task.Status,
+	})
+}
+
+// DeleteTaskItem removes a checklist item (lead only)
+func (h *CollaborativeTaskHandler) DeleteTaskItem(c *gin.Context) {
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("itemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	userRole, _ := c.Get("userRole")
+
+	collaborativeTaskService := services.NewCollaborativeTaskService(h.DB)
+	collaborativeTaskService.SetNotificationService(h.NotificationService)
+	if err := collaborativeTaskService.DeleteTaskItem(uint(taskID), uint(itemID), userID.(uint), userRole.(models.Role)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Checklist item deleted successfully"})
+}
+
+// taskItemResponse formats a checklist item for API responses
+func taskItemResponse(item models.CollaborativeTaskItem) gin.H {
+	response := gin.H{
+		"id":           item.ID,
+		"title":        item.Title,
+		"description":  item.Description,
+		"assignee_id":  item.AssigneeID,
+		"sort_order":   item.SortOrder,
+		"due_date":     item.DueDate,
+		"is_completed": item.IsCompleted,
+		"completed_at": item.CompletedAt,
+		"created_at":   item.CreatedAt,
+	}
+	if item.Assignee != nil {
+		response["assignee"] = item.Assignee.Username
+	}
+	if item.CompletedBy != nil {
+		response["completed_by"] = item.CompletedBy.Username
+	}
+	return response
+}
+
+// ReviewTask records the current user's review decision on a task awaiting review (reviewers only)
+func (h *CollaborativeTaskHandler) ReviewTask(c *gin.Context) {
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var reviewRequest struct {
+		Decision models.ReviewDecision `json:"decision" binding:"required"` // approved, changes_requested
+		Comment  string                `json:"comment"`
+	}
+
+	if err := c.ShouldBindJSON(&reviewRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	collaborativeTaskService := services.NewCollaborativeTaskService(h.DB)
+	collaborativeTaskService.SetNotificationService(h.NotificationService)
+	task, err := collaborativeTaskService.ReviewTask(uint(taskID), userID.(uint), reviewRequest.Decision, reviewRequest.Comment)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Review recorded successfully",
+		"status":  task.Status,
+	})
+}
+
+// GetTaskReviews returns the review history of a collaborative task
+func (h *CollaborativeTaskHandler) GetTaskReviews(c *gin.Context) {
+	taskID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	collaborativeTaskService := services.NewCollaborativeTaskService(h.DB)
+	reviews, err := collaborativeTaskService.GetTaskReviews(uint(taskID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	var reviewList []gin.H
+	for _, review := range reviews {
+		reviewList = append(reviewList, gin.H{
+			"id":         review.ID,
+			"round":      review.Round,
+			"reviewer":   review.Reviewer.Username,
+			"decision":   review.Decision,
+			"comment":    review.Comment,
+			"created_at": review.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reviews": reviewList})
+}
@@ -1,7 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"project-x/config"
 	"project-x/models"
 	"project-x/services"
 	"strconv"
@@ -63,6 +68,13 @@ func (h *HRProblemHandler) CreateProblem(c *gin.Context) {
 		request.Priority = models.ProblemPriorityMedium
 	}
 
+	// Anonymous reports are filed without any link to the submitting account
+	if request.IsAnonymous {
+		h.createAnonymousProblem(c, request.Title, request.Description, request.Category, request.Priority,
+			request.WitnessInfo, request.Location, request.IncidentDate, request.PreviousReports)
+		return
+	}
+
 	// Set default contact method if not provided
 	if request.ContactMethod == "" {
 		request.ContactMethod = "email"
@@ -116,7 +128,11 @@ func (h *HRProblemHandler) GetProblem(c *gin.Context) {
 
 	problem, err := h.HRProblemService.GetProblemByID(uint(problemID), userID.(uint), userRole.(models.Role))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Problem not found"})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Problem not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -144,7 +160,7 @@ func (h *HRProblemHandler) GetProblem(c *gin.Context) {
 	}
 
 	// Add reporter info if not anonymous and user has permission
-	if !problem.IsAnonymous && (userRole.(models.Role) == models.RoleHR || userRole.(models.Role) == models.RoleAdmin) {
+	if !problem.IsAnonymous && problem.Reporter != nil && (userRole.(models.Role) == models.RoleHR || userRole.(models.Role) == models.RoleAdmin) {
 		response["reporter"] = gin.H{
 			"id":       problem.Reporter.ID,
 			"username": problem.Reporter.Username,
@@ -164,17 +180,29 @@ func (h *HRProblemHandler) GetProblem(c *gin.Context) {
 	if userRole.(models.Role) == models.RoleHR || userRole.(models.Role) == models.RoleAdmin {
 		response["hr_notes"] = problem.HRNotes
 		response["follow_up_date"] = problem.FollowUpDate
+		response["sla"] = h.HRProblemService.GetProblemSLA(problem)
+
+		var followUps []gin.H
+		for _, followUp := range problem.FollowUps {
+			followUps = append(followUps, followUpResponse(followUp))
+		}
+		response["follow_ups"] = followUps
 	}
 
+	// Add evidence attachments, grouped by the comment they belong to
+	attachments, commentAttachments := groupAttachments(problem.Attachments)
+	response["attachments"] = attachments
+
 	// Add comments
 	var comments []gin.H
 	for _, comment := range problem.Comments {
 		comments = append(comments, gin.H{
-			"id":         comment.ID,
-			"comment":    comment.Comment,
-			"user":       comment.User.Username,
-			"is_hr_only": comment.IsHROnly,
-			"created_at": comment.CreatedAt,
+			"id":          comment.ID,
+			"comment":     comment.Comment,
+			"user":        hrCommentAuthor(comment),
+			"is_hr_only":  comment.IsHROnly,
+			"attachments": commentAttachments[comment.ID],
+			"created_at":  comment.CreatedAt,
 		})
 	}
 	response["comments"] = comments
@@ -187,7 +215,7 @@ func (h *HRProblemHandler) GetProblem(c *gin.Context) {
 			"old_status":   update.OldStatus,
 			"new_status":   update.NewStatus,
 			"update_note":  update.UpdateNote,
-			"updated_by":   update.UpdatedByUser.Username,
+			"updated_by":   hrUpdateAuthor(update),
 			"is_automatic": update.IsAutomatic,
 			"created_at":   update.CreatedAt,
 		})
@@ -277,9 +305,10 @@ func (h *HRProblemHandler) GetAllProblems(c *gin.Context) {
 			"is_anonymous": problem.IsAnonymous,
 			"reported_at":  problem.ReportedAt,
 			"resolved_at":  problem.ResolvedAt,
+			"sla_breached": problem.SLAFirstResponseBreached || problem.SLAResolutionBreached,
 		}
 
-		if !problem.IsAnonymous {
+		if !problem.IsAnonymous && problem.Reporter != nil {
 			problemData["reporter"] = gin.H{
 				"id":       problem.Reporter.ID,
 				"username": problem.Reporter.Username,
@@ -341,9 +370,10 @@ func (h *HRProblemHandler) GetAssignedProblems(c *gin.Context) {
 			"is_anonymous": problem.IsAnonymous,
 			"reported_at":  problem.ReportedAt,
 			"resolved_at":  problem.ResolvedAt,
+			"sla_breached": problem.SLAFirstResponseBreached || problem.SLAResolutionBreached,
 		}
 
-		if !problem.IsAnonymous {
+		if !problem.IsAnonymous && problem.Reporter != nil {
 			problemData["reporter"] = gin.H{
 				"id":       problem.Reporter.ID,
 				"username": problem.Reporter.Username,
@@ -490,7 +520,8 @@ func (h *HRProblemHandler) SetResolution(c *gin.Context) {
 	}
 
 	var request struct {
-		Resolution string `json:"resolution" binding:"required"`
+		Resolution            string `json:"resolution" binding:"required"`
+		PublishAsPolicyAnswer bool   `json:"publish_as_policy_answer"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -500,7 +531,7 @@ func (h *HRProblemHandler) SetResolution(c *gin.Context) {
 
 	userID, _ := c.Get("userID")
 
-	err = h.HRProblemService.SetResolution(uint(problemID), request.Resolution, userID.(uint))
+	err = h.HRProblemService.SetResolution(uint(problemID), request.Resolution, request.PublishAsPolicyAnswer, userID.(uint))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -519,3 +550,832 @@ func (h *HRProblemHandler) GetStatistics(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"statistics": stats})
 }
+
+// GetTrendReport returns the monthly trend report (HR/Admin only). ?from=YYYY-MM&to=YYYY-MM
+// selects the months (default: the last 12), ?format=json|csv|pdf the output.
+func (h *HRProblemHandler) GetTrendReport(c *gin.Context) {
+	now := time.Now()
+	from := now.AddDate(0, -11, 0)
+	to := now
+
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = time.Parse("2006-01", value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse("2006-01", value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM"})
+			return
+		}
+	}
+
+	report, err := h.HRProblemService.GetTrendReport(from, to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("hr-trends-%s-to-%s", report.From.Format("2006-01"), report.To.Format("2006-01"))
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{"report": report})
+	case "csv":
+		data, err := h.HRProblemService.ExportTrendReportCSV(report)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export report"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+	case "pdf":
+		data, err := h.HRProblemService.ExportTrendReportPDF(report)
+		if errors.Is(err, services.ErrPDFUnsupportedText) {
+			// Names the PDF fonts cannot show (e.g. Arabic) would come out as "?"
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": "The PDF cannot show some department or category names (e.g. Arabic). Use format=csv, which is UTF-8.",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export report"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, filename))
+		c.Data(http.StatusOK, "application/pdf", data)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format. Use 'json', 'csv' or 'pdf'"})
+	}
+}
+
+// CreateAnonymousProblem accepts a report without authentication. Nothing about the
+// submitter is stored; the response carries the case code and passphrase for follow-up.
+func (h *HRProblemHandler) CreateAnonymousProblem(c *gin.Context) {
+	var request struct {
+		Title           string                 `json:"title" binding:"required"`
+		Description     string                 `json:"description" binding:"required"`
+		Category        models.ProblemCategory `json:"category" binding:"required"`
+		Priority        models.ProblemPriority `json:"priority"`
+		WitnessInfo     string                 `json:"witness_info"`
+		Location        string                 `json:"location"`
+		IncidentDate    *time.Time             `json:"incident_date"`
+		PreviousReports bool                   `json:"previous_reports"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.Priority == "" {
+		request.Priority = models.ProblemPriorityMedium
+	}
+
+	h.createAnonymousProblem(c, request.Title, request.Description, request.Category, request.Priority,
+		request.WitnessInfo, request.Location, request.IncidentDate, request.PreviousReports)
+}
+
+// GetAnonymousCase returns an anonymous case to the holder of its case code and passphrase
+func (h *HRProblemHandler) GetAnonymousCase(c *gin.Context) {
+	var request struct {
+		CaseCode   string `json:"case_code" binding:"required"`
+		Passphrase string `json:"passphrase" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	problem, err := h.HRProblemService.GetAnonymousCase(request.CaseCode, request.Passphrase)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"case_code":        problem.CaseCode,
+		"title":            problem.Title,
+		"description":      problem.Description,
+		"category":         problem.Category,
+		"priority":         problem.Priority,
+		"status":           problem.Status,
+		"is_urgent":        problem.IsUrgent,
+		"reported_at":      problem.ReportedAt,
+		"resolved_at":      problem.ResolvedAt,
+		"witness_info":     problem.WitnessInfo,
+		"location":         problem.Location,
+		"incident_date":    problem.IncidentDate,
+		"previous_reports": problem.PreviousReports,
+		"resolution":       problem.Resolution,
+		"is_assigned":      problem.AssignedHRID != nil,
+	}
+
+	attachments, commentAttachments := groupAttachments(problem.Attachments)
+	response["attachments"] = attachments
+
+	var comments []gin.H
+	for _, comment := range problem.Comments {
+		comments = append(comments, gin.H{
+			"id":          comment.ID,
+			"comment":     comment.Comment,
+			"user":        hrCommentAuthor(comment),
+			"is_mine":     comment.IsAnonymousReporter,
+			"attachments": commentAttachments[comment.ID],
+			"created_at":  comment.CreatedAt,
+		})
+	}
+	response["comments"] = comments
+
+	// Status history only; who made each change stays internal to HR
+	var updates []gin.H
+	for _, update := range problem.Updates {
+		if update.OldStatus == "" && update.NewStatus == "" {
+			continue
+		}
+		updates = append(updates, gin.H{
+			"old_status": update.OldStatus,
+			"new_status": update.NewStatus,
+			"created_at": update.CreatedAt,
+		})
+	}
+	response["updates"] = updates
+
+	c.JSON(http.StatusOK, gin.H{"problem": response})
+}
+
+// AddAnonymousComment lets an anonymous reporter reply on their case
+func (h *HRProblemHandler) AddAnonymousComment(c *gin.Context) {
+	var request struct {
+		CaseCode   string `json:"case_code" binding:"required"`
+		Passphrase string `json:"passphrase" binding:"required"`
+		Comment    string `json:"comment" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.HRProblemService.AddAnonymousComment(request.CaseCode, request.Passphrase, request.Comment)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "invalid case code or passphrase" {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment added successfully"})
+}
+
+// createAnonymousProblem files an anonymous report and returns the follow-up credentials
+func (h *HRProblemHandler) createAnonymousProblem(
+	c *gin.Context,
+	title, description string,
+	category models.ProblemCategory,
+	priority models.ProblemPriority,
+	witnessInfo, location string,
+	incidentDate *time.Time,
+	previousReports bool,
+) {
+	problem, passphrase, err := h.HRProblemService.CreateAnonymousProblem(
+		title, description, category, priority, witnessInfo, location, incidentDate, previousReports,
+	)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Problem reported anonymously. Save the case code and passphrase - they cannot be recovered.",
+		"problem": gin.H{
+			"title":       problem.Title,
+			"category":    problem.Category,
+			"priority":    problem.Priority,
+			"status":      problem.Status,
+			"reported_at": problem.ReportedAt,
+			"is_urgent":   problem.IsUrgent,
+		},
+		"case_code":  problem.CaseCode,
+		"passphrase": passphrase,
+	})
+}
+
+// hrCommentAuthor returns the display name for a comment author
+func hrCommentAuthor(comment models.HRProblemComment) string {
+	if comment.IsAnonymousReporter || comment.User == nil {
+		return "Anonymous reporter"
+	}
+	return comment.User.Username
+}
+
+// hrUpdateAuthor returns the display name for the user behind a status update
+func hrUpdateAuthor(update models.HRProblemUpdate) string {
+	if update.UpdatedByUser == nil {
+		return "System"
+	}
+	return update.UpdatedByUser.Username
+}
+
+// GetSLAPolicies returns the SLA targets for every priority (HR/Admin only)
+func (h *HRProblemHandler) GetSLAPolicies(c *gin.Context) {
+	policies, err := h.HRProblemService.GetSLAPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch SLA policies"})
+		return
+	}
+
+	var policyList []gin.H
+	for _, policy := range policies {
+		policyData := gin.H{
+			"priority":             policy.Priority,
+			"first_response_hours": policy.FirstResponseHours,
+			"resolution_hours":     policy.ResolutionHours,
+			"warning_threshold":    policy.WarningThreshold,
+			"is_default":           policy.ID == 0,
+		}
+		if policy.UpdatedBy != nil {
+			policyData["updated_by"] = policy.UpdatedBy.Username
+			policyData["updated_at"] = policy.UpdatedAt
+		}
+		policyList = append(policyList, policyData)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"policies": policyList,
+		"unit":     "working_hours",
+	})
+}
+
+// UpdateSLAPolicy changes the SLA targets for a priority (Admin only)
+func (h *HRProblemHandler) UpdateSLAPolicy(c *gin.Context) {
+	var request struct {
+		FirstResponseHours float64 `json:"first_response_hours" binding:"required"`
+		ResolutionHours    float64 `json:"resolution_hours" binding:"required"`
+		WarningThreshold   float64 `json:"warning_threshold"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	policy, err := h.HRProblemService.UpdateSLAPolicy(
+		models.ProblemPriority(c.Param("priority")),
+		request.FirstResponseHours,
+		request.ResolutionHours,
+		request.WarningThreshold,
+		userID.(uint),
+	)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "SLA policy updated successfully",
+		"policy": gin.H{
+			"priority":             policy.Priority,
+			"first_response_hours": policy.FirstResponseHours,
+			"resolution_hours":     policy.ResolutionHours,
+			"warning_threshold":    policy.WarningThreshold,
+		},
+	})
+}
+
+// UploadAttachments adds evidence files to a problem, or to one of its comments when
+// comment_id is given. Files are sent as multipart form data in the "files" field.
+func (h *HRProblemHandler) UploadAttachments(c *gin.Context) {
+	problemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid problem ID"})
+		return
+	}
+
+	form, commentID, err := parseAttachmentForm(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	files, err := readAttachmentUploads(form)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	userRole, _ := c.Get("userRole")
+
+	attachments, err := h.HRProblemService.AddAttachments(uint(problemID), commentID, userID.(uint), userRole.(models.Role), files)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var attachmentList []gin.H
+	for _, attachment := range attachments {
+		attachmentList = append(attachmentList, attachmentResponse(attachment))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Evidence uploaded successfully",
+		"attachments": attachmentList,
+	})
+}
+
+// DownloadAttachment returns a decrypted evidence file to the reporter or HR
+func (h *HRProblemHandler) DownloadAttachment(c *gin.Context) {
+	problemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid problem ID"})
+		return
+	}
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	userRole, _ := c.Get("userRole")
+
+	attachment, content, err := h.HRProblemService.DownloadAttachment(uint(problemID), uint(attachmentID), userID.(uint), userRole.(models.Role))
+	if err != nil {
+		if err.Error() == "attachment not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sendAttachment(c, attachment, content)
+}
+
+// UploadAnonymousAttachments adds evidence files to an anonymous case. The multipart form
+// carries case_code and passphrase alongside the files.
+func (h *HRProblemHandler) UploadAnonymousAttachments(c *gin.Context) {
+	form, commentID, err := parseAttachmentForm(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	files, err := readAttachmentUploads(form)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attachments, err := h.HRProblemService.AddAnonymousAttachments(
+		c.PostForm("case_code"), c.PostForm("passphrase"), commentID, files,
+	)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err.Error() == "invalid case code or passphrase" {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	var attachmentList []gin.H
+	for _, attachment := range attachments {
+		attachmentList = append(attachmentList, attachmentResponse(attachment))
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Evidence uploaded successfully",
+		"attachments": attachmentList,
+	})
+}
+
+// DownloadAnonymousAttachment returns a decrypted evidence file to the holder of the case credentials
+func (h *HRProblemHandler) DownloadAnonymousAttachment(c *gin.Context) {
+	attachmentID, err := strconv.ParseUint(c.Param("attachmentId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	var request struct {
+		CaseCode   string `json:"case_code" binding:"required"`
+		Passphrase string `json:"passphrase" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attachment, content, err := h.HRProblemService.DownloadAnonymousAttachment(request.CaseCode, request.Passphrase, uint(attachmentID))
+	if err != nil {
+		switch err.Error() {
+		case "invalid case code or passphrase":
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case "attachment not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	sendAttachment(c, attachment, content)
+}
+
+// parseAttachmentForm reads a size-limited multipart form and its optional comment_id
+func parseAttachmentForm(c *gin.Context) (*multipart.Form, *uint, error) {
+	maxBody := int64(services.MaxHRAttachmentSize*services.MaxHRAttachmentsPerUpload) + 1<<20
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBody)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, nil, errors.New("invalid or too large multipart upload")
+	}
+
+	var commentID *uint
+	if value := c.PostForm("comment_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, nil, errors.New("invalid comment ID")
+		}
+		parsed := uint(id)
+		commentID = &parsed
+	}
+
+	return form, commentID, nil
+}
+
+// readAttachmentUploads loads the files of a multipart form into memory
+func readAttachmentUploads(form *multipart.Form) ([]services.HRAttachmentUpload, error) {
+	var uploads []services.HRAttachmentUpload
+	for _, fileHeader := range form.File["files"] {
+		if fileHeader.Size > services.MaxHRAttachmentSize {
+			return nil, fmt.Errorf("%s exceeds the %d MB size limit", fileHeader.Filename, services.MaxHRAttachmentSize>>20)
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(io.LimitReader(file, services.MaxHRAttachmentSize+1))
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		uploads = append(uploads, services.HRAttachmentUpload{
+			FileName:    fileHeader.Filename,
+			ContentType: fileHeader.Header.Get("Content-Type"),
+			Content:     content,
+		})
+	}
+	return uploads, nil
+}
+
+// sendAttachment writes a decrypted evidence file as a download
+func sendAttachment(c *gin.Context, attachment *models.HRProblemAttachment, content []byte) {
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.FileName))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, attachment.ContentType, content)
+}
+
+// groupAttachments splits attachments into problem-level ones and ones keyed by comment ID
+func groupAttachments(attachments []models.HRProblemAttachment) ([]gin.H, map[uint][]gin.H) {
+	var problemAttachments []gin.H
+	commentAttachments := make(map[uint][]gin.H)
+	for _, attachment := range attachments {
+		if attachment.CommentID != nil {
+			commentAttachments[*attachment.CommentID] = append(commentAttachments[*attachment.CommentID], attachmentResponse(attachment))
+		} else {
+			problemAttachments = append(problemAttachments, attachmentResponse(attachment))
+		}
+	}
+	return problemAttachments, commentAttachments
+}
+
+// attachmentResponse formats an evidence attachment for API responses
+func attachmentResponse(attachment models.HRProblemAttachment) gin.H {
+	var uploadedBy string
+	switch {
+	case attachment.IsAnonymousReporter:
+		uploadedBy = "Anonymous reporter"
+	case attachment.UploadedBy != nil:
+		uploadedBy = attachment.UploadedBy.Username
+	default:
+		uploadedBy = "Deleted user"
+	}
+
+	return gin.H{
+		"id":           attachment.ID,
+		"file_name":    attachment.FileName,
+		"content_type": attachment.ContentType,
+		"size":         attachment.Size,
+		"comment_id":   attachment.CommentID,
+		"scan_status":  attachment.ScanStatus,
+		"uploaded_by":  uploadedBy,
+		"created_at":   attachment.CreatedAt,
+	}
+}
+
+// ScheduleFollowUp schedules a follow-up on a problem (HR/Admin only)
+func (h *HRProblemHandler) ScheduleFollowUp(c *gin.Context) {
+	problemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid problem ID"})
+		return
+	}
+
+	var request struct {
+		DueDate string `json:"due_date" binding:"required"` // "2006-01-02" (start of the working day) or RFC 3339
+		Note    string `json:"note"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dueDate, err := parseFollowUpDate(request.DueDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	followUp, err := h.HRProblemService.ScheduleFollowUp(uint(problemID), userID.(uint), dueDate, request.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   "Follow-up scheduled successfully",
+		"follow_up": followUpResponse(*followUp),
+	})
+}
+
+// GetDueFollowUps returns the follow-ups due queue (HR/Admin only). ?days=N includes follow-ups
+// due in the next N days, ?mine=true limits it to problems assigned to the current user.
+func (h *HRProblemHandler) GetDueFollowUps(c *gin.Context) {
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "0"))
+
+	var hrUserID *uint
+	if c.Query("mine") == "true" {
+		userID, _ := c.Get("userID")
+		id := userID.(uint)
+		hrUserID = &id
+	}
+
+	followUps, err := h.HRProblemService.GetDueFollowUps(days, hrUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch follow-ups"})
+		return
+	}
+
+	now := time.Now()
+	var followUpList []gin.H
+	for _, followUp := range followUps {
+		followUpData := gin.H{
+			"id":           followUp.ID,
+			"due_date":     followUp.DueDate,
+			"is_overdue":   followUp.DueDate.Before(now),
+			"scheduled_by": followUp.ScheduledBy.Username,
+			"problem": gin.H{
+				"id":       followUp.Problem.ID,
+				"title":    followUp.Problem.Title,
+				"category": followUp.Problem.Category,
+				"priority": followUp.Problem.Priority,
+				"status":   followUp.Problem.Status,
+			},
+		}
+		if followUp.Problem.AssignedHR != nil {
+			followUpData["assigned_hr"] = followUp.Problem.AssignedHR.Username
+		}
+		followUpList = append(followUpList, followUpData)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"follow_ups": followUpList,
+		"days":       days,
+	})
+}
+
+// CompleteFollowUp records the outcome of a follow-up (HR/Admin only). An outcome of
+// "persists" reopens a resolved problem.
+func (h *HRProblemHandler) CompleteFollowUp(c *gin.Context) {
+	problemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid problem ID"})
+		return
+	}
+	followUpID, err := strconv.ParseUint(c.Param("followUpId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid follow-up ID"})
+		return
+	}
+
+	var request struct {
+		Outcome models.FollowUpOutcome `json:"outcome" binding:"required"`
+		Note    string                 `json:"note"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	reopened, err := h.HRProblemService.CompleteFollowUp(uint(problemID), uint(followUpID), userID.(uint), request.Outcome, request.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "Follow-up completed successfully"
+	if reopened {
+		message = "Follow-up completed and problem reopened"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  message,
+		"reopened": reopened,
+	})
+}
+
+// CancelFollowUp cancels a pending follow-up (HR/Admin only)
+func (h *HRProblemHandler) CancelFollowUp(c *gin.Context) {
+	problemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid problem ID"})
+		return
+	}
+	followUpID, err := strconv.ParseUint(c.Param("followUpId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid follow-up ID"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	if err := h.HRProblemService.CancelFollowUp(uint(problemID), uint(followUpID), userID.(uint)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Follow-up cancelled successfully"})
+}
+
+// parseFollowUpDate accepts a plain date, meaning the start of that working day, or an RFC 3339 timestamp
+func parseFollowUpDate(value string) (time.Time, error) {
+	if dueDate, err := time.Parse(time.RFC3339, value); err == nil {
+		return dueDate, nil
+	}
+
+	schedule := config.GetDefaultWorkSchedule()
+	dueDate, err := time.ParseInLocation("2006-01-02", value, schedule.Location())
+	if err != nil {
+		return time.Time{}, errors.New("due_date must be YYYY-MM-DD or an RFC 3339 timestamp")
+	}
+	return dueDate.Add(9 * time.Hour), nil
+}
+
+// followUpResponse formats a follow-up for API responses
+func followUpResponse(followUp models.HRProblemFollowUp) gin.H {
+	response := gin.H{
+		"id":               followUp.ID,
+		"due_date":         followUp.DueDate,
+		"note":             followUp.Note,
+		"status":           followUp.Status,
+		"reminder_sent_at": followUp.ReminderSentAt,
+		"outcome":          followUp.Outcome,
+		"outcome_note":     followUp.OutcomeNote,
+		"completed_at":     followUp.CompletedAt,
+		"created_at":       followUp.CreatedAt,
+	}
+	if followUp.ScheduledBy.ID != 0 {
+		response["scheduled_by"] = followUp.ScheduledBy.Username
+	}
+	if followUp.CompletedBy != nil {
+		response["completed_by"] = followUp.CompletedBy.Username
+	}
+	return response
+}
+
+// GetAssignmentRules returns the auto-assignment rules in evaluation order (HR/Admin only)
+func (h *HRProblemHandler) GetAssignmentRules(c *gin.Context) {
+	rules, err := h.HRProblemService.GetAssignmentRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assignment rules"})
+		return
+	}
+
+	var ruleList []gin.H
+	for _, rule := range rules {
+		ruleList = append(ruleList, assignmentRuleResponse(rule))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": ruleList})
+}
+
+// CreateAssignmentRule adds an auto-assignment rule (Admin only)
+func (h *HRProblemHandler) CreateAssignmentRule(c *gin.Context) {
+	var request services.HRAssignmentRuleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	rule, err := h.HRProblemService.CreateAssignmentRule(request, userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Assignment rule created successfully",
+		"rule":    assignmentRuleResponse(*rule),
+	})
+}
+
+// UpdateAssignmentRule replaces an auto-assignment rule (Admin only)
+func (h *HRProblemHandler) UpdateAssignmentRule(c *gin.Context) {
+	ruleID, err := strconv.ParseUint(c.Param("ruleId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	var request services.HRAssignmentRuleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	rule, err := h.HRProblemService.UpdateAssignmentRule(uint(ruleID), request, userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Assignment rule updated successfully",
+		"rule":    assignmentRuleResponse(*rule),
+	})
+}
+
+// DeleteAssignmentRule removes an auto-assignment rule (Admin only)
+func (h *HRProblemHandler) DeleteAssignmentRule(c *gin.Context) {
+	ruleID, err := strconv.ParseUint(c.Param("ruleId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+
+	if err := h.HRProblemService.DeleteAssignmentRule(uint(ruleID), userID.(uint)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Assignment rule deleted successfully"})
+}
+
+// assignmentRuleResponse formats an assignment rule for API responses
+func assignmentRuleResponse(rule models.HRAssignmentRule) gin.H {
+	assignees := []gin.H{}
+	for _, user := range rule.Assignees {
+		assignees = append(assignees, gin.H{
+			"id":       user.ID,
+			"username": user.Username,
+		})
+	}
+
+	return gin.H{
+		"id":                  rule.ID,
+		"name":                rule.Name,
+		"sort_order":          rule.SortOrder,
+		"is_active":           rule.IsActive,
+		"category":            rule.Category,
+		"reporter_department": rule.ReporterDepartment,
+		"strategy":            rule.Strategy,
+		"max_open_cases":      rule.MaxOpenCases,
+		"assignees":           assignees,
+	}
+}
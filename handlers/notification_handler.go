@@ -202,6 +202,9 @@ func (h *NotificationHandler) GetNotificationPreferences(c *gin.Context) {
 			EmailNotifications: false,
 			PushNotifications:  true,
 			InAppNotifications: true,
+			DailyStandup:       true,
+			WeeklyDigest:       true,
+			DigestDelivery:     models.AIDigestDeliveryNotification,
 		}
 		h.notificationService.GetDB().Create(&preference)
 	}
@@ -220,17 +223,21 @@ func (h *NotificationHandler) UpdateNotificationPreferences(c *gin.Context) {
 	}
 
 	var request struct {
-		TaskAssigned       *bool `json:"task_assigned"`
-		TaskUpdated        *bool `json:"task_updated"`
-		TaskCompleted      *bool `json:"task_completed"`
-		TaskCommented      *bool `json:"task_commented"`
-		TaskDueSoon        *bool `json:"task_due_soon"`
-		ProjectCreated     *bool `json:"project_created"`
-		UserJoined         *bool `json:"user_joined"`
-		FileUploaded       *bool `json:"file_uploaded"`
-		EmailNotifications *bool `json:"email_notifications"`
-		PushNotifications  *bool `json:"push_notifications"`
-		InAppNotifications *bool `json:"in_app_notifications"`
+		TaskAssigned       *bool   `json:"task_assigned"`
+		TaskUpdated        *bool   `json:"task_updated"`
+		TaskCompleted      *bool   `json:"task_completed"`
+		TaskCommented      *bool   `json:"task_commented"`
+		TaskDueSoon        *bool   `json:"task_due_soon"`
+		ProjectCreated     *bool   `json:"project_created"`
+		UserJoined         *bool   `json:"user_joined"`
+		FileUploaded       *bool   `json:"file_uploaded"`
+		EmailNotifications *bool   `json:"email_notifications"`
+		PushNotifications  *bool   `json:"push_notifications"`
+		InAppNotifications *bool   `json:"in_app_notifications"`
+		DailyStandup       *bool   `json:"daily_standup"`
+		WeeklyDigest       *bool   `json:"weekly_digest"`
+		DigestDelivery     *string `json:"digest_delivery"`
+		Language           *string `json:"language"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -238,6 +245,18 @@ func (h *NotificationHandler) UpdateNotificationPreferences(c *gin.Context) {
 		return
 	}
 
+	if request.DigestDelivery != nil {
+		delivery := models.AIDigestDelivery(*request.DigestDelivery)
+		if delivery != models.AIDigestDeliveryNotification && delivery != models.AIDigestDeliveryChat {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "digest_delivery must be 'notification' or 'chat'"})
+			return
+		}
+	}
+	if request.Language != nil && *request.Language != "" && *request.Language != "en" && *request.Language != "ar" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "language must be 'en', 'ar' or empty to detect it"})
+		return
+	}
+
 	// Get existing preferences or create new ones
 	var preference models.UserNotificationPreference
 	err := h.notificationService.GetDB().Where("user_id = ?", userID).First(&preference).Error
@@ -281,6 +300,18 @@ func (h *NotificationHandler) UpdateNotificationPreferences(c *gin.Context) {
 	if request.InAppNotifications != nil {
 		preference.InAppNotifications = *request.InAppNotifications
 	}
+	if request.DailyStandup != nil {
+		preference.DailyStandup = *request.DailyStandup
+	}
+	if request.WeeklyDigest != nil {
+		preference.WeeklyDigest = *request.WeeklyDigest
+	}
+	if request.DigestDelivery != nil {
+		preference.DigestDelivery = models.AIDigestDelivery(*request.DigestDelivery)
+	}
+	if request.Language != nil {
+		preference.Language = *request.Language
+	}
 
 	// Save preferences
 	if preference.ID == 0 {
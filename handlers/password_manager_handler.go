@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"project-x/models"
 	"project-x/services"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -14,8 +18,8 @@ type PasswordManagerHandler struct {
 	PasswordManagerService *services.PasswordManagerService
 }
 
-func NewPasswordManagerHandler(db *gorm.DB) (*PasswordManagerHandler, error) {
-	service, err := services.NewPasswordManagerService(db)
+func NewPasswordManagerHandler(db *gorm.DB, notificationService *services.NotificationService) (*PasswordManagerHandler, error) {
+	service, err := services.NewPasswordManagerService(db, notificationService)
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +69,7 @@ func (h *PasswordManagerHandler) CreateCredential(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"message":    "Credential created successfully",
 		"credential": credential,
+		"strength":   h.PasswordManagerService.Policy.EvaluatePasswordStrength(req.Password, req.Platform, req.Email, req.Username),
 	})
 }
 
@@ -106,11 +111,12 @@ func (h *PasswordManagerHandler) GetAllCredentials(c *gin.Context) {
 		var sharedWith []map[string]interface{}
 		for _, share := range cred.SharedWith {
 			sharedWith = append(sharedWith, map[string]interface{}{
-				"user_id":   share.UserID,
-				"username":  share.User.Username,
-				"can_view":  share.CanView,
-				"can_copy":  share.CanCopy,
-				"shared_at": share.SharedAt,
+				"user_id":    share.UserID,
+				"username":   share.User.Username,
+				"can_view":   share.CanView,
+				"can_copy":   share.CanCopy,
+				"shared_at":  share.SharedAt,
+				"expires_at": share.ExpiresAt,
 			})
 		}
 
@@ -267,10 +273,11 @@ func (h *PasswordManagerHandler) ShareCredential(c *gin.Context) {
 	}
 
 	var req struct {
-		UserIDs []uint `json:"user_ids" binding:"required"`
-		CanView bool   `json:"can_view" binding:"required"`
-		CanCopy bool   `json:"can_copy" binding:"required"`
-		Notes   string `json:"notes"`
+		UserIDs   []uint     `json:"user_ids" binding:"required"`
+		CanView   bool       `json:"can_view" binding:"required"`
+		CanCopy   bool       `json:"can_copy" binding:"required"`
+		Notes     string     `json:"notes"`
+		ExpiresAt *time.Time `json:"expires_at"` // Optional: share is revoked automatically after this time
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -285,6 +292,7 @@ func (h *PasswordManagerHandler) ShareCredential(c *gin.Context) {
 		req.CanView,
 		req.CanCopy,
 		req.Notes,
+		req.ExpiresAt,
 	)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -434,3 +442,857 @@ func (h *PasswordManagerHandler) GetAccessLogs(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"logs": response})
 }
+
+// GetRequestableCredentials lists active credentials a user can request access to (no secrets)
+func (h *PasswordManagerHandler) GetRequestableCredentials(c *gin.Context) {
+	credentials, err := h.PasswordManagerService.GetRequestableCredentials()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var response []gin.H
+	for _, cred := range credentials {
+		response = append(response, gin.H{
+			"id":       cred.ID,
+			"platform": cred.Platform,
+			"url":      cred.URL,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credentials": response})
+}
+
+// RequestAccess asks admins for temporary access to a credential
+func (h *PasswordManagerHandler) RequestAccess(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	credentialID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid credential ID"})
+		return
+	}
+
+	var req struct {
+		Hours  int    `json:"hours" binding:"required"`
+		Reason string `json:"reason" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := h.PasswordManagerService.RequestAccess(uint(credentialID), currentUserID.(uint), req.Hours, req.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Access request submitted",
+		"request": accessRequestResponse(request),
+	})
+}
+
+// GetMyAccessRequests returns the current user's access requests
+func (h *PasswordManagerHandler) GetMyAccessRequests(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	requests, err := h.PasswordManagerService.GetMyAccessRequests(currentUserID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var response []gin.H
+	for i := range requests {
+		response = append(response, accessRequestResponse(&requests[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requests": response})
+}
+
+// GetAccessRequests returns access requests filtered by status (Admin only)
+func (h *PasswordManagerHandler) GetAccessRequests(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	requests, err := h.PasswordManagerService.GetAccessRequests(currentUserID.(uint), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	var response []gin.H
+	for i := range requests {
+		response = append(response, accessRequestResponse(&requests[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requests": response})
+}
+
+// ApproveAccessRequest grants time-limited access for a pending request (Admin only)
+func (h *PasswordManagerHandler) ApproveAccessRequest(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request ID"})
+		return
+	}
+
+	var req struct {
+		Hours   int    `json:"hours"`    // Optional: defaults to the requested duration
+		CanCopy *bool  `json:"can_copy"` // Optional: defaults to true
+		Note    string `json:"note"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	canCopy := true
+	if req.CanCopy != nil {
+		canCopy = *req.CanCopy
+	}
+
+	request, err := h.PasswordManagerService.ApproveAccessRequest(uint(requestID), currentUserID.(uint), req.Hours, canCopy, req.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Access request approved",
+		"request": accessRequestResponse(request),
+	})
+}
+
+// DenyAccessRequest rejects a pending access request (Admin only)
+func (h *PasswordManagerHandler) DenyAccessRequest(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request ID"})
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	request, err := h.PasswordManagerService.DenyAccessRequest(uint(requestID), currentUserID.(uint), req.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Access request denied",
+		"request": accessRequestResponse(request),
+	})
+}
+
+// accessRequestResponse formats an access request for API responses
+func accessRequestResponse(request *models.CredentialAccessRequest) gin.H {
+	response := gin.H{
+		"id":              request.ID,
+		"credential_id":   request.CredentialID,
+		"platform":        request.Credential.Platform,
+		"requester_id":    request.RequesterID,
+		"requester":       request.Requester.Username,
+		"reason":          request.Reason,
+		"requested_hours": request.RequestedHours,
+		"status":          request.Status,
+		"review_note":     request.ReviewNote,
+		"reviewed_at":     request.ReviewedAt,
+		"expires_at":      request.ExpiresAt,
+		"created_at":      request.CreatedAt,
+	}
+	if request.ReviewedBy != nil {
+		response["reviewed_by"] = request.ReviewedBy.Username
+	}
+	return response
+}
+
+// GeneratePassword returns a newly generated password with its strength estimate (Admin only)
+func (h *PasswordManagerHandler) GeneratePassword(c *gin.Context) {
+	opts := services.DefaultPasswordGeneratorOptions()
+	if c.Request.ContentLength > 0 {
+		// Only the options present in the body override the defaults
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	password, err := h.PasswordManagerService.Policy.GeneratePassword(opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"password": password,
+		"strength": h.PasswordManagerService.Policy.EvaluatePasswordStrength(password),
+	})
+}
+
+// CheckPasswordStrength scores a candidate password and screens it against the breach list (Admin only)
+func (h *PasswordManagerHandler) CheckPasswordStrength(c *gin.Context) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+		Platform string `json:"platform"`
+		Email    string `json:"email"`
+		Username string `json:"username"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy := h.PasswordManagerService.Policy
+	breached, breachCount := policy.CheckBreached(req.Password)
+
+	c.JSON(http.StatusOK, gin.H{
+		"strength":     policy.EvaluatePasswordStrength(req.Password, req.Platform, req.Email, req.Username),
+		"breached":     breached,
+		"breach_count": breachCount,
+	})
+}
+
+// GetBreachedRange returns breached hash suffixes for a SHA-1 prefix (k-anonymity lookup)
+func (h *PasswordManagerHandler) GetBreachedRange(c *gin.Context) {
+	suffixes, err := h.PasswordManagerService.Policy.LookupBreachedRange(c.Param("prefix"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"prefix":   c.Param("prefix"),
+		"suffixes": suffixes,
+	})
+}
+
+// GetPasswordHealthReport reports weak, breached, reused and stale credentials (Admin only)
+func (h *PasswordManagerHandler) GetPasswordHealthReport(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	staleDays, _ := strconv.Atoi(c.DefaultQuery("stale_days", strconv.Itoa(services.DefaultStalePasswordDays)))
+
+	report, err := h.PasswordManagerService.GetPasswordHealthReport(currentUserID.(uint), staleDays)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// SetCredentialTOTP stores a TOTP seed on a credential (Admin only)
+func (h *PasswordManagerHandler) SetCredentialTOTP(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	credentialID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid credential ID"})
+		return
+	}
+
+	var req struct {
+		Secret     string `json:"secret"`      // Base32 seed shown next to the QR code
+		OTPAuthURI string `json:"otpauth_uri"` // Alternatively, the otpauth:// URI from the QR code
+		Digits     int    `json:"digits"`
+		Period     int    `json:"period"`
+		Algorithm  string `json:"algorithm"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	config := services.TOTPConfig{
+		Secret:    req.Secret,
+		Digits:    req.Digits,
+		Period:    req.Period,
+		Algorithm: req.Algorithm,
+	}
+	if req.OTPAuthURI != "" {
+		parsed, err := services.ParseOTPAuthURI(req.OTPAuthURI)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		config = *parsed
+	}
+
+	err = h.PasswordManagerService.SetCredentialTOTP(uint(credentialID), currentUserID.(uint), config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "TOTP secret saved successfully"})
+}
+
+// RemoveCredentialTOTP removes the TOTP seed from a credential (Admin only)
+func (h *PasswordManagerHandler) RemoveCredentialTOTP(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	credentialID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid credential ID"})
+		return
+	}
+
+	err = h.PasswordManagerService.RemoveCredentialTOTP(uint(credentialID), currentUserID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "TOTP secret removed successfully"})
+}
+
+// GetTOTPCode returns the current 2FA code for a credential (Admin or shared user with view permission)
+func (h *PasswordManagerHandler) GetTOTPCode(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	credentialID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid credential ID"})
+		return
+	}
+
+	code, err := h.PasswordManagerService.GetTOTPCode(
+		uint(credentialID),
+		currentUserID.(uint),
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+	)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"totp": code})
+}
+
+// ImportCredentials imports credentials from a Bitwarden/KeePass export or an encrypted archive (Admin only)
+func (h *PasswordManagerHandler) ImportCredentials(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req struct {
+		Format      string `json:"format" binding:"required"`  // bitwarden_csv, bitwarden_json, keepass_csv, projectx_encrypted
+		Content     string `json:"content" binding:"required"` // Raw file contents
+		Passphrase  string `json:"passphrase"`                 // Required for projectx_encrypted
+		DryRun      bool   `json:"dry_run"`
+		OnDuplicate string `json:"on_duplicate"` // skip (default) or update
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.PasswordManagerService.ImportCredentials(currentUserID.(uint), services.ImportOptions{
+		Format:      req.Format,
+		Content:     req.Content,
+		Passphrase:  req.Passphrase,
+		DryRun:      req.DryRun,
+		OnDuplicate: req.OnDuplicate,
+		IPAddress:   c.ClientIP(),
+		UserAgent:   c.GetHeader("User-Agent"),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "Import completed"
+	if result.DryRun {
+		message = "Dry run completed - no changes were made"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"result":  result,
+	})
+}
+
+// ExportCredentials downloads a passphrase-protected archive of credentials (Admin only)
+func (h *PasswordManagerHandler) ExportCredentials(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req struct {
+		Passphrase    string `json:"passphrase" binding:"required"`
+		CredentialIDs []uint `json:"credential_ids"` // Optional: defaults to all credentials
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	archive, err := h.PasswordManagerService.ExportCredentials(
+		currentUserID.(uint),
+		req.Passphrase,
+		req.CredentialIDs,
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+	)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode archive"})
+		return
+	}
+
+	filename := fmt.Sprintf("credentials-%s.pxvault", archive.CreatedAt.Format("20060102-150405"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/json", body)
+}
+
+// GetBulkOperations returns the import/export audit trail (Admin only)
+func (h *PasswordManagerHandler) GetBulkOperations(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	operations, err := h.PasswordManagerService.GetBulkOperations(currentUserID.(uint))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	var response []gin.H
+	for _, op := range operations {
+		response = append(response, gin.H{
+			"id":            op.ID,
+			"operation":     op.Operation,
+			"format":        op.Format,
+			"dry_run":       op.DryRun,
+			"total_items":   op.TotalItems,
+			"created_count": op.CreatedCount,
+			"updated_count": op.UpdatedCount,
+			"skipped_count": op.SkippedCount,
+			"failed_count":  op.FailedCount,
+			"details":       json.RawMessage(op.Details),
+			"admin":         op.Admin.Username,
+			"ip_address":    op.IPAddress,
+			"performed_at":  op.PerformedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"operations": response})
+}
+
+// CreateVault creates a credential vault (Admin only)
+func (h *PasswordManagerHandler) CreateVault(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	vault, err := h.PasswordManagerService.CreateVault(currentUserID.(uint), req.Name, req.Description)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Vault created successfully",
+		"vault":   vaultResponse(vault),
+	})
+}
+
+// GetVaults returns all vaults (Admin only)
+func (h *PasswordManagerHandler) GetVaults(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	vaults, err := h.PasswordManagerService.GetVaults(currentUserID.(uint))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	var response []gin.H
+	for i := range vaults {
+		response = append(response, vaultResponse(&vaults[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"vaults": response})
+}
+
+// GetVault returns a single vault with its credentials and grants (Admin only)
+func (h *PasswordManagerHandler) GetVault(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	vaultID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid vault ID"})
+		return
+	}
+
+	vault, err := h.PasswordManagerService.GetVault(uint(vaultID), currentUserID.(uint))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"vault": vaultResponse(vault)})
+}
+
+// UpdateVault updates a vault's name or description (Admin only)
+func (h *PasswordManagerHandler) UpdateVault(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	vaultID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid vault ID"})
+		return
+	}
+
+	var req struct {
+		Name        string  `json:"name"`
+		Description *string `json:"description"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+
+	err = h.PasswordManagerService.UpdateVault(uint(vaultID), currentUserID.(uint), updates)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vault updated successfully"})
+}
+
+// DeleteVault deletes a vault without deleting its credentials (Admin only)
+func (h *PasswordManagerHandler) DeleteVault(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	vaultID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid vault ID"})
+		return
+	}
+
+	err = h.PasswordManagerService.DeleteVault(uint(vaultID), currentUserID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vault deleted successfully"})
+}
+
+// AddCredentialsToVault adds credentials to a vault (Admin only)
+func (h *PasswordManagerHandler) AddCredentialsToVault(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	vaultID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid vault ID"})
+		return
+	}
+
+	var req struct {
+		CredentialIDs []uint `json:"credential_ids" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.PasswordManagerService.AddCredentialsToVault(uint(vaultID), currentUserID.(uint), req.CredentialIDs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Credentials added to vault successfully"})
+}
+
+// RemoveCredentialFromVault removes a credential from a vault (Admin only)
+func (h *PasswordManagerHandler) RemoveCredentialFromVault(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	vaultID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid vault ID"})
+		return
+	}
+
+	credentialID, err := strconv.ParseUint(c.Param("credentialId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid credential ID"})
+		return
+	}
+
+	err = h.PasswordManagerService.RemoveCredentialFromVault(uint(vaultID), uint(credentialID), currentUserID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Credential removed from vault successfully"})
+}
+
+// GrantVaultAccess shares a vault with a user, department or role (Admin only)
+func (h *PasswordManagerHandler) GrantVaultAccess(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	vaultID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid vault ID"})
+		return
+	}
+
+	var req services.VaultGrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	grant, err := h.PasswordManagerService.GrantVaultAccess(uint(vaultID), currentUserID.(uint), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Vault shared successfully",
+		"grant":   vaultGrantResponse(grant),
+	})
+}
+
+// RevokeVaultGrant removes a vault grant (Admin only)
+func (h *PasswordManagerHandler) RevokeVaultGrant(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	vaultID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid vault ID"})
+		return
+	}
+
+	grantID, err := strconv.ParseUint(c.Param("grantId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid grant ID"})
+		return
+	}
+
+	err = h.PasswordManagerService.RevokeVaultGrant(uint(vaultID), uint(grantID), currentUserID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Vault access revoked successfully"})
+}
+
+// GetMyVaults returns vaults available to the current user
+func (h *PasswordManagerHandler) GetMyVaults(c *gin.Context) {
+	currentUserID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	vaults, err := h.PasswordManagerService.GetMyVaults(currentUserID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var response []gin.H
+	for _, vault := range vaults {
+		var credentials []gin.H
+		for _, item := range vault.Items {
+			if item.Credential.ID == 0 {
+				continue // Inactive credential
+			}
+			credentials = append(credentials, gin.H{
+				"id":       item.Credential.ID,
+				"platform": item.Credential.Platform,
+				"url":      item.Credential.URL,
+			})
+		}
+		response = append(response, gin.H{
+			"id":          vault.ID,
+			"name":        vault.Name,
+			"description": vault.Description,
+			"credentials": credentials,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"vaults": response})
+}
+
+// vaultResponse formats a vault for admin API responses (no secrets)
+func vaultResponse(vault *models.CredentialVault) gin.H {
+	var credentials []gin.H
+	for _, item := range vault.Items {
+		credentials = append(credentials, gin.H{
+			"id":        item.CredentialID,
+			"platform":  item.Credential.Platform,
+			"url":       item.Credential.URL,
+			"is_active": item.Credential.IsActive,
+		})
+	}
+
+	var grants []gin.H
+	for i := range vault.Grants {
+		grants = append(grants, vaultGrantResponse(&vault.Grants[i]))
+	}
+
+	return gin.H{
+		"id":          vault.ID,
+		"name":        vault.Name,
+		"description": vault.Description,
+		"created_by":  vault.CreatedBy.Username,
+		"created_at":  vault.CreatedAt.Format("2006-01-02 15:04:05"),
+		"credentials": credentials,
+		"grants":      grants,
+	}
+}
+
+// vaultGrantResponse formats a vault grant for API responses
+func vaultGrantResponse(grant *models.CredentialVaultGrant) gin.H {
+	response := gin.H{
+		"id":           grant.ID,
+		"grantee_type": grant.GranteeType,
+		"can_view":     grant.CanView,
+		"can_copy":     grant.CanCopy,
+		"granted_at":   grant.GrantedAt,
+	}
+	switch grant.GranteeType {
+	case models.VaultGranteeUser:
+		response["user_id"] = grant.UserID
+		if grant.User != nil {
+			response["username"] = grant.User.Username
+		}
+	case models.VaultGranteeDepartment:
+		response["department"] = grant.Department
+	case models.VaultGranteeRole:
+		response["role"] = grant.Role
+	}
+	return response
+}
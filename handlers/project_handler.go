@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"project-x/models"
@@ -261,6 +262,105 @@ func (h *ProjectHandler) UpdateProjectStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Project status updated successfully"})
 }
 
+// GetProjectWorkflow returns the task workflow of a project
+func (h *ProjectHandler) GetProjectWorkflow(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	projectService := services.NewProjectService(h.DB)
+	if _, err := projectService.GetProjectWithDetails(uint(projectID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	id := uint(projectID)
+	workflow, err := projectService.GetProjectWorkflow(&id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch project workflow"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"workflow": workflowResponse(workflow)})
+}
+
+// UpdateProjectWorkflow replaces the task workflow of a project (Manager/Admin only)
+func (h *ProjectHandler) UpdateProjectWorkflow(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	var workflowRequest struct {
+		States      []services.WorkflowStateRequest      `json:"states" binding:"required,dive"`
+		Transitions []services.WorkflowTransitionRequest `json:"transitions" binding:"dive"`
+	}
+
+	if err := c.ShouldBindJSON(&workflowRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	projectService := services.NewProjectService(h.DB)
+	workflow, err := projectService.SetProjectWorkflow(uint(projectID), workflowRequest.States, workflowRequest.Transitions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Project workflow updated successfully",
+		"workflow": workflowResponse(workflow),
+	})
+}
+
+// ResetProjectWorkflow removes a project's custom workflow (Manager/Admin only)
+func (h *ProjectHandler) ResetProjectWorkflow(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	projectService := services.NewProjectService(h.DB)
+	if err := projectService.ResetProjectWorkflow(uint(projectID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project workflow reset to the default statuses"})
+}
+
+// workflowResponse formats a workflow's states and transitions
+func workflowResponse(workflow *services.ProjectWorkflow) gin.H {
+	states := make([]gin.H, 0, len(workflow.States))
+	for _, state := range workflow.States {
+		states = append(states, gin.H{
+			"key":      state.Key,
+			"name":     state.Name,
+			"category": state.Category,
+			"is_done":  state.Category == models.TaskStatusCompleted,
+		})
+	}
+
+	transitions := make([]gin.H, 0, len(workflow.Transitions))
+	for _, transition := range workflow.Transitions {
+		transitions = append(transitions, gin.H{
+			"from": transition.FromState,
+			"to":   transition.ToState,
+		})
+	}
+
+	return gin.H{
+		"custom":      workflow.Custom,
+		"states":      states,
+		"transitions": transitions,
+	}
+}
+
 // DeleteProject deletes a project (Admin only)
 func (h *ProjectHandler) DeleteProject(c *gin.Context) {
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -344,10 +444,16 @@ func (h *ProjectHandler) GetProjectStatistics(c *gin.Context) {
 		"end_date":          project.EndDate,
 	}
 
+	// Break regular tasks down by custom workflow state; done states are already counted as
+	// completed through their category
+	if workflow, err := projectService.GetProjectWorkflow(&project.ID); err == nil && workflow.Custom {
+		stats["workflow_states"] = workflow.StateCounts(project.Tasks)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"statistics": stats})
 }
 
-// GenerateProjectTasksWithAI generates tasks for a project using AI
+// GenerateProjectTasksWithAI generates tasks for a project using AI and saves them as a plan draft
 func (h *ProjectHandler) GenerateProjectTasksWithAI(c *gin.Context) {
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -355,82 +461,56 @@ func (h *ProjectHandler) GenerateProjectTasksWithAI(c *gin.Context) {
 		return
 	}
 
-	// Get project details
-	projectService := services.NewProjectService(h.DB)
-	project, err := projectService.GetProjectWithDetails(uint(projectID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
-		return
-	}
-
-	// Get project members with their job roles
-	members, userProjects, err := projectService.GetProjectMembers(uint(projectID))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch project members"})
-		return
-	}
-
-	if len(members) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Project has no team members. Please add team members first."})
-		return
-	}
-
-	// Create a map of userID to UserProject for quick lookup
-	userProjectMap := make(map[uint]models.UserProject)
-	for _, up := range userProjects {
-		userProjectMap[up.UserID] = up
-	}
-
-	// Build team member info
-	var teamMembers []services.TeamMemberInfo
-	for _, member := range members {
-		userProject := userProjectMap[member.ID]
-		teamMembers = append(teamMembers, services.TeamMemberInfo{
-			UserID:     member.ID,
-			Username:   member.Username,
-			JobRole:    userProject.JobRole,
-			Role:       userProject.Role,
-			Department: member.Department,
-			Skills:     member.Skills,
-		})
-	}
-
 	// Initialize AI task generator
 	taskService := services.NewTaskService(h.DB)
 	aiGenerator := services.NewAIProjectTaskGenerator(h.DB, taskService)
+	defer aiGenerator.Close()
+	planService := services.NewProjectPlanService(h.DB, aiGenerator)
 
-	// Prepare generation request
-	req := services.ProjectTaskGenerationRequest{
-		ProjectID:    project.ID,
-		ProjectTitle: project.Title,
-		Description:  project.Description,
-		TeamMembers:  teamMembers,
-		StartDate:    project.StartDate,
-		EndDate:      project.EndDate,
+	// Prepare generation request from the project and its team
+	userID, _ := c.Get("userID")
+	req, err := planService.GenerationRequest(uint(projectID), userID.(uint))
+	if err != nil {
+		planErrorResponse(c, err, "generate tasks")
+		return
 	}
 
 	// Generate tasks
 	generationResponse, err := aiGenerator.GenerateProjectTasks(req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to generate tasks: %v", err)})
+		planErrorResponse(c, err, "generate tasks")
+		return
+	}
+
+	// Keep the plan as a draft to refine before confirming
+	draft, err := planService.CreateDraft(req.ProjectID, userID.(uint), generationResponse)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to save plan draft: %v", err)})
 		return
 	}
 
 	// Always return preview - user must confirm to create
 	c.JSON(http.StatusOK, gin.H{
-		"message":         "Tasks generated successfully. Review and edit before confirming.",
-		"summary":         generationResponse.Summary,
-		"generated_tasks": generationResponse.Tasks,
-		"next_step":       "Edit tasks if needed, then call POST /api/projects/:id/confirm-tasks to create them",
+		"message":           "Tasks generated successfully. Review and edit before confirming.",
+		"draft_id":          draft.ID,
+		"draft":             draft,
+		"summary":           generationResponse.Summary,
+		"generated_tasks":   generationResponse.Tasks,
+		"validation_issues": generationResponse.ValidationIssues,
+		"rejected_tasks":    generationResponse.RejectedTasks,
+		"next_step":         "Refine the draft if needed, then call POST /api/projects/:id/plan-drafts/:draftId/confirm to create its tasks",
 		"instructions": gin.H{
-			"review":  "Review all generated tasks below",
-			"edit":    "Edit any task fields (start_time, end_time, assigned_to_user_id, priority, etc.)",
-			"confirm": "Call POST /api/projects/:id/confirm-tasks with the edited tasks array to create them",
+			"review":     "Review all generated tasks below",
+			"edit":       "Edit one task with PATCH /api/projects/:id/plan-drafts/:draftId/tasks/:taskKey, add or remove tasks with POST and DELETE",
+			"regenerate": "Call POST /api/projects/:id/plan-drafts/:draftId/regenerate with task keys and feedback to have the AI rework part of the plan",
+			"compare":    "Call GET /api/projects/:id/plan-drafts/:draftId/diff?from=1&to=2 to compare versions",
+			"confirm":    "Call POST /api/projects/:id/plan-drafts/:draftId/confirm to create the tasks of the current version",
 		},
 	})
 }
 
-// ConfirmAndCreateProjectTasks creates tasks after review and editing
+// ConfirmAndCreateProjectTasks creates tasks after review and editing, either from a plan draft or
+// from an edited tasks array
 func (h *ProjectHandler) ConfirmAndCreateProjectTasks(c *gin.Context) {
 	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -447,7 +527,9 @@ func (h *ProjectHandler) ConfirmAndCreateProjectTasks(c *gin.Context) {
 	}
 
 	var confirmRequest struct {
-		Tasks []services.GeneratedTask `json:"tasks" binding:"required"` // Edited tasks
+		DraftID uint                     `json:"draft_id"` // Plan draft to confirm
+		Version int                      `json:"version"`  // Version of the draft, the current one when omitted
+		Tasks   []services.GeneratedTask `json:"tasks"`    // Edited tasks, when no draft is given
 	}
 
 	if err := c.ShouldBindJSON(&confirmRequest); err != nil {
@@ -455,6 +537,29 @@ func (h *ProjectHandler) ConfirmAndCreateProjectTasks(c *gin.Context) {
 		return
 	}
 
+	// Initialize AI task generator
+	taskService := services.NewTaskService(h.DB)
+	aiGenerator := services.NewAIProjectTaskGenerator(h.DB, taskService)
+	defer aiGenerator.Close()
+
+	if confirmRequest.DraftID != 0 {
+		userID, _ := c.Get("userID")
+		planService := services.NewProjectPlanService(h.DB, aiGenerator)
+		draft, createdTasks, err := planService.ConfirmDraft(project.ID, confirmRequest.DraftID, confirmRequest.Version, userID.(uint))
+		if err != nil {
+			planErrorResponse(c, err, "create tasks")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":             "Tasks created successfully",
+			"draft":               draft,
+			"created_tasks_count": len(createdTasks),
+			"tasks":               createdTasks,
+		})
+		return
+	}
+
 	if len(confirmRequest.Tasks) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No tasks provided to create"})
 		return
@@ -478,10 +583,6 @@ func (h *ProjectHandler) ConfirmAndCreateProjectTasks(c *gin.Context) {
 		}
 	}
 
-	// Initialize AI task generator
-	taskService := services.NewTaskService(h.DB)
-	aiGenerator := services.NewAIProjectTaskGenerator(h.DB, taskService)
-
 	// Create tasks from the edited/confirmed tasks
 	createdTasks, err := aiGenerator.CreateTasksFromGeneration(project.ID, confirmRequest.Tasks)
 	if err != nil {
@@ -495,3 +596,254 @@ func (h *ProjectHandler) ConfirmAndCreateProjectTasks(c *gin.Context) {
 		"tasks":               createdTasks,
 	})
 }
+
+// GetPlanDrafts lists the project's AI plan drafts (Manager/Admin only)
+func (h *ProjectHandler) GetPlanDrafts(c *gin.Context) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	status := models.ProjectPlanDraftStatus(c.Query("status"))
+	if status != "" && status != models.ProjectPlanDraftOpen && status != models.ProjectPlanDraftConfirmed && status != models.ProjectPlanDraftDiscarded {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be 'draft', 'confirmed' or 'discarded'"})
+		return
+	}
+
+	drafts, err := services.NewProjectPlanService(h.DB, nil).GetProjectDrafts(uint(projectID), status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get plan drafts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"drafts": drafts, "count": len(drafts)})
+}
+
+// GetPlanDraft returns a plan draft with its current version, or the one in ?version=
+// (Manager/Admin only)
+func (h *ProjectHandler) GetPlanDraft(c *gin.Context) {
+	projectID, draftID, ok := planDraftParams(c)
+	if !ok {
+		return
+	}
+	version, _ := strconv.Atoi(c.DefaultQuery("version", "0"))
+
+	draft, err := services.NewProjectPlanService(h.DB, nil).GetDraft(projectID, draftID, version)
+	if err != nil {
+		planErrorResponse(c, err, "get plan draft")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"draft": draft})
+}
+
+// UpdatePlanTask edits one task of a plan draft, saving a new version (Manager/Admin only)
+func (h *ProjectHandler) UpdatePlanTask(c *gin.Context) {
+	projectID, draftID, ok := planDraftParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		services.PlanTaskChanges
+		BaseVersion int `json:"base_version"` // Version the edit was made on
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	draft, err := services.NewProjectPlanService(h.DB, nil).UpdateTask(projectID, draftID, c.Param("taskKey"), req.PlanTaskChanges, req.BaseVersion, userID.(uint))
+	if err != nil {
+		planErrorResponse(c, err, "update plan task")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task updated", "draft": draft})
+}
+
+// AddPlanTask adds a task to a plan draft, saving a new version (Manager/Admin only)
+func (h *ProjectHandler) AddPlanTask(c *gin.Context) {
+	projectID, draftID, ok := planDraftParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		services.PlanTask
+		After       string `json:"after"` // Key of the task to add it after; at the end when empty
+		BaseVersion int    `json:"base_version"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	draft, err := services.NewProjectPlanService(h.DB, nil).AddTask(projectID, draftID, req.PlanTask, req.After, req.BaseVersion, userID.(uint))
+	if err != nil {
+		planErrorResponse(c, err, "add plan task")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Task added", "draft": draft})
+}
+
+// RemovePlanTask removes a task from a plan draft, saving a new version (Manager/Admin only)
+func (h *ProjectHandler) RemovePlanTask(c *gin.Context) {
+	projectID, draftID, ok := planDraftParams(c)
+	if !ok {
+		return
+	}
+	baseVersion, _ := strconv.Atoi(c.DefaultQuery("base_version", "0"))
+
+	userID, _ := c.Get("userID")
+	draft, err := services.NewProjectPlanService(h.DB, nil).RemoveTask(projectID, draftID, c.Param("taskKey"), baseVersion, userID.(uint))
+	if err != nil {
+		planErrorResponse(c, err, "remove plan task")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task removed", "draft": draft})
+}
+
+// RegeneratePlanTasks has the AI rework some or all tasks of a plan draft following feedback,
+// saving a new version (Manager/Admin only)
+func (h *ProjectHandler) RegeneratePlanTasks(c *gin.Context) {
+	projectID, draftID, ok := planDraftParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		TaskKeys    []string `json:"task_keys"` // Tasks to rework; the whole plan when empty
+		Feedback    string   `json:"feedback" binding:"required"`
+		BaseVersion int      `json:"base_version"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	taskService := services.NewTaskService(h.DB)
+	aiGenerator := services.NewAIProjectTaskGenerator(h.DB, taskService)
+	defer aiGenerator.Close()
+
+	userID, _ := c.Get("userID")
+	draft, err := services.NewProjectPlanService(h.DB, aiGenerator).RegenerateTasks(projectID, draftID, req.TaskKeys, req.Feedback, req.BaseVersion, userID.(uint))
+	if err != nil {
+		planErrorResponse(c, err, "regenerate tasks")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tasks regenerated. Review the changes before confirming.", "draft": draft})
+}
+
+// DiffPlanDraftVersions compares two versions of a plan draft; from defaults to the version
+// before to, and to to the current version (Manager/Admin only)
+func (h *ProjectHandler) DiffPlanDraftVersions(c *gin.Context) {
+	projectID, draftID, ok := planDraftParams(c)
+	if !ok {
+		return
+	}
+	from, _ := strconv.Atoi(c.DefaultQuery("from", "0"))
+	to, _ := strconv.Atoi(c.DefaultQuery("to", "0"))
+
+	diff, err := services.NewProjectPlanService(h.DB, nil).DiffVersions(projectID, draftID, from, to)
+	if err != nil {
+		planErrorResponse(c, err, "compare plan versions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"diff": diff})
+}
+
+// ConfirmPlanDraft creates the tasks of a plan draft's current version, or of the version in the
+// body (Manager/Admin only)
+func (h *ProjectHandler) ConfirmPlanDraft(c *gin.Context) {
+	projectID, draftID, ok := planDraftParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Version int `json:"version"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	taskService := services.NewTaskService(h.DB)
+	aiGenerator := services.NewAIProjectTaskGenerator(h.DB, taskService)
+	defer aiGenerator.Close()
+
+	userID, _ := c.Get("userID")
+	draft, createdTasks, err := services.NewProjectPlanService(h.DB, aiGenerator).ConfirmDraft(projectID, draftID, req.Version, userID.(uint))
+	if err != nil {
+		planErrorResponse(c, err, "create tasks")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Tasks created successfully",
+		"draft":               draft,
+		"created_tasks_count": len(createdTasks),
+		"tasks":               createdTasks,
+	})
+}
+
+// DiscardPlanDraft closes a plan draft without creating its tasks (Manager/Admin only)
+func (h *ProjectHandler) DiscardPlanDraft(c *gin.Context) {
+	projectID, draftID, ok := planDraftParams(c)
+	if !ok {
+		return
+	}
+
+	if err := services.NewProjectPlanService(h.DB, nil).DiscardDraft(projectID, draftID); err != nil {
+		planErrorResponse(c, err, "discard plan draft")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Plan draft discarded"})
+}
+
+// planDraftParams parses the project and draft IDs of a plan draft route
+func planDraftParams(c *gin.Context) (uint, uint, bool) {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return 0, 0, false
+	}
+	draftID, err := strconv.ParseUint(c.Param("draftId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid draft ID"})
+		return 0, 0, false
+	}
+	return uint(projectID), uint(draftID), true
+}
+
+// planErrorResponse maps the errors of task generation and plan drafts to responses
+func planErrorResponse(c *gin.Context, err error, action string) {
+	var validationErr *services.AIValidationError
+	switch {
+	case errors.Is(err, services.ErrPlanProjectNotFound), errors.Is(err, services.ErrPlanDraftNotFound),
+		errors.Is(err, services.ErrPlanVersionNotFound), errors.Is(err, services.ErrPlanTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPlanDraftClosed), errors.Is(err, services.ErrPlanVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPlanTask), errors.Is(err, services.ErrProjectHasNoMembers),
+		errors.Is(err, services.ErrPlanFeedbackRequired), errors.Is(err, services.ErrPlanNothingToCompare):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAIBudgetExceeded):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.As(err, &validationErr):
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to %s: the AI's answer failed validation", action), "validation_issues": validationErr.Issues})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+	}
+}
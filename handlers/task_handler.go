@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -374,17 +375,24 @@ func (h *TaskHandler) GetUserTasks(c *gin.Context) {
 		return
 	}
 
+	workflowStates, err := services.NewProjectService(h.DB).WorkflowStateKeys(tasks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
+		return
+	}
+
 	var taskList []gin.H
 	for _, task := range tasks {
 		taskList = append(taskList, gin.H{
-			"id":          task.ID,
-			"title":       task.Title,
-			"description": task.Description,
-			"status":      task.Status,
-			"project_id":  task.ProjectID,
-			"assigned_at": task.AssignedAt,
-			"due_date":    task.DueDate,
-			"created_at":  task.CreatedAt,
+			"id":             task.ID,
+			"title":          task.Title,
+			"description":    task.Description,
+			"status":         task.Status,
+			"workflow_state": workflowStates[task.ID],
+			"project_id":     task.ProjectID,
+			"assigned_at":    task.AssignedAt,
+			"due_date":       task.DueDate,
+			"created_at":     task.CreatedAt,
 		})
 	}
 
@@ -435,17 +443,24 @@ func (h *TaskHandler) GetProjectTasks(c *gin.Context) {
 		return
 	}
 
+	workflowStates, err := services.NewProjectService(h.DB).WorkflowStateKeys(tasks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch project tasks"})
+		return
+	}
+
 	var taskList []gin.H
 	for _, task := range tasks {
 		taskList = append(taskList, gin.H{
-			"id":          task.ID,
-			"title":       task.Title,
-			"description": task.Description,
-			"status":      task.Status,
-			"user_id":     task.UserID,
-			"assigned_at": task.AssignedAt,
-			"due_date":    task.DueDate,
-			"created_at":  task.CreatedAt,
+			"id":             task.ID,
+			"title":          task.Title,
+			"description":    task.Description,
+			"status":         task.Status,
+			"workflow_state": workflowStates[task.ID],
+			"user_id":        task.UserID,
+			"assigned_at":    task.AssignedAt,
+			"due_date":       task.DueDate,
+			"created_at":     task.CreatedAt,
 		})
 	}
 
@@ -503,21 +518,7 @@ func (h *TaskHandler) UpdateTaskStatus(c *gin.Context) {
 		return
 	}
 
-	// Validate status
-	validStatuses := []string{"pending", "in_progress", "completed", "cancelled"}
-	validStatus := false
-	for _, status := range validStatuses {
-		if status == updateRequest.Status {
-			validStatus = true
-			break
-		}
-	}
-	if !validStatus {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
-		return
-	}
-
-	// Get current task to check old status and ownership
+	// Get current task to check ownership
 	var task models.Task
 	if err := h.DB.First(&task, taskID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
@@ -535,14 +536,17 @@ func (h *TaskHandler) UpdateTaskStatus(c *gin.Context) {
 
 	oldStatus := task.Status
 
-	err = h.TaskService.UpdateTaskStatus(uint(taskID), models.TaskStatus(updateRequest.Status))
+	// The status must be a state of the project's workflow that the task can move to
+	transition, err := h.TaskService.UpdateTaskStatus(uint(taskID), updateRequest.Status)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task status"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	updatedTask := transition.Task
+	completed := updatedTask.Status == models.TaskStatusCompleted && oldStatus != models.TaskStatusCompleted
 
 	// Update AI analysis with actual results if task is completed
-	if updateRequest.Status == string(models.TaskStatusCompleted) {
+	if completed {
 		if err := h.TaskService.UpdateAIAnalysisOnTaskCompletion(uint(taskID)); err != nil {
 			log.Printf("Warning: Failed to update AI analysis for task %d: %v", taskID, err)
 			// Don't fail the request, just log the warning
@@ -552,19 +556,19 @@ func (h *TaskHandler) UpdateTaskStatus(c *gin.Context) {
 	// Send notification for status change
 	var currentUser models.User
 	if err := h.DB.First(&currentUser, currentUserID.(uint)).Error; err == nil {
-		// Get updated task
-		var updatedTask models.Task
-		if err := h.DB.First(&updatedTask, taskID).Error; err == nil {
-			h.NotificationService.SendTaskStatusChangedNotification(&updatedTask, &currentUser, oldStatus, models.TaskStatus(updateRequest.Status))
-
-			// Send completion notification if task is completed
-			if updateRequest.Status == "completed" {
-				h.NotificationService.SendTaskCompletedNotification(&updatedTask, &currentUser)
-			}
+		h.NotificationService.SendTaskStatusChangedNotification(updatedTask, &currentUser, models.TaskStatus(transition.FromState), models.TaskStatus(transition.ToState))
+
+		// Send completion notification if the task reached a done state
+		if completed {
+			h.NotificationService.SendTaskCompletedNotification(updatedTask, &currentUser)
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Task status updated successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Task status updated successfully",
+		"status":         updatedTask.Status,
+		"workflow_state": transition.ToState,
+	})
 }
 
 // UpdateCollaborativeTaskStatus updates collaborative task status
@@ -614,18 +618,36 @@ func (h *TaskHandler) UpdateCollaborativeTaskStatus(c *gin.Context) {
 		return
 	}
 
+	// Completing a collaborative task sends it to its reviewers first
+	if updateRequest.Status == string(models.TaskStatusCompleted) {
+		collaborativeTaskService := services.NewCollaborativeTaskService(h.DB)
+		collaborativeTaskService.SetNotificationService(h.NotificationService)
+
+		completed, err := collaborativeTaskService.SubmitForReview(uint(taskID), currentUserID.(uint))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !completed {
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Collaborative task submitted for review",
+				"status":  models.TaskStatusInReview,
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Collaborative task completed (no reviewers assigned)",
+			"status":  models.TaskStatusCompleted,
+		})
+		return
+	}
+
 	err = h.TaskService.UpdateCollaborativeTaskStatus(uint(taskID), models.TaskStatus(updateRequest.Status))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collaborative task status"})
 		return
 	}
 
-	// Update AI analysis with actual results if task is completed
-	// Note: Collaborative tasks AI analysis update can be added later if needed
-	if updateRequest.Status == string(models.TaskStatusCompleted) {
-		log.Printf("Note: Collaborative task %d completed - AI analysis update for collaborative tasks not yet implemented", taskID)
-	}
-
 	c.JSON(http.StatusOK, gin.H{"message": "Collaborative task status updated successfully"})
 }
 
@@ -889,37 +911,27 @@ func (h *TaskHandler) BulkUpdateTaskStatus(c *gin.Context) {
 		return
 	}
 
-	// Validate status
-	validStatuses := []models.TaskStatus{
-		models.TaskStatusPending,
-		models.TaskStatusInProgress,
-		models.TaskStatusCompleted,
-		models.TaskStatusCancelled,
-	}
-	validStatus := false
-	for _, status := range validStatuses {
-		if status == bulkUpdateRequest.Status {
-			validStatus = true
-			break
-		}
-	}
-	if !validStatus {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
-		return
-	}
-
+	// Each task is validated against its own project's workflow
 	taskService := services.NewTaskService(h.DB)
-	updatedCount, err := taskService.BulkUpdateTaskStatus(bulkUpdateRequest.TaskIDs, bulkUpdateRequest.Status)
+	updatedCount, failures, err := taskService.BulkUpdateTaskStatus(bulkUpdateRequest.TaskIDs, string(bulkUpdateRequest.Status))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if updatedCount == 0 && len(failures) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No task could be moved to this status", "status_failures": failures})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message":         "Tasks updated successfully",
 		"updated_count":   updatedCount,
 		"total_requested": len(bulkUpdateRequest.TaskIDs),
-	})
+	}
+	if len(failures) > 0 {
+		response["status_failures"] = failures
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // GetTaskStatistics returns task statistics for managers and admins
@@ -1874,8 +1886,9 @@ func (h *TaskHandler) handleSmartBulkUpdate(c *gin.Context, currentUserID uint,
 		return
 	}
 
-	// Validate status if provided
-	if bulkUpdateRequest.Status != nil {
+	// Validate status if provided. Regular tasks are checked against their project's workflow
+	// when they are updated.
+	if bulkUpdateRequest.Status != nil && bulkUpdateRequest.TaskType == "collaborative" {
 		validStatuses := []string{"pending", "in_progress", "completed", "cancelled"}
 		validStatus := false
 		for _, status := range validStatuses {
@@ -1928,8 +1941,9 @@ func (h *TaskHandler) handleSmartBulkUpdate(c *gin.Context, currentUserID uint,
 		return
 	}
 
-	// If only status is being updated, use the existing bulk status update
-	if bulkUpdateRequest.Status != nil &&
+	// If only the status of regular tasks is being updated, use the existing bulk status update
+	if bulkUpdateRequest.TaskType == "regular" &&
+		bulkUpdateRequest.Status != nil &&
 		bulkUpdateRequest.Title == nil &&
 		bulkUpdateRequest.Description == nil &&
 		bulkUpdateRequest.DueDate == nil &&
@@ -1940,18 +1954,26 @@ func (h *TaskHandler) handleSmartBulkUpdate(c *gin.Context, currentUserID uint,
 		bulkUpdateRequest.LeadUserID == nil &&
 		bulkUpdateRequest.ProjectID == nil {
 
-		updatedCount, err := h.TaskService.BulkUpdateTaskStatus(bulkUpdateRequest.TaskIDs, models.TaskStatus(*bulkUpdateRequest.Status))
+		updatedCount, failures, err := h.TaskService.BulkUpdateTaskStatus(bulkUpdateRequest.TaskIDs, *bulkUpdateRequest.Status)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if updatedCount == 0 && len(failures) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No task could be moved to this status", "status_failures": failures})
+			return
+		}
 
-		c.JSON(http.StatusOK, gin.H{
+		response := gin.H{
 			"action":        "bulk_status_updated",
 			"type":          bulkUpdateRequest.TaskType,
 			"updated_count": updatedCount,
 			"total":         len(bulkUpdateRequest.TaskIDs),
-		})
+		}
+		if len(failures) > 0 {
+			response["status_failures"] = failures
+		}
+		c.JSON(http.StatusOK, response)
 		return
 	}
 
@@ -2024,7 +2046,7 @@ func (h *TaskHandler) handleSmartBulkUpdate(c *gin.Context, currentUserID uint,
 					permissionDeniedTasks = append(permissionDeniedTasks, taskID)
 					continue
 				}
-				if err := h.TaskService.UpdateTaskStatus(taskID, models.TaskStatus(*bulkUpdateRequest.Status)); err != nil {
+				if _, err := h.TaskService.UpdateTaskStatus(taskID, *bulkUpdateRequest.Status); err != nil {
 					failedTasks = append(failedTasks, taskID)
 					continue
 				}
@@ -2126,7 +2148,15 @@ func (h *TaskHandler) handleSmartBulkUpdate(c *gin.Context, currentUserID uint,
 					permissionDeniedTasks = append(permissionDeniedTasks, taskID)
 					continue
 				}
-				if err := h.TaskService.UpdateCollaborativeTaskStatus(taskID, models.TaskStatus(*bulkUpdateRequest.Status)); err != nil {
+				if *bulkUpdateRequest.Status == string(models.TaskStatusCompleted) {
+					// Completion goes through the review stage
+					collaborativeTaskService := services.NewCollaborativeTaskService(h.DB)
+					collaborativeTaskService.SetNotificationService(h.NotificationService)
+					if _, err := collaborativeTaskService.SubmitForReview(taskID, currentUserID); err != nil {
+						failedTasks = append(failedTasks, taskID)
+						continue
+					}
+				} else if err := h.TaskService.UpdateCollaborativeTaskStatus(taskID, models.TaskStatus(*bulkUpdateRequest.Status)); err != nil {
 					failedTasks = append(failedTasks, taskID)
 					continue
 				}
@@ -2486,16 +2516,14 @@ func (h *TaskHandler) GetTaskTimeAnalysis(c *gin.Context) {
 
 	// Get AI analysis if available
 	var aiAnalysis *services.TimeAnalysis
-	if h.AIOptimizer != nil {
-		// Try to get AI analysis for this specific task
-		allAnalyses, err := h.AIOptimizer.AnalyzeTaskTimeRisks()
+	var aiValidationErr *services.AIValidationError
+	if h.AIOptimizer != nil && (task.Status == models.TaskStatusPending || task.Status == models.TaskStatusInProgress) {
+		// Analyze only this task, reusing a recent analysis when there is one
+		analysis, err := h.AIOptimizer.AnalyzeTask(uint(taskID))
 		if err == nil {
-			for _, analysis := range allAnalyses {
-				if analysis.TaskID == uint(taskID) {
-					aiAnalysis = &analysis
-					break
-				}
-			}
+			aiAnalysis = analysis
+		} else {
+			errors.As(err, &aiValidationErr)
 		}
 	}
 
@@ -2555,6 +2583,13 @@ func (h *TaskHandler) GetTaskTimeAnalysis(c *gin.Context) {
 			"predicted_completion":         aiAnalysis.PredictedCompletion,
 			"working_hours_until_deadline": aiAnalysis.WorkingHoursUntilDeadline,
 			"is_within_working_hours":      aiAnalysis.IsWithinWorkingHours,
+			"validation_issues":            aiAnalysis.ValidationIssues,
+		}
+	} else if aiValidationErr != nil {
+		response["ai_analysis"] = gin.H{
+			"status":            "AI analysis not available",
+			"reason":            "The AI's answer failed validation",
+			"validation_issues": aiValidationErr.Issues,
 		}
 	} else {
 		response["ai_analysis"] = gin.H{
@@ -31,8 +31,12 @@ func main() {
 		&models.Task{},
 		&models.CollaborativeTask{},
 		&models.CollaborativeTaskParticipant{},
+		&models.CollaborativeTaskItem{},
+		&models.CollaborativeTaskReview{},
 		&models.Project{},
 		&models.UserProject{},
+		&models.ProjectWorkflowState{},
+		&models.ProjectWorkflowTransition{},
 		&models.Notification{},
 		&models.UserNotificationPreference{},
 		// Simple chat models
@@ -43,20 +47,41 @@ func main() {
 		&models.HRProblem{},
 		&models.HRProblemComment{},
 		&models.HRProblemUpdate{},
+		&models.HRSLAPolicy{},
+		&models.HRProblemAttachment{},
+		&models.HRProblemFollowUp{},
+		&models.HRAssignmentRule{},
 		// AI Analysis models
 		&models.AIAnalysis{},
 		&models.CollaborativeAIAnalysis{},
+		&models.AIUsageRecord{},
+		&models.AIAnalysisVariant{},
+		&models.AIChatAction{},
+		&models.AIDigest{},
+		&models.AIDigestFailure{},
+		&models.ProjectPlanDraft{},
+		&models.ProjectPlanVersion{},
 		// Admin Daily Checklist
 		&models.AdminDailyChecklist{},
 		// Password Manager models
 		&models.Credential{},
 		&models.CredentialShare{},
 		&models.CredentialAccessLog{},
+		&models.CredentialAccessRequest{},
+		&models.CredentialBulkOperation{},
+		&models.CredentialVault{},
+		&models.CredentialVaultItem{},
+		&models.CredentialVaultGrant{},
 	); err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 	log.Println("✅ Database tables migrated successfully")
 
+	// Check the HR content key once here rather than in every service that uses it
+	if _, err := services.NewEncryptionServiceFromEnv("HR_ENCRYPTION_KEY"); err != nil {
+		log.Printf("⚠️ HR problem content encryption disabled: %v. New HR reports, notes and evidence will be rejected until it is set", err)
+	}
+
 	// Initialize routes
 	setupRoutes(r, db)
 
@@ -133,12 +158,13 @@ func setupRoutes(r *gin.Engine, db *gorm.DB) {
 	routes.SetupUserRoutes(r, db)
 	routes.SetupTaskRoutes(r, db)
 	routes.SetupProjectRoutes(r, db)
-	routes.SetupCollaborativeTaskRoutes(r, db)
+	routes.SetupCollaborativeTaskRoutes(r, db, notificationService)
 	routes.SetupWebSocketRoutes(r, db, wsService)
 	routes.SetupNotificationRoutes(r, notificationHandler, db)
 	routes.SetupChatRoutes(r, db, wsService, notificationService)
 	routes.SetupHRProblemRoutes(r, db, notificationService)
 	routes.SetupAITimeRoutes(r, db)
+	routes.SetupAIDigestRoutes(r, db, wsService, notificationService)
 	routes.SetupAdminRoutes(r, db)
-	routes.SetupPasswordManagerRoutes(r, db)
+	routes.SetupPasswordManagerRoutes(r, db, notificationService)
 }
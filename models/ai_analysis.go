@@ -21,6 +21,14 @@ type AIAnalysis struct {
 	OptimalStartDate          time.Time `gorm:"not null"`
 	WorkingHoursUntilDeadline float64   `gorm:"default:0"`
 
+	// Local duration estimate used for scheduling, calibrated from past accuracy data.
+	// PredictedDuration keeps the model's raw estimate so the calibration keeps learning from it.
+	EstimatedDuration *int   `gorm:"default:null"`      // hours (null when no estimate was made)
+	EstimateLow       *int   `gorm:"default:null"`      // lower bound of the 90% interval
+	EstimateHigh      *int   `gorm:"default:null"`      // upper bound of the 90% interval
+	EstimateSource    string `gorm:"type:varchar(100)"` // history the estimate came from, e.g. "user:12" or "global"
+	EstimateMethod    string `gorm:"type:varchar(20)"`  // calibrated or historical
+
 	// Actual Results (updated when task completes)
 	ActualDuration     *int       `gorm:"default:null"` // hours (null until completed)
 	ActualCompletion   *time.Time `gorm:"default:null"`
@@ -31,12 +39,25 @@ type AIAnalysis struct {
 	// Metadata
 	AnalysisDate    time.Time `gorm:"not null;index"`
 	ModelVersion    string    `gorm:"default:'gemini-2.0-flash';type:varchar(100)"`
-	ConfidenceScore int       `gorm:"default:0"` // 0-100
+	PromptVersion   string    `gorm:"default:'v1';type:varchar(20)"`
+	Variant         string    `gorm:"type:varchar(50);index"` // A/B variant the task was assigned to
+	ConfidenceScore int       `gorm:"default:0"`              // 0-100
 
 	// Relationships
 	Task Task `gorm:"foreignKey:TaskID;constraint:OnDelete:CASCADE"`
 }
 
+// AIAnalysisVariant is one model and prompt configuration tasks are assigned to for A/B
+// comparison of analysis accuracy
+type AIAnalysisVariant struct {
+	gorm.Model
+	Name          string `gorm:"not null;uniqueIndex;type:varchar(50)"`
+	ModelName     string `gorm:"not null;type:varchar(100)"`
+	PromptVersion string `gorm:"not null;type:varchar(20)"`
+	Weight        int    `gorm:"not null;default:1"` // Share of tasks assigned, relative to the other variants
+	Description   string `gorm:"type:text"`
+}
+
 // CollaborativeAIAnalysis stores AI predictions for collaborative tasks
 type CollaborativeAIAnalysis struct {
 	gorm.Model
@@ -66,3 +87,20 @@ type CollaborativeAIAnalysis struct {
 	// Relationships
 	CollaborativeTask CollaborativeTask `gorm:"foreignKey:CollaborativeTaskID;constraint:OnDelete:CASCADE"`
 }
+
+// AIUsageRecord records one LLM call for cost reporting and budget enforcement
+type AIUsageRecord struct {
+	gorm.Model
+	UserID         *uint  `gorm:"index"`                           // user the call was made for (null for system work)
+	Feature        string `gorm:"not null;type:varchar(50);index"` // chat, time_analysis, task_generation, ...
+	ModelName      string `gorm:"type:varchar(100)"`
+	PromptTokens   int    `gorm:"default:0"`
+	ResponseTokens int    `gorm:"default:0"`
+	TotalTokens    int    `gorm:"default:0"`
+	LatencyMs      int64  `gorm:"default:0"`
+	Status         string `gorm:"not null;type:varchar(20)"` // success, error or over_budget
+	Error          string `gorm:"type:text"`
+
+	// Relationships
+	User *User `gorm:"foreignKey:UserID"`
+}
@@ -42,6 +42,7 @@ type ChatRoom struct {
 	CreatedBy   uint       `gorm:"not null;index"`
 	MaxMembers  int        `gorm:"default:1000"`
 	LastMessage *time.Time `gorm:"default:null"`
+	ProjectID   *uint      `gorm:"index"` // Set on a project's team room; weekly project digests read only these rooms
 
 	// Relationships
 	Creator  User          `gorm:"foreignKey:CreatedBy;constraint:OnDelete:CASCADE"`
@@ -79,3 +80,36 @@ type ChatMessage struct {
 	Sender   User         `gorm:"foreignKey:SenderID;constraint:OnDelete:CASCADE"`
 	ReplyTo  *ChatMessage `gorm:"foreignKey:ReplyToID;constraint:OnDelete:SET NULL"`
 }
+
+// AIChatActionStatus tracks a change proposed by the AI assistant
+type AIChatActionStatus string
+
+const (
+	AIChatActionPending   AIChatActionStatus = "pending"
+	AIChatActionRunning   AIChatActionStatus = "running" // Confirmed and being carried out
+	AIChatActionCompleted AIChatActionStatus = "completed"
+	AIChatActionCancelled AIChatActionStatus = "cancelled"
+	AIChatActionExpired   AIChatActionStatus = "expired"
+	AIChatActionFailed    AIChatActionStatus = "failed"
+)
+
+// AIChatAction is a change the AI assistant proposed through a tool call. It is only carried out
+// once the user confirms it.
+type AIChatAction struct {
+	gorm.Model
+	UserID     uint               `gorm:"not null;index"`
+	ChatRoomID uint               `gorm:"not null;index"`
+	Tool       string             `gorm:"not null;type:varchar(100)"`
+	Arguments  string             `gorm:"type:text"`     // JSON arguments of the tool call
+	Encrypted  bool               `gorm:"default:false"` // Arguments are encrypted with the HR key and dropped once resolved
+	Summary    string             `gorm:"type:text"`     // What the user is asked to confirm
+	Language   string             `gorm:"type:varchar(10);default:'en'"`
+	Status     AIChatActionStatus `gorm:"not null;default:'pending';index;type:varchar(20)"`
+	Result     string             `gorm:"type:text"` // JSON result, or the error when it failed
+	ExpiresAt  time.Time          `gorm:"not null"`
+	ResolvedAt *time.Time
+
+	// Relationships
+	User     User     `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	ChatRoom ChatRoom `gorm:"foreignKey:ChatRoomID;constraint:OnDelete:CASCADE"`
+}
@@ -9,15 +9,21 @@ import (
 // Credential represents a stored credential (password, email, username) for external platforms
 type Credential struct {
 	gorm.Model
-	Platform       string     `gorm:"not null;index;type:varchar(255) COLLATE \"default\""` // "Instagram", "Stripe", "GitHub", etc.
-	Email          string     `gorm:"not null;type:text"`                                   // Encrypted email
-	Username       string     `gorm:"type:text"`                                            // Encrypted username (optional)
-	Password       string     `gorm:"not null;type:text"`                                   // Encrypted password
-	URL            string     `gorm:"type:varchar(500)"`                                    // Platform URL (optional)
-	Notes          string     `gorm:"type:text"`                                            // Optional notes
-	CreatedByID    uint       `gorm:"not null;index"`                                       // Admin who created it
-	LastAccessedAt *time.Time `gorm:"index"`                                                // Last time anyone accessed
-	IsActive       bool       `gorm:"default:true;index"`                                   // Can be deactivated
+	Platform          string     `gorm:"not null;index;type:varchar(255) COLLATE \"default\""` // "Instagram", "Stripe", "GitHub", etc.
+	Email             string     `gorm:"not null;type:text"`                                   // Encrypted email
+	Username          string     `gorm:"type:text"`                                            // Encrypted username (optional)
+	Password          string     `gorm:"not null;type:text"`                                   // Encrypted password
+	URL               string     `gorm:"type:varchar(500)"`                                    // Platform URL (optional)
+	Notes             string     `gorm:"type:text"`                                            // Optional notes
+	CreatedByID       uint       `gorm:"not null;index"`                                       // Admin who created it
+	LastAccessedAt    *time.Time `gorm:"index"`                                                // Last time anyone accessed
+	IsActive          bool       `gorm:"default:true;index"`                                   // Can be deactivated
+	PasswordScore     int        `gorm:"default:0"`                                            // Strength score (0-4) when the password was last set
+	PasswordChangedAt *time.Time `gorm:"index"`                                                // When the password was last set
+	TOTPSecret        string     `gorm:"type:text"`                                            // Encrypted base32 TOTP seed (optional)
+	TOTPDigits        int        `gorm:"default:6"`                                            // Length of generated TOTP codes
+	TOTPPeriod        int        `gorm:"default:30"`                                           // TOTP code rotation in seconds
+	TOTPAlgorithm     string     `gorm:"type:varchar(10);default:'SHA1'"`                      // TOTP HMAC algorithm
 
 	// Relationships
 	CreatedBy  User                  `gorm:"foreignKey:CreatedByID;constraint:OnDelete:SET NULL"`
@@ -28,13 +34,15 @@ type Credential struct {
 // CredentialShare represents sharing permissions for a credential
 type CredentialShare struct {
 	gorm.Model
-	CredentialID uint      `gorm:"not null;index"`
-	UserID       uint      `gorm:"not null;index"`
-	SharedByID   uint      `gorm:"not null;index"` // Admin who shared it
-	SharedAt     time.Time `gorm:"not null;index"`
-	CanView      bool      `gorm:"default:true"` // Can view credentials
-	CanCopy      bool      `gorm:"default:true"` // Can copy password
-	Notes        string    `gorm:"type:text"`    // Optional sharing notes
+	CredentialID uint       `gorm:"not null;index"`
+	UserID       uint       `gorm:"not null;index"`
+	SharedByID   uint       `gorm:"not null;index"` // Admin who shared it
+	SharedAt     time.Time  `gorm:"not null;index"`
+	CanView      bool       `gorm:"default:true"` // Can view credentials
+	CanCopy      bool       `gorm:"default:true"` // Can copy password
+	Notes        string     `gorm:"type:text"`    // Optional sharing notes
+	ExpiresAt    *time.Time `gorm:"index"`        // Share is revoked after this time (nil = permanent)
+	RequestID    *uint      `gorm:"index"`        // Access request that granted this share (if any)
 
 	// Relationships
 	Credential Credential `gorm:"foreignKey:CredentialID;constraint:OnDelete:CASCADE"`
@@ -42,12 +50,41 @@ type CredentialShare struct {
 	SharedBy   User       `gorm:"foreignKey:SharedByID;constraint:OnDelete:SET NULL"`
 }
 
+// CredentialAccessRequestStatus represents the state of a just-in-time access request
+type CredentialAccessRequestStatus string
+
+const (
+	CredentialAccessRequestPending  CredentialAccessRequestStatus = "pending"
+	CredentialAccessRequestApproved CredentialAccessRequestStatus = "approved"
+	CredentialAccessRequestDenied   CredentialAccessRequestStatus = "denied"
+	CredentialAccessRequestExpired  CredentialAccessRequestStatus = "expired"
+)
+
+// CredentialAccessRequest represents an employee asking for temporary access to a credential
+type CredentialAccessRequest struct {
+	gorm.Model
+	CredentialID   uint                          `gorm:"not null;index"`
+	RequesterID    uint                          `gorm:"not null;index"`
+	Reason         string                        `gorm:"type:text"`          // Why access is needed
+	RequestedHours int                           `gorm:"not null;default:1"` // Requested access duration
+	Status         CredentialAccessRequestStatus `gorm:"not null;default:'pending';index;type:varchar(50)"`
+	ReviewedByID   *uint                         `gorm:"index"`     // Admin who approved/denied
+	ReviewedAt     *time.Time                    `gorm:"index"`     // When the request was reviewed
+	ReviewNote     string                        `gorm:"type:text"` // Optional admin note
+	ExpiresAt      *time.Time                    `gorm:"index"`     // When the granted access ends
+
+	// Relationships
+	Credential Credential `gorm:"foreignKey:CredentialID;constraint:OnDelete:CASCADE"`
+	Requester  User       `gorm:"foreignKey:RequesterID;constraint:OnDelete:CASCADE"`
+	ReviewedBy *User      `gorm:"foreignKey:ReviewedByID;constraint:OnDelete:SET NULL"`
+}
+
 // CredentialAccessLog represents an audit log entry for credential access
 type CredentialAccessLog struct {
 	gorm.Model
 	CredentialID uint      `gorm:"not null;index"`
 	UserID       uint      `gorm:"not null;index"`
-	Action       string    `gorm:"not null;index;type:varchar(50)"` // "view", "copy", "decrypt"
+	Action       string    `gorm:"not null;index;type:varchar(50)"` // "view", "copy", "decrypt", "totp"
 	IPAddress    string    `gorm:"type:varchar(45)"`                // User's IP address
 	UserAgent    string    `gorm:"type:text"`                       // Browser/client info
 	AccessedAt   time.Time `gorm:"not null;index"`
@@ -57,6 +94,80 @@ type CredentialAccessLog struct {
 	User       User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 }
 
+// Vault grantee types
+const (
+	VaultGranteeUser       = "user"
+	VaultGranteeDepartment = "department"
+	VaultGranteeRole       = "role"
+)
+
+// CredentialVault is a named collection of credentials that can be shared as a unit
+type CredentialVault struct {
+	gorm.Model
+	Name        string `gorm:"not null;uniqueIndex;type:varchar(255) COLLATE \"default\""` // "Marketing", "Payments", etc.
+	Description string `gorm:"type:text"`
+	CreatedByID uint   `gorm:"not null;index"` // Admin who created it
+
+	// Relationships
+	CreatedBy User                   `gorm:"foreignKey:CreatedByID;constraint:OnDelete:SET NULL"`
+	Items     []CredentialVaultItem  `gorm:"foreignKey:VaultID;constraint:OnDelete:CASCADE"`
+	Grants    []CredentialVaultGrant `gorm:"foreignKey:VaultID;constraint:OnDelete:CASCADE"`
+}
+
+// CredentialVaultItem places a credential in a vault
+type CredentialVaultItem struct {
+	gorm.Model
+	VaultID      uint `gorm:"not null;uniqueIndex:idx_vault_credential"`
+	CredentialID uint `gorm:"not null;uniqueIndex:idx_vault_credential;index"`
+	AddedByID    uint `gorm:"not null;index"`
+
+	// Relationships
+	Vault      CredentialVault `gorm:"foreignKey:VaultID;constraint:OnDelete:CASCADE"`
+	Credential Credential      `gorm:"foreignKey:CredentialID;constraint:OnDelete:CASCADE"`
+}
+
+// CredentialVaultGrant gives a user, a whole department or a whole role access to a vault.
+// Department and role grants are evaluated against the user's current Department/Role on
+// every access, so moving a user between departments or roles changes their access immediately.
+type CredentialVaultGrant struct {
+	gorm.Model
+	VaultID     uint      `gorm:"not null;index"`
+	GranteeType string    `gorm:"not null;index;type:varchar(50)"`             // "user", "department", "role"
+	UserID      *uint     `gorm:"index"`                                       // Set for user grants
+	Department  string    `gorm:"index;type:varchar(255) COLLATE \"default\""` // Set for department grants
+	Role        Role      `gorm:"index;type:varchar(50)"`                      // Set for role grants
+	CanView     bool      `gorm:"default:true"`                                // Inherited by every credential in the vault
+	CanCopy     bool      `gorm:"default:true"`                                // Inherited by every credential in the vault
+	GrantedByID uint      `gorm:"not null;index"`                              // Admin who granted it
+	GrantedAt   time.Time `gorm:"not null;index"`
+
+	// Relationships
+	Vault     CredentialVault `gorm:"foreignKey:VaultID;constraint:OnDelete:CASCADE"`
+	User      *User           `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	GrantedBy User            `gorm:"foreignKey:GrantedByID;constraint:OnDelete:SET NULL"`
+}
+
+// CredentialBulkOperation is an audit entry for a password manager import or export
+type CredentialBulkOperation struct {
+	gorm.Model
+	AdminID      uint      `gorm:"not null;index"`
+	Operation    string    `gorm:"not null;index;type:varchar(50)"` // "import", "export"
+	Format       string    `gorm:"type:varchar(50)"`                // "bitwarden_csv", "keepass_csv", "projectx_encrypted", etc.
+	DryRun       bool      `gorm:"default:false"`
+	TotalItems   int       `gorm:"default:0"`
+	CreatedCount int       `gorm:"default:0"`
+	UpdatedCount int       `gorm:"default:0"`
+	SkippedCount int       `gorm:"default:0"`
+	FailedCount  int       `gorm:"default:0"`
+	Details      string    `gorm:"type:json"`        // Per-item summary (no secrets or account emails)
+	IPAddress    string    `gorm:"type:varchar(45)"` // Admin's IP address
+	UserAgent    string    `gorm:"type:text"`        // Browser/client info
+	PerformedAt  time.Time `gorm:"not null;index"`
+
+	// Relationships
+	Admin User `gorm:"foreignKey:AdminID;constraint:OnDelete:CASCADE"`
+}
+
 // CredentialDetails represents decrypted credential data (for API responses)
 type CredentialDetails struct {
 	ID             uint       `json:"id"`
@@ -69,5 +180,6 @@ type CredentialDetails struct {
 	CreatedAt      time.Time  `json:"created_at"`
 	LastAccessedAt *time.Time `json:"last_accessed_at"`
 	IsActive       bool       `json:"is_active"`
+	HasTOTP        bool       `json:"has_totp"`    // 2FA codes available via the TOTP endpoint
 	SharedWith     []uint     `json:"shared_with"` // User IDs who have access
 }
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AIDigestType is the kind of summary the AI wrote
+type AIDigestType string
+
+const (
+	AIDigestDailyStandup  AIDigestType = "daily_standup"
+	AIDigestWeeklyProject AIDigestType = "weekly_project"
+)
+
+// AIDigestDelivery is how a user receives their digests
+type AIDigestDelivery string
+
+const (
+	AIDigestDeliveryNotification AIDigestDelivery = "notification"
+	AIDigestDeliveryChat         AIDigestDelivery = "chat" // Posted in the user's AI assistant room
+)
+
+// AIDigest is an AI-written narrative summary kept as a per-project archive. A daily standup is
+// split into one digest per project the user worked on, with ProjectID null for tasks outside
+// projects. A weekly project digest is written once per language its recipients read.
+type AIDigest struct {
+	gorm.Model
+	Type        AIDigestType `gorm:"not null;index;type:varchar(30)"`
+	ProjectID   *uint        `gorm:"index"`
+	UserID      *uint        `gorm:"index"` // Whose standup; null for project digests
+	PeriodStart time.Time    `gorm:"not null;index"`
+	PeriodEnd   time.Time    `gorm:"not null;index"`
+	Language    string       `gorm:"type:varchar(10);default:'en'"`
+	Content     string       `gorm:"not null;type:text"`
+	Facts       string       `gorm:"type:json"` // Task activity the digest was written from; chat messages are not kept
+
+	// Relationships
+	Project *Project `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
+	User    *User    `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+}
+
+// AIDigestFailure counts the failed attempts at one digest, so the sweep retries it a few times
+// instead of on every run
+type AIDigestFailure struct {
+	gorm.Model
+	Type          AIDigestType `gorm:"not null;type:varchar(30);uniqueIndex:idx_digest_failure"`
+	SubjectID     uint         `gorm:"not null;uniqueIndex:idx_digest_failure"` // User of a standup, project of a weekly digest
+	PeriodStart   time.Time    `gorm:"not null;uniqueIndex:idx_digest_failure"`
+	Language      string       `gorm:"type:varchar(10);not null;default:'';uniqueIndex:idx_digest_failure"` // Empty for standups
+	Attempts      int          `gorm:"not null;default:0"`
+	LastAttemptAt time.Time    `gorm:"not null"`
+	LastError     string       `gorm:"type:text"`
+}
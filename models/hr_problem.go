@@ -51,54 +51,75 @@ const (
 // HRProblem represents a workplace problem reported by a user
 type HRProblem struct {
 	gorm.Model
-	Title           string          `gorm:"not null;type:varchar(500) COLLATE \"default\""`
-	Description     string          `gorm:"not null;type:text"`
-	Category        ProblemCategory `gorm:"not null;index;type:varchar(100)"`
-	Priority        ProblemPriority `gorm:"default:'medium';index;type:varchar(50)"`
-	Status          ProblemStatus   `gorm:"default:'pending';index;type:varchar(50)"`
-	ReporterID      uint            `gorm:"not null;index"`
-	AssignedHRID    *uint           `gorm:"index"` // HR person handling this
-	IsAnonymous     bool            `gorm:"default:false"`
-	IsUrgent        bool            `gorm:"default:false;index"`
-	ReportedAt      time.Time       `gorm:"not null;index"`
-	ResolvedAt      *time.Time      `gorm:"index"`
-	HRNotes         string          `gorm:"type:text"`                             // HR internal notes
-	Resolution      string          `gorm:"type:text"`                             // Final resolution description
-	FollowUpDate    *time.Time      `gorm:"index"`                                 // When to follow up
-	AttachmentPath  string          `gorm:"type:varchar(500)"`                     // Path to uploaded files
-	ContactMethod   string          `gorm:"type:varchar(100);default:'email'"`     // How user wants to be contacted
-	PhoneNumber     string          `gorm:"type:varchar(20)"`                      // Optional phone for urgent issues
-	PreferredTime   string          `gorm:"type:varchar(100)"`                     // Preferred contact time
-	WitnessInfo     string          `gorm:"type:text"`                             // Witness information if any
-	PreviousReports bool            `gorm:"default:false"`                         // Has this been reported before
-	Location        string          `gorm:"type:varchar(255) COLLATE \"default\""` // Where the incident occurred
-	IncidentDate    *time.Time      `gorm:"index"`                                 // When the incident occurred
+	Title                 string          `gorm:"not null;type:varchar(500) COLLATE \"default\""`
+	Description           string          `gorm:"not null;type:text"` // Encrypted with the HR key
+	Category              ProblemCategory `gorm:"not null;index;type:varchar(100)"`
+	Priority              ProblemPriority `gorm:"default:'medium';index;type:varchar(50)"`
+	Status                ProblemStatus   `gorm:"default:'pending';index;type:varchar(50)"`
+	ReporterID            *uint           `gorm:"index"` // Nil for anonymous reports
+	AssignedHRID          *uint           `gorm:"index"` // HR person handling this
+	IsAnonymous           bool            `gorm:"default:false"`
+	IsUrgent              bool            `gorm:"default:false;index"`
+	ReportedAt            time.Time       `gorm:"not null;index"`
+	ResolvedAt            *time.Time      `gorm:"index"`
+	HRNotes               string          `gorm:"type:text"`                             // HR internal notes (encrypted)
+	Resolution            string          `gorm:"type:text"`                             // Final resolution description
+	PublishAsPolicyAnswer bool            `gorm:"default:false"`                         // HR shares the resolution as a general policy answer in the knowledge base
+	FollowUpDate          *time.Time      `gorm:"index"`                                 // Next pending follow-up (see FollowUps)
+	AttachmentPath        string          `gorm:"type:varchar(500)"`                     // Deprecated: evidence is stored as HRProblemAttachment
+	ContactMethod         string          `gorm:"type:varchar(100);default:'email'"`     // How user wants to be contacted
+	PhoneNumber           string          `gorm:"type:text"`                             // Optional phone for urgent issues (encrypted)
+	PreferredTime         string          `gorm:"type:varchar(100)"`                     // Preferred contact time
+	WitnessInfo           string          `gorm:"type:text"`                             // Witness information if any (encrypted)
+	PreviousReports       bool            `gorm:"default:false"`                         // Has this been reported before
+	Location              string          `gorm:"type:varchar(255) COLLATE \"default\""` // Where the incident occurred
+	IncidentDate          *time.Time      `gorm:"index"`                                 // When the incident occurred
+	IsEncrypted           bool            `gorm:"default:false;index"`                   // Sensitive content is encrypted at rest
+
+	// SLA tracking (targets are in working hours, snapshotted from HRSLAPolicy when reported)
+	FirstResponseAt          *time.Time `gorm:"index"` // First HR status change, reply or resolution
+	SLAFirstResponseHours    float64
+	SLAResolutionHours       float64
+	SLAFirstResponseDueAt    *time.Time `gorm:"index"`
+	SLAResolutionDueAt       *time.Time `gorm:"index"`
+	SLAFirstResponseWarned   bool       `gorm:"default:false"`
+	SLAResolutionWarned      bool       `gorm:"default:false"`
+	SLAFirstResponseBreached bool       `gorm:"default:false;index"`
+	SLAResolutionBreached    bool       `gorm:"default:false;index"`
+
+	// Anonymous follow-up credentials (only set for anonymous reports)
+	CaseCode           *string `gorm:"uniqueIndex;type:varchar(32)"` // Public case reference given to the reporter
+	CasePassphraseHash string  `gorm:"type:varchar(255)" json:"-"`   // bcrypt hash of the case passphrase
 
 	// Relationships
-	Reporter   User               `gorm:"foreignKey:ReporterID;constraint:OnDelete:CASCADE"`
-	AssignedHR *User              `gorm:"foreignKey:AssignedHRID;constraint:OnDelete:SET NULL"`
-	Comments   []HRProblemComment `gorm:"foreignKey:ProblemID;constraint:OnDelete:CASCADE"`
-	Updates    []HRProblemUpdate  `gorm:"foreignKey:ProblemID;constraint:OnDelete:CASCADE"`
+	Reporter    *User                 `gorm:"foreignKey:ReporterID;constraint:OnDelete:CASCADE"`
+	AssignedHR  *User                 `gorm:"foreignKey:AssignedHRID;constraint:OnDelete:SET NULL"`
+	Comments    []HRProblemComment    `gorm:"foreignKey:ProblemID;constraint:OnDelete:CASCADE"`
+	Updates     []HRProblemUpdate     `gorm:"foreignKey:ProblemID;constraint:OnDelete:CASCADE"`
+	Attachments []HRProblemAttachment `gorm:"foreignKey:ProblemID;constraint:OnDelete:CASCADE"`
+	FollowUps   []HRProblemFollowUp   `gorm:"foreignKey:ProblemID;constraint:OnDelete:CASCADE"`
 }
 
 // HRProblemComment represents comments on a problem report
 type HRProblemComment struct {
 	gorm.Model
-	ProblemID uint   `gorm:"not null;index"`
-	UserID    uint   `gorm:"not null;index"`
-	Comment   string `gorm:"not null;type:text"`
-	IsHROnly  bool   `gorm:"default:false"` // Only HR can see this comment
+	ProblemID           uint   `gorm:"not null;index"`
+	UserID              *uint  `gorm:"index"` // Nil when posted by an anonymous reporter
+	Comment             string `gorm:"not null;type:text"`
+	IsHROnly            bool   `gorm:"default:false"` // Only HR can see this comment
+	IsAnonymousReporter bool   `gorm:"default:false"` // Posted through the anonymous case code
 
 	// Relationships
-	Problem HRProblem `gorm:"foreignKey:ProblemID;constraint:OnDelete:CASCADE"`
-	User    User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Problem     HRProblem             `gorm:"foreignKey:ProblemID;constraint:OnDelete:CASCADE"`
+	User        *User                 `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Attachments []HRProblemAttachment `gorm:"foreignKey:CommentID;constraint:OnDelete:CASCADE"`
 }
 
 // HRProblemUpdate represents status updates on a problem report
 type HRProblemUpdate struct {
 	gorm.Model
 	ProblemID   uint          `gorm:"not null;index"`
-	UpdatedBy   uint          `gorm:"not null;index"`
+	UpdatedBy   *uint         `gorm:"index"` // Nil for anonymous reporter or system actions
 	OldStatus   ProblemStatus `gorm:"type:varchar(50)"`
 	NewStatus   ProblemStatus `gorm:"type:varchar(50)"`
 	UpdateNote  string        `gorm:"type:text"`
@@ -106,7 +127,126 @@ type HRProblemUpdate struct {
 
 	// Relationships
 	Problem       HRProblem `gorm:"foreignKey:ProblemID;constraint:OnDelete:CASCADE"`
-	UpdatedByUser User      `gorm:"foreignKey:UpdatedBy;constraint:OnDelete:CASCADE"`
+	UpdatedByUser *User     `gorm:"foreignKey:UpdatedBy;constraint:OnDelete:CASCADE"`
+}
+
+// AttachmentScanStatus is the outcome of the virus scan of an evidence file
+type AttachmentScanStatus string
+
+const (
+	AttachmentScanClean   AttachmentScanStatus = "clean"
+	AttachmentScanSkipped AttachmentScanStatus = "skipped" // No scanner configured
+)
+
+// HRProblemAttachment is an evidence file attached to a problem or one of its comments.
+// The file itself is encrypted with the HR key on local disk.
+type HRProblemAttachment struct {
+	gorm.Model
+	ProblemID           uint                 `gorm:"not null;index"`
+	CommentID           *uint                `gorm:"index"` // Set when attached to a comment
+	UploadedByID        *uint                `gorm:"index"` // Nil when uploaded by an anonymous reporter
+	IsAnonymousReporter bool                 `gorm:"default:false"`
+	FileName            string               `gorm:"not null;type:text"` // Original file name (encrypted)
+	ContentType         string               `gorm:"type:varchar(255)"`
+	Size                int64                `gorm:"not null"`
+	StoragePath         string               `gorm:"not null;type:varchar(500)" json:"-"` // Relative to the attachment directory
+	SHA256              string               `gorm:"type:varchar(64)"`                    // Hash of the plaintext for integrity checks
+	ScanStatus          AttachmentScanStatus `gorm:"type:varchar(20)"`
+	ScanResult          string               `gorm:"type:varchar(255)"`
+
+	// Relationships
+	Problem    HRProblem         `gorm:"foreignKey:ProblemID;constraint:OnDelete:CASCADE"`
+	Comment    *HRProblemComment `gorm:"foreignKey:CommentID;constraint:OnDelete:CASCADE"`
+	UploadedBy *User             `gorm:"foreignKey:UploadedByID;constraint:OnDelete:SET NULL"`
+}
+
+// FollowUpStatus represents the state of a scheduled follow-up
+type FollowUpStatus string
+
+const (
+	FollowUpStatusPending   FollowUpStatus = "pending"
+	FollowUpStatusCompleted FollowUpStatus = "completed"
+	FollowUpStatusCancelled FollowUpStatus = "cancelled"
+)
+
+// FollowUpOutcome records what HR found when completing a follow-up
+type FollowUpOutcome string
+
+const (
+	FollowUpOutcomeResolved FollowUpOutcome = "resolved" // The issue is confirmed resolved
+	FollowUpOutcomePersists FollowUpOutcome = "persists" // The issue persists; resolved cases are reopened
+)
+
+// HRProblemFollowUp is a check-in HR schedules on a problem
+type HRProblemFollowUp struct {
+	gorm.Model
+	ProblemID      uint            `gorm:"not null;index"`
+	ScheduledByID  uint            `gorm:"not null;index"`
+	DueDate        time.Time       `gorm:"not null;index"`
+	Note           string          `gorm:"type:text"` // Encrypted with the HR key
+	Status         FollowUpStatus  `gorm:"default:'pending';index;type:varchar(20)"`
+	ReminderSentAt *time.Time      `gorm:"index"`
+	CompletedAt    *time.Time      `gorm:"index"`
+	CompletedByID  *uint           `gorm:"index"`
+	Outcome        FollowUpOutcome `gorm:"type:varchar(20)"`
+	OutcomeNote    string          `gorm:"type:text"` // Encrypted with the HR key
+
+	// Relationships
+	Problem     HRProblem `gorm:"foreignKey:ProblemID;constraint:OnDelete:CASCADE"`
+	ScheduledBy User      `gorm:"foreignKey:ScheduledByID;constraint:OnDelete:CASCADE"`
+	CompletedBy *User     `gorm:"foreignKey:CompletedByID;constraint:OnDelete:SET NULL"`
+}
+
+// HRSLAPolicy defines response and resolution targets for a problem priority
+type HRSLAPolicy struct {
+	gorm.Model
+	Priority           ProblemPriority `gorm:"uniqueIndex;not null;type:varchar(50)"`
+	FirstResponseHours float64         `gorm:"not null"`              // Working hours until HR must first respond
+	ResolutionHours    float64         `gorm:"not null"`              // Working hours until the problem must be resolved
+	WarningThreshold   float64         `gorm:"not null;default:0.75"` // Fraction of the target after which HR is warned
+	UpdatedByID        *uint           `gorm:"index"`
+
+	// Relationships
+	UpdatedBy *User `gorm:"foreignKey:UpdatedByID;constraint:OnDelete:SET NULL"`
+}
+
+// HRAssignmentStrategy decides how a rule picks between its eligible HR users
+type HRAssignmentStrategy string
+
+const (
+	HRAssignmentRoundRobin  HRAssignmentStrategy = "round_robin"  // Take turns in user order
+	HRAssignmentLeastLoaded HRAssignmentStrategy = "least_loaded" // Fewest open assigned problems
+)
+
+// HRAssignmentRule auto-assigns new problems that match its filters. Rules are evaluated in
+// SortOrder and the first one that yields an eligible HR user wins.
+type HRAssignmentRule struct {
+	gorm.Model
+	Name               string               `gorm:"not null;type:varchar(255)"`
+	SortOrder          int                  `gorm:"not null;default:0;index"`
+	IsActive           bool                 `gorm:"default:true;index"`
+	Category           ProblemCategory      `gorm:"type:varchar(100);index"`               // Empty matches every category
+	ReporterDepartment string               `gorm:"type:varchar(255) COLLATE \"default\""` // Empty matches every department (and anonymous reports)
+	Strategy           HRAssignmentStrategy `gorm:"not null;default:'least_loaded';type:varchar(50)"`
+	MaxOpenCases       int                  `gorm:"default:0"` // HR users at this many open problems are skipped (0 = no limit)
+	LastAssignedID     *uint                // Round-robin cursor
+	UpdatedByID        *uint                `gorm:"index"`
+
+	// Relationships
+	Assignees []User `gorm:"many2many:hr_assignment_rule_assignees;constraint:OnDelete:CASCADE"` // Empty means every HR user
+	UpdatedBy *User  `gorm:"foreignKey:UpdatedByID;constraint:OnDelete:SET NULL"`
+}
+
+// GetDefaultSLAPolicies returns the SLA targets promised by GetProblemPriorities,
+// expressed in working hours (6 effective hours per working day)
+func GetDefaultSLAPolicies() map[ProblemPriority]HRSLAPolicy {
+	return map[ProblemPriority]HRSLAPolicy{
+		ProblemPriorityLow:       {Priority: ProblemPriorityLow, FirstResponseHours: 18, ResolutionHours: 60, WarningThreshold: 0.75},
+		ProblemPriorityMedium:    {Priority: ProblemPriorityMedium, FirstResponseHours: 12, ResolutionHours: 36, WarningThreshold: 0.75},
+		ProblemPriorityHigh:      {Priority: ProblemPriorityHigh, FirstResponseHours: 6, ResolutionHours: 18, WarningThreshold: 0.75},
+		ProblemPriorityUrgent:    {Priority: ProblemPriorityUrgent, FirstResponseHours: 2, ResolutionHours: 6, WarningThreshold: 0.5},
+		ProblemPritorityCritical: {Priority: ProblemPritorityCritical, FirstResponseHours: 1, ResolutionHours: 6, WarningThreshold: 0.5},
+	}
 }
 
 // GetProblemCategories returns all available problem categories with descriptions
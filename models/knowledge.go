@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// KnowledgeEmbeddingDimensions is the size of the embeddings stored for knowledge chunks
+const KnowledgeEmbeddingDimensions = 768
+
+// KnowledgeSourceType is what a knowledge chunk was taken from
+type KnowledgeSourceType string
+
+const (
+	KnowledgeSourceTask     KnowledgeSourceType = "task"
+	KnowledgeSourceProject  KnowledgeSourceType = "project"
+	KnowledgeSourceChat     KnowledgeSourceType = "chat"
+	KnowledgeSourceHRAnswer KnowledgeSourceType = "hr_answer"
+)
+
+// KnowledgeChunk is a passage indexed for the AI assistant, with its embedding. The visibility
+// columns are copied from the source so searches only return what the asking user may see:
+// OwnerID and ProjectID for tasks and projects, ChatRoomID for chat. HR answers are visible to
+// everyone.
+type KnowledgeChunk struct {
+	gorm.Model
+	SourceType      KnowledgeSourceType `gorm:"not null;type:varchar(20);index:idx_knowledge_source"`
+	SourceID        uint                `gorm:"not null;index:idx_knowledge_source"` // Task, project or HR problem; first message of a chat passage
+	LastSourceID    uint                // Last message of a chat passage
+	ChunkIndex      int                 `gorm:"default:0"` // Position when a long source is split
+	Title           string              `gorm:"type:varchar(500)"`
+	Content         string              `gorm:"not null;type:text"`
+	ContentHash     string              `gorm:"type:varchar(64)"` // Unchanged content is not embedded again
+	OwnerID         *uint               `gorm:"index"`
+	ProjectID       *uint               `gorm:"index"`
+	ChatRoomID      *uint               `gorm:"index"`
+	SourceDate      time.Time
+	SourceUpdatedAt time.Time `gorm:"index"`                     // Indexing watermark
+	Embedding       string    `gorm:"type:vector(768)" json:"-"` // pgvector literal, e.g. [0.1,0.2,...]
+}
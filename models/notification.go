@@ -17,10 +17,25 @@ const (
 	NotificationTypeProjectCreated NotificationType = "project_created"
 	NotificationTypeUserJoined     NotificationType = "user_joined"
 	NotificationTypeFileUploaded   NotificationType = "file_uploaded"
+	// Collaborative task review notifications
+	NotificationTypeTaskReviewRequested  NotificationType = "task_review_requested"
+	NotificationTypeTaskChangesRequested NotificationType = "task_changes_requested"
+	NotificationTypeTaskApproved         NotificationType = "task_approved"
 	// HR-related notifications
 	NotificationTypeHRProblem         NotificationType = "hr_problem"
 	NotificationTypeHRProblemUpdate   NotificationType = "hr_problem_update"
 	NotificationTypeHRProblemAssigned NotificationType = "hr_problem_assigned"
+	NotificationTypeHRSLAWarning      NotificationType = "hr_sla_warning"
+	NotificationTypeHRSLABreached     NotificationType = "hr_sla_breached"
+	NotificationTypeHRFollowUpDue     NotificationType = "hr_follow_up_due"
+	// Password manager notifications
+	NotificationTypeCredentialAccessRequested NotificationType = "credential_access_requested"
+	NotificationTypeCredentialAccessApproved  NotificationType = "credential_access_approved"
+	NotificationTypeCredentialAccessDenied    NotificationType = "credential_access_denied"
+	NotificationTypeCredentialAccessExpired   NotificationType = "credential_access_expired"
+	// AI digest notifications
+	NotificationTypeAIDailyStandup NotificationType = "ai_daily_standup"
+	NotificationTypeAIWeeklyDigest NotificationType = "ai_weekly_digest"
 )
 
 type Notification struct {
@@ -58,6 +73,12 @@ type UserNotificationPreference struct {
 	PushNotifications  bool `gorm:"default:true"`
 	InAppNotifications bool `gorm:"default:true"`
 
+	// AI digests
+	DailyStandup   bool             `gorm:"default:true"`
+	WeeklyDigest   bool             `gorm:"default:true"` // Sent to project managers and heads
+	DigestDelivery AIDigestDelivery `gorm:"type:varchar(20);default:'notification'"`
+	Language       string           `gorm:"type:varchar(10)"` // "en" or "ar"; empty means detected from the user's messages
+
 	// Relationships
 	User User `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 }
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ProjectPlanDraftStatus tracks an AI-generated task plan from generation to task creation
+type ProjectPlanDraftStatus string
+
+const (
+	ProjectPlanDraftOpen      ProjectPlanDraftStatus = "draft"
+	ProjectPlanDraftConfirmed ProjectPlanDraftStatus = "confirmed" // Its tasks were created
+	ProjectPlanDraftDiscarded ProjectPlanDraftStatus = "discarded"
+)
+
+// ProjectPlanChange is what produced a version of a plan draft
+type ProjectPlanChange string
+
+const (
+	ProjectPlanChangeGenerated   ProjectPlanChange = "generated"
+	ProjectPlanChangeTaskEdited  ProjectPlanChange = "task_edited"
+	ProjectPlanChangeTaskAdded   ProjectPlanChange = "task_added"
+	ProjectPlanChangeTaskRemoved ProjectPlanChange = "task_removed"
+	ProjectPlanChangeRegenerated ProjectPlanChange = "regenerated"
+)
+
+// ProjectPlanDraft is an AI-generated task plan that managers refine before its tasks are created.
+// Every change adds a version, so earlier versions can be viewed, compared and confirmed.
+type ProjectPlanDraft struct {
+	gorm.Model
+	ProjectID        uint                   `gorm:"not null;index"`
+	CreatedBy        uint                   `gorm:"not null;index"`
+	Status           ProjectPlanDraftStatus `gorm:"not null;default:'draft';index;type:varchar(20)"`
+	CurrentVersion   int                    `gorm:"not null;default:1"`
+	NextTaskKey      int                    `gorm:"not null;default:1"` // Numbers the keys given to new tasks
+	ConfirmedVersion *int
+	ConfirmedBy      *uint
+	ConfirmedAt      *time.Time
+	CreatedTaskIDs   *string `gorm:"type:json"` // IDs of the tasks created on confirmation
+
+	// Relationships
+	Project  Project              `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
+	Creator  User                 `gorm:"foreignKey:CreatedBy;constraint:OnDelete:CASCADE"`
+	Versions []ProjectPlanVersion `gorm:"foreignKey:DraftID;constraint:OnDelete:CASCADE"`
+}
+
+// ProjectPlanVersion is one immutable state of a plan draft
+type ProjectPlanVersion struct {
+	gorm.Model
+	DraftID          uint              `gorm:"not null;uniqueIndex:idx_plan_draft_version"`
+	Version          int               `gorm:"not null;uniqueIndex:idx_plan_draft_version"`
+	Change           ProjectPlanChange `gorm:"not null;type:varchar(30)"`
+	Note             string            `gorm:"type:text"` // Key of the edited task, or the feedback given for a regeneration
+	Summary          string            `gorm:"type:text"`
+	Tasks            string            `gorm:"not null;type:json"`              // Keyed plan tasks, in order
+	ValidationIssues string            `gorm:"not null;type:json;default:'[]'"` // Problems the AI's answer had that were repaired
+	RejectedTasks    string            `gorm:"not null;type:json;default:'[]'"` // Tasks the AI proposed that were left out
+	CreatedBy        uint              `gorm:"not null"`
+}
@@ -24,6 +24,15 @@ const (
 	TaskStatusInProgress TaskStatus = "in_progress"
 	TaskStatusCompleted  TaskStatus = "completed"
 	TaskStatusCancelled  TaskStatus = "cancelled"
+	TaskStatusInReview   TaskStatus = "in_review" // Collaborative tasks awaiting reviewer approval
+)
+
+// ReviewDecision is a reviewer's verdict on a collaborative task submitted for review
+type ReviewDecision string
+
+const (
+	ReviewDecisionApproved         ReviewDecision = "approved"
+	ReviewDecisionChangesRequested ReviewDecision = "changes_requested"
 )
 
 type ProjectStatus string
@@ -66,6 +75,11 @@ type Task struct {
 	EndTime     *time.Time `gorm:"index"` // When task should end
 	DueDate     *time.Time `gorm:"index"` // Optional due date (legacy, can be removed later)
 
+	// Key of the project workflow state the task is in; Status holds that state's category.
+	// Empty outside custom workflows and for tasks that have not moved yet, which are in the
+	// first state of their Status's category.
+	WorkflowState string `gorm:"index;type:varchar(50)"`
+
 	// Relationships
 	User    User     `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 	Project *Project `gorm:"foreignKey:ProjectID;constraint:OnDelete:SET NULL"`
@@ -83,14 +97,18 @@ type CollaborativeTask struct {
 	EndTime         *time.Time `gorm:"index"`                                   // When task should end
 	DueDate         *time.Time `gorm:"index"`                                   // Optional due date (legacy, can be removed later)
 	Priority        string     `gorm:"default:'medium';index;type:varchar(50)"` // high, medium, low
-	Progress        int        `gorm:"default:0;index"`                         // 0-100 percentage
+	Progress        int        `gorm:"default:0;index"`                         // 0-100 percentage, computed from Items when there are any
 	Complexity      string     `gorm:"default:'medium';index;type:varchar(50)"` // simple, medium, complex
 	MaxParticipants int        `gorm:"default:5;index"`                         // Maximum number of participants
+	ReviewRound     int        `gorm:"default:0"`                               // Incremented every time the task is submitted for review
+	SubmittedAt     *time.Time `gorm:"index"`                                   // When the current review round started
 
 	// Relationships
 	LeadUser     User                           `gorm:"foreignKey:LeadUserID;constraint:OnDelete:SET NULL"`
 	Project      *Project                       `gorm:"foreignKey:ProjectID;constraint:OnDelete:SET NULL"`
 	Participants []CollaborativeTaskParticipant `gorm:"foreignKey:CollaborativeTaskID;constraint:OnDelete:CASCADE"`
+	Items        []CollaborativeTaskItem        `gorm:"foreignKey:CollaborativeTaskID;constraint:OnDelete:CASCADE"`
+	Reviews      []CollaborativeTaskReview      `gorm:"foreignKey:CollaborativeTaskID;constraint:OnDelete:CASCADE"`
 }
 
 // CollaborativeTaskParticipant represents team members working on a collaborative task
@@ -99,9 +117,9 @@ type CollaborativeTaskParticipant struct {
 	CollaborativeTaskID uint       `gorm:"not null;index"`
 	UserID              uint       `gorm:"not null;index"`
 	Role                string     `gorm:"not null;default:'contributor';index;type:varchar(100)"` // lead, contributor, reviewer, observer
-	Status              string     `gorm:"not null;default:'active';index;type:varchar(50)"`       // active, inactive, completed
+	Status              string     `gorm:"not null;default:'active';index;type:varchar(50)"`       // active, inactive, completed (derived from their items when they have any)
 	AssignedAt          time.Time  `gorm:"not null;index"`
-	CompletedAt         *time.Time `gorm:"index"`
+	CompletedAt         *time.Time `gorm:"index"`           // When their last item was completed
 	Contribution        string     `gorm:"index;type:text"` // Description of their contribution
 
 	// Relationships
@@ -109,6 +127,40 @@ type CollaborativeTaskParticipant struct {
 	User              User              `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 }
 
+// CollaborativeTaskItem is a sub-task or checklist item of a collaborative task, assigned to
+// one participant
+type CollaborativeTaskItem struct {
+	gorm.Model
+	CollaborativeTaskID uint       `gorm:"not null;index"`
+	AssigneeID          *uint      `gorm:"index"` // Participant responsible for the item, nil if unassigned
+	Title               string     `gorm:"not null;type:varchar(500) COLLATE \"default\""`
+	Description         string     `gorm:"type:text"`
+	SortOrder           int        `gorm:"default:0;index"`
+	DueDate             *time.Time `gorm:"index"`
+	IsCompleted         bool       `gorm:"default:false;index"`
+	CompletedAt         *time.Time `gorm:"index"`
+	CompletedByID       *uint      `gorm:"index"`
+
+	// Relationships
+	CollaborativeTask CollaborativeTask `gorm:"foreignKey:CollaborativeTaskID;constraint:OnDelete:CASCADE"`
+	Assignee          *User             `gorm:"foreignKey:AssigneeID;constraint:OnDelete:SET NULL"`
+	CompletedBy       *User             `gorm:"foreignKey:CompletedByID;constraint:OnDelete:SET NULL"`
+}
+
+// CollaborativeTaskReview is one reviewer's decision in a review round
+type CollaborativeTaskReview struct {
+	gorm.Model
+	CollaborativeTaskID uint           `gorm:"not null;index;uniqueIndex:idx_collaborative_review_round"`
+	ReviewerID          uint           `gorm:"not null;index;uniqueIndex:idx_collaborative_review_round"`
+	Round               int            `gorm:"not null;index;uniqueIndex:idx_collaborative_review_round"` // One decision per reviewer and round
+	Decision            ReviewDecision `gorm:"not null;type:varchar(50)"`
+	Comment             string         `gorm:"type:text"`
+
+	// Relationships
+	CollaborativeTask CollaborativeTask `gorm:"foreignKey:CollaborativeTaskID;constraint:OnDelete:CASCADE"`
+	Reviewer          User              `gorm:"foreignKey:ReviewerID;constraint:OnDelete:CASCADE"`
+}
+
 type Project struct {
 	gorm.Model
 	Title       string        `gorm:"not null;index;type:varchar(500) COLLATE \"default\""`
@@ -125,6 +177,28 @@ type Project struct {
 	CollaborativeTasks []CollaborativeTask `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
 }
 
+// ProjectWorkflowState is a custom task state in a project's workflow, such as "QA" or
+// "Blocked". Category maps the state onto the built-in TaskStatus values, which is what the rest
+// of the system (analytics, overdue checks, AI analysis) sees: states in the completed category
+// count as done.
+type ProjectWorkflowState struct {
+	gorm.Model
+	ProjectID uint       `gorm:"not null;index"`
+	Key       string     `gorm:"not null;type:varchar(50)"` // Stored in Task.WorkflowState
+	Name      string     `gorm:"not null;type:varchar(100)"`
+	Category  TaskStatus `gorm:"not null;type:varchar(50)"` // pending, in_progress, completed or cancelled
+	SortOrder int        `gorm:"default:0"`
+}
+
+// ProjectWorkflowTransition allows tasks to move from one workflow state to another. A workflow
+// without transitions allows every move.
+type ProjectWorkflowTransition struct {
+	gorm.Model
+	ProjectID uint   `gorm:"not null;index"`
+	FromState string `gorm:"not null;type:varchar(50)"`
+	ToState   string `gorm:"not null;type:varchar(50)"`
+}
+
 // UserProject represents the many-to-many relationship between users and projects
 type UserProject struct {
 	UserID    uint      `gorm:"primaryKey;index"`
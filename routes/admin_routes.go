@@ -35,6 +35,9 @@ func SetupAdminRoutes(r *gin.Engine, db *gorm.DB) {
 		// System health
 		adminGroup.GET("/system-health", adminHandler.GetSystemHealth)
 
+		// AI usage, cost and budgets
+		adminGroup.GET("/ai-usage", adminHandler.GetAIUsage)
+
 		// Checklist endpoints
 		adminGroup.GET("/checklist/status", adminHandler.GetChecklistStatus)
 		adminGroup.POST("/checklist/update", adminHandler.UpdateChecklistItem)
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"project-x/handlers"
+	"project-x/middleware"
+	"project-x/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SetupAIDigestRoutes sets up the AI daily standup and weekly project digest routes and starts
+// writing them on schedule
+func SetupAIDigestRoutes(r *gin.Engine, db *gorm.DB, wsService *services.WebSocketService, notificationService *services.NotificationService) {
+	digestService := services.NewAIDigestService(db, notificationService, wsService)
+	digestService.StartDigestSweep(15 * time.Minute)
+	digestHandler := handlers.NewAIDigestHandler(db, digestService)
+
+	digestGroup := r.Group("/api/ai/digests")
+	digestGroup.Use(middleware.AuthMiddleware(db)) // All routes require authentication
+
+	// My daily standups (all users)
+	digestGroup.GET("/standups", digestHandler.GetMyStandups)
+
+	// A project's archive of weekly digests and member standups (project members, Manager+, Admin)
+	digestGroup.GET("/project/:projectId", digestHandler.GetProjectDigests)
+
+	// Write a digest of the project's past week now (Manager/Admin only)
+	digestGroup.POST("/project/:projectId/generate", middleware.RequireManagerOrHigher(), digestHandler.GenerateProjectDigest)
+}
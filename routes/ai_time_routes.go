@@ -39,4 +39,18 @@ func SetupAITimeRoutes(r *gin.Engine, db *gorm.DB) {
 
 	// 📊 **مقاييس أداء الذكاء الاصطناعي** - AI Performance Metrics (Manager+, HR, Admin only)
 	aiTimeGroup.GET("/performance", aiTimeHandler.GetAIPerformanceMetrics)
+
+	// ⏱️ **تقدير المدة محلياً** - Local Duration Estimate without an AI call (Task assignee, Manager+, HR, Admin)
+	aiTimeGroup.GET("/estimate/:taskId", aiTimeHandler.GetTaskDurationEstimate)
+
+	// 📐 **نموذج تقدير المدة** - Duration Estimator Models and Accuracy (Manager+, HR, Admin only)
+	aiTimeGroup.GET("/estimator", aiTimeHandler.GetDurationEstimatorSummary)
+
+	// 🧪 **متغيرات التحليل** - A/B Model and Prompt Variants (View: Manager+, HR, Admin; Configure: Admin only)
+	aiTimeGroup.GET("/variants", aiTimeHandler.GetAnalysisVariants)
+	aiTimeGroup.PUT("/variants", aiTimeHandler.UpdateAnalysisVariants)
+	aiTimeGroup.DELETE("/variants", aiTimeHandler.ResetAnalysisVariants)
+
+	// 🎯 **تقييم الدقة** - Accuracy Evaluation by Variant (Manager+, HR, Admin only)
+	aiTimeGroup.GET("/evaluation", aiTimeHandler.GetAIEvaluationReport)
 }
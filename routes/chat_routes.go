@@ -1,9 +1,11 @@
 package routes
 
 import (
+	"log"
 	"project-x/handlers"
 	"project-x/middleware"
 	"project-x/services"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -17,9 +19,20 @@ func SetupChatRoutes(r *gin.Engine, db *gorm.DB, wsService *services.WebSocketSe
 	// Create AI chat service
 	aiChatService := services.NewAIChatService(db, taskService)
 
+	// Drop the plaintext arguments of confidential actions logged before they were encrypted
+	if err := aiChatService.RedactConfidentialActions(); err != nil {
+		log.Printf("Failed to redact confidential AI chat actions: %v", err)
+	}
+
+	// Index project knowledge for the AI assistant to retrieve and cite
+	knowledgeService := services.NewKnowledgeService(db)
+	knowledgeService.StartIndexSweep(10 * time.Minute)
+	aiChatService.Knowledge = knowledgeService
+
 	// Create chat service and handler
 	chatService := services.NewChatService(db, wsService, notificationService)
 	chatService.SetAIChatService(aiChatService) // Connect AI chat service
+	wsService.SetAIChatService(aiChatService)   // Lets WebSocket clients stop an AI answer
 	chatHandler := handlers.NewChatHandler(db, chatService)
 
 	// Create AI chat handler
@@ -39,6 +52,9 @@ func SetupChatRoutes(r *gin.Engine, db *gorm.DB, wsService *services.WebSocketSe
 		// Get or create the main team chat room (auto-joins user)
 		chatAPI.GET("/team-chat", chatHandler.GetOrCreateTeamChat)
 
+		// Get or create a project's team room (project members, Admins and Managers; auto-joins user)
+		chatAPI.GET("/projects/:projectId/room", chatHandler.GetOrCreateProjectChat)
+
 		// Send a message to team chat
 		chatAPI.POST("/rooms/:roomId/messages", chatHandler.SendMessage)
 
@@ -56,6 +72,14 @@ func SetupChatRoutes(r *gin.Engine, db *gorm.DB, wsService *services.WebSocketSe
 
 		// Process AI message (can be used for direct API calls)
 		chatAPI.POST("/ai/rooms/:roomId/message", aiChatHandler.ProcessAIMessage)
+
+		// Stop the AI answer being generated in a room
+		chatAPI.POST("/ai/rooms/:roomId/cancel", aiChatHandler.CancelAIGeneration)
+
+		// Changes the AI proposed wait here until the user confirms or cancels them
+		chatAPI.GET("/ai/rooms/:roomId/actions", aiChatHandler.GetPendingAIActions)
+		chatAPI.POST("/ai/actions/:actionId/confirm", aiChatHandler.ConfirmAIAction)
+		chatAPI.POST("/ai/actions/:actionId/cancel", aiChatHandler.CancelAIAction)
 	}
 
 	// WebSocket status endpoint
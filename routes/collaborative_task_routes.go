@@ -3,13 +3,14 @@ package routes
 import (
 	"project-x/handlers"
 	"project-x/middleware"
+	"project-x/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func SetupCollaborativeTaskRoutes(r *gin.Engine, db *gorm.DB) {
-	collaborativeTaskHandler := handlers.NewCollaborativeTaskHandler(db)
+func SetupCollaborativeTaskRoutes(r *gin.Engine, db *gorm.DB, notificationService *services.NotificationService) {
+	collaborativeTaskHandler := handlers.NewCollaborativeTaskHandler(db, notificationService)
 
 	collaborativeTaskGroup := r.Group("/api/collaborative-tasks")
 	collaborativeTaskGroup.Use(middleware.AuthMiddleware(db))
@@ -34,5 +35,16 @@ func SetupCollaborativeTaskRoutes(r *gin.Engine, db *gorm.DB) {
 
 		// Update task progress (participants only)
 		collaborativeTaskGroup.PATCH("/:id/progress", collaborativeTaskHandler.UpdateTaskProgress)
+
+		// Checklist items (lead manages items, assignees check off their own)
+		collaborativeTaskGroup.GET("/:id/items", collaborativeTaskHandler.GetTaskItems)
+		collaborativeTaskGroup.POST("/:id/items", collaborativeTaskHandler.AddTaskItem)
+		collaborativeTaskGroup.PUT("/:id/items/:itemId", collaborativeTaskHandler.UpdateTaskItem)
+		collaborativeTaskGroup.PATCH("/:id/items/:itemId/complete", collaborativeTaskHandler.SetTaskItemCompleted)
+		collaborativeTaskGroup.DELETE("/:id/items/:itemId", collaborativeTaskHandler.DeleteTaskItem)
+
+		// Review stage: reviewers approve or request changes on tasks in review
+		collaborativeTaskGroup.POST("/:id/review", collaborativeTaskHandler.ReviewTask)
+		collaborativeTaskGroup.GET("/:id/reviews", collaborativeTaskHandler.GetTaskReviews)
 	}
 }
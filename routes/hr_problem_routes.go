@@ -1,9 +1,11 @@
 package routes
 
 import (
+	"log"
 	"project-x/handlers"
 	"project-x/middleware"
 	"project-x/services"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -14,6 +16,48 @@ func SetupHRProblemRoutes(r *gin.Engine, db *gorm.DB, notificationService *servi
 	hrProblemService := services.NewHRProblemService(db, notificationService)
 	hrProblemHandler := handlers.NewHRProblemHandler(db, hrProblemService)
 
+	// Encrypt reports stored before HR content encryption was enabled
+	go func() {
+		encrypted, err := hrProblemService.EncryptLegacyProblems()
+		if err != nil {
+			log.Printf("Failed to encrypt existing HR problem content: %v", err)
+		} else if encrypted > 0 {
+			log.Printf("Encrypted content of %d existing HR problems", encrypted)
+		}
+	}()
+
+	// Anonymous reports filed before anonymous intake existed still name their reporter
+	if unlinked, err := hrProblemService.UnlinkLegacyAnonymousProblems(); err != nil {
+		log.Printf("Failed to unlink existing anonymous HR problems: %v", err)
+	} else if unlinked > 0 {
+		log.Printf("Removed the reporter from %d existing anonymous HR problems", unlinked)
+	}
+
+	// Warn HR and escalate to admins when SLA targets are at risk or breached
+	hrProblemService.StartSLASweep(5 * time.Minute)
+
+	// Remind HR about follow-ups that have come due
+	hrProblemService.StartFollowUpSweep(15 * time.Minute)
+
+	// Anonymous reporting endpoints (no authentication, nothing about the caller is stored)
+	anonymousAPI := r.Group("/api/hr-problems/anonymous")
+	{
+		// Report a problem anonymously and receive a case code and passphrase
+		anonymousAPI.POST("", hrProblemHandler.CreateAnonymousProblem)
+
+		// View a case with its case code and passphrase
+		anonymousAPI.POST("/case", hrProblemHandler.GetAnonymousCase)
+
+		// Reply on a case with its case code and passphrase
+		anonymousAPI.POST("/case/comments", hrProblemHandler.AddAnonymousComment)
+
+		// Upload evidence to a case (multipart form with case_code and passphrase)
+		anonymousAPI.POST("/case/attachments", hrProblemHandler.UploadAnonymousAttachments)
+
+		// Download evidence from a case with its case code and passphrase
+		anonymousAPI.POST("/case/attachments/:attachmentId", hrProblemHandler.DownloadAnonymousAttachment)
+	}
+
 	// HR Problem API routes
 	hrProblemAPI := r.Group("/api/hr-problems")
 	hrProblemAPI.Use(middleware.AuthMiddleware(db)) // All endpoints require authentication
@@ -34,6 +78,12 @@ func SetupHRProblemRoutes(r *gin.Engine, db *gorm.DB, notificationService *servi
 
 		// Add comment to a problem (users can comment on their own problems)
 		hrProblemAPI.POST("/:id/comments", hrProblemHandler.AddComment)
+
+		// Upload evidence to a problem or comment (reporter or HR/Admin)
+		hrProblemAPI.POST("/:id/attachments", hrProblemHandler.UploadAttachments)
+
+		// Download evidence (reporter or HR/Admin, every download is logged)
+		hrProblemAPI.GET("/:id/attachments/:attachmentId", hrProblemHandler.DownloadAttachment)
 	}
 
 	// HR and Admin only endpoints
@@ -60,5 +110,35 @@ func SetupHRProblemRoutes(r *gin.Engine, db *gorm.DB, notificationService *servi
 
 		// Get statistics
 		hrOnlyAPI.GET("/statistics", hrProblemHandler.GetStatistics)
+
+		// Monthly trend report (JSON, CSV or PDF) with small-count suppression
+		hrOnlyAPI.GET("/reports/trends", hrProblemHandler.GetTrendReport)
+
+		// Get SLA policies
+		hrOnlyAPI.GET("/sla-policies", hrProblemHandler.GetSLAPolicies)
+
+		// Get auto-assignment rules
+		hrOnlyAPI.GET("/assignment-rules", hrProblemHandler.GetAssignmentRules)
+
+		// Follow-ups due queue
+		hrOnlyAPI.GET("/follow-ups/due", hrProblemHandler.GetDueFollowUps)
+
+		// Schedule, complete and cancel follow-ups
+		hrOnlyAPI.POST("/:id/follow-ups", hrProblemHandler.ScheduleFollowUp)
+		hrOnlyAPI.POST("/:id/follow-ups/:followUpId/complete", hrProblemHandler.CompleteFollowUp)
+		hrOnlyAPI.DELETE("/:id/follow-ups/:followUpId", hrProblemHandler.CancelFollowUp)
+	}
+
+	// Admin only endpoints
+	adminAPI := hrProblemAPI.Group("")
+	adminAPI.Use(middleware.RequireAdmin())
+	{
+		// Change SLA targets for a priority
+		adminAPI.PUT("/sla-policies/:priority", hrProblemHandler.UpdateSLAPolicy)
+
+		// Manage auto-assignment rules
+		adminAPI.POST("/assignment-rules", hrProblemHandler.CreateAssignmentRule)
+		adminAPI.PUT("/assignment-rules/:ruleId", hrProblemHandler.UpdateAssignmentRule)
+		adminAPI.DELETE("/assignment-rules/:ruleId", hrProblemHandler.DeleteAssignmentRule)
 	}
 }
@@ -1,22 +1,33 @@
 package routes
 
 import (
+	"log"
 	"project-x/handlers"
 	"project-x/middleware"
+	"project-x/services"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 // SetupPasswordManagerRoutes sets up all password manager routes
-func SetupPasswordManagerRoutes(r *gin.Engine, db *gorm.DB) {
-	passwordManagerHandler, err := handlers.NewPasswordManagerHandler(db)
+func SetupPasswordManagerRoutes(r *gin.Engine, db *gorm.DB, notificationService *services.NotificationService) {
+	passwordManagerHandler, err := handlers.NewPasswordManagerHandler(db, notificationService)
 	if err != nil {
 		// Log error but don't fail - encryption service will fail on first use if key is missing
 		// This allows the app to start but password manager endpoints will fail gracefully
 		return
 	}
 
+	// Revoke time-limited shares once they expire
+	passwordManagerHandler.PasswordManagerService.StartExpirySweep(time.Minute)
+
+	// Remove account emails from import/export audit entries recorded before they were left out
+	if err := passwordManagerHandler.PasswordManagerService.RedactBulkOperationEmails(); err != nil {
+		log.Printf("Failed to redact emails from credential import/export audit entries: %v", err)
+	}
+
 	// Admin routes - all require Admin role
 	adminGroup := r.Group("/api/password-manager")
 	adminGroup.Use(middleware.AuthMiddleware(db))
@@ -33,8 +44,39 @@ func SetupPasswordManagerRoutes(r *gin.Engine, db *gorm.DB) {
 		adminGroup.POST("/credentials/:id/share", passwordManagerHandler.ShareCredential)
 		adminGroup.DELETE("/credentials/:id/share/:userId", passwordManagerHandler.UnshareCredential)
 
+		// Vaults (collections of credentials shared with users, departments or roles)
+		adminGroup.POST("/vaults", passwordManagerHandler.CreateVault)
+		adminGroup.GET("/vaults", passwordManagerHandler.GetVaults)
+		adminGroup.GET("/vaults/:id", passwordManagerHandler.GetVault)
+		adminGroup.PUT("/vaults/:id", passwordManagerHandler.UpdateVault)
+		adminGroup.DELETE("/vaults/:id", passwordManagerHandler.DeleteVault)
+		adminGroup.POST("/vaults/:id/credentials", passwordManagerHandler.AddCredentialsToVault)
+		adminGroup.DELETE("/vaults/:id/credentials/:credentialId", passwordManagerHandler.RemoveCredentialFromVault)
+		adminGroup.POST("/vaults/:id/grants", passwordManagerHandler.GrantVaultAccess)
+		adminGroup.DELETE("/vaults/:id/grants/:grantId", passwordManagerHandler.RevokeVaultGrant)
+
+		// TOTP (2FA) secrets
+		adminGroup.PUT("/credentials/:id/totp", passwordManagerHandler.SetCredentialTOTP)
+		adminGroup.DELETE("/credentials/:id/totp", passwordManagerHandler.RemoveCredentialTOTP)
+
 		// Access logs (Admin only)
 		adminGroup.GET("/credentials/:id/logs", passwordManagerHandler.GetAccessLogs)
+
+		// Password generation, strength checking and health reporting
+		adminGroup.POST("/generate-password", passwordManagerHandler.GeneratePassword)
+		adminGroup.POST("/check-password", passwordManagerHandler.CheckPasswordStrength)
+		adminGroup.GET("/breached-range/:prefix", passwordManagerHandler.GetBreachedRange)
+		adminGroup.GET("/reports/password-health", passwordManagerHandler.GetPasswordHealthReport)
+
+		// Import / export
+		adminGroup.POST("/import", passwordManagerHandler.ImportCredentials)
+		adminGroup.POST("/export", passwordManagerHandler.ExportCredentials)
+		adminGroup.GET("/bulk-operations", passwordManagerHandler.GetBulkOperations)
+
+		// Just-in-time access requests
+		adminGroup.GET("/access-requests", passwordManagerHandler.GetAccessRequests)
+		adminGroup.POST("/access-requests/:id/approve", passwordManagerHandler.ApproveAccessRequest)
+		adminGroup.POST("/access-requests/:id/deny", passwordManagerHandler.DenyAccessRequest)
 	}
 
 	// User routes - accessible to all authenticated users
@@ -45,5 +87,12 @@ func SetupPasswordManagerRoutes(r *gin.Engine, db *gorm.DB) {
 		userGroup.GET("/my-credentials", passwordManagerHandler.GetMyCredentials)
 		userGroup.GET("/my-credentials/:id", passwordManagerHandler.GetCredentialDetails)
 		userGroup.POST("/my-credentials/:id/copy", passwordManagerHandler.CopyPassword)
+		userGroup.GET("/my-credentials/:id/totp", passwordManagerHandler.GetTOTPCode)
+		userGroup.GET("/my-vaults", passwordManagerHandler.GetMyVaults)
+
+		// Request temporary access to a credential
+		userGroup.GET("/requestable-credentials", passwordManagerHandler.GetRequestableCredentials)
+		userGroup.POST("/requestable-credentials/:id/request", passwordManagerHandler.RequestAccess)
+		userGroup.GET("/my-access-requests", passwordManagerHandler.GetMyAccessRequests)
 	}
 }
@@ -35,15 +35,35 @@ func SetupProjectRoutes(router *gin.Engine, db *gorm.DB) {
 		// Update project status (Manager/Admin only)
 		projects.PATCH("/:id/status", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.UpdateProjectStatus)
 
+		// Get the project's task workflow (all authenticated users)
+		projects.GET("/:id/workflow", middleware.AuthMiddleware(db), projectHandler.GetProjectWorkflow)
+
+		// Replace the project's task workflow (Manager/Admin only)
+		projects.PUT("/:id/workflow", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.UpdateProjectWorkflow)
+
+		// Reset the project to the default task statuses (Manager/Admin only)
+		projects.DELETE("/:id/workflow", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.ResetProjectWorkflow)
+
 		// Get project statistics (project members only)
 		projects.GET("/:id/statistics", middleware.AuthMiddleware(db), projectHandler.GetProjectStatistics)
 
-		// Generate tasks with AI (Manager/Admin only) - Returns preview only
+		// Generate tasks with AI (Manager/Admin only) - Returns a plan draft to review
 		projects.POST("/:id/generate-tasks", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.GenerateProjectTasksWithAI)
 
 		// Confirm and create tasks after review (Manager/Admin only)
 		projects.POST("/:id/confirm-tasks", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.ConfirmAndCreateProjectTasks)
 
+		// AI plan drafts (Manager/Admin only)
+		projects.GET("/:id/plan-drafts", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.GetPlanDrafts)
+		projects.GET("/:id/plan-drafts/:draftId", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.GetPlanDraft)
+		projects.GET("/:id/plan-drafts/:draftId/diff", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.DiffPlanDraftVersions)
+		projects.POST("/:id/plan-drafts/:draftId/tasks", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.AddPlanTask)
+		projects.PATCH("/:id/plan-drafts/:draftId/tasks/:taskKey", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.UpdatePlanTask)
+		projects.DELETE("/:id/plan-drafts/:draftId/tasks/:taskKey", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.RemovePlanTask)
+		projects.POST("/:id/plan-drafts/:draftId/regenerate", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.RegeneratePlanTasks)
+		projects.POST("/:id/plan-drafts/:draftId/confirm", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.ConfirmPlanDraft)
+		projects.DELETE("/:id/plan-drafts/:draftId", middleware.AuthMiddleware(db), middleware.RequireManagerOrHigher(), projectHandler.DiscardPlanDraft)
+
 		// Delete project (Admin only)
 		projects.DELETE("/:id", middleware.AuthMiddleware(db), middleware.RequireAdmin(), projectHandler.DeleteProject)
 	}
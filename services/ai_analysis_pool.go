@@ -0,0 +1,571 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"project-x/models"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+const (
+	// aiAnalysisFreshFor is how long a stored task analysis is served instead of asking the AI again
+	aiAnalysisFreshFor = time.Hour
+	// aiAnalysisServeFor is how old a stored analysis dashboards, workloads and alerts still
+	// accept; only tasks without one are analyzed
+	aiAnalysisServeFor = 24 * time.Hour
+	// aiRateLimitWait is the longest an AI call waits for the rate limiter before giving up
+	aiRateLimitWait = 30 * time.Second
+)
+
+// aiAnalysisSettings tunes the analysis pool; see env.example
+type aiAnalysisSettings struct {
+	workers           int     // Batches analyzed concurrently
+	batchSize         int     // Tasks per AI request
+	requestsPerMinute float64 // Shared limit on AI requests
+}
+
+func loadAIAnalysisSettings() aiAnalysisSettings {
+	settings := aiAnalysisSettings{workers: 4, batchSize: 5, requestsPerMinute: 15}
+	if n, err := strconv.Atoi(os.Getenv("AI_ANALYSIS_WORKERS")); err == nil && n > 0 {
+		settings.workers = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("AI_ANALYSIS_BATCH_SIZE")); err == nil && n > 0 {
+		settings.batchSize = min(n, 10)
+	}
+	if n, err := strconv.ParseFloat(os.Getenv("AI_REQUESTS_PER_MINUTE"), 64); err == nil && n > 0 {
+		settings.requestsPerMinute = n
+	}
+	return settings
+}
+
+var (
+	aiSettingsOnce sync.Once
+	aiSettings     aiAnalysisSettings
+	aiRateLimiter  *tokenBucket
+)
+
+// sharedAISettings returns the pool settings and the process-wide rate limiter. Handlers create
+// their own AITimeOptimizer, so the limiter has to be shared to cap the total request rate.
+func sharedAISettings() (aiAnalysisSettings, *tokenBucket) {
+	aiSettingsOnce.Do(func() {
+		aiSettings = loadAIAnalysisSettings()
+		aiRateLimiter = newTokenBucket(aiSettings.requestsPerMinute/60, aiSettings.requestsPerMinute/6)
+	})
+	return aiSettings, aiRateLimiter
+}
+
+// tokenBucket is a token-bucket rate limiter: tokens refill at a steady rate up to a burst
+// capacity, and each request takes one
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64 // Tokens per second
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate, capacity float64) *tokenBucket {
+	capacity = max(capacity, 1)
+	return &tokenBucket{rate: rate, capacity: capacity, tokens: capacity, last: time.Now()}
+}
+
+// Wait blocks until a token is available or the context is done
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// generate sends a prompt to the named model, answering with JSON matching the schema, once the
+// shared rate limiter allows it. Calls are metered for the organization, as optimizer analyses
+// are not made for any one user.
+func (a *AITimeOptimizer) generate(ctx context.Context, modelName string, feature AIFeature, schema *genai.Schema, prompt string) (*genai.GenerateContentResponse, error) {
+	if a.model == nil {
+		return nil, fmt.Errorf("AI service not available")
+	}
+
+	usage := NewAIUsageService(a.DB)
+	if err := usage.CheckBudget(feature, nil); err != nil {
+		return nil, err
+	}
+
+	_, limiter := sharedAISettings()
+	waitCtx, cancel := context.WithTimeout(ctx, aiRateLimitWait)
+	defer cancel()
+	if err := limiter.Wait(waitCtx); err != nil {
+		return nil, errors.New("AI rate limit reached, try again shortly")
+	}
+
+	return usage.Generate(ctx, withResponseSchema(a.modelFor(modelName), schema), modelName, feature, nil, prompt)
+}
+
+// analysisBatch is a group of tasks analyzed together with one variant
+type analysisBatch struct {
+	variant AnalysisVariant
+	tasks   []models.Task
+}
+
+// analysisCall is an analysis in progress that other requests for the same task wait on
+type analysisCall struct {
+	done     chan struct{}
+	analysis *TimeAnalysis
+	err      error
+}
+
+// TaskAnalysisFailure is a task that could not be analyzed, with the problems found in the AI's
+// answer when it was unusable
+type TaskAnalysisFailure struct {
+	TaskID    uint                `json:"task_id"`
+	TaskTitle string              `json:"task_title"`
+	Error     string              `json:"error"`
+	Issues    []AIValidationIssue `json:"validation_issues,omitempty"`
+}
+
+// inflightAnalyses coalesces concurrent requests for the same task into one AI analysis
+var inflightAnalyses = struct {
+	sync.Mutex
+	calls map[uint]*analysisCall
+}{calls: make(map[uint]*analysisCall)}
+
+// activeTaskAnalyses analyzes the pending and in-progress tasks, optionally of one user, reusing
+// stored analyses up to maxAge old, and reports the tasks that could not be analyzed. Without the
+// AI service only stored analyses are returned.
+func (a *AITimeOptimizer) activeTaskAnalyses(userID *uint, maxAge time.Duration) ([]models.Task, map[uint]*TimeAnalysis, []TaskAnalysisFailure, error) {
+	query := a.DB.Where("status IN ?", []string{"pending", "in_progress"})
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	var tasks []models.Task
+	if err := query.Preload("User").Preload("Project").Order("id ASC").Find(&tasks).Error; err != nil {
+		return nil, nil, nil, err
+	}
+
+	results, errs := a.analyzeTasks(tasks, maxAge)
+	if a.model == nil && len(results) == 0 && len(tasks) > 0 {
+		return nil, nil, nil, fmt.Errorf("AI service not available")
+	}
+	for taskID, err := range errs {
+		log.Printf("Error analyzing task %d: %v", taskID, err)
+	}
+
+	return tasks, results, analysisFailures(tasks, errs), nil
+}
+
+// analysisFailures lists the tasks with an analysis error, in task order
+func analysisFailures(tasks []models.Task, errs map[uint]error) []TaskAnalysisFailure {
+	var failures []TaskAnalysisFailure
+	for _, task := range tasks {
+		err := errs[task.ID]
+		if err == nil {
+			continue
+		}
+
+		failure := TaskAnalysisFailure{TaskID: task.ID, TaskTitle: task.Title, Error: err.Error()}
+		var validationErr *AIValidationError
+		if errors.As(err, &validationErr) {
+			failure.Issues = validationErr.Issues
+		}
+		failures = append(failures, failure)
+	}
+	return failures
+}
+
+// analyzeTasks returns an analysis for each task. Analyses stored within maxAge are served from
+// the database; a task already being analyzed by another request is waited on; the rest are
+// assigned to A/B variants and split into per-variant batches that a pool of workers sends to
+// the AI under the shared rate limit.
+func (a *AITimeOptimizer) analyzeTasks(tasks []models.Task, maxAge time.Duration) (map[uint]*TimeAnalysis, map[uint]error) {
+	results := a.storedAnalyses(tasks, maxAge)
+	errs := make(map[uint]error)
+
+	var pending []models.Task
+	for _, task := range tasks {
+		if results[task.ID] == nil {
+			pending = append(pending, task)
+		}
+	}
+	if len(pending) == 0 {
+		return results, errs
+	}
+	if a.model == nil {
+		for _, task := range pending {
+			errs[task.ID] = fmt.Errorf("AI service not available")
+		}
+		return results, errs
+	}
+
+	// Over budget, serve the latest stored analysis however old it is
+	if err := NewAIUsageService(a.DB).CheckBudget(AIFeatureTimeAnalysis, nil); err != nil {
+		stale := a.storedAnalyses(pending, 0)
+		for _, task := range pending {
+			if analysis := stale[task.ID]; analysis != nil {
+				results[task.ID] = analysis
+				continue
+			}
+			errs[task.ID] = err
+		}
+		return results, errs
+	}
+
+	owned, calls := claimAnalyses(pending)
+	if len(owned) > 0 {
+		settings, _ := sharedAISettings()
+		batches := splitAnalysisBatches(owned, NewAIExperimentService(a.DB).AssignVariants(owned), settings.batchSize)
+
+		queue := make(chan analysisBatch)
+		var wg sync.WaitGroup
+		for i := 0; i < min(settings.workers, len(batches)); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for batch := range queue {
+					a.runAnalysisBatch(batch)
+				}
+			}()
+		}
+		for _, batch := range batches {
+			queue <- batch
+		}
+		close(queue)
+		wg.Wait()
+	}
+
+	for taskID, call := range calls {
+		<-call.done
+		if call.err != nil {
+			errs[taskID] = call.err
+			continue
+		}
+		results[taskID] = call.analysis
+	}
+
+	return results, errs
+}
+
+// storedAnalyses returns the latest stored analysis of each task made within maxAge, or of any
+// age when maxAge is 0
+func (a *AITimeOptimizer) storedAnalyses(tasks []models.Task, maxAge time.Duration) map[uint]*TimeAnalysis {
+	results := make(map[uint]*TimeAnalysis)
+	if len(tasks) == 0 {
+		return results
+	}
+
+	taskIDs := make([]uint, 0, len(tasks))
+	byID := make(map[uint]models.Task, len(tasks))
+	for _, task := range tasks {
+		taskIDs = append(taskIDs, task.ID)
+		byID[task.ID] = task
+	}
+
+	query := a.DB.Where("task_id IN ? AND task_type = ?", taskIDs, "regular")
+	if maxAge > 0 {
+		query = query.Where("created_at > ?", time.Now().Add(-maxAge))
+	}
+
+	var records []models.AIAnalysis
+	err := query.Order("created_at DESC").Find(&records).Error
+	if err != nil {
+		log.Printf("⚠️ Warning: Failed to load stored AI analyses: %v", err)
+		return results
+	}
+
+	for _, record := range records {
+		if results[record.TaskID] == nil {
+			results[record.TaskID] = a.timeAnalysisFromRecord(record, byID[record.TaskID])
+		}
+	}
+	return results
+}
+
+// splitAnalysisBatches groups tasks by their assigned variant, in variant name order, and splits
+// each group into batches of at most batchSize
+func splitAnalysisBatches(tasks []models.Task, assignments map[uint]AnalysisVariant, batchSize int) []analysisBatch {
+	groups := make(map[string]*analysisBatch)
+	var names []string
+	for _, task := range tasks {
+		variant := assignments[task.ID]
+		group, ok := groups[variant.Name]
+		if !ok {
+			group = &analysisBatch{variant: variant}
+			groups[variant.Name] = group
+			names = append(names, variant.Name)
+		}
+		group.tasks = append(group.tasks, task)
+	}
+	sort.Strings(names)
+
+	var batches []analysisBatch
+	for _, name := range names {
+		group := groups[name]
+		for start := 0; start < len(group.tasks); start += batchSize {
+			batches = append(batches, analysisBatch{
+				variant: group.variant,
+				tasks:   group.tasks[start:min(start+batchSize, len(group.tasks))],
+			})
+		}
+	}
+	return batches
+}
+
+// runAnalysisBatch analyzes a batch of claimed tasks and releases their calls. Tasks the batched
+// response leaves out or gets wrong are retried one at a time.
+func (a *AITimeOptimizer) runAnalysisBatch(batch analysisBatch) {
+	ctx := context.Background()
+	results := make(map[uint]*TimeAnalysis)
+	errs := make(map[uint]error)
+
+	if len(batch.tasks) > 1 {
+		batchResults, err := a.analyzeTaskBatch(ctx, batch.tasks, batch.variant)
+		if err != nil {
+			log.Printf("⚠️ Batched analysis of %d tasks failed, analyzing individually: %v", len(batch.tasks), err)
+		}
+		for taskID, analysis := range batchResults {
+			results[taskID] = analysis
+		}
+	}
+
+	for _, task := range batch.tasks {
+		if results[task.ID] != nil {
+			continue
+		}
+		analysis, err := a.analyzeSingleTask(ctx, task, batch.variant)
+		if err != nil {
+			errs[task.ID] = err
+			continue
+		}
+		results[task.ID] = analysis
+	}
+
+	releaseAnalyses(batch.tasks, results, errs)
+}
+
+// claimAnalyses claims the tasks nobody is analyzing yet, returning them, and the call to wait on
+// for every task, claimed or already in progress
+func claimAnalyses(tasks []models.Task) ([]models.Task, map[uint]*analysisCall) {
+	var owned []models.Task
+	calls := make(map[uint]*analysisCall)
+	inflightAnalyses.Lock()
+	defer inflightAnalyses.Unlock()
+	for _, task := range tasks {
+		if call, ok := inflightAnalyses.calls[task.ID]; ok {
+			calls[task.ID] = call
+			continue
+		}
+		call := &analysisCall{done: make(chan struct{})}
+		inflightAnalyses.calls[task.ID] = call
+		calls[task.ID] = call
+		owned = append(owned, task)
+	}
+	return owned, calls
+}
+
+// releaseAnalyses hands the outcome of claimed tasks to everyone waiting on them, so the next
+// request for the same task starts a new analysis
+func releaseAnalyses(tasks []models.Task, results map[uint]*TimeAnalysis, errs map[uint]error) {
+	inflightAnalyses.Lock()
+	defer inflightAnalyses.Unlock()
+	for _, task := range tasks {
+		call := inflightAnalyses.calls[task.ID]
+		call.analysis, call.err = results[task.ID], errs[task.ID]
+		delete(inflightAnalyses.calls, task.ID)
+		close(call.done)
+	}
+}
+
+// analyzeTaskBatch analyzes several tasks with one AI request using a variant's model and prompt,
+// and saves the results. An answer that fails validation is sent back once with its problems;
+// tasks still missing from it or with an unrepairable analysis are left out of the returned map.
+func (a *AITimeOptimizer) analyzeTaskBatch(ctx context.Context, tasks []models.Task, variant AnalysisVariant) (map[uint]*TimeAnalysis, error) {
+	type taskContext struct {
+		task                      models.Task
+		workingHoursUntilDeadline float64
+		isWithinWorkingHours      bool
+	}
+
+	contexts := make(map[uint]taskContext, len(tasks))
+	sections := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		historicalData, err := a.getHistoricalTaskData(task)
+		if err != nil {
+			return nil, err
+		}
+		workingHoursUntilDeadline, isWithinWorkingHours := a.deadlineContext(task)
+		contexts[task.ID] = taskContext{task, workingHoursUntilDeadline, isWithinWorkingHours}
+		section := a.batchTaskSection(task, historicalData, workingHoursUntilDeadline)
+		sections = append(sections, section+strings.TrimRight(a.promptAdditions(task, variant.PromptVersion), "\n"))
+	}
+
+	validate := func(aiResponses *[]taskAnalysisResponse) []AIValidationIssue {
+		var issues []AIValidationIssue
+		answered := make(map[uint]bool, len(tasks))
+		for i, aiResponse := range *aiResponses {
+			switch _, ok := contexts[aiResponse.TaskID]; {
+			case !ok:
+				issues = append(issues, AIValidationIssue{Item: i + 1, Field: "task_id", Message: fmt.Sprintf("%d is not one of the tasks to analyze", aiResponse.TaskID)})
+			case answered[aiResponse.TaskID]:
+				issues = append(issues, AIValidationIssue{Item: i + 1, Field: "task_id", Message: fmt.Sprintf("task %d is analyzed more than once", aiResponse.TaskID)})
+			default:
+				answered[aiResponse.TaskID] = true
+				for _, issue := range a.validateTaskAnalysisResponse(aiResponse, aiResponse.TaskID) {
+					issue.Item = i + 1
+					issues = append(issues, issue)
+				}
+			}
+		}
+		for _, task := range tasks {
+			if !answered[task.ID] {
+				issues = append(issues, AIValidationIssue{TaskID: task.ID, Field: "task_id", Message: "missing from the response"})
+			}
+		}
+		return issues
+	}
+
+	prompt := buildBatchAnalysisPrompt(sections, a.WorkSchedule.IsWorkingHour(time.Now()))
+	aiResponses, issues, err := generateValidated(ctx, func(ctx context.Context, prompt string) (*genai.GenerateContentResponse, error) {
+		return a.generate(ctx, variant.ModelName, AIFeatureTimeAnalysis, batchTaskAnalysisSchema, prompt)
+	}, prompt, validate)
+	if err != nil {
+		return nil, err
+	}
+
+	issuesByTask := make(map[uint][]AIValidationIssue)
+	for _, issue := range issues {
+		issuesByTask[issue.TaskID] = append(issuesByTask[issue.TaskID], issue)
+	}
+
+	results := make(map[uint]*TimeAnalysis, len(tasks))
+	for _, aiResponse := range *aiResponses {
+		tc, ok := contexts[aiResponse.TaskID]
+		if !ok || results[aiResponse.TaskID] != nil {
+			continue
+		}
+		analysis, err := a.parseTaskAnalysisResponse(aiResponse, issuesByTask[aiResponse.TaskID], tc.task)
+		if err != nil {
+			log.Printf("⚠️ Batched analysis of task %d unusable, analyzing individually: %v", tc.task.ID, err)
+			continue
+		}
+		analysis.Variant = &variant
+		a.completeTaskAnalysis(tc.task, analysis, tc.workingHoursUntilDeadline, tc.isWithinWorkingHours)
+		results[tc.task.ID] = analysis
+	}
+
+	return results, nil
+}
+
+// batchTaskSection describes one task of a batched analysis prompt
+func (a *AITimeOptimizer) batchTaskSection(task models.Task, historicalData map[string]interface{}, workingHoursUntilDeadline float64) string {
+	dueDate := "No deadline set"
+	if task.DueDate != nil {
+		dueDate = fmt.Sprintf("%d working days (%.1f working hours)",
+			a.WorkSchedule.GetWorkingDaysUntil(time.Now(), *task.DueDate), workingHoursUntilDeadline)
+	}
+
+	return fmt.Sprintf(`TASK %d:
+- Title: %s
+- Description: %s
+- Status: %s
+- Created: %d calendar days ago
+- Due Date: %s
+- Assigned User: %s
+- User's average task completion: %.1f days
+- User's completion rate: %.1f%%
+- Current workload: %.0f active tasks
+- Similar tasks completed: %.0f
+- Project total/completed/overdue tasks: %.0f/%.0f/%.0f`,
+		task.ID,
+		task.Title,
+		task.Description,
+		task.Status,
+		int(time.Since(task.CreatedAt).Hours()/24),
+		dueDate,
+		task.User.Username,
+		historicalData["user_avg_completion_days"],
+		historicalData["user_completion_rate"],
+		historicalData["current_workload"],
+		historicalData["similar_tasks_count"],
+		historicalData["project_total_tasks"],
+		historicalData["project_completed_tasks"],
+		historicalData["project_overdue_tasks"],
+	)
+}
+
+// buildBatchAnalysisPrompt asks for the analysis of several tasks in one request
+func buildBatchAnalysisPrompt(sections []string, isWithinWorkingHours bool) string {
+	workingTimeStatus := "outside working hours"
+	if isWithinWorkingHours {
+		workingTimeStatus = "within working hours"
+	}
+
+	return fmt.Sprintf(`
+You are an AI project management expert analyzing tasks for an Arabic organization with specific working hours.
+
+WORKING SCHEDULE CONTEXT:
+- Working Days: Saturday to Thursday (6 days per week)
+- Working Hours: 9:00 AM to 4:00 PM (7 hours with 1 hour lunch = 6 effective hours per day)
+- Weekly Capacity: 42 working hours per person
+- Timezone: Asia/Riyadh
+- Current Time Status: %s
+
+%s
+
+For EACH task above (considering the Arabic working schedule, Friday off):
+1. Estimate realistic completion time in working hours (6 hours per day, Saturday-Thursday)
+2. Assess deadline risk level considering only working hours
+3. Identify specific risk factors including working schedule constraints
+4. Provide 3-5 actionable recommendations that respect working hours
+5. Suggest optimal start time within working schedule
+6. Predict actual completion date considering weekends (Friday off)
+
+Respond with a JSON array containing one object per task, in this exact format:
+[
+  {
+    "task_id": <task number>,
+    "estimated_duration_hours": <number>,
+    "deadline_risk": "<low|medium|high|critical>",
+    "risk_factors": ["factor1", "factor2"],
+    "recommendations": ["rec1", "rec2", "rec3"],
+    "optimal_start_date": "<YYYY-MM-DD>",
+    "predicted_completion": "<YYYY-MM-DD>",
+    "confidence_score": <0-100>
+  }
+]`, workingTimeStatus, strings.Join(sections, "\n\n"))
+}
+
+// orderedAnalyses returns the analyses in task order, skipping tasks without one
+func orderedAnalyses(tasks []models.Task, results map[uint]*TimeAnalysis) []TimeAnalysis {
+	analyses := make([]TimeAnalysis, 0, len(results))
+	for _, task := range tasks {
+		if analysis := results[task.ID]; analysis != nil {
+			analyses = append(analyses, *analysis)
+		}
+	}
+	return analyses
+}
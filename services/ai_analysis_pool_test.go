@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"project-x/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func analysisTasks(ids ...uint) []models.Task {
+	tasks := make([]models.Task, len(ids))
+	for i, id := range ids {
+		tasks[i].ID = id
+	}
+	return tasks
+}
+
+func analysisTaskIDs(tasks []models.Task) []uint {
+	var ids []uint
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64 // Tokens per second
+		capacity float64
+		calls    int
+		timeout  time.Duration
+		wantOK   int // Calls that get a token before the timeout
+	}{
+		{name: "a full bucket serves its burst at once", rate: 0.01, capacity: 3, calls: 3, timeout: 50 * time.Millisecond, wantOK: 3},
+		{name: "an empty bucket makes the next call wait", rate: 0.01, capacity: 3, calls: 4, timeout: 50 * time.Millisecond, wantOK: 3},
+		{name: "capacity is at least one token", rate: 0.01, capacity: 0.2, calls: 2, timeout: 50 * time.Millisecond, wantOK: 1},
+		{name: "tokens refill at the rate", rate: 100, capacity: 1, calls: 3, timeout: time.Second, wantOK: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := newTokenBucket(tt.rate, tt.capacity)
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			ok := 0
+			for i := 0; i < tt.calls; i++ {
+				if err := bucket.Wait(ctx); err != nil {
+					if !errors.Is(err, context.DeadlineExceeded) {
+						t.Fatalf("Wait() error = %v, want deadline exceeded", err)
+					}
+					break
+				}
+				ok++
+			}
+			if ok != tt.wantOK {
+				t.Errorf("%d calls got a token, want %d", ok, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestClaimAnalysesCoalescesConcurrentRequests(t *testing.T) {
+	first := analysisTasks(9001, 9002)
+	owned, firstCalls := claimAnalyses(first)
+	if got := analysisTaskIDs(owned); !reflect.DeepEqual(got, []uint{9001, 9002}) {
+		t.Fatalf("first request owns %v, want [9001 9002]", got)
+	}
+
+	// A second request overlapping the first only analyzes the task nobody has claimed
+	second := analysisTasks(9002, 9003)
+	owned, secondCalls := claimAnalyses(second)
+	if got := analysisTaskIDs(owned); !reflect.DeepEqual(got, []uint{9003}) {
+		t.Fatalf("second request owns %v, want [9003]", got)
+	}
+	if secondCalls[9002] != firstCalls[9002] {
+		t.Fatal("second request does not wait on the first request's analysis of task 9002")
+	}
+
+	analysis := &TimeAnalysis{TaskID: 9002}
+	failure := errors.New("AI service not available")
+	releaseAnalyses(first, map[uint]*TimeAnalysis{9002: analysis}, map[uint]error{9001: failure})
+	releaseAnalyses(analysisTasks(9003), map[uint]*TimeAnalysis{9003: {TaskID: 9003}}, nil)
+
+	tests := []struct {
+		taskID       uint
+		wantAnalysis *TimeAnalysis
+		wantErr      error
+	}{
+		{9001, nil, failure},
+		{9002, analysis, nil},
+	}
+	for _, tt := range tests {
+		call := firstCalls[tt.taskID]
+		select {
+		case <-call.done:
+		default:
+			t.Fatalf("call for task %d was not released", tt.taskID)
+		}
+		if call.analysis != tt.wantAnalysis || call.err != tt.wantErr {
+			t.Errorf("task %d = (%v, %v), want (%v, %v)", tt.taskID, call.analysis, call.err, tt.wantAnalysis, tt.wantErr)
+		}
+	}
+	if got := secondCalls[9002].analysis; got != analysis {
+		t.Errorf("waiting request got %v for task 9002, want the first request's analysis", got)
+	}
+
+	// Released tasks are analyzed again by the next request
+	owned, _ = claimAnalyses(first)
+	if got := analysisTaskIDs(owned); !reflect.DeepEqual(got, []uint{9001, 9002}) {
+		t.Errorf("after release the next request owns %v, want [9001 9002]", got)
+	}
+	releaseAnalyses(owned, nil, nil)
+}
+
+func TestSplitAnalysisBatches(t *testing.T) {
+	control := AnalysisVariant{Name: "control"}
+	candidate := AnalysisVariant{Name: "candidate"}
+
+	tests := []struct {
+		name        string
+		tasks       []models.Task
+		assignments map[uint]AnalysisVariant
+		batchSize   int
+		want        [][]uint
+		wantNames   []string
+	}{
+		{
+			name:        "splits one variant into batches of the batch size",
+			tasks:       analysisTasks(1, 2, 3, 4, 5),
+			assignments: map[uint]AnalysisVariant{1: control, 2: control, 3: control, 4: control, 5: control},
+			batchSize:   2,
+			want:        [][]uint{{1, 2}, {3, 4}, {5}},
+			wantNames:   []string{"control", "control", "control"},
+		},
+		{
+			name:        "never mixes variants in a batch and orders them by name",
+			tasks:       analysisTasks(1, 2, 3, 4),
+			assignments: map[uint]AnalysisVariant{1: control, 2: candidate, 3: control, 4: candidate},
+			batchSize:   5,
+			want:        [][]uint{{2, 4}, {1, 3}},
+			wantNames:   []string{"candidate", "control"},
+		},
+		{
+			name:        "no tasks make no batches",
+			tasks:       nil,
+			assignments: nil,
+			batchSize:   5,
+			want:        nil,
+			wantNames:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]uint
+			var names []string
+			for _, batch := range splitAnalysisBatches(tt.tasks, tt.assignments, tt.batchSize) {
+				got = append(got, analysisTaskIDs(batch.tasks))
+				names = append(names, batch.variant.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("batches = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("variants = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
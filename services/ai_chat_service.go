@@ -3,12 +3,14 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"project-x/config"
 	"project-x/models"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/generative-ai-go/genai"
@@ -22,6 +24,16 @@ type AIChatService struct {
 	model        *genai.GenerativeModel
 	WorkSchedule *config.WorkScheduleConfig
 	TaskService  *TaskService
+	Knowledge    *KnowledgeService // Optional; questions are answered without retrieval when nil
+
+	// Answers being generated, by user and room, so they can be cancelled
+	generations   map[string]*aiGeneration
+	generationsMu sync.Mutex
+}
+
+// aiGeneration is an answer being generated
+type aiGeneration struct {
+	cancel context.CancelFunc
 }
 
 type UserChatContext struct {
@@ -35,12 +47,28 @@ type UserChatContext struct {
 	Language        string // "ar" or "en"
 }
 
+// chatToolRounds limits how many rounds of tool calls one message can trigger
+const chatToolRounds = 4
+
+// chatKnowledgePassages is how many knowledge passages are retrieved for a question
+const chatKnowledgePassages = 5
+
+// chatActionTTL is how long a proposed change waits for the user's confirmation
+const chatActionTTL = 15 * time.Minute
+
+var (
+	ErrChatActionNotFound = errors.New("action not found")
+	ErrChatActionResolved = errors.New("action is no longer pending")
+	ErrChatActionExpired  = errors.New("action has expired, ask the assistant again")
+)
+
 type AIResponse struct {
 	Message     string                 `json:"message"`
-	Action      string                 `json:"action"` // "answer", "create_task", "get_analytics", etc.
+	Action      string                 `json:"action"` // "answer", "confirm_required", the tool of a confirmed action, etc.
 	Data        map[string]interface{} `json:"data,omitempty"`
 	Suggestions []string               `json:"suggestions,omitempty"`
 	Language    string                 `json:"language"`
+	Cancelled   bool                   `json:"cancelled,omitempty"` // Generation was stopped; Message holds what was streamed
 }
 
 func NewAIChatService(db *gorm.DB, taskService *TaskService) *AIChatService {
@@ -69,7 +97,7 @@ func NewAIChatService(db *gorm.DB, taskService *TaskService) *AIChatService {
 	}
 
 	// Configure the model for chat (more conversational)
-	model := client.GenerativeModel("gemini-2.0-flash")
+	model := client.GenerativeModel(geminiModelName)
 	model.SetTemperature(0.7) // Higher temperature for more natural conversation
 	model.SetTopP(0.9)
 	model.SetTopK(40)
@@ -86,17 +114,22 @@ func NewAIChatService(db *gorm.DB, taskService *TaskService) *AIChatService {
 
 // GetOrCreateAIChatRoom gets or creates a private AI chat room for a user
 func (a *AIChatService) GetOrCreateAIChatRoom(userID uint) (*models.ChatRoom, error) {
+	return getOrCreateAIChatRoom(a.DB, userID)
+}
+
+// getOrCreateAIChatRoom finds the user's private AI chat room, creating it the first time
+func getOrCreateAIChatRoom(db *gorm.DB, userID uint) (*models.ChatRoom, error) {
 	// Check if AI chat room already exists for this user
 	var room models.ChatRoom
 	roomName := fmt.Sprintf("AI Assistant - %d", userID)
 
-	err := a.DB.Where("name = ? AND created_by = ?", roomName, userID).First(&room).Error
+	err := db.Where("name = ? AND created_by = ?", roomName, userID).First(&room).Error
 	if err == nil {
 		// Room exists, ensure user is a participant
 		var participant models.ChatParticipant
-		if err := a.DB.Where("chat_room_id = ? AND user_id = ?", room.ID, userID).First(&participant).Error; err != nil {
+		if err := db.Where("chat_room_id = ? AND user_id = ?", room.ID, userID).First(&participant).Error; err != nil {
 			// User not in room, add them
-			a.DB.Create(&models.ChatParticipant{
+			db.Create(&models.ChatParticipant{
 				ChatRoomID: room.ID,
 				UserID:     userID,
 				JoinedAt:   time.Now(),
@@ -108,7 +141,7 @@ func (a *AIChatService) GetOrCreateAIChatRoom(userID uint) (*models.ChatRoom, er
 
 	// Get user info
 	var user models.User
-	if err := a.DB.First(&user, userID).Error; err != nil {
+	if err := db.First(&user, userID).Error; err != nil {
 		return nil, fmt.Errorf("user not found: %v", err)
 	}
 
@@ -120,12 +153,12 @@ func (a *AIChatService) GetOrCreateAIChatRoom(userID uint) (*models.ChatRoom, er
 		MaxMembers:  2, // Only user and AI
 	}
 
-	if err := a.DB.Create(&room).Error; err != nil {
+	if err := db.Create(&room).Error; err != nil {
 		return nil, err
 	}
 
 	// Add user as participant
-	a.DB.Create(&models.ChatParticipant{
+	db.Create(&models.ChatParticipant{
 		ChatRoomID: room.ID,
 		UserID:     userID,
 		JoinedAt:   time.Now(),
@@ -160,6 +193,14 @@ func (a *AIChatService) IsAIMessage(message string, roomID uint) bool {
 
 // ProcessAIMessage processes a message and returns AI response
 func (a *AIChatService) ProcessAIMessage(userID uint, message string, roomID uint) (*AIResponse, error) {
+	return a.StreamAIMessage(userID, message, roomID, nil)
+}
+
+// StreamAIMessage is ProcessAIMessage with the answer streamed: onDelta receives each piece of
+// text as the model writes it. A new message in the same room stops the answer still being
+// generated there, as does CancelGeneration; the response then holds the text streamed so far
+// and is marked Cancelled.
+func (a *AIChatService) StreamAIMessage(userID uint, message string, roomID uint, onDelta func(string)) (*AIResponse, error) {
 	if a.model == nil {
 		return &AIResponse{
 			Message:  "AI service is currently unavailable. Please check your GEMINI_API_KEY configuration.",
@@ -169,21 +210,63 @@ func (a *AIChatService) ProcessAIMessage(userID uint, message string, roomID uin
 	}
 
 	// Get user context
-	context, err := a.getUserContext(userID)
+	userContext, err := a.getUserContext(userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user context: %v", err)
 	}
 
 	// Detect language (simple detection)
-	context.Language = a.detectLanguage(message)
+	userContext.Language = a.detectLanguage(message)
+
+	ctx, done := a.startGeneration(userID, roomID)
+	defer done()
+
+	// Process as a question/chat; the model calls tools for anything that reads or changes data
+	return a.processQuestion(ctx, message, userContext, roomID, onDelta)
+}
+
+// CancelGeneration stops the answer being generated for the user in a room. It reports whether
+// one was running.
+func (a *AIChatService) CancelGeneration(userID, roomID uint) bool {
+	a.generationsMu.Lock()
+	defer a.generationsMu.Unlock()
 
-	// Check if it's a command (create task, get analytics, etc.)
-	if response := a.handleCommand(message, context); response != nil {
-		return response, nil
+	generation, ok := a.generations[generationKey(userID, roomID)]
+	if ok {
+		generation.cancel()
 	}
+	return ok
+}
+
+// startGeneration registers a cancellable answer for the user's room, stopping the one already
+// running there. done must be called when the answer is finished.
+func (a *AIChatService) startGeneration(userID, roomID uint) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	generation := &aiGeneration{cancel: cancel}
+	key := generationKey(userID, roomID)
+
+	a.generationsMu.Lock()
+	if a.generations == nil {
+		a.generations = map[string]*aiGeneration{}
+	}
+	if previous, ok := a.generations[key]; ok {
+		previous.cancel()
+	}
+	a.generations[key] = generation
+	a.generationsMu.Unlock()
+
+	return ctx, func() {
+		cancel()
+		a.generationsMu.Lock()
+		if a.generations[key] == generation {
+			delete(a.generations, key)
+		}
+		a.generationsMu.Unlock()
+	}
+}
 
-	// Process as a question/chat
-	return a.processQuestion(message, context, roomID)
+func generationKey(userID, roomID uint) string {
+	return fmt.Sprintf("%d:%d", userID, roomID)
 }
 
 // getUserContext gathers user context for AI
@@ -207,7 +290,7 @@ func (a *AIChatService) getUserContext(userID uint) (*UserChatContext, error) {
 
 	projectNames := make([]string, len(projects))
 	for i, p := range projects {
-		projectNames[i] = p.Title
+		projectNames[i] = fmt.Sprintf("%s (project_id %d)", p.Title, p.ID)
 	}
 
 	// Get current workload
@@ -235,6 +318,11 @@ func (a *AIChatService) getUserContext(userID uint) (*UserChatContext, error) {
 
 // detectLanguage detects if message is in Arabic or English
 func (a *AIChatService) detectLanguage(message string) string {
+	return detectTextLanguage(message)
+}
+
+// detectTextLanguage returns "ar" for text containing Arabic letters and "en" otherwise
+func detectTextLanguage(message string) string {
 	// Simple detection: check for Arabic characters
 	for _, r := range message {
 		if r >= 0x0600 && r <= 0x06FF {
@@ -244,72 +332,505 @@ func (a *AIChatService) detectLanguage(message string) string {
 	return "en"
 }
 
-// handleCommand processes commands like "create task", "get analytics", etc.
-func (a *AIChatService) handleCommand(message string, userContext *UserChatContext) *AIResponse {
-	messageLower := strings.ToLower(message)
+// processQuestion answers a message with the AI. In a room only the user takes part in, passages
+// of project knowledge they may see are retrieved for the question and the ones the answer cites
+// are returned with it. Answers in shared rooms are broadcast to every member, so they are given
+// no retrieved knowledge, which other members might not be allowed to see.
+func (a *AIChatService) processQuestion(ctx context.Context, message string, userContext *UserChatContext, roomID uint, onDelta func(string)) (*AIResponse, error) {
+	// Get recent chat history for context
+	recentMessages, _ := a.getRecentChatHistory(roomID, 5)
 
-	// Create task command
-	if strings.Contains(messageLower, "create task") ||
-		strings.Contains(messageLower, "new task") ||
-		strings.Contains(messageLower, "add task") ||
-		strings.Contains(messageLower, "إنشاء مهمة") ||
-		strings.Contains(messageLower, "مهمة جديدة") {
-		return a.handleCreateTaskCommand(message, userContext)
+	var citations []KnowledgeCitation
+	if a.isPrivateRoom(roomID, userContext.UserID) {
+		var err error
+		citations, err = a.Knowledge.Search(ctx, userContext.UserID, models.Role(userContext.Role), message, chatKnowledgePassages)
+		if err != nil {
+			log.Printf("Knowledge search failed, answering without it: %v", err)
+		}
 	}
 
-	// Get tasks command
-	if strings.Contains(messageLower, "my tasks") ||
-		strings.Contains(messageLower, "what tasks") ||
-		strings.Contains(messageLower, "show tasks") ||
-		strings.Contains(messageLower, "مهامي") ||
-		strings.Contains(messageLower, "ما هي المهام") {
-		return a.handleGetTasksCommand(userContext)
+	// Build prompt with context
+	prompt := a.buildChatPrompt(message, userContext, recentMessages, citations)
+
+	response, err := a.converse(ctx, prompt, userContext, roomID, onDelta)
+	if err != nil {
+		return nil, err
 	}
+	if cited := citedIn(response.Message, citations); len(cited) > 0 {
+		if response.Data == nil {
+			response.Data = map[string]interface{}{}
+		}
+		response.Data["citations"] = cited
+	}
+	return response, nil
+}
 
-	// Get analytics/workload command
-	if strings.Contains(messageLower, "workload") ||
-		strings.Contains(messageLower, "capacity") ||
-		strings.Contains(messageLower, "how busy") ||
-		strings.Contains(messageLower, "عبء العمل") ||
-		strings.Contains(messageLower, "كم أنا مشغول") {
-		return a.handleGetWorkloadCommand(userContext)
+// isPrivateRoom reports whether the user is the room's only participant, as in their AI assistant
+// room, so nobody else reads the answers given there
+func (a *AIChatService) isPrivateRoom(roomID, userID uint) bool {
+	var others int64
+	if err := a.DB.Model(&models.ChatParticipant{}).
+		Where("chat_room_id = ? AND user_id <> ?", roomID, userID).
+		Count(&others).Error; err != nil {
+		return false
 	}
+	return others == 0
+}
 
-	return nil // Not a command, process as question
+// citedIn returns the citations the answer refers to as [n]
+func citedIn(message string, citations []KnowledgeCitation) []KnowledgeCitation {
+	var cited []KnowledgeCitation
+	for _, citation := range citations {
+		if strings.Contains(message, fmt.Sprintf("[%d]", citation.Ref)) {
+			cited = append(cited, citation)
+		}
+	}
+	return cited
 }
 
-// processQuestion processes general questions using AI
-func (a *AIChatService) processQuestion(message string, userContext *UserChatContext, roomID uint) (*AIResponse, error) {
-	ctx := context.Background()
+// converse runs the conversation for one message, letting the AI call the chat tools. Read tools
+// run and their results go back to the model; writes are stored as pending actions the user has
+// to confirm, and the model is told nothing has changed yet. Text is passed to onDelta as it
+// streams in, from every round.
+func (a *AIChatService) converse(ctx context.Context, prompt string, userContext *UserChatContext, roomID uint, onDelta func(string)) (*AIResponse, error) {
+	usage := NewAIUsageService(a.DB)
+	session := a.toolModel().StartChat()
+	parts := []genai.Part{genai.Text(prompt)}
+
+	var toolsUsed []string
+	var actions []models.AIChatAction
+	var streamed strings.Builder
+	onChunk := func(chunk *genai.GenerateContentResponse) {
+		if len(chunk.Candidates) == 0 {
+			return
+		}
+		if delta := chunkText(chunk.Candidates[0]); delta != "" {
+			streamed.WriteString(delta)
+			if onDelta != nil {
+				onDelta(delta)
+			}
+		}
+	}
 
-	// Get recent chat history for context
-	recentMessages, _ := a.getRecentChatHistory(roomID, 5)
+	for round := 0; ; round++ {
+		resp, err := usage.SendMessageStream(ctx, session, geminiModelName, AIFeatureChat, &userContext.UserID, onChunk, parts...)
+		if ctx.Err() != nil {
+			return a.cancelledResponse(strings.TrimSpace(streamed.String()), actions, toolsUsed, userContext), nil
+		}
+		if err != nil {
+			if len(actions) > 0 {
+				// The changes are already waiting for confirmation; only the wording is missing
+				return a.actionsResponse("", actions, toolsUsed, userContext), nil
+			}
+			if errors.Is(err, ErrAIBudgetExceeded) {
+				return a.budgetExceededResponse(userContext), nil
+			}
+			return nil, fmt.Errorf("failed to get AI response: %v", err)
+		}
+		if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+			return nil, fmt.Errorf("empty response from AI")
+		}
 
-	// Build prompt with context
-	prompt := a.buildChatPrompt(message, userContext, recentMessages)
+		calls := resp.Candidates[0].FunctionCalls()
+		if len(calls) == 0 || round == chatToolRounds {
+			responseText := strings.TrimSpace(streamed.String())
+			if len(actions) > 0 {
+				return a.actionsResponse(responseText, actions, toolsUsed, userContext), nil
+			}
+			if responseText == "" {
+				return nil, fmt.Errorf("empty response from AI")
+			}
+			return &AIResponse{
+				Message:  responseText,
+				Action:   "answer",
+				Data:     toolsUsedData(toolsUsed),
+				Language: userContext.Language,
+			}, nil
+		}
+
+		parts = nil
+		for _, call := range calls {
+			toolsUsed = append(toolsUsed, call.Name)
+			response, action := a.callTool(call, userContext, roomID)
+			parts = append(parts, response)
+			if action != nil {
+				actions = append(actions, *action)
+			}
+		}
+	}
+}
+
+// cancelledResponse is what was streamed before the user stopped the answer. Changes already
+// proposed stay pending, so they are still listed.
+func (a *AIChatService) cancelledResponse(message string, actions []models.AIChatAction, toolsUsed []string, userContext *UserChatContext) *AIResponse {
+	response := &AIResponse{
+		Message:  message,
+		Action:   "answer",
+		Data:     toolsUsedData(toolsUsed),
+		Language: userContext.Language,
+	}
+	if len(actions) > 0 {
+		response = a.actionsResponse(message, actions, toolsUsed, userContext)
+	}
+	response.Cancelled = true
+	return response
+}
+
+// toolModel returns a copy of the chat model that can call the chat tools
+func (a *AIChatService) toolModel() *genai.GenerativeModel {
+	model := *a.model
+	model.Tools = []*genai.Tool{{FunctionDeclarations: chatToolDeclarations()}}
+	model.ToolConfig = &genai.ToolConfig{
+		FunctionCallingConfig: &genai.FunctionCallingConfig{Mode: genai.FunctionCallingAuto},
+	}
+	return &model
+}
 
-	// Get AI response
-	resp, err := a.model.GenerateContent(ctx, genai.Text(prompt))
+// callTool authorizes a tool call for the caller and runs it, or stores it as a pending action
+// when it is a write. Refusals and errors are returned to the model so it can explain them.
+func (a *AIChatService) callTool(call genai.FunctionCall, userContext *UserChatContext, roomID uint) (genai.FunctionResponse, *models.AIChatAction) {
+	tool, ok := chatTools[call.Name]
+	if !ok {
+		return toolResponse(call.Name, map[string]interface{}{"error": "unknown tool"}), nil
+	}
+
+	arguments, err := json.Marshal(call.Args)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get AI response: %v", err)
+		return toolResponse(call.Name, map[string]interface{}{"error": err.Error()}), nil
+	}
+	if err := tool.authorize(a, userContext, arguments); err != nil {
+		return toolResponse(call.Name, map[string]interface{}{"error": err.Error()}), nil
 	}
 
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("empty response from AI")
+	if !tool.write {
+		result, err := tool.run(a, userContext, arguments)
+		if err != nil {
+			return toolResponse(call.Name, map[string]interface{}{"error": err.Error()}), nil
+		}
+		return toolResponse(call.Name, result), nil
+	}
+
+	action := &models.AIChatAction{
+		UserID:     userContext.UserID,
+		ChatRoomID: roomID,
+		Tool:       call.Name,
+		Arguments:  string(arguments),
+		Summary:    tool.summarize(a, userContext, arguments),
+		Language:   userContext.Language,
+		Status:     models.AIChatActionPending,
+		ExpiresAt:  time.Now().Add(chatActionTTL),
+	}
+	// Confidential arguments, such as an HR report, get the same at-rest encryption as where
+	// they end up
+	if tool.confidential {
+		encryption, err := NewEncryptionServiceFromEnv("HR_ENCRYPTION_KEY")
+		if err != nil {
+			return toolResponse(call.Name, map[string]interface{}{"error": "this cannot be done from chat: " + errHREncryptionUnavailable.Error()}), nil
+		}
+		if action.Arguments, err = encryption.Encrypt(action.Arguments); err != nil {
+			return toolResponse(call.Name, map[string]interface{}{"error": "could not save the change for confirmation"}), nil
+		}
+		action.Encrypted = true
 	}
+	if err := a.DB.Create(action).Error; err != nil {
+		return toolResponse(call.Name, map[string]interface{}{"error": "could not save the change for confirmation"}), nil
+	}
+
+	return toolResponse(call.Name, map[string]interface{}{
+		"status":    "awaiting_user_confirmation",
+		"action_id": action.ID,
+		"summary":   action.Summary,
+		"note":      "Nothing has changed yet. Tell the user what will happen and ask them to confirm or cancel it. Do not call the tool again for this change.",
+	}), action
+}
 
-	responseText := fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0])
-	responseText = strings.TrimSpace(responseText)
+// toolResponse wraps a tool result for the model. The result goes through JSON so it only holds
+// the plain maps, lists and values the API accepts.
+func toolResponse(name string, result map[string]interface{}) genai.FunctionResponse {
+	response := map[string]interface{}{}
+	if encoded, err := json.Marshal(result); err == nil {
+		json.Unmarshal(encoded, &response)
+	}
+	return genai.FunctionResponse{Name: name, Response: response}
+}
+
+// chunkText joins the text parts of a streamed chunk
+func chunkText(candidate *genai.Candidate) string {
+	if candidate.Content == nil {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		if t, ok := part.(genai.Text); ok {
+			text.WriteString(string(t))
+		}
+	}
+	return text.String()
+}
+
+func toolsUsedData(toolsUsed []string) map[string]interface{} {
+	if len(toolsUsed) == 0 {
+		return nil
+	}
+	return map[string]interface{}{"tools_used": toolsUsed}
+}
+
+// actionsResponse asks the user to confirm the changes the model proposed. The summaries are
+// listed even when the model's own wording is missing.
+func (a *AIChatService) actionsResponse(message string, actions []models.AIChatAction, toolsUsed []string, userContext *UserChatContext) *AIResponse {
+	if message == "" {
+		var list strings.Builder
+		list.WriteString(localized(userContext, "Please confirm or cancel:\n", "يرجى التأكيد أو الإلغاء:\n"))
+		for i, action := range actions {
+			list.WriteString(fmt.Sprintf("%d. %s\n", i+1, action.Summary))
+		}
+		message = strings.TrimSpace(list.String())
+	}
+
+	pending := make([]map[string]interface{}, len(actions))
+	for i, action := range actions {
+		pending[i] = a.chatActionData(&action)
+	}
 
 	return &AIResponse{
-		Message:  responseText,
-		Action:   "answer",
+		Message: message,
+		Action:  "confirm_required",
+		Data: map[string]interface{}{
+			"actions":    pending,
+			"tools_used": toolsUsed,
+		},
 		Language: userContext.Language,
-	}, nil
+	}
+}
+
+// chatActionData is how a pending action is shown to clients
+func (a *AIChatService) chatActionData(action *models.AIChatAction) map[string]interface{} {
+	var arguments map[string]interface{}
+	if raw, err := a.actionArguments(action); err == nil {
+		json.Unmarshal(raw, &arguments)
+	}
+
+	return map[string]interface{}{
+		"action_id":  action.ID,
+		"tool":       action.Tool,
+		"summary":    action.Summary,
+		"arguments":  arguments,
+		"status":     action.Status,
+		"expires_at": action.ExpiresAt,
+	}
+}
+
+// GetPendingActions returns the user's changes that still wait for confirmation in a room
+func (a *AIChatService) GetPendingActions(userID, roomID uint) ([]models.AIChatAction, error) {
+	a.DB.Model(&models.AIChatAction{}).
+		Where("user_id = ? AND status = ? AND expires_at <= ?", userID, models.AIChatActionPending, time.Now()).
+		Updates(map[string]interface{}{"status": models.AIChatActionExpired, "resolved_at": time.Now()})
+
+	var actions []models.AIChatAction
+	err := a.DB.Where("user_id = ? AND chat_room_id = ? AND status = ?", userID, roomID, models.AIChatActionPending).
+		Order("created_at ASC").
+		Find(&actions).Error
+	return actions, err
+}
+
+// ConfirmAction carries out a change the assistant proposed. Permissions are checked again with
+// the user's current role, since it or the task may have changed since the proposal. The outcome
+// is posted to the chat room.
+func (a *AIChatService) ConfirmAction(userID, actionID uint) (*AIResponse, error) {
+	action, err := a.pendingAction(userID, actionID)
+	if err != nil {
+		return nil, err
+	}
+	// Claim the action so that a repeated confirmation cannot carry it out twice
+	if err := a.claimAction(action, models.AIChatActionRunning); err != nil {
+		return nil, err
+	}
+
+	userContext, err := a.getUserContext(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user context: %v", err)
+	}
+	userContext.Language = action.Language
+
+	tool, ok := chatTools[action.Tool]
+	if !ok {
+		return a.resolveAction(action, userContext, nil, fmt.Errorf("unknown tool %s", action.Tool))
+	}
+
+	arguments, err := a.actionArguments(action)
+	if err != nil {
+		return a.resolveAction(action, userContext, nil, err)
+	}
+	if err := tool.authorize(a, userContext, arguments); err != nil {
+		return a.resolveAction(action, userContext, nil, err)
+	}
+	result, err := tool.run(a, userContext, arguments)
+	return a.resolveAction(action, userContext, result, err)
+}
+
+// CancelAction drops a change the assistant proposed
+func (a *AIChatService) CancelAction(userID, actionID uint) (*AIResponse, error) {
+	action, err := a.pendingAction(userID, actionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.claimAction(action, models.AIChatActionCancelled); err != nil {
+		return nil, err
+	}
+
+	userContext := &UserChatContext{UserID: userID, Language: action.Language}
+	response := &AIResponse{
+		Message:  localized(userContext, "Cancelled: ", "تم الإلغاء: ") + action.Summary,
+		Action:   "action_cancelled",
+		Data:     a.chatActionData(action),
+		Language: action.Language,
+	}
+	a.postActionOutcome(action, response)
+	return response, nil
+}
+
+// pendingAction loads one of the user's actions that can still be confirmed or cancelled
+func (a *AIChatService) pendingAction(userID, actionID uint) (*models.AIChatAction, error) {
+	var action models.AIChatAction
+	if err := a.DB.Where("id = ? AND user_id = ?", actionID, userID).First(&action).Error; err != nil {
+		return nil, ErrChatActionNotFound
+	}
+	if action.Status != models.AIChatActionPending {
+		return nil, fmt.Errorf("%w: it is %s", ErrChatActionResolved, action.Status)
+	}
+	if time.Now().After(action.ExpiresAt) {
+		now := time.Now()
+		action.Status = models.AIChatActionExpired
+		action.ResolvedAt = &now
+		a.DB.Save(&action)
+		return nil, ErrChatActionExpired
+	}
+	return &action, nil
+}
+
+// RedactConfidentialActions clears the arguments of confidential tool calls that were stored in
+// plaintext. Pending ones are expired, as they can no longer be carried out. It is safe to run
+// repeatedly.
+func (a *AIChatService) RedactConfidentialActions() error {
+	var tools []string
+	for name, tool := range chatTools {
+		if tool.confidential {
+			tools = append(tools, name)
+		}
+	}
+	if len(tools) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if err := a.DB.Model(&models.AIChatAction{}).
+		Where("tool IN ? AND encrypted = ? AND status = ?", tools, false, models.AIChatActionPending).
+		Updates(map[string]interface{}{"status": models.AIChatActionExpired, "resolved_at": now}).Error; err != nil {
+		return err
+	}
+	return a.DB.Model(&models.AIChatAction{}).
+		Where("tool IN ? AND encrypted = ? AND arguments <> ''", tools, false).
+		Update("arguments", "").Error
+}
+
+// actionArguments returns an action's JSON arguments, decrypting confidential ones
+func (a *AIChatService) actionArguments(action *models.AIChatAction) (json.RawMessage, error) {
+	if !action.Encrypted {
+		return json.RawMessage(action.Arguments), nil
+	}
+	encryption, err := NewEncryptionServiceFromEnv("HR_ENCRYPTION_KEY")
+	if err != nil {
+		return nil, errHREncryptionUnavailable
+	}
+	arguments, err := encryption.Decrypt(action.Arguments)
+	if err != nil {
+		return nil, errors.New("failed to decrypt the action")
+	}
+	return json.RawMessage(arguments), nil
+}
+
+// claimAction moves a pending action to status, failing when another request resolved or claimed
+// it since it was loaded
+func (a *AIChatService) claimAction(action *models.AIChatAction, status models.AIChatActionStatus) error {
+	updates := map[string]interface{}{"status": status}
+	if status != models.AIChatActionRunning {
+		updates["resolved_at"] = time.Now()
+		if action.Encrypted {
+			updates["arguments"] = ""
+			updates["encrypted"] = false
+		}
+	}
+
+	result := a.DB.Model(&models.AIChatAction{}).
+		Where("id = ? AND user_id = ? AND status = ?", action.ID, action.UserID, models.AIChatActionPending).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("%w: it is already being handled", ErrChatActionResolved)
+	}
+
+	action.Status = status
+	if resolvedAt, ok := updates["resolved_at"].(time.Time); ok {
+		action.ResolvedAt = &resolvedAt
+	}
+	return nil
+}
+
+// resolveAction records the outcome of a confirmed action and posts it to the chat room
+func (a *AIChatService) resolveAction(action *models.AIChatAction, userContext *UserChatContext, result map[string]interface{}, runErr error) (*AIResponse, error) {
+	now := time.Now()
+	action.ResolvedAt = &now
+
+	// The report now lives in its own encrypted record; the result holds its ID
+	if action.Encrypted {
+		action.Arguments = ""
+		action.Encrypted = false
+	}
+
+	response := &AIResponse{Language: action.Language}
+	if runErr != nil {
+		action.Status = models.AIChatActionFailed
+		action.Result = runErr.Error()
+		response.Message = localized(userContext,
+			fmt.Sprintf("❌ Could not complete: %s\nReason: %v", action.Summary, runErr),
+			fmt.Sprintf("❌ تعذر التنفيذ: %s\nالسبب: %v", action.Summary, runErr))
+		response.Action = "action_failed"
+	} else {
+		encoded, _ := json.Marshal(result)
+		action.Status = models.AIChatActionCompleted
+		action.Result = string(encoded)
+		response.Message = localized(userContext, "✅ Done: ", "✅ تم التنفيذ: ") + action.Summary
+		response.Action = action.Tool
+	}
+
+	if err := a.DB.Save(action).Error; err != nil {
+		return nil, err
+	}
+
+	response.Data = a.chatActionData(action)
+	response.Data["result"] = result
+	a.postActionOutcome(action, response)
+	return response, nil
+}
+
+// postActionOutcome saves the outcome of an action as an AI message in its chat room
+func (a *AIChatService) postActionOutcome(action *models.AIChatAction, response *AIResponse) {
+	message := &models.ChatMessage{
+		ChatRoomID: action.ChatRoomID,
+		SenderID:   action.UserID,
+		Content:    response.Message,
+		Metadata:   fmt.Sprintf(`{"ai_response":true,"action":"%s","action_id":%d}`, response.Action, action.ID),
+	}
+	if err := a.DB.Create(message).Error; err != nil {
+		log.Printf("Error saving AI action outcome: %v", err)
+	}
 }
 
 // buildChatPrompt creates the AI prompt for chat
-func (a *AIChatService) buildChatPrompt(message string, userContext *UserChatContext, recentMessages []models.ChatMessage) string {
+func (a *AIChatService) buildChatPrompt(message string, userContext *UserChatContext, recentMessages []models.ChatMessage, citations []KnowledgeCitation) string {
 	// Build context string
 	projectsStr := strings.Join(userContext.Projects, ", ")
 	if projectsStr == "" {
@@ -332,6 +853,17 @@ func (a *AIChatService) buildChatPrompt(message string, userContext *UserChatCon
 		languageInstruction = "Respond in Arabic (العربية)."
 	}
 
+	// Build knowledge context; passages are already limited to what this user may see
+	knowledgeContext := "None found.\n"
+	if len(citations) > 0 {
+		var knowledge strings.Builder
+		for _, citation := range citations {
+			knowledge.WriteString(fmt.Sprintf("[%d] %s \"%s\" (%s):\n%s\n\n",
+				citation.Ref, citation.SourceType, citation.Title, citation.Date.Format(aiDateFormat), citation.Content))
+		}
+		knowledgeContext = knowledge.String()
+	}
+
 	prompt := fmt.Sprintf(`
 You are an AI assistant for a project management system in an Arabic organization.
 
@@ -353,6 +885,8 @@ USER CONTEXT:
 RECENT CONVERSATION:
 %s
 
+RELEVANT KNOWLEDGE (tasks, projects, team chat and HR policy answers):
+%s
 USER QUESTION/MESSAGE:
 %s
 
@@ -370,6 +904,11 @@ INSTRUCTIONS:
    - Workload analysis
    - Priority suggestions
    - Best practices
+8. Today is %s. Use YYYY-MM-DD dates in tool calls and resolve "tomorrow" or "next week" from today.
+9. Use the tools to look up tasks, projects, workload and teammates instead of guessing, and never invent IDs.
+10. To create or update tasks, change a status or file an HR report, call the matching tool. The user confirms every change before it happens, so never say a change is done.
+11. If a tool returns an error, explain it to the user; it usually means they are not allowed to do that.
+12. When you use RELEVANT KNOWLEDGE, cite each passage you rely on by its number, like [1] or [2][3]. If it does not answer the question, say so instead of guessing, and do not cite it.
 
 Respond naturally and helpfully. If you don't understand something, ask for clarification.
 `,
@@ -380,8 +919,10 @@ Respond naturally and helpfully. If you don't understand something, ask for clar
 		projectsStr,
 		userContext.CurrentWorkload,
 		recentContext,
+		knowledgeContext,
 		message,
 		languageInstruction,
+		time.Now().Format("2006-01-02 (Monday)"),
 	)
 
 	return prompt
@@ -404,209 +945,17 @@ func (a *AIChatService) getRecentChatHistory(roomID uint, limit int) ([]models.C
 	return messages, err
 }
 
-// handleCreateTaskCommand handles task creation from chat
-func (a *AIChatService) handleCreateTaskCommand(message string, userContext *UserChatContext) *AIResponse {
-	// Extract task details from message using AI
-	taskDetails, err := a.extractTaskDetails(message, userContext)
-	if err != nil {
-		return &AIResponse{
-			Message:  "I couldn't understand the task details. Please provide: task title, description (optional), and due date (optional).",
-			Action:   "answer",
-			Language: userContext.Language,
-		}
-	}
-
-	// Get description (handle nil case)
-	description := ""
-	if taskDetails["description"] != nil {
-		description = taskDetails["description"].(string)
-	}
-
-	// Create task using TaskService
-	task, err := a.TaskService.CreateTask(
-		taskDetails["title"].(string),
-		description,
-		userContext.UserID,
-		nil, // projectID - can be enhanced later
-		nil, // startTime
-		nil, // endTime
-		taskDetails["dueDate"].(*time.Time),
-	)
-
-	if err != nil {
-		return &AIResponse{
-			Message:  fmt.Sprintf("Failed to create task: %v", err),
-			Action:   "answer",
-			Language: userContext.Language,
-		}
-	}
-
-	responseMsg := fmt.Sprintf("✅ Task created successfully!\n\nTitle: %s\nStatus: Pending\nTask ID: %d", task.Title, task.ID)
-	if task.DueDate != nil {
-		responseMsg += fmt.Sprintf("\nDue Date: %s", task.DueDate.Format("January 2, 2006"))
-	}
-
-	if userContext.Language == "ar" {
-		responseMsg = fmt.Sprintf("✅ تم إنشاء المهمة بنجاح!\n\nالعنوان: %s\nالحالة: قيد الانتظار\nرقم المهمة: %d", task.Title, task.ID)
-		if task.DueDate != nil {
-			responseMsg += fmt.Sprintf("\nتاريخ الاستحقاق: %s", task.DueDate.Format("2 January 2006"))
-		}
-	}
-
-	return &AIResponse{
-		Message: responseMsg,
-		Action:  "create_task",
-		Data: map[string]interface{}{
-			"task_id": task.ID,
-			"title":   task.Title,
-		},
-		Language: userContext.Language,
-	}
-}
-
-// extractTaskDetails uses AI to extract task information from natural language
-func (a *AIChatService) extractTaskDetails(message string, userContext *UserChatContext) (map[string]interface{}, error) {
-	ctx := context.Background()
-
-	prompt := fmt.Sprintf(`
-Extract task information from this message: "%s"
-
-Return JSON with:
-- title: string (required)
-- description: string (optional, can be empty)
-- dueDate: string in format "YYYY-MM-DD" or null
-
-Example response:
-{
-  "title": "Review API documentation",
-  "description": "Review and update API documentation for new endpoints",
-  "dueDate": "2024-01-19"
-}
-
-Only return valid JSON, no other text.
-`, message)
-
-	resp, err := a.model.GenerateContent(ctx, genai.Text(prompt))
-	if err != nil {
-		return nil, err
-	}
-
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("empty response")
-	}
-
-	responseText := fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0])
-	responseText = strings.TrimPrefix(responseText, "```json")
-	responseText = strings.TrimSuffix(responseText, "```")
-	responseText = strings.TrimSpace(responseText)
-
-	var result map[string]interface{}
-	if err := json.Unmarshal([]byte(responseText), &result); err != nil {
-		return nil, err
-	}
-
-	// Parse due date
-	var dueDate *time.Time
-	if result["dueDate"] != nil && result["dueDate"].(string) != "" {
-		parsed, err := time.Parse("2006-01-02", result["dueDate"].(string))
-		if err == nil {
-			dueDate = &parsed
-		}
-	}
-
-	return map[string]interface{}{
-		"title":       result["title"],
-		"description": result["description"],
-		"dueDate":     dueDate,
-	}, nil
-}
-
-// handleGetTasksCommand handles getting user's tasks
-func (a *AIChatService) handleGetTasksCommand(userContext *UserChatContext) *AIResponse {
-	var tasks []models.Task
-	a.DB.Where("user_id = ? AND status IN ?", userContext.UserID, []string{"pending", "in_progress"}).
-		Order("created_at DESC").
-		Limit(10).
-		Find(&tasks)
-
-	if len(tasks) == 0 {
-		msg := "You have no active tasks. Great job! 🎉"
-		if userContext.Language == "ar" {
-			msg = "ليس لديك مهام نشطة. عمل رائع! 🎉"
-		}
-		return &AIResponse{
-			Message:  msg,
-			Action:   "get_tasks",
-			Language: userContext.Language,
-		}
-	}
-
-	var taskList strings.Builder
+// budgetExceededResponse tells the user the AI budget is used up. Changes already proposed can
+// still be confirmed, as that needs no AI.
+func (a *AIChatService) budgetExceededResponse(userContext *UserChatContext) *AIResponse {
+	message := "The AI usage budget for this month has been reached, so I can't answer questions right now. Changes I already proposed can still be confirmed or cancelled."
 	if userContext.Language == "ar" {
-		taskList.WriteString(fmt.Sprintf("لديك %d مهام نشطة:\n\n", len(tasks)))
-	} else {
-		taskList.WriteString(fmt.Sprintf("You have %d active tasks:\n\n", len(tasks)))
-	}
-
-	for i, task := range tasks {
-		status := string(task.Status)
-		if userContext.Language == "ar" {
-			if status == "pending" {
-				status = "قيد الانتظار"
-			} else if status == "in_progress" {
-				status = "قيد التنفيذ"
-			}
-		}
-
-		taskList.WriteString(fmt.Sprintf("%d. %s (%s)\n", i+1, task.Title, status))
-		if task.DueDate != nil {
-			taskList.WriteString(fmt.Sprintf("   Due: %s\n", task.DueDate.Format("Jan 2, 2006")))
-		}
+		message = "تم الوصول إلى حد استخدام الذكاء الاصطناعي لهذا الشهر، لذلك لا يمكنني الإجابة على الأسئلة حالياً. لا يزال بإمكانك تأكيد أو إلغاء التغييرات التي اقترحتها سابقاً."
 	}
 
 	return &AIResponse{
-		Message: taskList.String(),
-		Action:  "get_tasks",
-		Data: map[string]interface{}{
-			"task_count": len(tasks),
-		},
-		Language: userContext.Language,
-	}
-}
-
-// handleGetWorkloadCommand handles workload queries
-func (a *AIChatService) handleGetWorkloadCommand(userContext *UserChatContext) *AIResponse {
-	workload := userContext.CurrentWorkload
-	status := "healthy"
-	statusEmoji := "✅"
-
-	if workload > 120 {
-		status = "critical overload"
-		statusEmoji = "🔴"
-	} else if workload > 100 {
-		status = "overloaded"
-		statusEmoji = "🟠"
-	} else if workload > 80 {
-		status = "high"
-		statusEmoji = "🟡"
-	}
-
-	var msg string
-	if userContext.Language == "ar" {
-		msg = fmt.Sprintf("%s عبء العمل الحالي: %.1f%%\n\nالحالة: %s\nالسعة الأسبوعية: 42 ساعة\nالمهام النشطة: %d",
-			statusEmoji, workload, status, userContext.ActiveTasks)
-	} else {
-		msg = fmt.Sprintf("%s Current workload: %.1f%%\n\nStatus: %s\nWeekly capacity: 42 hours\nActive tasks: %d",
-			statusEmoji, workload, status, userContext.ActiveTasks)
-	}
-
-	return &AIResponse{
-		Message: msg,
-		Action:  "get_workload",
-		Data: map[string]interface{}{
-			"workload_percent": workload,
-			"status":           status,
-		},
+		Message:  message,
+		Action:   "budget_exceeded",
 		Language: userContext.Language,
 	}
 }
@@ -0,0 +1,624 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"project-x/models"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// chatTool is a function the chat model can call. Reads run straight away; writes are stored as
+// an AIChatAction and only run once the user confirms them. Both run with the caller's
+// permissions, which authorize checks before anything is read or proposed and again on confirm.
+type chatTool struct {
+	declaration  *genai.FunctionDeclaration
+	write        bool
+	confidential bool
+	authorize    func(a *AIChatService, caller *UserChatContext, raw json.RawMessage) error
+	run          func(a *AIChatService, caller *UserChatContext, raw json.RawMessage) (map[string]interface{}, error)
+	summarize    func(a *AIChatService, caller *UserChatContext, raw json.RawMessage) string
+}
+
+// chatToolSpec describes a tool whose arguments decode into A
+type chatToolSpec[A any] struct {
+	Name        string
+	Description string
+	Parameters  *genai.Schema
+	Write       bool
+	// Confidential arguments are stored encrypted with the HR key until the action is resolved
+	Confidential bool
+	Authorize    func(a *AIChatService, caller *UserChatContext, args *A) error
+	Run          func(a *AIChatService, caller *UserChatContext, args *A) (map[string]interface{}, error)
+	// Summarize describes a write in the caller's language for the confirmation prompt
+	Summarize func(a *AIChatService, caller *UserChatContext, args *A) string
+}
+
+// tool builds the chatTool, decoding the model's arguments into A before every call
+func (s chatToolSpec[A]) tool() *chatTool {
+	decode := func(raw json.RawMessage) (*A, error) {
+		var args A
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return nil, fmt.Errorf("invalid arguments for %s: %v", s.Name, err)
+			}
+		}
+		return &args, nil
+	}
+
+	return &chatTool{
+		declaration:  &genai.FunctionDeclaration{Name: s.Name, Description: s.Description, Parameters: s.Parameters},
+		write:        s.Write,
+		confidential: s.Confidential,
+		authorize: func(a *AIChatService, caller *UserChatContext, raw json.RawMessage) error {
+			args, err := decode(raw)
+			if err != nil {
+				return err
+			}
+			if s.Authorize == nil {
+				return nil
+			}
+			return s.Authorize(a, caller, args)
+		},
+		run: func(a *AIChatService, caller *UserChatContext, raw json.RawMessage) (map[string]interface{}, error) {
+			args, err := decode(raw)
+			if err != nil {
+				return nil, err
+			}
+			return s.Run(a, caller, args)
+		},
+		summarize: func(a *AIChatService, caller *UserChatContext, raw json.RawMessage) string {
+			args, err := decode(raw)
+			if err != nil || s.Summarize == nil {
+				return s.Name
+			}
+			return s.Summarize(a, caller, args)
+		},
+	}
+}
+
+// chatTools is the registry of tools offered to the chat model, by name
+var chatTools = func() map[string]*chatTool {
+	tools := map[string]*chatTool{}
+	for _, tool := range []*chatTool{
+		listMyTasksTool.tool(),
+		getMyWorkloadTool.tool(),
+		listProjectTasksTool.tool(),
+		findTeammatesTool.tool(),
+		createTaskTool.tool(),
+		updateTaskTool.tool(),
+		changeTaskStatusTool.tool(),
+		fileHRProblemTool.tool(),
+	} {
+		tools[tool.declaration.Name] = tool
+	}
+	return tools
+}()
+
+// chatToolDeclarations lists the registry for the model, sorted so the prompt stays stable
+func chatToolDeclarations() []*genai.FunctionDeclaration {
+	declarations := make([]*genai.FunctionDeclaration, 0, len(chatTools))
+	for _, tool := range chatTools {
+		declarations = append(declarations, tool.declaration)
+	}
+	slices.SortFunc(declarations, func(a, b *genai.FunctionDeclaration) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return declarations
+}
+
+func objectSchema(properties map[string]*genai.Schema, required ...string) *genai.Schema {
+	return &genai.Schema{Type: genai.TypeObject, Properties: properties, Required: required}
+}
+
+// callerHasRole reports whether the caller has one of the roles
+func callerHasRole(caller *UserChatContext, roles ...models.Role) bool {
+	return slices.Contains(roles, models.Role(caller.Role))
+}
+
+// isProjectMember reports whether the user belongs to the project
+func (a *AIChatService) isProjectMember(projectID, userID uint) bool {
+	var count int64
+	a.DB.Model(&models.UserProject{}).Where("project_id = ? AND user_id = ?", projectID, userID).Count(&count)
+	return count > 0
+}
+
+// parseChatDueDate parses a YYYY-MM-DD due date from a tool call, rejecting dates in the past
+func parseChatDueDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	dueDate, err := time.Parse(aiDateFormat, value)
+	if err != nil {
+		return nil, fmt.Errorf("due date %q is not a YYYY-MM-DD date", value)
+	}
+	now := time.Now()
+	if dueDate.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)) {
+		return nil, fmt.Errorf("due date %s is in the past", value)
+	}
+	return &dueDate, nil
+}
+
+// chatTaskSummary is the view of a task returned to the model
+func chatTaskSummary(task models.Task) map[string]interface{} {
+	summary := map[string]interface{}{
+		"id":         task.ID,
+		"title":      task.Title,
+		"status":     task.Status,
+		"user_id":    task.UserID,
+		"project_id": task.ProjectID,
+	}
+	if task.WorkflowState != "" {
+		summary["workflow_state"] = task.WorkflowState
+	}
+	if task.DueDate != nil {
+		summary["due_date"] = task.DueDate.Format(aiDateFormat)
+	}
+	if task.User.ID != 0 {
+		summary["assignee"] = task.User.Username
+	}
+	return summary
+}
+
+// localized picks the Arabic text for Arabic conversations
+func localized(caller *UserChatContext, english, arabic string) string {
+	if caller.Language == "ar" {
+		return arabic
+	}
+	return english
+}
+
+type listMyTasksArgs struct {
+	Status string `json:"status"`
+}
+
+var listMyTasksTool = chatToolSpec[listMyTasksArgs]{
+	Name:        "list_my_tasks",
+	Description: "List the tasks assigned to the current user. Without a status, only active (pending and in progress) tasks are returned.",
+	Parameters: objectSchema(map[string]*genai.Schema{
+		"status": enumSchema("Only return tasks with this status", []string{
+			string(models.TaskStatusPending), string(models.TaskStatusInProgress), string(models.TaskStatusCompleted), string(models.TaskStatusCancelled),
+		}),
+	}),
+	Run: func(a *AIChatService, caller *UserChatContext, args *listMyTasksArgs) (map[string]interface{}, error) {
+		statuses := []string{string(models.TaskStatusPending), string(models.TaskStatusInProgress)}
+		if args.Status != "" {
+			statuses = []string{args.Status}
+		}
+
+		var tasks []models.Task
+		if err := a.DB.Where("user_id = ? AND status IN ?", caller.UserID, statuses).
+			Order("created_at DESC").
+			Limit(20).
+			Find(&tasks).Error; err != nil {
+			return nil, err
+		}
+
+		summaries := make([]map[string]interface{}, len(tasks))
+		for i, task := range tasks {
+			summaries[i] = chatTaskSummary(task)
+		}
+		return map[string]interface{}{"tasks": summaries, "count": len(tasks)}, nil
+	},
+}
+
+type getMyWorkloadArgs struct{}
+
+var getMyWorkloadTool = chatToolSpec[getMyWorkloadArgs]{
+	Name:        "get_my_workload",
+	Description: "Get the current user's workload as a percentage of their weekly capacity, with its status and active task count.",
+	Parameters:  objectSchema(map[string]*genai.Schema{}),
+	Run: func(a *AIChatService, caller *UserChatContext, args *getMyWorkloadArgs) (map[string]interface{}, error) {
+		status := "healthy"
+		switch {
+		case caller.CurrentWorkload > 120:
+			status = "critical overload"
+		case caller.CurrentWorkload > 100:
+			status = "overloaded"
+		case caller.CurrentWorkload > 80:
+			status = "high"
+		}
+
+		return map[string]interface{}{
+			"workload_percent":      caller.CurrentWorkload,
+			"status":                status,
+			"weekly_capacity_hours": a.WorkSchedule.WeeklyHours,
+			"active_tasks":          caller.ActiveTasks,
+		}, nil
+	},
+}
+
+type listProjectTasksArgs struct {
+	ProjectID uint   `json:"project_id"`
+	Status    string `json:"status"`
+}
+
+var listProjectTasksTool = chatToolSpec[listProjectTasksArgs]{
+	Name:        "list_project_tasks",
+	Description: "List the tasks of a project with their assignees. Use find_teammates or the user's project list to get the project ID.",
+	Parameters: objectSchema(map[string]*genai.Schema{
+		"project_id": {Type: genai.TypeInteger, Description: "ID of the project"},
+		"status":     {Type: genai.TypeString, Description: "Only return tasks with this status or workflow state"},
+	}, "project_id"),
+	Authorize: func(a *AIChatService, caller *UserChatContext, args *listProjectTasksArgs) error {
+		var project models.Project
+		if err := a.DB.First(&project, args.ProjectID).Error; err != nil {
+			return errors.New("project not found")
+		}
+		if !callerHasRole(caller, models.RoleAdmin, models.RoleManager) && !a.isProjectMember(args.ProjectID, caller.UserID) {
+			return errors.New("you can only view tasks of projects you are a member of")
+		}
+		return nil
+	},
+	Run: func(a *AIChatService, caller *UserChatContext, args *listProjectTasksArgs) (map[string]interface{}, error) {
+		tasks, err := a.TaskService.GetProjectTasks(args.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+
+		summaries := []map[string]interface{}{}
+		for _, task := range tasks {
+			if args.Status != "" && string(task.Status) != args.Status && task.WorkflowState != args.Status {
+				continue
+			}
+			if len(summaries) == 50 {
+				break
+			}
+			summaries = append(summaries, chatTaskSummary(task))
+		}
+		return map[string]interface{}{"project_id": args.ProjectID, "tasks": summaries, "count": len(summaries)}, nil
+	},
+}
+
+type findTeammatesArgs struct {
+	Query     string `json:"query"`
+	ProjectID uint   `json:"project_id"`
+}
+
+var findTeammatesTool = chatToolSpec[findTeammatesArgs]{
+	Name:        "find_teammates",
+	Description: "Look up people the current user works with, by name or department, optionally within one project. Returns user IDs for assigning tasks.",
+	Parameters: objectSchema(map[string]*genai.Schema{
+		"query":      {Type: genai.TypeString, Description: "Part of a username or department"},
+		"project_id": {Type: genai.TypeInteger, Description: "Only search members of this project"},
+	}),
+	Authorize: func(a *AIChatService, caller *UserChatContext, args *findTeammatesArgs) error {
+		if args.ProjectID != 0 && !callerHasRole(caller, models.RoleAdmin, models.RoleManager, models.RoleHR) && !a.isProjectMember(args.ProjectID, caller.UserID) {
+			return errors.New("you can only look up members of projects you are a member of")
+		}
+		return nil
+	},
+	Run: func(a *AIChatService, caller *UserChatContext, args *findTeammatesArgs) (map[string]interface{}, error) {
+		query := a.DB.Model(&models.User{})
+		switch {
+		case args.ProjectID != 0:
+			query = query.Where("id IN (?)", a.DB.Model(&models.UserProject{}).Select("user_id").Where("project_id = ?", args.ProjectID))
+		case !callerHasRole(caller, models.RoleAdmin, models.RoleManager, models.RoleHR):
+			// Everyone else only sees people they share a project with
+			callerProjects := a.DB.Model(&models.UserProject{}).Select("project_id").Where("user_id = ?", caller.UserID)
+			query = query.Where("id IN (?)", a.DB.Model(&models.UserProject{}).Select("user_id").Where("project_id IN (?)", callerProjects))
+		}
+		if search := strings.TrimSpace(args.Query); search != "" {
+			query = query.Where("username ILIKE ? OR department ILIKE ?", "%"+search+"%", "%"+search+"%")
+		}
+
+		var users []models.User
+		if err := query.Order("username").Limit(20).Find(&users).Error; err != nil {
+			return nil, err
+		}
+
+		teammates := make([]map[string]interface{}, len(users))
+		for i, user := range users {
+			teammates[i] = map[string]interface{}{
+				"id":         user.ID,
+				"username":   user.Username,
+				"department": user.Department,
+				"role":       user.Role,
+			}
+		}
+		return map[string]interface{}{"teammates": teammates, "count": len(users)}, nil
+	},
+}
+
+type createTaskArgs struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	DueDate     string `json:"due_date"`
+	ProjectID   *uint  `json:"project_id"`
+	AssigneeID  *uint  `json:"assignee_id"`
+}
+
+// assignee returns who the task is for, the caller unless someone else was named
+func (args *createTaskArgs) assignee(caller *UserChatContext) uint {
+	if args.AssigneeID != nil && *args.AssigneeID != 0 {
+		return *args.AssigneeID
+	}
+	return caller.UserID
+}
+
+var createTaskTool = chatToolSpec[createTaskArgs]{
+	Name:        "create_task",
+	Description: "Create a task. Assigned to the current user unless assignee_id is given. The user is asked to confirm before it is created.",
+	Parameters: objectSchema(map[string]*genai.Schema{
+		"title":       {Type: genai.TypeString, Description: "Specific task title"},
+		"description": {Type: genai.TypeString, Description: "Task description"},
+		"due_date":    {Type: genai.TypeString, Description: "Due date, YYYY-MM-DD"},
+		"project_id":  {Type: genai.TypeInteger, Description: "Project the task belongs to; the assignee must be a member"},
+		"assignee_id": {Type: genai.TypeInteger, Description: "User ID from find_teammates to assign the task to"},
+	}, "title"),
+	Write: true,
+	Authorize: func(a *AIChatService, caller *UserChatContext, args *createTaskArgs) error {
+		// Same rules as POST /api/tasks
+		if !callerHasRole(caller, models.RoleAdmin, models.RoleManager, models.RoleHead) {
+			return errors.New("only Admin/Manager/Head can create tasks")
+		}
+		assigneeID := args.assignee(caller)
+		if assigneeID != caller.UserID && !callerHasRole(caller, models.RoleAdmin, models.RoleManager) {
+			return errors.New("employees, Heads, and HR cannot assign tasks to other users")
+		}
+		if strings.TrimSpace(args.Title) == "" {
+			return errors.New("a task title is required")
+		}
+		if _, err := parseChatDueDate(args.DueDate); err != nil {
+			return err
+		}
+
+		var assignee models.User
+		if err := a.DB.First(&assignee, assigneeID).Error; err != nil {
+			return errors.New("assignee not found")
+		}
+		if args.ProjectID != nil && !a.isProjectMember(*args.ProjectID, assigneeID) {
+			return errors.New("the assignee is not a member of this project")
+		}
+		return nil
+	},
+	Run: func(a *AIChatService, caller *UserChatContext, args *createTaskArgs) (map[string]interface{}, error) {
+		dueDate, err := parseChatDueDate(args.DueDate)
+		if err != nil {
+			return nil, err
+		}
+
+		assigneeID := args.assignee(caller)
+		task, err := a.TaskService.CreateTask(strings.TrimSpace(args.Title), args.Description, assigneeID, args.ProjectID, nil, nil, dueDate)
+		if err != nil {
+			return nil, err
+		}
+
+		// Send notification if task is assigned to someone else
+		if assigneeID != caller.UserID && a.TaskService.notificationService != nil {
+			var assignedUser, currentUser models.User
+			if a.DB.First(&assignedUser, assigneeID).Error == nil && a.DB.First(&currentUser, caller.UserID).Error == nil {
+				a.TaskService.notificationService.SendTaskAssignedNotification(task, &assignedUser, &currentUser)
+			}
+		}
+
+		return map[string]interface{}{"task": chatTaskSummary(*task)}, nil
+	},
+	Summarize: func(a *AIChatService, caller *UserChatContext, args *createTaskArgs) string {
+		assignee := localized(caller, "you", "لك")
+		if assigneeID := args.assignee(caller); assigneeID != caller.UserID {
+			var user models.User
+			a.DB.Select("username").First(&user, assigneeID)
+			assignee = user.Username
+		}
+
+		summary := localized(caller,
+			fmt.Sprintf("Create task \"%s\" for %s", args.Title, assignee),
+			fmt.Sprintf("إنشاء مهمة \"%s\" لـ %s", args.Title, assignee))
+		if args.DueDate != "" {
+			summary += localized(caller, ", due "+args.DueDate, "، تاريخ الاستحقاق "+args.DueDate)
+		}
+		return summary
+	},
+}
+
+type updateTaskArgs struct {
+	TaskID      uint   `json:"task_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	DueDate     string `json:"due_date"`
+}
+
+var updateTaskTool = chatToolSpec[updateTaskArgs]{
+	Name:        "update_task",
+	Description: "Change the title, description or due date of a task. Only the given fields change. The user is asked to confirm first.",
+	Parameters: objectSchema(map[string]*genai.Schema{
+		"task_id":     {Type: genai.TypeInteger, Description: "ID of the task"},
+		"title":       {Type: genai.TypeString, Description: "New title"},
+		"description": {Type: genai.TypeString, Description: "New description"},
+		"due_date":    {Type: genai.TypeString, Description: "New due date, YYYY-MM-DD"},
+	}, "task_id"),
+	Write: true,
+	Authorize: func(a *AIChatService, caller *UserChatContext, args *updateTaskArgs) error {
+		var task models.Task
+		if err := a.DB.First(&task, args.TaskID).Error; err != nil {
+			return errors.New("task not found")
+		}
+
+		// Same rules as PUT /api/tasks/:id
+		if !callerHasRole(caller, models.RoleAdmin, models.RoleManager, models.RoleHead) {
+			return errors.New("only Admin/Manager/Head can update task details. Task assignees can only update status")
+		}
+		if callerHasRole(caller, models.RoleHead) && (task.ProjectID == nil || !a.isProjectMember(*task.ProjectID, caller.UserID)) {
+			return errors.New("you can only edit tasks in projects you're a member of")
+		}
+
+		if args.Title == "" && args.Description == "" && args.DueDate == "" {
+			return errors.New("nothing to update")
+		}
+		_, err := parseChatDueDate(args.DueDate)
+		return err
+	},
+	Run: func(a *AIChatService, caller *UserChatContext, args *updateTaskArgs) (map[string]interface{}, error) {
+		dueDate, err := parseChatDueDate(args.DueDate)
+		if err != nil {
+			return nil, err
+		}
+
+		task, err := a.TaskService.UpdateTask(args.TaskID, caller.UserID, args.Title, args.Description, dueDate, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"task": chatTaskSummary(*task)}, nil
+	},
+	Summarize: func(a *AIChatService, caller *UserChatContext, args *updateTaskArgs) string {
+		var task models.Task
+		a.DB.Select("id", "title").First(&task, args.TaskID)
+
+		var changes []string
+		if args.Title != "" {
+			changes = append(changes, localized(caller, fmt.Sprintf("title to \"%s\"", args.Title), fmt.Sprintf("العنوان إلى \"%s\"", args.Title)))
+		}
+		if args.Description != "" {
+			changes = append(changes, localized(caller, "the description", "الوصف"))
+		}
+		if args.DueDate != "" {
+			changes = append(changes, localized(caller, "due date to "+args.DueDate, "تاريخ الاستحقاق إلى "+args.DueDate))
+		}
+
+		return localized(caller,
+			fmt.Sprintf("Update task #%d \"%s\": %s", args.TaskID, task.Title, strings.Join(changes, ", ")),
+			fmt.Sprintf("تحديث المهمة #%d \"%s\": %s", args.TaskID, task.Title, strings.Join(changes, "، ")))
+	},
+}
+
+type changeTaskStatusArgs struct {
+	TaskID uint   `json:"task_id"`
+	Status string `json:"status"`
+}
+
+var changeTaskStatusTool = chatToolSpec[changeTaskStatusArgs]{
+	Name:        "change_task_status",
+	Description: "Move one of the current user's own tasks to another status or workflow state. The user is asked to confirm first.",
+	Parameters: objectSchema(map[string]*genai.Schema{
+		"task_id": {Type: genai.TypeInteger, Description: "ID of the task"},
+		"status":  {Type: genai.TypeString, Description: "Target status (pending, in_progress, completed, cancelled) or a state key of the project's workflow"},
+	}, "task_id", "status"),
+	Write: true,
+	Authorize: func(a *AIChatService, caller *UserChatContext, args *changeTaskStatusArgs) error {
+		var task models.Task
+		if err := a.DB.First(&task, args.TaskID).Error; err != nil {
+			return errors.New("task not found")
+		}
+		// Same rule as PATCH /api/tasks/:id/status
+		if task.UserID != caller.UserID {
+			return errors.New("only the assigned user can update task status")
+		}
+
+		workflow, err := NewProjectService(a.DB).GetProjectWorkflow(task.ProjectID)
+		if err != nil {
+			return err
+		}
+		to := workflow.State(args.Status)
+		if to == nil {
+			return fmt.Errorf("'%s' is not a state in this task's workflow", args.Status)
+		}
+		if from := workflow.StateOf(&task); !workflow.CanTransition(from, to) {
+			return fmt.Errorf("tasks cannot move from '%s' to '%s' in this project's workflow", from.Name, to.Name)
+		}
+		return nil
+	},
+	Run: func(a *AIChatService, caller *UserChatContext, args *changeTaskStatusArgs) (map[string]interface{}, error) {
+		var oldTask models.Task
+		if err := a.DB.First(&oldTask, args.TaskID).Error; err != nil {
+			return nil, errors.New("task not found")
+		}
+
+		transition, err := a.TaskService.UpdateTaskStatus(args.TaskID, args.Status)
+		if err != nil {
+			return nil, err
+		}
+		task := transition.Task
+		completed := task.Status == models.TaskStatusCompleted && oldTask.Status != models.TaskStatusCompleted
+
+		if completed {
+			if err := a.TaskService.UpdateAIAnalysisOnTaskCompletion(task.ID); err != nil {
+				log.Printf("Warning: Failed to update AI analysis for task %d: %v", task.ID, err)
+			}
+		}
+
+		if notifications := a.TaskService.notificationService; notifications != nil {
+			var currentUser models.User
+			if a.DB.First(&currentUser, caller.UserID).Error == nil {
+				notifications.SendTaskStatusChangedNotification(task, &currentUser, models.TaskStatus(transition.FromState), models.TaskStatus(transition.ToState))
+				if completed {
+					notifications.SendTaskCompletedNotification(task, &currentUser)
+				}
+			}
+		}
+
+		return map[string]interface{}{"task": chatTaskSummary(*task), "from_state": transition.FromState, "to_state": transition.ToState}, nil
+	},
+	Summarize: func(a *AIChatService, caller *UserChatContext, args *changeTaskStatusArgs) string {
+		var task models.Task
+		a.DB.Select("id", "title").First(&task, args.TaskID)
+		return localized(caller,
+			fmt.Sprintf("Move task #%d \"%s\" to %s", args.TaskID, task.Title, args.Status),
+			fmt.Sprintf("نقل المهمة #%d \"%s\" إلى %s", args.TaskID, task.Title, args.Status))
+	},
+}
+
+type fileHRProblemArgs struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	Priority    string `json:"priority"`
+}
+
+var fileHRProblemTool = chatToolSpec[fileHRProblemArgs]{
+	Name: "file_hr_problem",
+	Description: "File a confidential report to HR in the current user's name. Reports filed from chat are never anonymous; " +
+		"tell users who want to stay anonymous to use the HR problems form instead. The user is asked to confirm first.",
+	Parameters: objectSchema(map[string]*genai.Schema{
+		"title":       {Type: genai.TypeString, Description: "Short title of the problem"},
+		"description": {Type: genai.TypeString, Description: "What happened, in the user's words"},
+		"category":    enumSchema("Problem category", problemCategoryKeys()),
+		"priority": enumSchema("Priority, medium unless the user says otherwise", []string{
+			string(models.ProblemPriorityLow), string(models.ProblemPriorityMedium), string(models.ProblemPriorityHigh),
+			string(models.ProblemPriorityUrgent), string(models.ProblemPritorityCritical),
+		}),
+	}, "title", "description", "category"),
+	Write:        true,
+	Confidential: true,
+	Authorize: func(a *AIChatService, caller *UserChatContext, args *fileHRProblemArgs) error {
+		return validateProblemInput(args.Title, args.Description, models.ProblemCategory(args.Category), args.priority())
+	},
+	Run: func(a *AIChatService, caller *UserChatContext, args *fileHRProblemArgs) (map[string]interface{}, error) {
+		problem, err := NewHRProblemService(a.DB, a.TaskService.notificationService).CreateProblem(
+			args.Title, args.Description, models.ProblemCategory(args.Category), args.priority(),
+			caller.UserID, false, "email", "", "", "", "", nil, false,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"problem_id": problem.ID, "status": problem.Status}, nil
+	},
+	Summarize: func(a *AIChatService, caller *UserChatContext, args *fileHRProblemArgs) string {
+		return localized(caller,
+			fmt.Sprintf("File an HR report (%s, %s priority) in your name", args.Category, args.priority()),
+			fmt.Sprintf("تقديم بلاغ للموارد البشرية (%s، أولوية %s) باسمك", args.Category, args.priority()))
+	},
+}
+
+// priority defaults to medium, as the HR problems form does
+func (args *fileHRProblemArgs) priority() models.ProblemPriority {
+	if args.Priority == "" {
+		return models.ProblemPriorityMedium
+	}
+	return models.ProblemPriority(args.Priority)
+}
+
+// problemCategoryKeys lists the HR problem categories, sorted
+func problemCategoryKeys() []string {
+	var keys []string
+	for category := range models.GetProblemCategories() {
+		keys = append(keys, string(category))
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,1135 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"project-x/config"
+	"project-x/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+	"gorm.io/gorm"
+)
+
+const (
+	// digestTasksPerList is how many tasks each list of a digest prompt holds at most
+	digestTasksPerList = 10
+	// digestChatMessages is how many of the week's chat messages a project digest reads
+	digestChatMessages = 40
+	// digestChatMessageChars shortens long chat messages in the project digest prompt
+	digestChatMessageChars = 300
+	// digestBlockedKeyword marks workflow states that block a task, such as "Blocked"
+	digestBlockedKeyword = "block"
+	// digestMaxAttempts is how often the sweep tries to write one digest before giving up on it
+	digestMaxAttempts = 3
+	// digestRetryDelay is how long the sweep waits before trying a failed digest again
+	digestRetryDelay = 2 * time.Hour
+)
+
+var (
+	ErrDigestProjectNotFound = errors.New("project not found")
+	// ErrDigestAccessDenied is returned when someone outside a project reads its digests
+	ErrDigestAccessDenied = errors.New("only project members, Admins and Managers can read this project's digests")
+)
+
+// AIDigestService writes daily standups and weekly project digests with the AI, delivers them in
+// each reader's language and archives them per project
+type AIDigestService struct {
+	DB                  *gorm.DB
+	client              *genai.Client
+	model               *genai.GenerativeModel
+	WorkSchedule        *config.WorkScheduleConfig
+	TaskService         *TaskService
+	notificationService *NotificationService
+	wsService           *WebSocketService
+	standupAt           time.Duration // Time of day standups are written, in the work schedule's time zone
+	weeklyAt            time.Duration // Time of day weekly digests are written on the last working day of the week
+}
+
+// AIDigestFilter narrows a project's digest archive
+type AIDigestFilter struct {
+	Type     models.AIDigestType
+	Language string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+}
+
+// digestRecipient is someone a digest is delivered to
+type digestRecipient struct {
+	UserID   uint
+	Language string
+	Delivery models.AIDigestDelivery
+}
+
+// digestTask is a task as a digest prompt lists it
+type digestTask struct {
+	ID       uint       `json:"id"`
+	Title    string     `json:"title"`
+	Assignee string     `json:"assignee,omitempty"`
+	Status   string     `json:"status"` // Workflow state name when the project has a custom workflow
+	Due      *time.Time `json:"due,omitempty"`
+	Note     string     `json:"note,omitempty"` // Why the task is listed as a blocker
+}
+
+// standupFacts is one project's part of a user's standup
+type standupFacts struct {
+	ProjectID    uint         `json:"project_id"` // 0 for tasks outside projects
+	ProjectTitle string       `json:"project_title"`
+	Completed    []digestTask `json:"completed_yesterday"`
+	Planned      []digestTask `json:"planned_today"`
+	Blockers     []digestTask `json:"blockers"`
+}
+
+// digestChatMessage is a chat message as the project digest prompt lists it
+type digestChatMessage struct {
+	Room    string    `json:"room"`
+	Sender  string    `json:"sender"`
+	Time    time.Time `json:"time"`
+	Content string    `json:"content"`
+}
+
+// projectDigestFacts is a project's week as the digest prompt describes it
+type projectDigestFacts struct {
+	ProjectID        uint                 `json:"project_id"`
+	ProjectTitle     string               `json:"project_title"`
+	ProjectStatus    models.ProjectStatus `json:"project_status"`
+	EndDate          *time.Time           `json:"end_date,omitempty"`
+	Statistics       interface{}          `json:"statistics"`
+	UserPerformance  interface{}          `json:"user_performance"`
+	Created          []digestTask         `json:"created_this_week"`
+	Completed        []digestTask         `json:"completed_this_week"`
+	UpdatedCount     int64                `json:"tasks_updated_this_week"`
+	Overdue          []digestTask         `json:"overdue"`
+	Blocked          []digestTask         `json:"blocked"`
+	NewMembers       []string             `json:"new_members"`
+	ChatMessageCount int64                `json:"chat_messages_this_week"`
+	Chat             []digestChatMessage  `json:"recent_chat,omitempty"` // Prompt only, never archived
+}
+
+// standupSection is the AI's standup for one project
+type standupSection struct {
+	ProjectID int    `json:"project_id"`
+	Yesterday string `json:"yesterday"`
+	Today     string `json:"today"`
+	Blockers  string `json:"blockers"`
+}
+
+type standupAIResponse struct {
+	Sections []standupSection `json:"sections"`
+}
+
+func NewAIDigestService(db *gorm.DB, notificationService *NotificationService, wsService *WebSocketService) *AIDigestService {
+	service := &AIDigestService{
+		DB:                  db,
+		WorkSchedule:        config.GetDefaultWorkSchedule(),
+		TaskService:         NewTaskService(db),
+		notificationService: notificationService,
+		wsService:           wsService,
+		standupAt:           parseDigestTime("AI_STANDUP_TIME", 8*time.Hour+30*time.Minute),
+		weeklyAt:            parseDigestTime("AI_WEEKLY_DIGEST_TIME", 14*time.Hour),
+	}
+
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		log.Printf("Warning: GEMINI_API_KEY not set, AI digests will be disabled")
+		return service
+	}
+
+	client, err := genai.NewClient(context.Background(), option.WithAPIKey(apiKey))
+	if err != nil {
+		log.Printf("Error creating Gemini client for digests: %v", err)
+		return service
+	}
+
+	model := client.GenerativeModel(geminiModelName)
+	model.SetTemperature(0.4) // Lower temperature so summaries stick to the facts
+	model.SetMaxOutputTokens(2048)
+
+	service.client = client
+	service.model = model
+	return service
+}
+
+// parseDigestTime reads an HH:MM time of day from the environment
+func parseDigestTime(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		log.Printf("Warning: %s must be HH:MM, using the default: %v", name, err)
+		return fallback
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute
+}
+
+// StartDigestSweep periodically writes the standups and weekly digests that have come due
+func (s *AIDigestService) StartDigestSweep(interval time.Duration) {
+	if s.model == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			now := time.Now()
+			if sent, err := s.SendDueStandups(now); err != nil {
+				log.Printf("AI standup sweep failed: %v", err)
+			} else if sent > 0 {
+				log.Printf("Sent %d AI daily standups", sent)
+			}
+			if sent, err := s.SendDueProjectDigests(now); err != nil {
+				log.Printf("AI project digest sweep failed: %v", err)
+			} else if sent > 0 {
+				log.Printf("Sent %d AI weekly project digests", sent)
+			}
+		}
+	}()
+}
+
+// SendDueStandups writes today's standup for every user who wants one, has not had one yet, and
+// finished work on the previous working day or has work open. Standups are written on working
+// days once the configured time of day has passed.
+func (s *AIDigestService) SendDueStandups(now time.Time) (int, error) {
+	local := now.In(s.WorkSchedule.Location())
+	if !s.WorkSchedule.IsWorkingDay(local) {
+		return 0, nil
+	}
+	todayStart := digestDayStart(local)
+	if local.Before(todayStart.Add(s.standupAt)) {
+		return 0, nil
+	}
+	periodStart := s.previousWorkingDay(todayStart)
+
+	var userIDs []uint
+	err := s.DB.Model(&models.User{}).
+		Joins("LEFT JOIN user_notification_preferences p ON p.user_id = users.id AND p.deleted_at IS NULL").
+		Where("(p.id IS NULL OR p.daily_standup)").
+		Where(`NOT EXISTS (SELECT 1 FROM ai_digests d WHERE d.user_id = users.id AND d.type = ? AND d.period_end = ? AND d.deleted_at IS NULL)`,
+			models.AIDigestDailyStandup, todayStart).
+		Where(`EXISTS (SELECT 1 FROM tasks t WHERE t.user_id = users.id AND t.deleted_at IS NULL
+			AND (t.status IN ? OR (t.status = ? AND t.updated_at >= ? AND t.updated_at < ?)))`,
+			[]models.TaskStatus{models.TaskStatusPending, models.TaskStatusInProgress}, models.TaskStatusCompleted, periodStart, todayStart).
+		Pluck("users.id", &userIDs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, userID := range userIDs {
+		if !s.digestAttemptDue(models.AIDigestDailyStandup, userID, periodStart, "", now) {
+			continue
+		}
+		digests, err := s.writeStandup(userID, periodStart, todayStart, now)
+		if errors.Is(err, ErrAIBudgetExceeded) {
+			return sent, err
+		}
+		if err != nil {
+			log.Printf("Failed to write the daily standup of user %d: %v", userID, err)
+			s.recordDigestFailure(models.AIDigestDailyStandup, userID, periodStart, "", now, err)
+			continue
+		}
+		if len(digests) > 0 {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// writeStandup writes, archives and delivers one user's standup
+func (s *AIDigestService) writeStandup(userID uint, periodStart, periodEnd, now time.Time) ([]models.AIDigest, error) {
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	facts, err := s.standupFacts(userID, periodStart, periodEnd, now)
+	if err != nil || len(facts) == 0 {
+		return nil, err
+	}
+
+	recipient := s.recipientFor(userID, s.digestPreference(userID))
+	sections, err := s.generateStandup(user, facts, periodStart, periodEnd, recipient.Language)
+	if err != nil {
+		return nil, err
+	}
+
+	factsByProject := make(map[uint]standupFacts, len(facts))
+	for _, projectFacts := range facts {
+		factsByProject[projectFacts.ProjectID] = projectFacts
+	}
+
+	digests := make([]models.AIDigest, 0, len(sections))
+	parts := make([]string, 0, len(sections))
+	for _, section := range sections {
+		projectFacts := factsByProject[uint(section.ProjectID)]
+		factsJSON, _ := json.Marshal(projectFacts)
+		content := standupSectionText(section, recipient.Language)
+
+		digest := models.AIDigest{
+			Type:        models.AIDigestDailyStandup,
+			UserID:      &userID,
+			PeriodStart: periodStart,
+			PeriodEnd:   periodEnd,
+			Language:    recipient.Language,
+			Content:     content,
+			Facts:       string(factsJSON),
+		}
+		if projectFacts.ProjectID != 0 {
+			projectID := projectFacts.ProjectID
+			digest.ProjectID = &projectID
+		}
+		digests = append(digests, digest)
+
+		heading := projectFacts.ProjectTitle
+		if projectFacts.ProjectID == 0 {
+			heading = digestText(recipient.Language, "Other tasks", "مهام أخرى")
+		}
+		parts = append(parts, fmt.Sprintf("📁 %s\n%s", heading, content))
+	}
+
+	if err := s.DB.Create(&digests).Error; err != nil {
+		return nil, err
+	}
+
+	digestIDs := make([]uint, len(digests))
+	for i, digest := range digests {
+		digestIDs[i] = digest.ID
+	}
+	title := digestText(recipient.Language, "Your daily standup", "ملخصك اليومي") + " - " + periodEnd.Format("2006-01-02")
+	s.deliver(recipient, models.NotificationTypeAIDailyStandup, title, strings.Join(parts, "\n\n"), map[string]interface{}{
+		"digest_type": models.AIDigestDailyStandup,
+		"digest_ids":  digestIDs,
+		"date":        periodEnd.Format("2006-01-02"),
+	})
+
+	return digests, nil
+}
+
+// standupFacts gathers a user's tasks by project: those completed in the period, those planned
+// for today and those blocked, overdue or at high deadline risk
+func (s *AIDigestService) standupFacts(userID uint, periodStart, periodEnd, now time.Time) ([]standupFacts, error) {
+	var completed []models.Task
+	err := s.DB.Preload("Project").
+		Where("user_id = ? AND status = ? AND updated_at >= ? AND updated_at < ?", userID, models.TaskStatusCompleted, periodStart, periodEnd).
+		Order("updated_at ASC").
+		Find(&completed).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var active []models.Task
+	err = s.DB.Preload("Project").
+		Where("user_id = ? AND status IN ?", userID, []models.TaskStatus{models.TaskStatusPending, models.TaskStatusInProgress}).
+		Order("CASE WHEN status = 'in_progress' THEN 0 ELSE 1 END, COALESCE(end_time, due_date) ASC NULLS LAST").
+		Find(&active).Error
+	if err != nil {
+		return nil, err
+	}
+
+	stateNames := s.workflowStateNames(append(completed, active...))
+	risks := s.latestDeadlineRisks(active)
+
+	byProject := map[uint]*standupFacts{}
+	factsFor := func(task models.Task) *standupFacts {
+		projectID := uint(0)
+		title := ""
+		if task.Project != nil {
+			projectID, title = task.Project.ID, task.Project.Title
+		}
+		if byProject[projectID] == nil {
+			byProject[projectID] = &standupFacts{
+				ProjectID:    projectID,
+				ProjectTitle: title,
+				Completed:    []digestTask{},
+				Planned:      []digestTask{},
+				Blockers:     []digestTask{},
+			}
+		}
+		return byProject[projectID]
+	}
+
+	for _, task := range completed {
+		facts := factsFor(task)
+		if len(facts.Completed) < digestTasksPerList {
+			facts.Completed = append(facts.Completed, s.toDigestTask(task, stateNames, ""))
+		}
+	}
+	for _, task := range active {
+		facts := factsFor(task)
+		if note := s.blockerNote(task, stateNames, risks[task.ID], now); note != "" {
+			if len(facts.Blockers) < digestTasksPerList {
+				facts.Blockers = append(facts.Blockers, s.toDigestTask(task, stateNames, note))
+			}
+			continue
+		}
+		if len(facts.Planned) < digestTasksPerList {
+			facts.Planned = append(facts.Planned, s.toDigestTask(task, stateNames, ""))
+		}
+	}
+
+	facts := make([]standupFacts, 0, len(byProject))
+	for _, projectFacts := range byProject {
+		facts = append(facts, *projectFacts)
+	}
+	sort.Slice(facts, func(i, j int) bool { return facts[i].ProjectID < facts[j].ProjectID })
+	return facts, nil
+}
+
+// generateStandup asks the AI for one standup section per project and keeps the valid ones
+func (s *AIDigestService) generateStandup(user models.User, facts []standupFacts, periodStart, periodEnd time.Time, language string) ([]standupSection, error) {
+	if s.model == nil {
+		return nil, fmt.Errorf("AI service not available")
+	}
+
+	factsJSON, _ := json.MarshalIndent(facts, "", "  ")
+	prompt := fmt.Sprintf(`
+You are writing %s's daily standup for their team. Use only the facts below; do not invent work.
+
+TODAY: %s
+PREVIOUS WORKING DAY: %s
+
+TASKS BY PROJECT (JSON):
+%s
+
+INSTRUCTIONS:
+1. Write one section per project in the list, with its project_id (0 is tasks outside projects)
+2. "yesterday": what was completed on the previous working day, 1-3 sentences in the first person; say plainly when nothing was completed
+3. "today": what is planned for today, in-progress tasks first and then the nearest deadlines
+4. "blockers": each blocker and why it blocks, or an empty string when there are none
+5. Refer to tasks by their titles and keep each section short enough to read in 20 seconds
+6. %s
+`,
+		user.Username,
+		periodEnd.Format("Monday 2006-01-02"),
+		periodStart.Format("Monday 2006-01-02"),
+		string(factsJSON),
+		digestLanguageInstruction(language),
+	)
+
+	ctx := context.Background()
+	usage := NewAIUsageService(s.DB)
+	model := withResponseSchema(s.model, dailyStandupSchema)
+	aiResponse, _, err := generateValidated(ctx, func(ctx context.Context, prompt string) (*genai.GenerateContentResponse, error) {
+		return usage.Generate(ctx, model, geminiModelName, AIFeatureDailyStandup, nil, prompt)
+	}, prompt, func(aiResponse *standupAIResponse) []AIValidationIssue {
+		return validateStandupSections(*aiResponse, facts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the sections that are usable even when others still have problems
+	known := make(map[int]bool, len(facts))
+	for _, projectFacts := range facts {
+		known[int(projectFacts.ProjectID)] = true
+	}
+	var sections []standupSection
+	for _, section := range aiResponse.Sections {
+		if !known[section.ProjectID] || standupSectionEmpty(section) {
+			continue
+		}
+		known[section.ProjectID] = false // Only the first section of a project is kept
+		sections = append(sections, section)
+	}
+	if len(sections) == 0 {
+		return nil, &AIValidationError{Issues: validateStandupSections(*aiResponse, facts)}
+	}
+	return sections, nil
+}
+
+// validateStandupSections checks that every project has exactly one section and no other
+// sections were added
+func validateStandupSections(aiResponse standupAIResponse, facts []standupFacts) []AIValidationIssue {
+	known := make(map[int]bool, len(facts))
+	for _, projectFacts := range facts {
+		known[int(projectFacts.ProjectID)] = true
+	}
+
+	var issues []AIValidationIssue
+	seen := map[int]bool{}
+	for i, section := range aiResponse.Sections {
+		item := i + 1
+		switch {
+		case !known[section.ProjectID]:
+			issues = append(issues, AIValidationIssue{Item: item, Field: "project_id", Message: fmt.Sprintf("%d is not one of the listed projects", section.ProjectID)})
+		case seen[section.ProjectID]:
+			issues = append(issues, AIValidationIssue{Item: item, Field: "project_id", Message: fmt.Sprintf("project %d already has a section", section.ProjectID)})
+		}
+		seen[section.ProjectID] = true
+		if standupSectionEmpty(section) {
+			issues = append(issues, AIValidationIssue{Item: item, Field: "today", Message: "yesterday and today must not both be empty"})
+		}
+	}
+	for _, projectFacts := range facts {
+		if !seen[int(projectFacts.ProjectID)] {
+			issues = append(issues, AIValidationIssue{Field: "sections", Message: fmt.Sprintf("project %d has no section", projectFacts.ProjectID)})
+		}
+	}
+	return issues
+}
+
+func standupSectionEmpty(section standupSection) bool {
+	return strings.TrimSpace(section.Yesterday) == "" && strings.TrimSpace(section.Today) == ""
+}
+
+// standupSectionText lays a section out under localized headings
+func standupSectionText(section standupSection, language string) string {
+	blockers := strings.TrimSpace(section.Blockers)
+	if blockers == "" {
+		blockers = digestText(language, "None", "لا يوجد")
+	}
+	return fmt.Sprintf("%s: %s\n%s: %s\n%s: %s",
+		digestText(language, "Yesterday", "أمس"), strings.TrimSpace(section.Yesterday),
+		digestText(language, "Today", "اليوم"), strings.TrimSpace(section.Today),
+		digestText(language, "Blockers", "العوائق"), blockers,
+	)
+}
+
+// SendDueProjectDigests writes the weekly digest of every active project that had activity in
+// the past week. Digests are written on the last working day of the week once the configured
+// time of day has passed, once per language of the project's managers and heads.
+func (s *AIDigestService) SendDueProjectDigests(now time.Time) (int, error) {
+	local := now.In(s.WorkSchedule.Location())
+	if !s.WorkSchedule.IsWorkingDay(local) || s.WorkSchedule.IsWorkingDay(local.AddDate(0, 0, 1)) {
+		return 0, nil
+	}
+	todayStart := digestDayStart(local)
+	if local.Before(todayStart.Add(s.weeklyAt)) {
+		return 0, nil
+	}
+	periodStart := todayStart.AddDate(0, 0, -6)
+
+	var projects []models.Project
+	if err := s.DB.Where("status = ?", models.ProjectStatusActive).Find(&projects).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, project := range projects {
+		// One digest per language the recipients read; kept in English when nobody receives it
+		byLanguage := map[string][]digestRecipient{}
+		for _, recipient := range s.projectDigestRecipients(project) {
+			byLanguage[recipient.Language] = append(byLanguage[recipient.Language], recipient)
+		}
+		if len(byLanguage) == 0 {
+			byLanguage["en"] = nil
+		}
+		// Skip the languages written this week, and those that failed until they are due again
+		for language := range byLanguage {
+			if s.projectDigestWritten(project.ID, periodStart, language) ||
+				!s.digestAttemptDue(models.AIDigestWeeklyProject, project.ID, periodStart, language, now) {
+				delete(byLanguage, language)
+			}
+		}
+		if len(byLanguage) == 0 {
+			continue
+		}
+
+		facts, active, err := s.projectDigestFacts(project, periodStart, now)
+		if err != nil {
+			log.Printf("Failed to gather the weekly digest of project %d: %v", project.ID, err)
+			continue
+		}
+		if !active {
+			continue
+		}
+
+		for language, recipients := range byLanguage {
+			digest, err := s.writeProjectDigest(project, facts, periodStart, now, language, nil)
+			if errors.Is(err, ErrAIBudgetExceeded) {
+				return sent, err
+			}
+			if err != nil {
+				log.Printf("Failed to write the weekly digest of project %d: %v", project.ID, err)
+				s.recordDigestFailure(models.AIDigestWeeklyProject, project.ID, periodStart, language, now, err)
+				continue
+			}
+			title := digestText(language, "Weekly digest", "الملخص الأسبوعي") + ": " + project.Title
+			for _, recipient := range recipients {
+				s.deliver(recipient, models.NotificationTypeAIWeeklyDigest, title, digest.Content, map[string]interface{}{
+					"digest_type": models.AIDigestWeeklyProject,
+					"digest_id":   digest.ID,
+					"project_id":  project.ID,
+				})
+			}
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// projectDigestWritten reports whether a project's weekly digest for the period exists in a language
+func (s *AIDigestService) projectDigestWritten(projectID uint, periodStart time.Time, language string) bool {
+	var count int64
+	s.DB.Model(&models.AIDigest{}).
+		Where("project_id = ? AND type = ? AND period_start = ? AND language = ?", projectID, models.AIDigestWeeklyProject, periodStart, language).
+		Count(&count)
+	return count > 0
+}
+
+// digestAttemptDue reports whether the sweep may try a digest: it never failed, or it failed fewer
+// than digestMaxAttempts times and not within the last digestRetryDelay
+func (s *AIDigestService) digestAttemptDue(digestType models.AIDigestType, subjectID uint, periodStart time.Time, language string, now time.Time) bool {
+	var failure models.AIDigestFailure
+	err := s.DB.Where("type = ? AND subject_id = ? AND period_start = ? AND language = ?", digestType, subjectID, periodStart, language).
+		First(&failure).Error
+	if err != nil {
+		return true
+	}
+	return failure.Attempts < digestMaxAttempts && now.Sub(failure.LastAttemptAt) >= digestRetryDelay
+}
+
+// recordDigestFailure counts a failed attempt at a digest
+func (s *AIDigestService) recordDigestFailure(digestType models.AIDigestType, subjectID uint, periodStart time.Time, language string, now time.Time, cause error) {
+	failure := models.AIDigestFailure{
+		Type:          digestType,
+		SubjectID:     subjectID,
+		PeriodStart:   periodStart,
+		Language:      language,
+		LastAttemptAt: now,
+	}
+	err := s.DB.Where(map[string]interface{}{
+		"type":         digestType,
+		"subject_id":   subjectID,
+		"period_start": periodStart,
+		"language":     language,
+	}).FirstOrCreate(&failure).Error
+	if err == nil {
+		err = s.DB.Model(&failure).Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_attempt_at": now,
+			"last_error":      cause.Error(),
+		}).Error
+	}
+	if err != nil {
+		log.Printf("Failed to record the failed %s digest of %d: %v", digestType, subjectID, err)
+	}
+}
+
+// GenerateProjectDigest writes a digest of the project's past seven days now, in the language of
+// the manager asking for it. It is archived with the scheduled ones but not delivered.
+func (s *AIDigestService) GenerateProjectDigest(projectID, requestedBy uint) (*models.AIDigest, error) {
+	var project models.Project
+	if err := s.DB.First(&project, projectID).Error; err != nil {
+		return nil, ErrDigestProjectNotFound
+	}
+
+	now := time.Now()
+	periodStart := now.AddDate(0, 0, -7)
+	facts, _, err := s.projectDigestFacts(project, periodStart, now)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient := s.recipientFor(requestedBy, s.digestPreference(requestedBy))
+	return s.writeProjectDigest(project, facts, periodStart, now, recipient.Language, &requestedBy)
+}
+
+// writeProjectDigest writes and archives a project digest in one language
+func (s *AIDigestService) writeProjectDigest(project models.Project, facts *projectDigestFacts, periodStart, periodEnd time.Time, language string, requestedBy *uint) (*models.AIDigest, error) {
+	if s.model == nil {
+		return nil, fmt.Errorf("AI service not available")
+	}
+
+	factsJSON, _ := json.MarshalIndent(facts, "", "  ")
+	prompt := fmt.Sprintf(`
+You are writing the weekly digest of the project "%s" for its managers. They already see the numbers of the project report; tell the story behind them using only the facts below, without inventing anything.
+
+PERIOD: %s to %s
+
+PROJECT ACTIVITY (JSON):
+%s
+
+INSTRUCTIONS:
+1. Start with a two-sentence overview of how the week went
+2. Follow with short paragraphs on: progress made (name the completed work and who did it), new work that came in, risks and blockers (overdue and blocked tasks and who owns them), and what the team discussed in chat
+3. Skip a paragraph when there is nothing to say about it
+4. End with 2-3 concrete points for next week
+5. Use plain text with short headings, no tables, and stay under 300 words
+6. %s
+`,
+		project.Title,
+		periodStart.In(s.WorkSchedule.Location()).Format("2006-01-02"),
+		periodEnd.In(s.WorkSchedule.Location()).Format("2006-01-02"),
+		string(factsJSON),
+		digestLanguageInstruction(language),
+	)
+
+	usage := NewAIUsageService(s.DB)
+	resp, err := usage.Generate(context.Background(), s.model, geminiModelName, AIFeatureProjectDigest, requestedBy, prompt)
+	if err != nil {
+		return nil, err
+	}
+	content, err := aiResponseText(resp)
+	if err != nil {
+		return nil, err
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, &AIValidationError{Issues: []AIValidationIssue{{Field: "response", Message: "must not be empty"}}}
+	}
+
+	// Archive the facts without the chat messages themselves
+	archived := *facts
+	archived.Chat = nil
+	archivedJSON, _ := json.Marshal(archived)
+
+	projectID := project.ID
+	digest := &models.AIDigest{
+		Type:        models.AIDigestWeeklyProject,
+		ProjectID:   &projectID,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Language:    language,
+		Content:     content,
+		Facts:       string(archivedJSON),
+	}
+	if err := s.DB.Create(digest).Error; err != nil {
+		return nil, err
+	}
+	return digest, nil
+}
+
+// projectDigestFacts gathers a project's report numbers, task changes and team chat for the
+// period. It reports whether anything happened in the period.
+func (s *AIDigestService) projectDigestFacts(project models.Project, periodStart, now time.Time) (*projectDigestFacts, bool, error) {
+	facts := &projectDigestFacts{
+		ProjectID:     project.ID,
+		ProjectTitle:  project.Title,
+		ProjectStatus: project.Status,
+		EndDate:       project.EndDate,
+		Created:       []digestTask{},
+		Completed:     []digestTask{},
+		Overdue:       []digestTask{},
+		Blocked:       []digestTask{},
+		NewMembers:    []string{},
+		Chat:          []digestChatMessage{},
+	}
+
+	report, err := s.TaskService.GetProjectReport(project.ID, "weekly")
+	if err != nil {
+		return nil, false, err
+	}
+	facts.Statistics = report["statistics"]
+	facts.UserPerformance = report["user_performance"]
+
+	var created, completed, active []models.Task
+	if err := s.DB.Preload("User").
+		Where("project_id = ? AND created_at >= ? AND created_at < ?", project.ID, periodStart, now).
+		Order("created_at ASC").Find(&created).Error; err != nil {
+		return nil, false, err
+	}
+	if err := s.DB.Preload("User").
+		Where("project_id = ? AND status = ? AND updated_at >= ? AND updated_at < ?", project.ID, models.TaskStatusCompleted, periodStart, now).
+		Order("updated_at ASC").Find(&completed).Error; err != nil {
+		return nil, false, err
+	}
+	if err := s.DB.Preload("User").
+		Where("project_id = ? AND status IN ?", project.ID, []models.TaskStatus{models.TaskStatusPending, models.TaskStatusInProgress}).
+		Order("COALESCE(end_time, due_date) ASC NULLS LAST").Find(&active).Error; err != nil {
+		return nil, false, err
+	}
+	s.DB.Model(&models.Task{}).
+		Where("project_id = ? AND updated_at >= ? AND updated_at < ?", project.ID, periodStart, now).
+		Count(&facts.UpdatedCount)
+
+	stateNames := s.workflowStateNames(append(append(created, completed...), active...))
+	for _, task := range created {
+		if len(facts.Created) < digestTasksPerList*2 {
+			facts.Created = append(facts.Created, s.toDigestTask(task, stateNames, ""))
+		}
+	}
+	for _, task := range completed {
+		if len(facts.Completed) < digestTasksPerList*2 {
+			facts.Completed = append(facts.Completed, s.toDigestTask(task, stateNames, ""))
+		}
+	}
+	for _, task := range active {
+		if due := digestTaskDue(task); due != nil && due.Before(now) && len(facts.Overdue) < digestTasksPerList*2 {
+			facts.Overdue = append(facts.Overdue, s.toDigestTask(task, stateNames, "overdue since "+due.In(s.WorkSchedule.Location()).Format("2006-01-02")))
+		}
+		if isBlockedState(task, stateNames) && len(facts.Blocked) < digestTasksPerList*2 {
+			facts.Blocked = append(facts.Blocked, s.toDigestTask(task, stateNames, ""))
+		}
+	}
+
+	s.DB.Model(&models.User{}).
+		Joins("JOIN user_projects ON user_projects.user_id = users.id").
+		Where("user_projects.project_id = ? AND user_projects.joined_at >= ? AND user_projects.joined_at < ?", project.ID, periodStart, now).
+		Pluck("users.username", &facts.NewMembers)
+
+	if err := s.projectChat(facts, periodStart, now); err != nil {
+		return nil, false, err
+	}
+
+	hadActivity := len(facts.Created) > 0 || len(facts.Completed) > 0 || facts.UpdatedCount > 0 || facts.ChatMessageCount > 0
+	return facts, hadActivity, nil
+}
+
+// projectChat adds the week's messages from the project's team rooms. Only rooms explicitly tied
+// to the project are read, never private conversations between its members. AI assistant rooms
+// and AI answers are left out.
+func (s *AIDigestService) projectChat(facts *projectDigestFacts, periodStart, now time.Time) error {
+	var roomIDs []uint
+	err := s.DB.Model(&models.ChatRoom{}).
+		Where("project_id = ? AND name NOT LIKE ?", facts.ProjectID, "AI Assistant%").
+		Pluck("id", &roomIDs).Error
+	if err != nil || len(roomIDs) == 0 {
+		return err
+	}
+
+	weekMessages := func() *gorm.DB {
+		return s.DB.Model(&models.ChatMessage{}).
+			Where("chat_room_id IN ? AND created_at >= ? AND created_at < ?", roomIDs, periodStart, now).
+			Where("COALESCE(metadata, '') NOT LIKE ?", `%"ai_response":true%`)
+	}
+	if err := weekMessages().Count(&facts.ChatMessageCount).Error; err != nil {
+		return err
+	}
+
+	var messages []models.ChatMessage
+	if err := weekMessages().Preload("Sender").Preload("ChatRoom").
+		Order("created_at DESC").Limit(digestChatMessages).
+		Find(&messages).Error; err != nil {
+		return err
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		facts.Chat = append(facts.Chat, digestChatMessage{
+			Room:    messages[i].ChatRoom.Name,
+			Sender:  messages[i].Sender.Username,
+			Time:    messages[i].CreatedAt,
+			Content: truncateRunes(messages[i].Content, digestChatMessageChars),
+		})
+	}
+	return nil
+}
+
+// projectDigestRecipients are the project's creator and its members with the manager or head
+// project role who want weekly digests
+func (s *AIDigestService) projectDigestRecipients(project models.Project) []digestRecipient {
+	var userIDs []uint
+	s.DB.Model(&models.User{}).
+		Where("(id = ? OR id IN (SELECT user_id FROM user_projects WHERE project_id = ? AND role IN ?))",
+			project.CreatedBy, project.ID, []string{"manager", "head"}).
+		Order("id ASC").
+		Pluck("id", &userIDs)
+
+	var recipients []digestRecipient
+	for _, userID := range userIDs {
+		preference := s.digestPreference(userID)
+		if !preference.WeeklyDigest {
+			continue
+		}
+		recipients = append(recipients, s.recipientFor(userID, preference))
+	}
+	return recipients
+}
+
+// GetProjectDigests returns a project's archived digests, newest first. Project members, Admins
+// and Managers can read them.
+func (s *AIDigestService) GetProjectDigests(projectID, userID uint, role models.Role, filter AIDigestFilter) ([]models.AIDigest, error) {
+	var project models.Project
+	if err := s.DB.First(&project, projectID).Error; err != nil {
+		return nil, ErrDigestProjectNotFound
+	}
+	if role != models.RoleAdmin && role != models.RoleManager {
+		var membership int64
+		s.DB.Model(&models.UserProject{}).Where("project_id = ? AND user_id = ?", projectID, userID).Count(&membership)
+		if membership == 0 {
+			return nil, ErrDigestAccessDenied
+		}
+	}
+
+	query := s.DB.Preload("User").Where("project_id = ?", projectID)
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Language != "" {
+		query = query.Where("language = ?", filter.Language)
+	}
+	if filter.From != nil {
+		query = query.Where("period_end >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("period_start < ?", *filter.To)
+	}
+	if filter.Limit <= 0 || filter.Limit > 200 {
+		filter.Limit = 50
+	}
+
+	var digests []models.AIDigest
+	err := query.Order("period_end DESC, id DESC").Limit(filter.Limit).Find(&digests).Error
+	return digests, err
+}
+
+// GetUserStandups returns the user's own standups of the past days, newest first
+func (s *AIDigestService) GetUserStandups(userID uint, days int) ([]models.AIDigest, error) {
+	if days <= 0 || days > 90 {
+		days = 14
+	}
+
+	var digests []models.AIDigest
+	err := s.DB.Preload("Project").
+		Where("user_id = ? AND type = ? AND period_end >= ?", userID, models.AIDigestDailyStandup, time.Now().AddDate(0, 0, -days)).
+		Order("period_end DESC, project_id ASC NULLS LAST").
+		Find(&digests).Error
+	return digests, err
+}
+
+// deliver sends a digest as a notification or, for users who prefer it, as a post in their AI
+// assistant room. Chat posts that fail fall back to a notification.
+func (s *AIDigestService) deliver(recipient digestRecipient, notificationType models.NotificationType, title, message string, data map[string]interface{}) {
+	if recipient.Delivery == models.AIDigestDeliveryChat {
+		err := s.postToAssistantRoom(recipient.UserID, notificationType, title, message, data)
+		if err == nil {
+			return
+		}
+		log.Printf("Failed to post digest to the AI room of user %d, sending a notification: %v", recipient.UserID, err)
+	}
+
+	if s.notificationService == nil {
+		return
+	}
+	if err := s.notificationService.CreateNotification(recipient.UserID, title, message, string(notificationType), data); err != nil {
+		log.Printf("Failed to deliver digest to user %d: %v", recipient.UserID, err)
+	}
+}
+
+// postToAssistantRoom saves the digest as an AI message in the user's assistant room and shows it
+// to the user if they are connected
+func (s *AIDigestService) postToAssistantRoom(userID uint, notificationType models.NotificationType, title, message string, data map[string]interface{}) error {
+	room, err := getOrCreateAIChatRoom(s.DB, userID)
+	if err != nil {
+		return err
+	}
+
+	metadata := map[string]interface{}{"ai_response": true, "action": notificationType}
+	for key, value := range data {
+		metadata[key] = value
+	}
+	metadataJSON, _ := json.Marshal(metadata)
+
+	content := title + "\n\n" + message
+	chatMessage := &models.ChatMessage{
+		ChatRoomID: room.ID,
+		SenderID:   userID,
+		Content:    content,
+		Metadata:   string(metadataJSON),
+	}
+	if err := s.DB.Create(chatMessage).Error; err != nil {
+		return err
+	}
+
+	if s.wsService != nil {
+		s.wsService.BroadcastToRoom(strconv.FormatUint(uint64(room.ID), 10), Notification{
+			Type:    "message",
+			Title:   "AI Assistant",
+			Message: content,
+			Data: map[string]interface{}{
+				"message_id": chatMessage.ID,
+				"room_id":    room.ID,
+				"sender":     "AI Assistant",
+				"content":    content,
+				"action":     notificationType,
+				"data":       data,
+			},
+			Timestamp: time.Now(),
+		})
+	}
+	return nil
+}
+
+// digestPreference returns the user's digest settings, the defaults when they have none
+func (s *AIDigestService) digestPreference(userID uint) models.UserNotificationPreference {
+	var preference models.UserNotificationPreference
+	if err := s.DB.Where("user_id = ?", userID).First(&preference).Error; err != nil {
+		return models.UserNotificationPreference{
+			UserID:         userID,
+			DailyStandup:   true,
+			WeeklyDigest:   true,
+			DigestDelivery: models.AIDigestDeliveryNotification,
+		}
+	}
+	return preference
+}
+
+// recipientFor works out how a user reads digests. Without a language preference the language
+// most of their recent chat messages were written in is used.
+func (s *AIDigestService) recipientFor(userID uint, preference models.UserNotificationPreference) digestRecipient {
+	recipient := digestRecipient{UserID: userID, Language: preference.Language, Delivery: preference.DigestDelivery}
+	if recipient.Delivery == "" {
+		recipient.Delivery = models.AIDigestDeliveryNotification
+	}
+	if recipient.Language != "" {
+		return recipient
+	}
+
+	var contents []string
+	s.DB.Model(&models.ChatMessage{}).
+		Where("sender_id = ? AND COALESCE(metadata, '') NOT LIKE ?", userID, `%"ai_response":true%`).
+		Order("created_at DESC").
+		Limit(10).
+		Pluck("content", &contents)
+
+	arabic := 0
+	for _, content := range contents {
+		if detectTextLanguage(content) == "ar" {
+			arabic++
+		}
+	}
+	recipient.Language = "en"
+	if len(contents) > 0 && arabic*2 > len(contents) {
+		recipient.Language = "ar"
+	}
+	return recipient
+}
+
+// workflowStateNames maps "projectID:key" to the names of the custom workflow states the tasks
+// are in
+func (s *AIDigestService) workflowStateNames(tasks []models.Task) map[string]string {
+	names := map[string]string{}
+	var projectIDs []uint
+	for _, task := range tasks {
+		if task.ProjectID != nil && task.WorkflowState != "" {
+			projectIDs = append(projectIDs, *task.ProjectID)
+		}
+	}
+	if len(projectIDs) == 0 {
+		return names
+	}
+
+	var states []models.ProjectWorkflowState
+	s.DB.Where("project_id IN ?", projectIDs).Find(&states)
+	for _, state := range states {
+		names[fmt.Sprintf("%d:%s", state.ProjectID, state.Key)] = state.Name
+	}
+	return names
+}
+
+// latestDeadlineRisks returns the deadline risk of each task's latest AI analysis
+func (s *AIDigestService) latestDeadlineRisks(tasks []models.Task) map[uint]string {
+	risks := map[uint]string{}
+	if len(tasks) == 0 {
+		return risks
+	}
+	taskIDs := make([]uint, len(tasks))
+	for i, task := range tasks {
+		taskIDs[i] = task.ID
+	}
+
+	var rows []struct {
+		TaskID       uint
+		DeadlineRisk string
+	}
+	s.DB.Raw(`
+		SELECT DISTINCT ON (task_id) task_id, deadline_risk
+		FROM ai_analyses
+		WHERE task_id IN ? AND task_type = 'regular' AND deleted_at IS NULL
+		ORDER BY task_id, analysis_date DESC
+	`, taskIDs).Scan(&rows)
+	for _, row := range rows {
+		risks[row.TaskID] = row.DeadlineRisk
+	}
+	return risks
+}
+
+// blockerNote says why an open task blocks progress: it is overdue, in a blocked workflow state
+// or at high deadline risk. It is empty for tasks on track.
+func (s *AIDigestService) blockerNote(task models.Task, stateNames map[string]string, risk string, now time.Time) string {
+	var notes []string
+	if due := digestTaskDue(task); due != nil && due.Before(now) {
+		notes = append(notes, "overdue since "+due.In(s.WorkSchedule.Location()).Format("2006-01-02"))
+	}
+	if isBlockedState(task, stateNames) {
+		notes = append(notes, "in workflow state "+digestTaskStatus(task, stateNames))
+	}
+	if risk == "high" || risk == "critical" {
+		notes = append(notes, risk+" deadline risk")
+	}
+	return strings.Join(notes, "; ")
+}
+
+func (s *AIDigestService) toDigestTask(task models.Task, stateNames map[string]string, note string) digestTask {
+	return digestTask{
+		ID:       task.ID,
+		Title:    task.Title,
+		Assignee: task.User.Username,
+		Status:   digestTaskStatus(task, stateNames),
+		Due:      digestTaskDue(task),
+		Note:     note,
+	}
+}
+
+// previousWorkingDay returns the start of the last working day before the day
+func (s *AIDigestService) previousWorkingDay(dayStart time.Time) time.Time {
+	day := dayStart.AddDate(0, 0, -1)
+	for i := 0; i < 7 && !s.WorkSchedule.IsWorkingDay(day); i++ {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// digestTaskStatus is the workflow state name of a task, or its status outside custom workflows
+func digestTaskStatus(task models.Task, stateNames map[string]string) string {
+	if task.ProjectID != nil && task.WorkflowState != "" {
+		if name, ok := stateNames[fmt.Sprintf("%d:%s", *task.ProjectID, task.WorkflowState)]; ok {
+			return name
+		}
+	}
+	return string(task.Status)
+}
+
+func isBlockedState(task models.Task, stateNames map[string]string) bool {
+	if task.WorkflowState == "" {
+		return false
+	}
+	label := strings.ToLower(task.WorkflowState + " " + digestTaskStatus(task, stateNames))
+	return strings.Contains(label, digestBlockedKeyword)
+}
+
+// digestTaskDue is when a task should be finished: its end time, or the legacy due date
+func digestTaskDue(task models.Task) *time.Time {
+	if task.EndTime != nil {
+		return task.EndTime
+	}
+	return task.DueDate
+}
+
+func digestDayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func digestLanguageInstruction(language string) string {
+	if language == "ar" {
+		return "Write in Arabic (العربية)."
+	}
+	return "Write in English."
+}
+
+func digestText(language, en, ar string) string {
+	if language == "ar" {
+		return ar
+	}
+	return en
+}
+
+// Close closes the AI client
+func (s *AIDigestService) Close() error {
+	if s.client != nil {
+		return s.client.Close()
+	}
+	return nil
+}
@@ -0,0 +1,524 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"project-x/models"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"gorm.io/gorm"
+)
+
+// taskAnalysisPromptVersions lists the revisions of the task analysis prompt a variant can use
+var taskAnalysisPromptVersions = map[string]string{
+	"v1": "Task, schedule, history and project context",
+	"v2": "v1 plus how far past estimates for the assignee were off",
+}
+
+// TaskAnalysisPromptVersions describes the available prompt versions
+func TaskAnalysisPromptVersions() map[string]string {
+	return taskAnalysisPromptVersions
+}
+
+// evaluationMinSamples is how many completed tasks a variant needs before it can be recommended
+const evaluationMinSamples = 20
+
+// expectedLateRates is how often tasks in each deadline risk bucket should end up late when the
+// risk levels are well calibrated
+var expectedLateRates = map[string]float64{
+	"low":      0.10,
+	"medium":   0.30,
+	"high":     0.60,
+	"critical": 0.85,
+}
+
+var riskLevels = []string{"low", "medium", "high", "critical"}
+
+var variantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// AnalysisVariant is a model and prompt version task analyses can be made with
+type AnalysisVariant struct {
+	Name          string `json:"name"`
+	ModelName     string `json:"model_name"`
+	PromptVersion string `json:"prompt_version"`
+	Weight        int    `json:"weight,omitempty"`
+	Description   string `json:"description,omitempty"`
+}
+
+// AnalysisVariantRequest configures one variant
+type AnalysisVariantRequest struct {
+	Name          string `json:"name" binding:"required"`
+	ModelName     string `json:"model_name" binding:"required"`
+	PromptVersion string `json:"prompt_version" binding:"required"`
+	Weight        int    `json:"weight"`
+	Description   string `json:"description"`
+}
+
+// defaultAnalysisVariant is used when no variants are configured
+var defaultAnalysisVariant = AnalysisVariant{Name: "default", ModelName: geminiModelName, PromptVersion: "v1", Weight: 1}
+
+// RiskBucketCalibration compares how often tasks given a deadline risk level were actually late
+type RiskBucketCalibration struct {
+	Risk             string  `json:"risk"`
+	Tasks            int     `json:"tasks"`
+	Late             int     `json:"late"`
+	LateRate         float64 `json:"late_rate"`
+	ExpectedLateRate float64 `json:"expected_late_rate"`
+}
+
+// DepartmentAccuracy is a variant's duration accuracy for one department
+type DepartmentAccuracy struct {
+	Department   string  `json:"department"`
+	Samples      int     `json:"samples"`
+	DurationMAE  float64 `json:"duration_mae_hours"`
+	AccuracyRate float64 `json:"accuracy_rate"`
+}
+
+// VariantEvaluation measures one variant against the actual results of completed tasks
+type VariantEvaluation struct {
+	Variant         string                  `json:"variant"`
+	ModelName       string                  `json:"model_name"`
+	PromptVersion   string                  `json:"prompt_version"`
+	Samples         int                     `json:"samples"`
+	DurationMAE     float64                 `json:"duration_mae_hours"`           // AI's own estimate
+	DurationBias    float64                 `json:"duration_bias_hours"`          // mean actual - predicted; positive means underestimates
+	ScheduledMAE    float64                 `json:"scheduled_duration_mae_hours"` // estimate used for scheduling, after the duration estimator
+	AccuracyRate    float64                 `json:"accuracy_rate"`                // share of predictions within the 70% accuracy threshold
+	RiskSamples     int                     `json:"risk_samples"`                 // completed tasks that had a due date
+	RiskCalibration []RiskBucketCalibration `json:"risk_calibration"`
+	RiskBrierScore  float64                 `json:"risk_brier_score"` // lower is better; 0.25 is no better than guessing
+	RiskMonotonic   bool                    `json:"risk_monotonic"`   // late rate rises with every risk level
+	Departments     []DepartmentAccuracy    `json:"departments"`
+}
+
+// AIEvaluationReport compares the analysis variants on tasks completed in a period
+type AIEvaluationReport struct {
+	From                 time.Time           `json:"from"`
+	To                   time.Time           `json:"to"`
+	MinSamples           int                 `json:"min_samples"`
+	Variants             []VariantEvaluation `json:"variants"`
+	Recommended          string              `json:"recommended_variant,omitempty"`
+	RecommendationReason string              `json:"recommendation_reason"`
+}
+
+// AIExperimentService assigns task analyses to model and prompt variants and evaluates them
+type AIExperimentService struct {
+	DB *gorm.DB
+}
+
+func NewAIExperimentService(db *gorm.DB) *AIExperimentService {
+	return &AIExperimentService{DB: db}
+}
+
+// GetVariants returns the configured variants, or the default one when none are configured
+func (s *AIExperimentService) GetVariants() ([]AnalysisVariant, error) {
+	var records []models.AIAnalysisVariant
+	if err := s.DB.Order("id ASC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return []AnalysisVariant{defaultAnalysisVariant}, nil
+	}
+
+	variants := make([]AnalysisVariant, 0, len(records))
+	for _, record := range records {
+		variants = append(variants, AnalysisVariant{
+			Name:          record.Name,
+			ModelName:     record.ModelName,
+			PromptVersion: record.PromptVersion,
+			Weight:        record.Weight,
+			Description:   record.Description,
+		})
+	}
+	return variants, nil
+}
+
+// SetVariants replaces the variant configuration. A weight of 0 keeps a variant for reporting
+// without assigning new tasks to it; at least one variant needs a positive weight.
+func (s *AIExperimentService) SetVariants(requests []AnalysisVariantRequest) ([]AnalysisVariant, error) {
+	if len(requests) == 0 {
+		return nil, errors.New("at least one variant is required")
+	}
+
+	records := make([]models.AIAnalysisVariant, 0, len(requests))
+	names := make(map[string]bool)
+	totalWeight := 0
+	for _, request := range requests {
+		name := strings.TrimSpace(request.Name)
+		if !variantNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid variant name '%s': use lowercase letters, digits, '-' and '_'", request.Name)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate variant name '%s'", name)
+		}
+		modelName := strings.TrimSpace(request.ModelName)
+		if !strings.HasPrefix(modelName, "gemini-") {
+			return nil, fmt.Errorf("variant '%s': model_name must be a Gemini model, such as %s", name, geminiModelName)
+		}
+		if _, ok := taskAnalysisPromptVersions[request.PromptVersion]; !ok {
+			return nil, fmt.Errorf("variant '%s': unknown prompt_version '%s'", name, request.PromptVersion)
+		}
+		if request.Weight < 0 || request.Weight > 100 {
+			return nil, fmt.Errorf("variant '%s': weight must be between 0 and 100", name)
+		}
+
+		names[name] = true
+		totalWeight += request.Weight
+		records = append(records, models.AIAnalysisVariant{
+			Name:          name,
+			ModelName:     modelName,
+			PromptVersion: request.PromptVersion,
+			Weight:        request.Weight,
+			Description:   strings.TrimSpace(request.Description),
+		})
+	}
+	if totalWeight == 0 {
+		return nil, errors.New("at least one variant needs a positive weight")
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("1 = 1").Delete(&models.AIAnalysisVariant{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetVariants()
+}
+
+// ResetVariants removes the variant configuration so every task uses the default variant
+func (s *AIExperimentService) ResetVariants() error {
+	return s.DB.Unscoped().Where("1 = 1").Delete(&models.AIAnalysisVariant{}).Error
+}
+
+// AssignVariants picks the variant each task is analyzed with. A task keeps the variant of its
+// last analysis while that variant is still assigned new tasks; other tasks are hashed onto the
+// variants by weight, so assignment is stable across requests.
+func (s *AIExperimentService) AssignVariants(tasks []models.Task) map[uint]AnalysisVariant {
+	variants, err := s.GetVariants()
+	if err != nil || len(variants) == 0 {
+		variants = []AnalysisVariant{defaultAnalysisVariant}
+	}
+
+	var weighted []AnalysisVariant
+	totalWeight := 0
+	for _, variant := range variants {
+		if variant.Weight > 0 {
+			weighted = append(weighted, variant)
+			totalWeight += variant.Weight
+		}
+	}
+	if len(weighted) == 0 {
+		weighted, totalWeight = []AnalysisVariant{defaultAnalysisVariant}, 1
+	}
+
+	assignments := make(map[uint]AnalysisVariant, len(tasks))
+	if len(weighted) == 1 {
+		for _, task := range tasks {
+			assignments[task.ID] = weighted[0]
+		}
+		return assignments
+	}
+
+	previous := s.previousVariants(tasks)
+	for _, task := range tasks {
+		if name, ok := previous[task.ID]; ok {
+			for _, variant := range weighted {
+				if variant.Name == name {
+					assignments[task.ID] = variant
+					break
+				}
+			}
+			if _, ok := assignments[task.ID]; ok {
+				continue
+			}
+		}
+
+		hash := fnv.New32a()
+		fmt.Fprintf(hash, "task:%d", task.ID)
+		slot := int(hash.Sum32() % uint32(totalWeight))
+		for _, variant := range weighted {
+			if slot < variant.Weight {
+				assignments[task.ID] = variant
+				break
+			}
+			slot -= variant.Weight
+		}
+	}
+	return assignments
+}
+
+// previousVariants returns the variant of each task's latest analysis
+func (s *AIExperimentService) previousVariants(tasks []models.Task) map[uint]string {
+	taskIDs := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		taskIDs = append(taskIDs, task.ID)
+	}
+
+	var rows []struct {
+		TaskID  uint
+		Variant string
+	}
+	s.DB.Raw(`
+		SELECT DISTINCT ON (task_id) task_id, variant
+		FROM ai_analyses
+		WHERE task_id IN ? AND task_type = 'regular' AND variant <> '' AND deleted_at IS NULL
+		ORDER BY task_id, created_at DESC
+	`, taskIDs).Scan(&rows)
+
+	previous := make(map[uint]string, len(rows))
+	for _, row := range rows {
+		previous[row.TaskID] = row.Variant
+	}
+	return previous
+}
+
+// EvaluateVariants compares the variants on analyses of tasks completed between two times.
+// Analyses made before variants existed are reported as the default variant.
+func (s *AIExperimentService) EvaluateVariants(from, to time.Time) (*AIEvaluationReport, error) {
+	var rows []struct {
+		Variant           string
+		ModelVersion      string
+		PromptVersion     string
+		PredictedDuration int
+		EstimatedDuration *int
+		ActualDuration    int
+		DeadlineRisk      string
+		ActualCompletion  time.Time
+		WasAccurate       *bool
+		DueDate           *time.Time
+		Department        string
+	}
+	err := s.DB.Raw(`
+		SELECT COALESCE(NULLIF(aa.variant, ''), 'default') AS variant,
+			aa.model_version, COALESCE(NULLIF(aa.prompt_version, ''), 'v1') AS prompt_version,
+			aa.predicted_duration, aa.estimated_duration, aa.actual_duration, aa.deadline_risk,
+			aa.actual_completion, aa.was_accurate, t.due_date, COALESCE(u.department, '') AS department
+		FROM ai_analyses aa
+		JOIN tasks t ON t.id = aa.task_id
+		LEFT JOIN users u ON u.id = t.user_id
+		WHERE aa.task_type = 'regular' AND aa.actual_duration IS NOT NULL AND aa.deleted_at IS NULL
+			AND aa.actual_completion >= ? AND aa.actual_completion <= ?
+	`, from, to).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	type accumulator struct {
+		evaluation                  VariantEvaluation
+		absError, bias, scheduledAE float64
+		accurate, brier             float64
+		buckets                     map[string]*RiskBucketCalibration
+		departments                 map[string]*DepartmentAccuracy
+	}
+	byKey := make(map[string]*accumulator)
+	var keys []string
+
+	for _, row := range rows {
+		key := row.Variant + "|" + row.ModelVersion + "|" + row.PromptVersion
+		acc, ok := byKey[key]
+		if !ok {
+			acc = &accumulator{
+				evaluation:  VariantEvaluation{Variant: row.Variant, ModelName: row.ModelVersion, PromptVersion: row.PromptVersion},
+				buckets:     make(map[string]*RiskBucketCalibration),
+				departments: make(map[string]*DepartmentAccuracy),
+			}
+			byKey[key] = acc
+			keys = append(keys, key)
+		}
+
+		absError := math.Abs(float64(row.ActualDuration - row.PredictedDuration))
+		scheduled := row.PredictedDuration
+		if row.EstimatedDuration != nil {
+			scheduled = *row.EstimatedDuration
+		}
+		accurate := row.WasAccurate != nil && *row.WasAccurate
+
+		acc.evaluation.Samples++
+		acc.absError += absError
+		acc.bias += float64(row.ActualDuration - row.PredictedDuration)
+		acc.scheduledAE += math.Abs(float64(row.ActualDuration - scheduled))
+		if accurate {
+			acc.accurate++
+		}
+
+		department := row.Department
+		if department == "" {
+			department = "Unassigned"
+		}
+		dept, ok := acc.departments[department]
+		if !ok {
+			dept = &DepartmentAccuracy{Department: department}
+			acc.departments[department] = dept
+		}
+		dept.Samples++
+		dept.DurationMAE += absError
+		if accurate {
+			dept.AccuracyRate++
+		}
+
+		expected, known := expectedLateRates[row.DeadlineRisk]
+		if row.DueDate == nil || !known {
+			continue
+		}
+		bucket, ok := acc.buckets[row.DeadlineRisk]
+		if !ok {
+			bucket = &RiskBucketCalibration{Risk: row.DeadlineRisk, ExpectedLateRate: expected}
+			acc.buckets[row.DeadlineRisk] = bucket
+		}
+		late := 0.0
+		bucket.Tasks++
+		if row.ActualCompletion.After(*row.DueDate) {
+			bucket.Late++
+			late = 1
+		}
+		acc.evaluation.RiskSamples++
+		acc.brier += (expected - late) * (expected - late)
+	}
+
+	report := &AIEvaluationReport{From: from, To: to, MinSamples: evaluationMinSamples, Variants: []VariantEvaluation{}}
+	for _, key := range keys {
+		acc := byKey[key]
+		evaluation := acc.evaluation
+		n := float64(evaluation.Samples)
+		evaluation.DurationMAE = roundTo(acc.absError/n, 2)
+		evaluation.DurationBias = roundTo(acc.bias/n, 2)
+		evaluation.ScheduledMAE = roundTo(acc.scheduledAE/n, 2)
+		evaluation.AccuracyRate = roundTo(acc.accurate/n*100, 1)
+
+		evaluation.RiskCalibration = []RiskBucketCalibration{}
+		evaluation.RiskMonotonic = true
+		lastRate := -1.0
+		for _, risk := range riskLevels {
+			bucket, ok := acc.buckets[risk]
+			if !ok {
+				continue
+			}
+			bucket.LateRate = roundTo(float64(bucket.Late)/float64(bucket.Tasks)*100, 1)
+			bucket.ExpectedLateRate = bucket.ExpectedLateRate * 100
+			if bucket.LateRate < lastRate {
+				evaluation.RiskMonotonic = false
+			}
+			lastRate = bucket.LateRate
+			evaluation.RiskCalibration = append(evaluation.RiskCalibration, *bucket)
+		}
+		if evaluation.RiskSamples > 0 {
+			evaluation.RiskBrierScore = roundTo(acc.brier/float64(evaluation.RiskSamples), 3)
+		}
+
+		evaluation.Departments = make([]DepartmentAccuracy, 0, len(acc.departments))
+		for _, dept := range acc.departments {
+			dept.DurationMAE = roundTo(dept.DurationMAE/float64(dept.Samples), 2)
+			dept.AccuracyRate = roundTo(dept.AccuracyRate/float64(dept.Samples)*100, 1)
+			evaluation.Departments = append(evaluation.Departments, *dept)
+		}
+		sort.Slice(evaluation.Departments, func(i, j int) bool {
+			return evaluation.Departments[i].Samples > evaluation.Departments[j].Samples
+		})
+
+		report.Variants = append(report.Variants, evaluation)
+	}
+	sort.Slice(report.Variants, func(i, j int) bool { return report.Variants[i].Samples > report.Variants[j].Samples })
+
+	report.Recommended, report.RecommendationReason = recommendVariant(report.Variants)
+	return report, nil
+}
+
+// recommendVariant picks the variant with the lowest duration MAE among those with enough
+// samples, breaking ties on deadline risk calibration
+func recommendVariant(variants []VariantEvaluation) (string, string) {
+	var eligible []VariantEvaluation
+	for _, variant := range variants {
+		if variant.Samples >= evaluationMinSamples {
+			eligible = append(eligible, variant)
+		}
+	}
+	if len(eligible) < 2 {
+		return "", fmt.Sprintf("At least two variants need %d completed tasks each before they can be compared", evaluationMinSamples)
+	}
+
+	sort.Slice(eligible, func(i, j int) bool {
+		if eligible[i].DurationMAE != eligible[j].DurationMAE {
+			return eligible[i].DurationMAE < eligible[j].DurationMAE
+		}
+		return eligible[i].RiskBrierScore < eligible[j].RiskBrierScore
+	})
+	best, next := eligible[0], eligible[1]
+	return best.Variant, fmt.Sprintf("Lowest duration error: %.2fh MAE vs %.2fh for '%s' (risk Brier score %.3f vs %.3f)",
+		best.DurationMAE, next.DurationMAE, next.Variant, best.RiskBrierScore, next.RiskBrierScore)
+}
+
+// roundTo rounds a value to the given number of decimals
+func roundTo(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(value*factor) / factor
+}
+
+// configureAnalysisModel applies the generation settings used for task analysis
+func configureAnalysisModel(model *genai.GenerativeModel) {
+	model.SetTemperature(0.3) // Lower temperature for more consistent analysis
+	model.SetTopP(0.8)
+	model.SetTopK(40)
+	model.SetMaxOutputTokens(2048)
+}
+
+// modelFor returns the model to call for a variant's model name
+func (a *AITimeOptimizer) modelFor(modelName string) *genai.GenerativeModel {
+	if a.model == nil || modelName == "" || modelName == geminiModelName {
+		return a.model
+	}
+
+	a.modelsMu.Lock()
+	defer a.modelsMu.Unlock()
+	if a.variantModels == nil {
+		a.variantModels = make(map[string]*genai.GenerativeModel)
+	}
+	model, ok := a.variantModels[modelName]
+	if !ok {
+		model = a.client.GenerativeModel(modelName)
+		configureAnalysisModel(model)
+		a.variantModels[modelName] = model
+	}
+	return model
+}
+
+// promptAdditions returns what a prompt version adds to the v1 task analysis prompt
+func (a *AITimeOptimizer) promptAdditions(task models.Task, promptVersion string) string {
+	if promptVersion != "v2" {
+		return ""
+	}
+
+	var history struct {
+		Samples  int64
+		AvgRatio float64
+	}
+	a.DB.Raw(`
+		SELECT COUNT(*) AS samples, COALESCE(AVG(aa.actual_duration::float / aa.predicted_duration), 0) AS avg_ratio
+		FROM ai_analyses aa
+		JOIN tasks t ON t.id = aa.task_id
+		WHERE t.user_id = ? AND aa.task_type = 'regular' AND aa.actual_duration IS NOT NULL
+			AND aa.predicted_duration > 0 AND aa.deleted_at IS NULL
+	`, task.UserID).Scan(&history)
+
+	if history.Samples < 3 {
+		return "\nESTIMATION FEEDBACK:\n- Not enough completed tasks to judge past estimates for this user\n"
+	}
+
+	direction := "longer"
+	percent := (history.AvgRatio - 1) * 100
+	if percent < 0 {
+		direction = "shorter"
+		percent = -percent
+	}
+	return fmt.Sprintf("\nESTIMATION FEEDBACK:\n- Across %d completed tasks, this user's tasks took on average %.0f%% %s than the AI estimated. Adjust your estimate for this bias.\n",
+		history.Samples, percent, direction)
+}
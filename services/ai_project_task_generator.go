@@ -3,11 +3,14 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"project-x/config"
 	"project-x/models"
+	"slices"
 	"strings"
 	"time"
 
@@ -30,7 +33,7 @@ type GeneratedTask struct {
 	AssignedToID   uint       `json:"assigned_to_user_id"`
 	EstimatedHours int        `json:"estimated_hours"`
 	Priority       string     `json:"priority"`     // high, medium, low
-	Dependencies   []uint     `json:"dependencies"` // IDs of tasks this depends on
+	Dependencies   []uint     `json:"dependencies"` // 1-based positions in the generated list of the tasks this depends on
 	StartTime      *time.Time `json:"start_time,omitempty"`
 	EndTime        *time.Time `json:"end_time,omitempty"`
 }
@@ -42,6 +45,7 @@ type ProjectTaskGenerationRequest struct {
 	TeamMembers  []TeamMemberInfo
 	StartDate    time.Time
 	EndDate      *time.Time
+	RequestedBy  uint // User the generation is metered against
 }
 
 type TeamMemberInfo struct {
@@ -56,6 +60,37 @@ type TeamMemberInfo struct {
 type TaskGenerationResponse struct {
 	Tasks   []GeneratedTask `json:"tasks"`
 	Summary string          `json:"summary"`
+
+	ValidationIssues []AIValidationIssue `json:"validation_issues,omitempty"` // Problems repaired in the kept tasks
+	RejectedTasks    []RejectedTask      `json:"rejected_tasks,omitempty"`    // Tasks left out of Tasks
+}
+
+// RejectedTask is a generated task left out of the plan because of problems that could not be
+// repaired
+type RejectedTask struct {
+	Item   int                 `json:"item"` // 1-based position in the AI's list
+	Title  string              `json:"title"`
+	Issues []AIValidationIssue `json:"validation_issues"`
+}
+
+var taskPriorities = []string{"high", "medium", "low"}
+
+// generatedTaskResponse is a task as the AI returns it. Times stay strings so that one bad date
+// does not fail the whole answer.
+type generatedTaskResponse struct {
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	AssignedToID   int    `json:"assigned_to_user_id"`
+	EstimatedHours int    `json:"estimated_hours"`
+	Priority       string `json:"priority"`
+	Dependencies   []int  `json:"dependencies"`
+	StartTime      string `json:"start_time"`
+	EndTime        string `json:"end_time"`
+}
+
+type taskGenerationAIResponse struct {
+	Tasks   []generatedTaskResponse `json:"tasks"`
+	Summary string                  `json:"summary"`
 }
 
 func NewAIProjectTaskGenerator(db *gorm.DB, taskService *TaskService) *AIProjectTaskGenerator {
@@ -84,7 +119,7 @@ func NewAIProjectTaskGenerator(db *gorm.DB, taskService *TaskService) *AIProject
 	}
 
 	// Configure the model
-	model := client.GenerativeModel("gemini-2.0-flash")
+	model := client.GenerativeModel(geminiModelName)
 	model.SetTemperature(0.7) // Higher temperature for more creative task generation
 	model.SetTopP(0.9)
 	model.SetTopK(40)
@@ -108,44 +143,374 @@ func (a *AIProjectTaskGenerator) GenerateProjectTasks(req ProjectTaskGenerationR
 	// Build prompt for AI
 	prompt := a.buildTaskGenerationPrompt(req)
 
-	// Get AI response
+	// Get AI response, retrying once with the problems when the answer fails validation
+	ctx := context.Background()
+	usage := NewAIUsageService(a.DB)
+	model := withResponseSchema(a.model, taskGenerationSchema)
+	aiResponse, issues, err := generateValidated(ctx, func(ctx context.Context, prompt string) (*genai.GenerateContentResponse, error) {
+		return usage.Generate(ctx, model, geminiModelName, AIFeatureTaskGeneration, &req.RequestedBy, prompt)
+	}, prompt, func(aiResponse *taskGenerationAIResponse) []AIValidationIssue {
+		return a.validateGeneratedTasks(*aiResponse, req)
+	})
+	var validationErr *AIValidationError
+	if errors.Is(err, ErrAIBudgetExceeded) || errors.As(err, &validationErr) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AI response: %v", err)
+	}
+	if len(aiResponse.Tasks) == 0 {
+		return nil, &AIValidationError{Issues: issues}
+	}
+
+	response, _ := a.buildGenerationResponse(*aiResponse, req)
+	return response, nil
+}
+
+// PlanRevisionRequest asks for part of a task plan to be reworked following the manager's feedback
+type PlanRevisionRequest struct {
+	ProjectTaskGenerationRequest
+	Tasks    []PlanTask // The current plan, in order
+	Revise   []string   // Keys of the tasks to rework; empty for the whole plan
+	Feedback string
+}
+
+// PlanRevision is a revised task plan. Keys holds the key of the current task each task is or
+// replaces, empty for new tasks.
+type PlanRevision struct {
+	TaskGenerationResponse
+	Keys []string
+}
+
+// planRevisionTaskResponse is a revised task as the AI returns it
+type planRevisionTaskResponse struct {
+	Key string `json:"key"`
+	generatedTaskResponse
+}
+
+type planRevisionAIResponse struct {
+	Tasks   []planRevisionTaskResponse `json:"tasks"`
+	Summary string                     `json:"summary"`
+}
+
+// RevisePlan has the AI rework the listed tasks of a plan following the manager's feedback, with
+// the rest of the plan and the team as context. The answer is validated and repaired like a
+// generation answer; keys that are not in the current plan or are used twice are cleared, so
+// those tasks count as new.
+func (a *AIProjectTaskGenerator) RevisePlan(req PlanRevisionRequest) (*PlanRevision, error) {
+	if a.model == nil {
+		return nil, fmt.Errorf("AI service not available")
+	}
+
+	prompt := a.buildPlanRevisionPrompt(req)
+
 	ctx := context.Background()
-	resp, err := a.model.GenerateContent(ctx, genai.Text(prompt))
+	usage := NewAIUsageService(a.DB)
+	model := withResponseSchema(a.model, planRevisionSchema)
+	aiResponse, issues, err := generateValidated(ctx, func(ctx context.Context, prompt string) (*genai.GenerateContentResponse, error) {
+		return usage.Generate(ctx, model, geminiModelName, AIFeatureTaskGeneration, &req.RequestedBy, prompt)
+	}, prompt, func(aiResponse *planRevisionAIResponse) []AIValidationIssue {
+		return a.validatePlanRevision(*aiResponse, req)
+	})
+	var validationErr *AIValidationError
+	if errors.Is(err, ErrAIBudgetExceeded) || errors.As(err, &validationErr) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get AI response: %v", err)
 	}
+	if len(aiResponse.Tasks) == 0 {
+		return nil, &AIValidationError{Issues: issues}
+	}
 
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("empty AI response")
+	var keyIssues []AIValidationIssue
+	generation := taskGenerationAIResponse{Summary: aiResponse.Summary}
+	seen := make(map[string]bool)
+	for i, task := range aiResponse.Tasks {
+		key := strings.TrimSpace(task.Key)
+		if key != "" && (seen[key] || !slices.ContainsFunc(req.Tasks, func(current PlanTask) bool { return current.Key == key })) {
+			keyIssues = append(keyIssues, AIValidationIssue{Item: i + 1, Field: "key", Message: fmt.Sprintf("cleared %q, the task is treated as new", key), Repaired: true})
+			key = ""
+		}
+		if key != "" {
+			seen[key] = true
+		}
+		aiResponse.Tasks[i].Key = key
+		generation.Tasks = append(generation.Tasks, task.generatedTaskResponse)
 	}
 
-	// Parse AI response
-	responseText := fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0])
-	responseText = strings.TrimSpace(responseText)
+	response, keptItems := a.buildGenerationResponse(generation, req.ProjectTaskGenerationRequest)
+	revision := &PlanRevision{TaskGenerationResponse: *response, Keys: make([]string, len(keptItems))}
+	revision.ValidationIssues = append(keyIssues, revision.ValidationIssues...)
+	for i, item := range keptItems {
+		revision.Keys[i] = aiResponse.Tasks[item-1].Key
+	}
+	return revision, nil
+}
 
-	// Extract JSON from response (AI might wrap it in markdown code blocks)
-	jsonStart := strings.Index(responseText, "{")
-	jsonEnd := strings.LastIndex(responseText, "}")
-	if jsonStart == -1 || jsonEnd == -1 {
-		return nil, fmt.Errorf("invalid AI response format")
+// validatePlanRevision checks a revision answer: every task like a generated one, keys that belong
+// to the current plan and are used once, and every task that was not listed for revision kept
+func (a *AIProjectTaskGenerator) validatePlanRevision(aiResponse planRevisionAIResponse, req PlanRevisionRequest) []AIValidationIssue {
+	if len(aiResponse.Tasks) == 0 {
+		return []AIValidationIssue{{Field: "tasks", Message: "must not be empty"}}
 	}
 
-	jsonStr := responseText[jsonStart : jsonEnd+1]
+	var issues []AIValidationIssue
+	seen := make(map[string]bool)
+	for i, task := range aiResponse.Tasks {
+		item := i + 1
+		issues = append(issues, a.validateGeneratedTask(task.generatedTaskResponse, item, req.ProjectTaskGenerationRequest)...)
 
-	var aiResponse struct {
-		Tasks   []GeneratedTask `json:"tasks"`
-		Summary string          `json:"summary"`
+		key := strings.TrimSpace(task.Key)
+		if key == "" {
+			continue
+		}
+		if !slices.ContainsFunc(req.Tasks, func(current PlanTask) bool { return current.Key == key }) {
+			issues = append(issues, AIValidationIssue{Item: item, Field: "key", Message: fmt.Sprintf("%q is not a key of the current plan", key)})
+		} else if seen[key] {
+			issues = append(issues, AIValidationIssue{Item: item, Field: "key", Message: fmt.Sprintf("%q is used by more than one task", key)})
+		}
+		seen[key] = true
 	}
 
-	if err := json.Unmarshal([]byte(jsonStr), &aiResponse); err != nil {
-		log.Printf("Failed to parse AI response: %v\nResponse: %s", err, jsonStr)
-		return nil, fmt.Errorf("failed to parse AI response: %v", err)
+	if len(req.Revise) > 0 {
+		for _, task := range req.Tasks {
+			if !slices.Contains(req.Revise, task.Key) && !seen[task.Key] {
+				issues = append(issues, AIValidationIssue{Field: "tasks", Message: fmt.Sprintf("task %s (%q) is not listed for revision and must be kept", task.Key, task.Title)})
+			}
+		}
 	}
+	return issues
+}
+
+// buildPlanRevisionPrompt extends the generation prompt with the current plan, the tasks to
+// rework and the manager's feedback
+func (a *AIProjectTaskGenerator) buildPlanRevisionPrompt(req PlanRevisionRequest) string {
+	base := strings.TrimSuffix(strings.TrimSpace(a.buildTaskGenerationPrompt(req.ProjectTaskGenerationRequest)), "Generate tasks now:")
+
+	type keyedTask struct {
+		Key string `json:"key"`
+		GeneratedTask
+	}
+	current := make([]keyedTask, len(req.Tasks))
+	for i, task := range generatedFromPlanTasks(req.Tasks) {
+		current[i] = keyedTask{Key: req.Tasks[i].Key, GeneratedTask: task}
+	}
+	currentJSON, _ := json.MarshalIndent(current, "", "  ")
+
+	revise := "ALL TASKS (you may rework the whole plan)"
+	keepRule := "Any task may be changed, split, merged, moved or removed"
+	if len(req.Revise) > 0 {
+		revise = strings.Join(req.Revise, ", ")
+		keepRule = "Copy every task that is NOT listed for revision unchanged, with its key; only its position in the list may change"
+	}
+
+	return fmt.Sprintf(`%s
+CURRENT PLAN (in order; "dependencies" are 1-based positions in this list):
+%s
+
+TASKS TO REVISE: %s
+
+MANAGER FEEDBACK:
+%s
+
+REVISION INSTRUCTIONS:
+1. Return the complete revised plan, in order, in the output format above with a "key" added to every task
+2. %s
+3. Rework the tasks listed for revision following the feedback: change, split, merge, reorder or remove them, and add tasks where the feedback asks for it
+4. A reworked task keeps the key of the task it replaces; a task split off or added has an empty key; leave a task out to remove it
+5. "dependencies" are 1-based positions in YOUR returned list and only point to earlier tasks
+6. The summary describes what you changed
+
+Revise the plan now:
+`, base, currentJSON, revise, req.Feedback, keepRule)
+}
+
+// validateGeneratedTasks checks every task of a generation answer
+func (a *AIProjectTaskGenerator) validateGeneratedTasks(aiResponse taskGenerationAIResponse, req ProjectTaskGenerationRequest) []AIValidationIssue {
+	if len(aiResponse.Tasks) == 0 {
+		return []AIValidationIssue{{Field: "tasks", Message: "must not be empty"}}
+	}
+
+	var issues []AIValidationIssue
+	for i, task := range aiResponse.Tasks {
+		issues = append(issues, a.validateGeneratedTask(task, i+1, req)...)
+	}
+	return issues
+}
+
+// validateGeneratedTask checks one generated task: a title, an assignee from the project team, a
+// known priority, hours above zero, dependencies on earlier tasks, and RFC 3339 times with the
+// start before the end, both within the project dates
+func (a *AIProjectTaskGenerator) validateGeneratedTask(task generatedTaskResponse, item int, req ProjectTaskGenerationRequest) []AIValidationIssue {
+	var issues []AIValidationIssue
+	addIssue := func(field, message string) {
+		issues = append(issues, AIValidationIssue{Item: item, Field: field, Message: message})
+	}
+
+	if strings.TrimSpace(task.Title) == "" {
+		addIssue("title", "must not be empty")
+	}
+	isMember := slices.ContainsFunc(req.TeamMembers, func(member TeamMemberInfo) bool {
+		return int(member.UserID) == task.AssignedToID
+	})
+	if !isMember {
+		addIssue("assigned_to_user_id", fmt.Sprintf("user %d is not a member of this project", task.AssignedToID))
+	}
+	if task.EstimatedHours <= 0 {
+		addIssue("estimated_hours", "must be greater than 0")
+	}
+	if !slices.Contains(taskPriorities, task.Priority) {
+		addIssue("priority", fmt.Sprintf("%q is not one of %s", task.Priority, strings.Join(taskPriorities, ", ")))
+	}
+	for _, dependency := range task.Dependencies {
+		if dependency < 1 || dependency >= item {
+			addIssue("dependencies", fmt.Sprintf("%d is not the position of an earlier task", dependency))
+		}
+	}
+
+	start, startErr := time.Parse(time.RFC3339, task.StartTime)
+	end, endErr := time.Parse(time.RFC3339, task.EndTime)
+	if startErr != nil {
+		addIssue("start_time", "must be an RFC 3339 time")
+	} else if start.Before(req.StartDate) {
+		addIssue("start_time", "is before the project start date")
+	}
+	if endErr != nil {
+		addIssue("end_time", "must be an RFC 3339 time")
+	} else if req.EndDate != nil && end.After(projectEndBoundary(*req.EndDate)) {
+		addIssue("end_time", "is after the project end date")
+	}
+	if startErr == nil && endErr == nil && !start.Before(end) {
+		addIssue("end_time", "must be after start_time")
+	}
+
+	return issues
+}
+
+// repairGeneratedTask fixes what can be worked out locally and returns the task's issues, marking
+// those the fixes resolved: the priority defaults to medium, bad dependencies are dropped, hours
+// are taken from valid times, and missing or out-of-range times are rescheduled on the working
+// calendar from the estimate and clamped to the project end. Titles and assignees are not
+// guessed.
+func (a *AIProjectTaskGenerator) repairGeneratedTask(task *generatedTaskResponse, item int, req ProjectTaskGenerationRequest) []AIValidationIssue {
+	issues := a.validateGeneratedTask(*task, item, req)
+	if len(issues) == 0 {
+		return nil
+	}
+
+	task.Priority = strings.ToLower(strings.TrimSpace(task.Priority))
+	if !slices.Contains(taskPriorities, task.Priority) {
+		task.Priority = "medium"
+	}
+
+	dependencies := make([]int, 0, len(task.Dependencies))
+	for _, dependency := range task.Dependencies {
+		if dependency >= 1 && dependency < item {
+			dependencies = append(dependencies, dependency)
+		}
+	}
+	task.Dependencies = dependencies
+
+	start, startErr := time.Parse(time.RFC3339, task.StartTime)
+	end, endErr := time.Parse(time.RFC3339, task.EndTime)
+	if task.EstimatedHours <= 0 && startErr == nil && endErr == nil && start.Before(end) {
+		task.EstimatedHours = int(math.Ceil(a.WorkSchedule.WorkingHoursBetween(start, end)))
+	}
+	if hours := float64(task.EstimatedHours); hours > 0 {
+		rescheduled := false
+		if startErr != nil || start.Before(req.StartDate) {
+			start, startErr, rescheduled = req.StartDate, nil, true
+		}
+		if rescheduled || endErr != nil || !start.Before(end) {
+			end, endErr = a.WorkSchedule.AddWorkingHours(start, hours), nil
+		}
+	}
+	if req.EndDate != nil && startErr == nil && endErr == nil {
+		if projectEnd := projectEndBoundary(*req.EndDate); end.After(projectEnd) && start.Before(projectEnd) {
+			end = projectEnd
+		}
+	}
+	if startErr == nil {
+		task.StartTime = start.Format(time.RFC3339)
+	}
+	if endErr == nil {
+		task.EndTime = end.Format(time.RFC3339)
+	}
+
+	// An issue is repaired when its field no longer has any
+	remaining := a.validateGeneratedTask(*task, item, req)
+	for i := range issues {
+		field := issues[i].Field
+		issues[i].Repaired = !slices.ContainsFunc(remaining, func(issue AIValidationIssue) bool { return issue.Field == field })
+	}
+	for _, issue := range remaining {
+		if !slices.ContainsFunc(issues, func(original AIValidationIssue) bool { return original.Field == issue.Field }) {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+// buildGenerationResponse repairs the generated tasks, rejects those with problems left, and
+// renumbers dependencies to positions in the kept list. It also returns the position in the AI's
+// list of every kept task.
+func (a *AIProjectTaskGenerator) buildGenerationResponse(aiResponse taskGenerationAIResponse, req ProjectTaskGenerationRequest) (*TaskGenerationResponse, []int) {
+	response := &TaskGenerationResponse{Tasks: []GeneratedTask{}, Summary: aiResponse.Summary}
+
+	positions := make(map[int]int) // Position in the AI's list -> position in response.Tasks
+	var kept []generatedTaskResponse
+	var keptItems []int
+	for i, task := range aiResponse.Tasks {
+		item := i + 1
+		issues := a.repairGeneratedTask(&task, item, req)
+		if len(unrepairedIssues(issues)) > 0 {
+			response.RejectedTasks = append(response.RejectedTasks, RejectedTask{Item: item, Title: task.Title, Issues: issues})
+			continue
+		}
+		response.ValidationIssues = append(response.ValidationIssues, issues...)
+		kept = append(kept, task)
+		keptItems = append(keptItems, item)
+		positions[item] = len(kept)
+	}
+
+	for i, task := range kept {
+		generated := GeneratedTask{
+			Title:          task.Title,
+			Description:    task.Description,
+			AssignedToID:   uint(task.AssignedToID),
+			EstimatedHours: task.EstimatedHours,
+			Priority:       task.Priority,
+			Dependencies:   []uint{},
+		}
+		if start, err := time.Parse(time.RFC3339, task.StartTime); err == nil {
+			generated.StartTime = &start
+		}
+		if end, err := time.Parse(time.RFC3339, task.EndTime); err == nil {
+			generated.EndTime = &end
+		}
+		for _, dependency := range task.Dependencies {
+			position, ok := positions[dependency]
+			if !ok {
+				response.ValidationIssues = append(response.ValidationIssues, AIValidationIssue{
+					Item:     i + 1,
+					Field:    "dependencies",
+					Message:  fmt.Sprintf("dropped the dependency on rejected task %d", dependency),
+					Repaired: true,
+				})
+				continue
+			}
+			generated.Dependencies = append(generated.Dependencies, uint(position))
+		}
+		response.Tasks = append(response.Tasks, generated)
+	}
+
+	return response, keptItems
+}
 
-	return &TaskGenerationResponse{
-		Tasks:   aiResponse.Tasks,
-		Summary: aiResponse.Summary,
-	}, nil
+// projectEndBoundary is the end of a project's last day
+func projectEndBoundary(endDate time.Time) time.Time {
+	return endDate.AddDate(0, 0, 1)
 }
 
 // getTeamMemberHistoricalData retrieves historical performance data for a team member
@@ -337,7 +702,7 @@ WORKING SCHEDULE CONTEXT:
 INSTRUCTIONS:
 1. Break down the project into specific, actionable tasks
 2. Assign tasks to team members based on their JOB ROLE in the project (not their project management role)
-3. Create task dependencies (which tasks must be completed before others can start)
+3. Create task dependencies (which tasks must be completed before others can start), given as the 1-based positions of earlier tasks in your list
 4. Estimate duration in hours for each task:
    - Use historical performance data to inform estimates
    - If user has completed similar tasks, use their average completion time as reference
@@ -374,7 +739,9 @@ IMPORTANT:
   * If AI predictions for this user are typically off by +2 hours, add buffer time
   * If user has high current workload, add 10-20%% more time
 - Create realistic time estimates based on task complexity AND user's historical performance
-- Ensure dependencies make logical sense
+- Ensure dependencies make logical sense and only point to tasks earlier in the list
+- Only assign tasks to the team member IDs listed above
+- Keep every start_time and end_time between the project start and end dates, with start_time before end_time
 - Balance work across all team members (consider their current workload)
 - Consider Arabic working hours in time estimates
 
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// aiValidationRetries is how many times output that fails validation is sent back to the model
+// with its problems before the caller repairs or rejects what is left
+const aiValidationRetries = 1
+
+// aiDateFormat is the date format the AI is asked to answer with
+const aiDateFormat = "2006-01-02"
+
+// AIValidationIssue is a problem found in the AI's output. Repaired issues were fixed locally and
+// the item was kept; the others made the item unusable.
+type AIValidationIssue struct {
+	Item     int    `json:"item,omitempty"` // 1-based position in a list response, 0 for the whole response
+	TaskID   uint   `json:"task_id,omitempty"`
+	Field    string `json:"field"`
+	Message  string `json:"message"`
+	Repaired bool   `json:"repaired"`
+}
+
+// AIValidationError is returned when the AI's output stays invalid after a retry and cannot be
+// repaired
+type AIValidationError struct {
+	Issues []AIValidationIssue
+}
+
+func (e *AIValidationError) Error() string {
+	messages := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		if !issue.Repaired {
+			messages = append(messages, issue.String())
+		}
+	}
+	return "invalid AI response: " + strings.Join(messages, "; ")
+}
+
+func (i AIValidationIssue) String() string {
+	prefix := ""
+	if i.Item > 0 {
+		prefix = fmt.Sprintf("item %d ", i.Item)
+	}
+	if i.TaskID > 0 {
+		prefix = fmt.Sprintf("task %d ", i.TaskID)
+	}
+	return fmt.Sprintf("%s%s: %s", prefix, i.Field, i.Message)
+}
+
+// unrepairedIssues returns the issues that were not fixed locally
+func unrepairedIssues(issues []AIValidationIssue) []AIValidationIssue {
+	var unrepaired []AIValidationIssue
+	for _, issue := range issues {
+		if !issue.Repaired {
+			unrepaired = append(unrepaired, issue)
+		}
+	}
+	return unrepaired
+}
+
+// withResponseSchema returns a copy of the model that answers with JSON matching the schema. The
+// copy shares the client, so per-call schemas do not need their own model.
+func withResponseSchema(model *genai.GenerativeModel, schema *genai.Schema) *genai.GenerativeModel {
+	if model == nil {
+		return nil
+	}
+	copied := *model
+	copied.ResponseMIMEType = "application/json"
+	copied.ResponseSchema = schema
+	return &copied
+}
+
+// generateValidated sends the prompt, parses the JSON answer into T and validates it. When the
+// answer does not parse or has problems, the prompt is sent again with the previous answer and
+// the list of problems. The last parsed answer is returned with the issues still left so the
+// caller can repair or reject items; an answer that never parsed is an AIValidationError.
+func generateValidated[T any](ctx context.Context, generate func(ctx context.Context, prompt string) (*genai.GenerateContentResponse, error), prompt string, validate func(*T) []AIValidationIssue) (*T, []AIValidationIssue, error) {
+	var parsed *T
+	var issues []AIValidationIssue
+
+	currentPrompt := prompt
+	for attempt := 0; attempt <= aiValidationRetries; attempt++ {
+		resp, err := generate(ctx, currentPrompt)
+		if err != nil {
+			if parsed != nil {
+				// Keep the first answer when the retry itself fails
+				return parsed, issues, nil
+			}
+			return nil, nil, err
+		}
+
+		responseText, err := aiResponseText(resp)
+		if err == nil {
+			var value T
+			if err = json.Unmarshal([]byte(responseText), &value); err == nil {
+				parsed, issues = &value, validate(&value)
+				if len(issues) == 0 {
+					return parsed, nil, nil
+				}
+			}
+		}
+		if err != nil && parsed == nil {
+			issues = []AIValidationIssue{{Field: "response", Message: fmt.Sprintf("not valid JSON: %v", err)}}
+		}
+
+		currentPrompt = buildValidationRetryPrompt(prompt, responseText, issues)
+	}
+
+	if parsed == nil {
+		return nil, issues, &AIValidationError{Issues: issues}
+	}
+	return parsed, issues, nil
+}
+
+// buildValidationRetryPrompt asks the model to correct its previous answer
+func buildValidationRetryPrompt(prompt, previous string, issues []AIValidationIssue) string {
+	var problems strings.Builder
+	for _, issue := range issues {
+		problems.WriteString("- " + issue.String() + "\n")
+	}
+
+	return fmt.Sprintf(`%s
+
+YOUR PREVIOUS ANSWER:
+%s
+
+It was rejected because of these problems:
+%s
+Answer again with the complete corrected JSON. Fix every problem listed and keep everything else.`,
+		prompt, previous, problems.String())
+}
+
+func stringListSchema(description string) *genai.Schema {
+	return &genai.Schema{
+		Type:        genai.TypeArray,
+		Description: description,
+		Items:       &genai.Schema{Type: genai.TypeString},
+	}
+}
+
+func enumSchema(description string, values []string) *genai.Schema {
+	return &genai.Schema{Type: genai.TypeString, Format: "enum", Description: description, Enum: values}
+}
+
+// taskAnalysisProperties are the fields of one task analysis
+func taskAnalysisProperties() map[string]*genai.Schema {
+	return map[string]*genai.Schema{
+		"estimated_duration_hours": {Type: genai.TypeInteger, Description: "Working hours the task needs, greater than 0"},
+		"deadline_risk":            enumSchema("Deadline risk level", riskLevels),
+		"risk_factors":             stringListSchema("Specific risk factors"),
+		"recommendations":          stringListSchema("3-5 actionable recommendations"),
+		"optimal_start_date":       {Type: genai.TypeString, Description: "Suggested start date, YYYY-MM-DD"},
+		"predicted_completion":     {Type: genai.TypeString, Description: "Predicted completion date, YYYY-MM-DD, not in the past"},
+		"confidence_score":         {Type: genai.TypeInteger, Description: "Confidence from 0 to 100"},
+	}
+}
+
+var taskAnalysisRequired = []string{
+	"estimated_duration_hours", "deadline_risk", "risk_factors", "recommendations",
+	"optimal_start_date", "predicted_completion", "confidence_score",
+}
+
+// taskAnalysisSchema is the answer to a single task analysis
+var taskAnalysisSchema = &genai.Schema{
+	Type:       genai.TypeObject,
+	Properties: taskAnalysisProperties(),
+	Required:   taskAnalysisRequired,
+}
+
+// batchTaskAnalysisSchema is the answer to a batched task analysis
+var batchTaskAnalysisSchema = func() *genai.Schema {
+	properties := taskAnalysisProperties()
+	properties["task_id"] = &genai.Schema{Type: genai.TypeInteger, Description: "The task number from the prompt"}
+	return &genai.Schema{
+		Type: genai.TypeArray,
+		Items: &genai.Schema{
+			Type:       genai.TypeObject,
+			Properties: properties,
+			Required:   append([]string{"task_id"}, taskAnalysisRequired...),
+		},
+	}
+}()
+
+// projectAnalysisSchema is the answer to a project time analysis
+var projectAnalysisSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"overall_risk":         enumSchema("Overall project risk", riskLevels),
+		"predicted_delay_days": {Type: genai.TypeInteger, Description: "Predicted delay in working days, 0 or more"},
+		"critical_path_task_ids": {
+			Type:        genai.TypeArray,
+			Description: "IDs of the analyzed tasks on the critical path",
+			Items:       &genai.Schema{Type: genai.TypeInteger},
+		},
+		"time_optimizations": stringListSchema("5-7 time optimizations"),
+		"resource_conflicts": stringListSchema("Resource conflicts"),
+		"executive_summary":  {Type: genai.TypeString, Description: "2-3 sentence summary"},
+	},
+	Required: []string{"overall_risk", "predicted_delay_days", "critical_path_task_ids", "time_optimizations", "resource_conflicts", "executive_summary"},
+}
+
+// workloadRecommendationsSchema is the answer to a workload recommendation request
+var workloadRecommendationsSchema = stringListSchema("3-5 workload recommendations")
+
+// chatTaskExtractionSchema is the answer to extracting a task from a chat message
+var chatTaskExtractionSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"title":       {Type: genai.TypeString, Description: "Task title"},
+		"description": {Type: genai.TypeString, Description: "Task description, can be empty"},
+		"dueDate":     {Type: genai.TypeString, Description: "Due date, YYYY-MM-DD", Nullable: true},
+	},
+	Required: []string{"title", "description", "dueDate"},
+}
+
+// taskGenerationSchema is the answer to generating a project's tasks
+var taskGenerationSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"tasks": {
+			Type: genai.TypeArray,
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"title":               {Type: genai.TypeString, Description: "Specific and actionable task title"},
+					"description":         {Type: genai.TypeString},
+					"assigned_to_user_id": {Type: genai.TypeInteger, Description: "ID of one of the listed team members"},
+					"estimated_hours":     {Type: genai.TypeInteger, Description: "Working hours, greater than 0"},
+					"priority":            enumSchema("Task priority", taskPriorities),
+					"dependencies": {
+						Type:        genai.TypeArray,
+						Description: "1-based positions in this list of the tasks that must be completed first",
+						Items:       &genai.Schema{Type: genai.TypeInteger},
+					},
+					"start_time": {Type: genai.TypeString, Description: "RFC 3339 start, within the project dates"},
+					"end_time":   {Type: genai.TypeString, Description: "RFC 3339 end, after start_time and within the project dates"},
+				},
+				Required: []string{"title", "description", "assigned_to_user_id", "estimated_hours", "priority", "dependencies", "start_time", "end_time"},
+			},
+		},
+		"summary": {Type: genai.TypeString, Description: "Brief summary of the task plan"},
+	},
+	Required: []string{"tasks", "summary"},
+}
+
+// planRevisionSchema is the answer to revising part of a task plan: the generation answer with
+// the key of the current task each task is
+var planRevisionSchema = func() *genai.Schema {
+	task := *taskGenerationSchema.Properties["tasks"].Items
+	properties := make(map[string]*genai.Schema, len(task.Properties)+1)
+	for name, property := range task.Properties {
+		properties[name] = property
+	}
+	properties["key"] = &genai.Schema{Type: genai.TypeString, Description: "Key of the current task this is or replaces, empty for a new task"}
+	task.Properties = properties
+	task.Required = append([]string{"key"}, task.Required...)
+
+	return &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"tasks":   {Type: genai.TypeArray, Items: &task},
+			"summary": {Type: genai.TypeString, Description: "Brief summary of what was revised"},
+		},
+		Required: []string{"tasks", "summary"},
+	}
+}()
+
+// dailyStandupSchema is the answer to writing a user's daily standup
+var dailyStandupSchema = &genai.Schema{
+	Type: genai.TypeObject,
+	Properties: map[string]*genai.Schema{
+		"sections": {
+			Type: genai.TypeArray,
+			Items: &genai.Schema{
+				Type: genai.TypeObject,
+				Properties: map[string]*genai.Schema{
+					"project_id": {Type: genai.TypeInteger, Description: "The project_id from the prompt, 0 for tasks outside projects"},
+					"yesterday":  {Type: genai.TypeString, Description: "What was completed on the previous working day"},
+					"today":      {Type: genai.TypeString, Description: "What is planned for today"},
+					"blockers":   {Type: genai.TypeString, Description: "Blockers and why, empty when there are none"},
+				},
+				Required: []string{"project_id", "yesterday", "today", "blockers"},
+			},
+		},
+	},
+	Required: []string{"sections"},
+}
@@ -9,7 +9,9 @@ import (
 	"os"
 	"project-x/config"
 	"project-x/models"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/generative-ai-go/genai"
@@ -22,6 +24,9 @@ type AITimeOptimizer struct {
 	client       *genai.Client
 	model        *genai.GenerativeModel
 	WorkSchedule *config.WorkScheduleConfig
+
+	modelsMu      sync.Mutex
+	variantModels map[string]*genai.GenerativeModel // Models of A/B variants, created on first use
 }
 
 type TimeAnalysis struct {
@@ -36,6 +41,16 @@ type TimeAnalysis struct {
 	PredictedCompletion       time.Time `json:"predicted_completion"`
 	WorkingHoursUntilDeadline float64   `json:"working_hours_until_deadline"`
 	IsWithinWorkingHours      bool      `json:"is_within_working_hours"`
+
+	// Set when EstimatedDuration comes from the local duration estimator
+	AIEstimatedDuration int               `json:"ai_estimated_duration_hours,omitempty"` // The AI's raw estimate
+	DurationEstimate    *DurationEstimate `json:"duration_estimate,omitempty"`
+
+	// Model and prompt version the analysis was made with
+	Variant *AnalysisVariant `json:"variant,omitempty"`
+
+	// Problems in the AI's answer that were repaired locally
+	ValidationIssues []AIValidationIssue `json:"validation_issues,omitempty"`
 }
 
 type ProjectTimeReport struct {
@@ -50,6 +65,10 @@ type ProjectTimeReport struct {
 	Summary              string         `json:"ai_summary"`
 	WorkingDaysRemaining int            `json:"working_days_remaining"`
 	TotalWorkingHours    float64        `json:"total_working_hours_required"`
+
+	// Tasks left out of TaskAnalyses, and problems repaired in the project-level answer
+	FailedAnalyses   []TaskAnalysisFailure `json:"failed_analyses,omitempty"`
+	ValidationIssues []AIValidationIssue   `json:"validation_issues,omitempty"`
 }
 
 type UserWorkloadAnalysis struct {
@@ -88,11 +107,8 @@ func NewAITimeOptimizer(db *gorm.DB) *AITimeOptimizer {
 	}
 
 	// Configure the model
-	model := client.GenerativeModel("gemini-2.0-flash")
-	model.SetTemperature(0.3) // Lower temperature for more consistent analysis
-	model.SetTopP(0.8)
-	model.SetTopK(40)
-	model.SetMaxOutputTokens(2048)
+	model := client.GenerativeModel(geminiModelName)
+	configureAnalysisModel(model)
 
 	return &AITimeOptimizer{
 		DB:           db,
@@ -102,34 +118,33 @@ func NewAITimeOptimizer(db *gorm.DB) *AITimeOptimizer {
 	}
 }
 
-// AnalyzeTaskTimeRisks analyzes all tasks for time-related risks using Arabic working hours
-func (a *AITimeOptimizer) AnalyzeTaskTimeRisks() ([]TimeAnalysis, error) {
-	if a.model == nil {
-		return nil, fmt.Errorf("AI service not available")
+// AnalyzeTaskTimeRisks analyzes all tasks for time-related risks using Arabic working hours.
+// Analyses from the last hour are served from the database; the rest are analyzed in batches.
+// Tasks that could not be analyzed are returned as failures.
+func (a *AITimeOptimizer) AnalyzeTaskTimeRisks() ([]TimeAnalysis, []TaskAnalysisFailure, error) {
+	tasks, results, failures, err := a.activeTaskAnalyses(nil, aiAnalysisFreshFor)
+	if err != nil {
+		return nil, nil, err
 	}
+	return orderedAnalyses(tasks, results), failures, nil
+}
 
-	// Get all active tasks with time data
-	var tasks []models.Task
-	err := a.DB.Where("status IN ?", []string{"pending", "in_progress"}).
-		Preload("User").
-		Preload("Project").
-		Find(&tasks).Error
+// AnalyzeUserTaskTimeRisks analyzes the active tasks assigned to one user
+func (a *AITimeOptimizer) AnalyzeUserTaskTimeRisks(userID uint) ([]TimeAnalysis, []TaskAnalysisFailure, error) {
+	tasks, results, failures, err := a.activeTaskAnalyses(&userID, aiAnalysisFreshFor)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return orderedAnalyses(tasks, results), failures, nil
+}
 
-	var analyses []TimeAnalysis
-
-	for _, task := range tasks {
-		analysis, err := a.analyzeIndividualTask(task)
-		if err != nil {
-			log.Printf("Error analyzing task %d: %v", task.ID, err)
-			continue
-		}
-		analyses = append(analyses, *analysis)
+// AnalyzeTask analyzes a single task, reusing an analysis from the last hour
+func (a *AITimeOptimizer) AnalyzeTask(taskID uint) (*TimeAnalysis, error) {
+	var task models.Task
+	if err := a.DB.Preload("User").Preload("Project").First(&task, taskID).Error; err != nil {
+		return nil, err
 	}
-
-	return analyses, nil
+	return a.analyzeIndividualTask(task)
 }
 
 // SaveAIAnalysis saves AI prediction to database for learning and tracking
@@ -145,10 +160,16 @@ func (a *AITimeOptimizer) SaveAIAnalysis(task models.Task, analysis *TimeAnalysi
 		return fmt.Errorf("failed to marshal recommendations: %v", err)
 	}
 
+	// PredictedDuration keeps the AI's own estimate so the estimator learns from it
+	predictedDuration := analysis.EstimatedDuration
+	if analysis.DurationEstimate != nil {
+		predictedDuration = analysis.AIEstimatedDuration
+	}
+
 	aiAnalysis := models.AIAnalysis{
 		TaskID:                    task.ID,
 		TaskType:                  "regular",
-		PredictedDuration:         analysis.EstimatedDuration,
+		PredictedDuration:         predictedDuration,
 		PredictedCompletion:       analysis.PredictedCompletion,
 		DeadlineRisk:              analysis.DeadlineRisk,
 		RiskFactors:               string(riskFactorsJSON),
@@ -156,9 +177,22 @@ func (a *AITimeOptimizer) SaveAIAnalysis(task models.Task, analysis *TimeAnalysi
 		OptimalStartDate:          analysis.OptimalStartDate,
 		WorkingHoursUntilDeadline: analysis.WorkingHoursUntilDeadline,
 		AnalysisDate:              time.Now(),
-		ModelVersion:              "gemini-2.0-flash",
+		ModelVersion:              geminiModelName,
+		PromptVersion:             defaultAnalysisVariant.PromptVersion,
 		ConfidenceScore:           85, // Default confidence, can be enhanced
 	}
+	if variant := analysis.Variant; variant != nil {
+		aiAnalysis.Variant = variant.Name
+		aiAnalysis.ModelVersion = variant.ModelName
+		aiAnalysis.PromptVersion = variant.PromptVersion
+	}
+	if estimate := analysis.DurationEstimate; estimate != nil {
+		aiAnalysis.EstimatedDuration = &estimate.Hours
+		aiAnalysis.EstimateLow = &estimate.Low
+		aiAnalysis.EstimateHigh = &estimate.High
+		aiAnalysis.EstimateSource = estimate.Source
+		aiAnalysis.EstimateMethod = estimate.Method
+	}
 
 	return a.DB.Create(&aiAnalysis).Error
 }
@@ -176,7 +210,17 @@ func (a *AITimeOptimizer) GetCachedAnalysis(taskID uint) (*TimeAnalysis, error)
 		return nil, err // No cached analysis found
 	}
 
-	// Convert AIAnalysis back to TimeAnalysis
+	// Get the task to return complete TimeAnalysis
+	var task models.Task
+	if err := a.DB.First(&task, taskID).Error; err != nil {
+		return nil, err
+	}
+
+	return a.timeAnalysisFromRecord(aiAnalysis, task), nil
+}
+
+// timeAnalysisFromRecord converts a stored AIAnalysis back to a TimeAnalysis
+func (a *AITimeOptimizer) timeAnalysisFromRecord(aiAnalysis models.AIAnalysis, task models.Task) *TimeAnalysis {
 	var riskFactors []string
 	var recommendations []string
 
@@ -187,13 +231,7 @@ func (a *AITimeOptimizer) GetCachedAnalysis(taskID uint) (*TimeAnalysis, error)
 		json.Unmarshal([]byte(aiAnalysis.Recommendations), &recommendations)
 	}
 
-	// Get the task to return complete TimeAnalysis
-	var task models.Task
-	if err := a.DB.First(&task, taskID).Error; err != nil {
-		return nil, err
-	}
-
-	return &TimeAnalysis{
+	analysis := &TimeAnalysis{
 		TaskID:                    aiAnalysis.TaskID,
 		TaskTitle:                 task.Title,
 		EstimatedDuration:         aiAnalysis.PredictedDuration,
@@ -204,7 +242,33 @@ func (a *AITimeOptimizer) GetCachedAnalysis(taskID uint) (*TimeAnalysis, error)
 		PredictedCompletion:       aiAnalysis.PredictedCompletion,
 		WorkingHoursUntilDeadline: aiAnalysis.WorkingHoursUntilDeadline,
 		IsWithinWorkingHours:      a.WorkSchedule.IsWorkingHour(time.Now()),
-	}, nil
+	}
+
+	if aiAnalysis.Variant != "" {
+		analysis.Variant = &AnalysisVariant{
+			Name:          aiAnalysis.Variant,
+			ModelName:     aiAnalysis.ModelVersion,
+			PromptVersion: aiAnalysis.PromptVersion,
+		}
+	}
+
+	// Restore the local estimate the analysis was scheduled with
+	if aiAnalysis.EstimatedDuration != nil {
+		analysis.AIEstimatedDuration = aiAnalysis.PredictedDuration
+		analysis.EstimatedDuration = *aiAnalysis.EstimatedDuration
+		analysis.DurationEstimate = &DurationEstimate{
+			Hours:      *aiAnalysis.EstimatedDuration,
+			Confidence: durationIntervalLevel,
+			Method:     aiAnalysis.EstimateMethod,
+			Source:     aiAnalysis.EstimateSource,
+		}
+		if aiAnalysis.EstimateLow != nil && aiAnalysis.EstimateHigh != nil {
+			analysis.DurationEstimate.Low = *aiAnalysis.EstimateLow
+			analysis.DurationEstimate.High = *aiAnalysis.EstimateHigh
+		}
+	}
+
+	return analysis
 }
 
 // UpdateAIAnalysisWithActual updates AI analysis with actual completion data
@@ -250,20 +314,25 @@ func (a *AITimeOptimizer) UpdateAIAnalysisWithActual(taskID uint, actualDuration
 	log.Printf("✅ Updated AI analysis for task %d: Accuracy %.1f%%, Was Accurate: %v",
 		taskID, accuracyScore, wasAccurate)
 
+	// Let the duration estimator learn from the new result
+	InvalidateDurationModels()
+
 	return nil
 }
 
-// analyzeIndividualTask performs AI analysis on a single task with Arabic working hours
+// analyzeIndividualTask performs AI analysis on a single task with Arabic working hours,
+// reusing an analysis from the last hour or one already running for the task
 func (a *AITimeOptimizer) analyzeIndividualTask(task models.Task) (*TimeAnalysis, error) {
-	// Check for cached analysis first (within 1 hour)
-	cachedAnalysis, err := a.GetCachedAnalysis(task.ID)
-	if err == nil && cachedAnalysis != nil {
-		log.Printf("📦 Using cached AI analysis for task %d", task.ID)
-		return cachedAnalysis, nil
+	results, errs := a.analyzeTasks([]models.Task{task}, aiAnalysisFreshFor)
+	if analysis := results[task.ID]; analysis != nil {
+		return analysis, nil
 	}
+	return nil, errs[task.ID]
+}
 
-	ctx := context.Background()
-
+// analyzeSingleTask asks the AI to analyze one task with a variant's model and prompt and saves
+// the result
+func (a *AITimeOptimizer) analyzeSingleTask(ctx context.Context, task models.Task, variant AnalysisVariant) (*TimeAnalysis, error) {
 	// Gather historical data for similar tasks
 	historicalData, err := a.getHistoricalTaskData(task)
 	if err != nil {
@@ -271,30 +340,49 @@ func (a *AITimeOptimizer) analyzeIndividualTask(task models.Task) (*TimeAnalysis
 	}
 
 	// Calculate working hours until deadline
-	now := time.Now()
-	var workingHoursUntilDeadline float64
-	var isWithinWorkingHours bool
-
-	if task.DueDate != nil {
-		workingHoursUntilDeadline = a.WorkSchedule.GetWorkingHoursInPeriod(now, *task.DueDate)
-		isWithinWorkingHours = a.WorkSchedule.IsWorkingHour(now)
-	}
+	workingHoursUntilDeadline, isWithinWorkingHours := a.deadlineContext(task)
 
 	// Create AI prompt for task analysis with Arabic working context
 	prompt := a.buildTaskAnalysisPromptArabic(task, historicalData, workingHoursUntilDeadline, isWithinWorkingHours)
-
-	// Get AI analysis
-	resp, err := a.model.GenerateContent(ctx, genai.Text(prompt))
+	prompt += a.promptAdditions(task, variant.PromptVersion)
+
+	// Get AI analysis, retrying once with the problems when the answer fails validation
+	aiResponse, issues, err := generateValidated(ctx, func(ctx context.Context, prompt string) (*genai.GenerateContentResponse, error) {
+		return a.generate(ctx, variant.ModelName, AIFeatureTimeAnalysis, taskAnalysisSchema, prompt)
+	}, prompt, func(aiResponse *taskAnalysisResponse) []AIValidationIssue {
+		return a.validateTaskAnalysisResponse(*aiResponse, task.ID)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	// Parse AI response
-	analysis, err := a.parseTaskAnalysisResponse(resp, task)
+	analysis, err := a.parseTaskAnalysisResponse(*aiResponse, issues, task)
 	if err != nil {
 		return nil, err
 	}
 
+	analysis.Variant = &variant
+	a.completeTaskAnalysis(task, analysis, workingHoursUntilDeadline, isWithinWorkingHours)
+	return analysis, nil
+}
+
+// deadlineContext returns the working hours left until a task's deadline and whether it is
+// currently working time
+func (a *AITimeOptimizer) deadlineContext(task models.Task) (float64, bool) {
+	if task.DueDate == nil {
+		return 0, false
+	}
+	now := time.Now()
+	return a.WorkSchedule.GetWorkingHoursInPeriod(now, *task.DueDate), a.WorkSchedule.IsWorkingHour(now)
+}
+
+// completeTaskAnalysis adds the local duration estimate and working schedule context to a parsed
+// analysis and saves it
+func (a *AITimeOptimizer) completeTaskAnalysis(task models.Task, analysis *TimeAnalysis, workingHoursUntilDeadline float64, isWithinWorkingHours bool) {
+	// Calibrate or replace the AI's duration with what past results suggest
+	a.applyDurationEstimate(task, analysis)
+
 	// Add Arabic working hours context
 	analysis.WorkingHoursUntilDeadline = workingHoursUntilDeadline
 	analysis.IsWithinWorkingHours = isWithinWorkingHours
@@ -311,8 +399,54 @@ func (a *AITimeOptimizer) analyzeIndividualTask(task models.Task) (*TimeAnalysis
 	} else {
 		log.Printf("💾 Saved AI analysis for task %d", task.ID)
 	}
+}
 
-	return analysis, nil
+// applyDurationEstimate replaces the AI's duration estimate with the local estimator's, according
+// to DURATION_ESTIMATOR_MODE, and recalculates the predicted completion and deadline risk from it.
+// Without enough history the AI's estimate is kept.
+func (a *AITimeOptimizer) applyDurationEstimate(task models.Task, analysis *TimeAnalysis) {
+	mode := DurationEstimatorMode()
+	if mode == DurationEstimatorOff {
+		return
+	}
+
+	department := task.User.Department
+	if department == "" {
+		var user models.User
+		if err := a.DB.Select("department").First(&user, task.UserID).Error; err == nil {
+			department = user.Department
+		}
+	}
+
+	estimator := NewDurationEstimator(a.DB)
+	var estimate *DurationEstimate
+	var err error
+	if mode == DurationEstimatorReplace {
+		estimate, err = estimator.Estimate(&task, department)
+	} else {
+		estimate, err = estimator.Calibrate(&task, department, analysis.EstimatedDuration)
+	}
+	if err != nil {
+		log.Printf("⚠️ Warning: Duration estimator failed for task %d: %v", task.ID, err)
+		return
+	}
+	if estimate == nil {
+		return
+	}
+
+	analysis.AIEstimatedDuration = analysis.EstimatedDuration
+	analysis.EstimatedDuration = estimate.Hours
+	analysis.DurationEstimate = estimate
+
+	// The AI's completion date and risk were based on its own estimate, so work them out again
+	// from the one that replaced it, the way an invalid answer is repaired
+	if estimate.Hours != analysis.AIEstimatedDuration && estimate.Hours > 0 {
+		hours := float64(estimate.Hours)
+		analysis.PredictedCompletion = a.WorkSchedule.AddWorkingHours(time.Now(), hours)
+		if task.DueDate != nil {
+			analysis.DeadlineRisk = a.WorkSchedule.CalculateDeadlineRisk(*task.DueDate, hours)
+		}
+	}
 }
 
 // buildTaskAnalysisPromptArabic creates the AI prompt with Arabic working hours context
@@ -416,27 +550,32 @@ Be specific about Arabic working schedule constraints in your recommendations.`,
 	return prompt
 }
 
-// AnalyzeUserWorkload analyzes individual user's time allocation with Arabic working hours
+// AnalyzeUserWorkload analyzes individual user's time allocation with Arabic working hours.
+// Stored task analyses are reused so the workload view does not re-analyze every task.
 func (a *AITimeOptimizer) AnalyzeUserWorkload(userID uint) (*UserWorkloadAnalysis, error) {
-	if a.model == nil {
-		return nil, fmt.Errorf("AI service not available")
+	var user models.User
+	if err := a.DB.First(&user, userID).Error; err != nil {
+		return nil, err
 	}
 
-	// Get user with active tasks
-	var user models.User
-	err := a.DB.Preload("Tasks", "status IN ?", []string{"pending", "in_progress"}).
-		First(&user, userID).Error
+	tasks, results, _, err := a.activeTaskAnalyses(&userID, aiAnalysisServeFor)
 	if err != nil {
 		return nil, err
 	}
 
-	// Analyze user's tasks for time estimates
+	analysis := a.buildUserWorkload(user, tasks, results)
+	a.addWorkloadRecommendations(user, tasks, analysis)
+	return analysis, nil
+}
+
+// buildUserWorkload sums a user's analyzed task estimates against the 42-hour working week
+func (a *AITimeOptimizer) buildUserWorkload(user models.User, tasks []models.Task, results map[uint]*TimeAnalysis) *UserWorkloadAnalysis {
 	totalEstimatedHours := 0
-	var taskRisks []string
+	taskRisks := []string{}
 
-	for _, task := range user.Tasks {
-		analysis, err := a.analyzeIndividualTask(task)
-		if err != nil {
+	for _, task := range tasks {
+		analysis := results[task.ID]
+		if analysis == nil {
 			continue
 		}
 
@@ -460,25 +599,32 @@ func (a *AITimeOptimizer) AnalyzeUserWorkload(userID uint) (*UserWorkloadAnalysi
 		overloadRisk = "medium"
 	}
 
-	// Generate AI recommendations with Arabic context
-	recommendations, err := a.generateUserRecommendationsArabic(user, totalEstimatedHours, utilizationPercent, taskRisks)
-	if err != nil {
-		recommendations = []string{"Unable to generate AI recommendations"}
-	}
-
-	analysis := &UserWorkloadAnalysis{
+	return &UserWorkloadAnalysis{
 		UserID:              user.ID,
 		Username:            user.Username,
 		TotalHoursAssigned:  totalEstimatedHours,
 		CapacityUtilization: utilizationPercent,
 		OverloadRisk:        overloadRisk,
 		TimeConflicts:       taskRisks,
-		Recommendations:     recommendations,
 		WeeklyCapacity:      weeklyCapacity,
 		CurrentWorkload:     float64(totalEstimatedHours),
 	}
+}
 
-	return analysis, nil
+// addWorkloadRecommendations asks the AI for workload recommendations
+func (a *AITimeOptimizer) addWorkloadRecommendations(user models.User, tasks []models.Task, analysis *UserWorkloadAnalysis) {
+	if a.model == nil {
+		analysis.Recommendations = []string{"Unable to generate AI recommendations"}
+		return
+	}
+
+	// Generate AI recommendations with Arabic context
+	user.Tasks = tasks
+	recommendations, err := a.generateUserRecommendationsArabic(user, analysis.TotalHoursAssigned, analysis.CapacityUtilization, analysis.TimeConflicts)
+	if err != nil {
+		recommendations = []string{"Unable to generate AI recommendations"}
+	}
+	analysis.Recommendations = recommendations
 }
 
 // generateUserRecommendationsArabic generates AI-powered recommendations considering Arabic working schedule
@@ -531,27 +677,40 @@ Examples of culturally appropriate recommendations:
 		strings.Join(risks, "; "),
 	)
 
-	resp, err := a.model.GenerateContent(ctx, genai.Text(prompt))
+	recommendations, issues, err := generateValidated(ctx, func(ctx context.Context, prompt string) (*genai.GenerateContentResponse, error) {
+		return a.generate(ctx, geminiModelName, AIFeatureWorkloadRecommendations, workloadRecommendationsSchema, prompt)
+	}, prompt, validateRecommendations)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("empty response from AI")
+	// Drop blank recommendations the retry did not fix
+	var kept []string
+	for _, recommendation := range *recommendations {
+		if strings.TrimSpace(recommendation) != "" {
+			kept = append(kept, recommendation)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, &AIValidationError{Issues: issues}
 	}
 
-	responseText := fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0])
-	responseText = strings.TrimPrefix(responseText, "```json")
-	responseText = strings.TrimSuffix(responseText, "```")
-	responseText = strings.TrimSpace(responseText)
+	return kept, nil
+}
 
-	var recommendations []string
-	err = json.Unmarshal([]byte(responseText), &recommendations)
-	if err != nil {
-		return []string{"Unable to parse AI recommendations"}, nil
+// validateRecommendations checks that a recommendation list is not empty and has no blank entries
+func validateRecommendations(recommendations *[]string) []AIValidationIssue {
+	if len(*recommendations) == 0 {
+		return []AIValidationIssue{{Field: "recommendations", Message: "must not be empty"}}
 	}
 
-	return recommendations, nil
+	var issues []AIValidationIssue
+	for i, recommendation := range *recommendations {
+		if strings.TrimSpace(recommendation) == "" {
+			issues = append(issues, AIValidationIssue{Item: i + 1, Field: "recommendation", Message: "must not be blank"})
+		}
+	}
+	return issues
 }
 
 // getHistoricalTaskData retrieves historical data for similar tasks
@@ -646,40 +805,142 @@ func (a *AITimeOptimizer) getHistoricalTaskData(task models.Task) (map[string]in
 	return data, nil
 }
 
-// parseTaskAnalysisResponse parses the AI response into TimeAnalysis struct
-func (a *AITimeOptimizer) parseTaskAnalysisResponse(resp *genai.GenerateContentResponse, task models.Task) (*TimeAnalysis, error) {
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("empty response from AI")
-	}
+// taskAnalysisResponse is the JSON the AI returns for one task
+type taskAnalysisResponse struct {
+	TaskID                 uint     `json:"task_id"` // Only in batched responses
+	EstimatedDurationHours int      `json:"estimated_duration_hours"`
+	DeadlineRisk           string   `json:"deadline_risk"`
+	RiskFactors            []string `json:"risk_factors"`
+	Recommendations        []string `json:"recommendations"`
+	OptimalStartDate       string   `json:"optimal_start_date"`
+	PredictedCompletion    string   `json:"predicted_completion"`
+	ConfidenceScore        int      `json:"confidence_score"`
+}
 
-	responseText := fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0])
+// validateTaskAnalysisResponse checks a task analysis answer: hours above zero, a known risk
+// level, a confidence from 0 to 100, and dates that parse, with the predicted completion between
+// today and two years ahead and the optimal start not after it
+func (a *AITimeOptimizer) validateTaskAnalysisResponse(aiResponse taskAnalysisResponse, taskID uint) []AIValidationIssue {
+	var issues []AIValidationIssue
+	addIssue := func(field, message string) {
+		issues = append(issues, AIValidationIssue{TaskID: taskID, Field: field, Message: message})
+	}
 
-	// Clean the response (remove markdown code blocks if present)
-	responseText = strings.TrimPrefix(responseText, "```json")
-	responseText = strings.TrimSuffix(responseText, "```")
-	responseText = strings.TrimSpace(responseText)
+	if aiResponse.EstimatedDurationHours <= 0 {
+		addIssue("estimated_duration_hours", "must be greater than 0")
+	}
+	if !slices.Contains(riskLevels, aiResponse.DeadlineRisk) {
+		addIssue("deadline_risk", fmt.Sprintf("%q is not one of %s", aiResponse.DeadlineRisk, strings.Join(riskLevels, ", ")))
+	}
+	if aiResponse.ConfidenceScore < 0 || aiResponse.ConfidenceScore > 100 {
+		addIssue("confidence_score", "must be between 0 and 100")
+	}
 
-	// Parse JSON response
-	var aiResponse struct {
-		EstimatedDurationHours int      `json:"estimated_duration_hours"`
-		DeadlineRisk           string   `json:"deadline_risk"`
-		RiskFactors            []string `json:"risk_factors"`
-		Recommendations        []string `json:"recommendations"`
-		OptimalStartDate       string   `json:"optimal_start_date"`
-		PredictedCompletion    string   `json:"predicted_completion"`
-		ConfidenceScore        int      `json:"confidence_score"`
+	// Dates are parsed as UTC, so compare them with today's date in UTC
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	completion, err := time.Parse(aiDateFormat, aiResponse.PredictedCompletion)
+	completionValid := false
+	switch {
+	case err != nil:
+		addIssue("predicted_completion", "must be a YYYY-MM-DD date")
+	case completion.Before(today):
+		addIssue("predicted_completion", "must not be in the past")
+	case completion.After(today.AddDate(2, 0, 0)):
+		addIssue("predicted_completion", "must be within two years")
+	default:
+		completionValid = true
 	}
 
-	err := json.Unmarshal([]byte(responseText), &aiResponse)
+	start, err := time.Parse(aiDateFormat, aiResponse.OptimalStartDate)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse AI response: %v", err)
+		addIssue("optimal_start_date", "must be a YYYY-MM-DD date")
+	} else if completionValid && start.After(completion) {
+		addIssue("optimal_start_date", "must not be after predicted_completion")
+	}
+
+	return issues
+}
+
+// repairTaskAnalysisResponse fixes what can be worked out locally and marks those issues
+// repaired: an unknown risk level is recalculated from the deadline, bad dates are rescheduled
+// from the estimate on the working calendar and the confidence is clamped. A missing duration
+// cannot be repaired.
+func (a *AITimeOptimizer) repairTaskAnalysisResponse(aiResponse *taskAnalysisResponse, task models.Task, issues []AIValidationIssue) []AIValidationIssue {
+	repaired := make([]AIValidationIssue, len(issues))
+	copy(repaired, issues)
+
+	hours := float64(aiResponse.EstimatedDurationHours)
+	for i := range repaired {
+		issue := &repaired[i]
+		switch issue.Field {
+		case "deadline_risk":
+			risk := strings.ToLower(strings.TrimSpace(aiResponse.DeadlineRisk))
+			if !slices.Contains(riskLevels, risk) {
+				if hours <= 0 {
+					continue
+				}
+				risk = "low"
+				if task.DueDate != nil {
+					risk = a.WorkSchedule.CalculateDeadlineRisk(*task.DueDate, hours)
+				}
+			}
+			aiResponse.DeadlineRisk = risk
+		case "optimal_start_date", "predicted_completion":
+			if hours <= 0 {
+				continue
+			}
+			now := time.Now()
+			aiResponse.OptimalStartDate = now.Format(aiDateFormat)
+			aiResponse.PredictedCompletion = a.WorkSchedule.AddWorkingHours(now, hours).Format(aiDateFormat)
+		case "confidence_score":
+			aiResponse.ConfidenceScore = max(0, min(100, aiResponse.ConfidenceScore))
+		default:
+			continue
+		}
+		issue.Repaired = true
 	}
 
+	return repaired
+}
+
+// parseTaskAnalysisResponse repairs what it can of a validated AI answer and converts it into a
+// TimeAnalysis. Repaired issues are kept on the analysis; any other issue makes the answer
+// unusable and is returned as an AIValidationError.
+func (a *AITimeOptimizer) parseTaskAnalysisResponse(aiResponse taskAnalysisResponse, issues []AIValidationIssue, task models.Task) (*TimeAnalysis, error) {
+	issues = a.repairTaskAnalysisResponse(&aiResponse, task, issues)
+	if len(unrepairedIssues(issues)) > 0 {
+		return nil, &AIValidationError{Issues: issues}
+	}
+
+	analysis := analysisFromResponse(aiResponse, task)
+	analysis.ValidationIssues = issues
+	return analysis, nil
+}
+
+// aiResponseText returns the text of the first candidate without markdown code fences
+func aiResponseText(resp *genai.GenerateContentResponse) (string, error) {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("empty response from AI")
+	}
+
+	responseText := fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0])
+
+	// Clean the response (remove markdown code blocks if present)
+	responseText = strings.TrimSpace(responseText)
+	responseText = strings.TrimPrefix(responseText, "```json")
+	responseText = strings.TrimSuffix(responseText, "```")
+	return strings.TrimSpace(responseText), nil
+}
+
+// analysisFromResponse converts a parsed AI response into a TimeAnalysis
+func analysisFromResponse(aiResponse taskAnalysisResponse, task models.Task) *TimeAnalysis {
 	// Parse dates
-	optimalStart, _ := time.Parse("2006-01-02", aiResponse.OptimalStartDate)
-	predictedCompletion, _ := time.Parse("2006-01-02", aiResponse.PredictedCompletion)
+	optimalStart, _ := time.Parse(aiDateFormat, aiResponse.OptimalStartDate)
+	predictedCompletion, _ := time.Parse(aiDateFormat, aiResponse.PredictedCompletion)
 
-	analysis := &TimeAnalysis{
+	return &TimeAnalysis{
 		TaskID:              task.ID,
 		TaskTitle:           task.Title,
 		EstimatedDuration:   aiResponse.EstimatedDurationHours,
@@ -689,8 +950,6 @@ func (a *AITimeOptimizer) parseTaskAnalysisResponse(resp *genai.GenerateContentR
 		OptimalStartDate:    optimalStart,
 		PredictedCompletion: predictedCompletion,
 	}
-
-	return analysis, nil
 }
 
 // AnalyzeProjectTimeRisks analyzes entire project for time optimization
@@ -709,19 +968,23 @@ func (a *AITimeOptimizer) AnalyzeProjectTimeRisks(projectID uint) (*ProjectTimeR
 	}
 
 	// Analyze individual tasks
-	var taskAnalyses []TimeAnalysis
-	totalWorkingHours := 0.0
+	var activeTasks []models.Task
 	for _, task := range project.Tasks {
 		if task.Status == "completed" || task.Status == "cancelled" {
 			continue
 		}
+		activeTasks = append(activeTasks, task)
+	}
 
-		analysis, err := a.analyzeIndividualTask(task)
-		if err != nil {
-			log.Printf("Error analyzing task %d: %v", task.ID, err)
-			continue
-		}
-		taskAnalyses = append(taskAnalyses, *analysis)
+	results, errs := a.analyzeTasks(activeTasks, aiAnalysisFreshFor)
+	for taskID, err := range errs {
+		log.Printf("Error analyzing task %d: %v", taskID, err)
+	}
+	failures := analysisFailures(activeTasks, errs)
+
+	taskAnalyses := orderedAnalyses(activeTasks, results)
+	totalWorkingHours := 0.0
+	for _, analysis := range taskAnalyses {
 		totalWorkingHours += float64(analysis.EstimatedDuration)
 	}
 
@@ -740,6 +1003,7 @@ func (a *AITimeOptimizer) AnalyzeProjectTimeRisks(projectID uint) (*ProjectTimeR
 	// Add Arabic working schedule context
 	projectReport.WorkingDaysRemaining = workingDaysRemaining
 	projectReport.TotalWorkingHours = totalWorkingHours
+	projectReport.FailedAnalyses = failures
 
 	return projectReport, nil
 }
@@ -751,19 +1015,18 @@ func (a *AITimeOptimizer) generateProjectAnalysis(project models.Project, taskAn
 	// Build project analysis prompt
 	prompt := a.buildProjectAnalysisPrompt(project, taskAnalyses)
 
-	// Get AI analysis
-	resp, err := a.model.GenerateContent(ctx, genai.Text(prompt))
+	// Get AI analysis, retrying once with the problems when the answer fails validation
+	aiResponse, issues, err := generateValidated(ctx, func(ctx context.Context, prompt string) (*genai.GenerateContentResponse, error) {
+		return a.generate(ctx, geminiModelName, AIFeatureProjectAnalysis, projectAnalysisSchema, prompt)
+	}, prompt, func(aiResponse *projectAnalysisResponse) []AIValidationIssue {
+		return validateProjectAnalysisResponse(*aiResponse, taskAnalyses)
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	// Parse response
-	report, err := a.parseProjectAnalysisResponse(resp, project, taskAnalyses)
-	if err != nil {
-		return nil, err
-	}
-
-	return report, nil
+	return a.parseProjectAnalysisResponse(*aiResponse, issues, project, taskAnalyses), nil
 }
 
 // buildProjectAnalysisPrompt creates prompt for project-level analysis
@@ -875,57 +1138,96 @@ func (a *AITimeOptimizer) formatTaskAnalysesForPrompt(analyses []TimeAnalysis) s
 	return formatted.String()
 }
 
-// parseProjectAnalysisResponse parses project analysis response
-func (a *AITimeOptimizer) parseProjectAnalysisResponse(resp *genai.GenerateContentResponse, project models.Project, taskAnalyses []TimeAnalysis) (*ProjectTimeReport, error) {
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("empty response from AI")
+// projectAnalysisResponse is the JSON the AI returns for a project
+type projectAnalysisResponse struct {
+	OverallRisk         string   `json:"overall_risk"`
+	PredictedDelayDays  int      `json:"predicted_delay_days"`
+	CriticalPathTaskIDs []uint   `json:"critical_path_task_ids"`
+	TimeOptimizations   []string `json:"time_optimizations"`
+	ResourceConflicts   []string `json:"resource_conflicts"`
+	ExecutiveSummary    string   `json:"executive_summary"`
+}
+
+// validateProjectAnalysisResponse checks a project analysis answer: a known risk level, a delay
+// of zero or more, and a critical path made only of the analyzed tasks
+func validateProjectAnalysisResponse(aiResponse projectAnalysisResponse, taskAnalyses []TimeAnalysis) []AIValidationIssue {
+	var issues []AIValidationIssue
+	if !slices.Contains(riskLevels, aiResponse.OverallRisk) {
+		issues = append(issues, AIValidationIssue{Field: "overall_risk", Message: fmt.Sprintf("%q is not one of %s", aiResponse.OverallRisk, strings.Join(riskLevels, ", "))})
+	}
+	if aiResponse.PredictedDelayDays < 0 {
+		issues = append(issues, AIValidationIssue{Field: "predicted_delay_days", Message: "must not be negative"})
+	}
+
+	analyzed := make(map[uint]bool, len(taskAnalyses))
+	for _, analysis := range taskAnalyses {
+		analyzed[analysis.TaskID] = true
+	}
+	for i, taskID := range aiResponse.CriticalPathTaskIDs {
+		if !analyzed[taskID] {
+			issues = append(issues, AIValidationIssue{Item: i + 1, Field: "critical_path_task_ids", Message: fmt.Sprintf("%d is not one of the analyzed tasks", taskID)})
+		}
+	}
+
+	return issues
+}
+
+// parseProjectAnalysisResponse builds the project report from a validated answer. Every project
+// issue can be repaired: an unknown risk level becomes the highest task risk, a negative delay
+// becomes 0 and unknown tasks are dropped from the critical path.
+func (a *AITimeOptimizer) parseProjectAnalysisResponse(aiResponse projectAnalysisResponse, issues []AIValidationIssue, project models.Project, taskAnalyses []TimeAnalysis) *ProjectTimeReport {
+	analyzed := make(map[uint]bool, len(taskAnalyses))
+	highestRisk := 0
+	for _, analysis := range taskAnalyses {
+		analyzed[analysis.TaskID] = true
+		highestRisk = max(highestRisk, slices.Index(riskLevels, analysis.DeadlineRisk))
 	}
 
-	responseText := fmt.Sprintf("%v", resp.Candidates[0].Content.Parts[0])
-	responseText = strings.TrimPrefix(responseText, "```json")
-	responseText = strings.TrimSuffix(responseText, "```")
-	responseText = strings.TrimSpace(responseText)
+	if risk := strings.ToLower(strings.TrimSpace(aiResponse.OverallRisk)); slices.Contains(riskLevels, risk) {
+		aiResponse.OverallRisk = risk
+	} else {
+		aiResponse.OverallRisk = riskLevels[highestRisk]
+	}
+	aiResponse.PredictedDelayDays = max(0, aiResponse.PredictedDelayDays)
 
-	var aiResponse struct {
-		OverallRisk         string   `json:"overall_risk"`
-		PredictedDelayDays  int      `json:"predicted_delay_days"`
-		CriticalPathTaskIDs []uint   `json:"critical_path_task_ids"`
-		TimeOptimizations   []string `json:"time_optimizations"`
-		ResourceConflicts   []string `json:"resource_conflicts"`
-		ExecutiveSummary    string   `json:"executive_summary"`
+	criticalPath := make([]uint, 0, len(aiResponse.CriticalPathTaskIDs))
+	for _, taskID := range aiResponse.CriticalPathTaskIDs {
+		if analyzed[taskID] {
+			criticalPath = append(criticalPath, taskID)
+		}
 	}
 
-	err := json.Unmarshal([]byte(responseText), &aiResponse)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse AI response: %v", err)
+	for i := range issues {
+		issues[i].Repaired = true
 	}
 
-	report := &ProjectTimeReport{
+	return &ProjectTimeReport{
 		ProjectID:         project.ID,
 		ProjectTitle:      project.Title,
 		OverallRisk:       aiResponse.OverallRisk,
 		PredictedDelay:    aiResponse.PredictedDelayDays,
-		CriticalPath:      aiResponse.CriticalPathTaskIDs,
+		CriticalPath:      criticalPath,
 		TimeOptimizations: aiResponse.TimeOptimizations,
 		ResourceConflicts: aiResponse.ResourceConflicts,
 		TaskAnalyses:      taskAnalyses,
 		Summary:           aiResponse.ExecutiveSummary,
+		ValidationIssues:  issues,
 	}
-
-	return report, nil
 }
 
-// GetCriticalTimeAlerts returns urgent time-related alerts considering Arabic working schedule
+// GetCriticalTimeAlerts returns urgent time-related alerts considering Arabic working schedule.
+// Alerts are built from stored analyses where possible, and user workloads reuse the same task
+// analyses instead of analyzing every task again.
 func (a *AITimeOptimizer) GetCriticalTimeAlerts() ([]map[string]interface{}, error) {
 	var alerts []map[string]interface{}
 
 	// Get tasks with critical deadline risks
-	analyses, err := a.AnalyzeTaskTimeRisks()
+	tasks, results, _, err := a.activeTaskAnalyses(nil, aiAnalysisServeFor)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, analysis := range analyses {
+	for _, analysis := range orderedAnalyses(tasks, results) {
 		switch analysis.DeadlineRisk {
 		case "critical", "high":
 			alert := map[string]interface{}{
@@ -945,17 +1247,21 @@ func (a *AITimeOptimizer) GetCriticalTimeAlerts() ([]map[string]interface{}, err
 	}
 
 	// Check for overloaded users based on 42-hour work week
+	tasksByUser := make(map[uint][]models.Task)
+	for _, task := range tasks {
+		tasksByUser[task.UserID] = append(tasksByUser[task.UserID], task)
+	}
+
 	var users []models.User
 	a.DB.Find(&users)
 
 	for _, user := range users {
-		workload, err := a.AnalyzeUserWorkload(user.ID)
-		if err != nil {
-			continue
-		}
+		workload := a.buildUserWorkload(user, tasksByUser[user.ID], results)
 
 		switch workload.OverloadRisk {
 		case "critical", "high":
+			// Only overloaded users need AI recommendations
+			a.addWorkloadRecommendations(user, tasksByUser[user.ID], workload)
 			alert := map[string]interface{}{
 				"type":             "user_overload",
 				"severity":         workload.OverloadRisk,
@@ -0,0 +1,473 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"project-x/models"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+	"gorm.io/gorm"
+)
+
+// geminiModelName is the model every AI feature uses
+const geminiModelName = "gemini-2.0-flash"
+
+// AIFeature names the part of the app an LLM call is made for
+type AIFeature string
+
+const (
+	AIFeatureChat                    AIFeature = "chat"
+	AIFeatureTaskGeneration          AIFeature = "task_generation"
+	AIFeatureTimeAnalysis            AIFeature = "time_analysis"
+	AIFeatureWorkloadRecommendations AIFeature = "workload_recommendations"
+	AIFeatureProjectAnalysis         AIFeature = "project_analysis"
+	AIFeatureKnowledgeIndex          AIFeature = "knowledge_index"
+	AIFeatureKnowledgeSearch         AIFeature = "knowledge_search"
+	AIFeatureDailyStandup            AIFeature = "daily_standup"
+	AIFeatureProjectDigest           AIFeature = "project_digest"
+)
+
+// background reports whether a feature runs analyses nobody is waiting on interactively. These
+// are cut off first when the organization budget runs low.
+func (f AIFeature) background() bool {
+	switch f {
+	case AIFeatureTimeAnalysis, AIFeatureWorkloadRecommendations, AIFeatureProjectAnalysis, AIFeatureKnowledgeIndex,
+		AIFeatureDailyStandup, AIFeatureProjectDigest:
+		return true
+	}
+	return false
+}
+
+// Usage record statuses
+const (
+	AIUsageStatusSuccess    = "success"
+	AIUsageStatusError      = "error"
+	AIUsageStatusOverBudget = "over_budget"
+)
+
+// ErrAIBudgetExceeded is returned instead of calling the AI when a budget is used up
+var ErrAIBudgetExceeded = errors.New("AI usage budget exceeded")
+
+// AIBudgetSettings are the monthly token budgets and prices; see env.example. A budget of 0
+// means unlimited.
+type AIBudgetSettings struct {
+	UserMonthlyTokens      int64   `json:"user_monthly_tokens"`
+	OrgMonthlyTokens       int64   `json:"org_monthly_tokens"`
+	BackgroundLimitPercent int     `json:"background_limit_percent"` // share of the org budget background features may use
+	InputCostPerMillion    float64 `json:"input_cost_per_million"`   // USD per million prompt tokens
+	OutputCostPerMillion   float64 `json:"output_cost_per_million"`  // USD per million response tokens
+}
+
+// LoadAIBudgetSettings reads the budgets from the environment
+func LoadAIBudgetSettings() AIBudgetSettings {
+	settings := AIBudgetSettings{
+		BackgroundLimitPercent: 90,
+		InputCostPerMillion:    0.30,
+		OutputCostPerMillion:   2.50,
+	}
+	if n, err := strconv.ParseInt(os.Getenv("AI_USER_MONTHLY_TOKEN_BUDGET"), 10, 64); err == nil && n > 0 {
+		settings.UserMonthlyTokens = n
+	}
+	if n, err := strconv.ParseInt(os.Getenv("AI_ORG_MONTHLY_TOKEN_BUDGET"), 10, 64); err == nil && n > 0 {
+		settings.OrgMonthlyTokens = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("AI_BACKGROUND_BUDGET_PERCENT")); err == nil && n > 0 && n <= 100 {
+		settings.BackgroundLimitPercent = n
+	}
+	if n, err := strconv.ParseFloat(os.Getenv("AI_INPUT_COST_PER_MILLION"), 64); err == nil && n >= 0 {
+		settings.InputCostPerMillion = n
+	}
+	if n, err := strconv.ParseFloat(os.Getenv("AI_OUTPUT_COST_PER_MILLION"), 64); err == nil && n >= 0 {
+		settings.OutputCostPerMillion = n
+	}
+	return settings
+}
+
+// AIUsageService meters LLM calls and enforces the token budgets
+type AIUsageService struct {
+	DB       *gorm.DB
+	Settings AIBudgetSettings
+}
+
+// AIUsageBreakdown sums the calls in one group of a usage report
+type AIUsageBreakdown struct {
+	Key            string  `json:"key"`
+	Calls          int64   `json:"calls"`
+	Errors         int64   `json:"errors"`
+	OverBudget     int64   `json:"over_budget"`
+	PromptTokens   int64   `json:"prompt_tokens"`
+	ResponseTokens int64   `json:"response_tokens"`
+	TotalTokens    int64   `json:"total_tokens"`
+	AvgLatencyMs   float64 `json:"avg_latency_ms"`
+	EstimatedCost  float64 `json:"estimated_cost_usd"`
+}
+
+// AIUserUsage is one user's share of a usage report
+type AIUserUsage struct {
+	UserID   *uint  `json:"user_id"`
+	Username string `json:"username"`
+	AIUsageBreakdown
+}
+
+// AIBudgetStatus shows how much of this month's organization budget is used
+type AIBudgetStatus struct {
+	Month             string           `json:"month"`
+	Settings          AIBudgetSettings `json:"settings"`
+	OrgUsedTokens     int64            `json:"org_used_tokens"`
+	OrgUsedPercent    float64          `json:"org_used_percent"`
+	BackgroundPaused  bool             `json:"background_paused"`
+	OrgBudgetExceeded bool             `json:"org_budget_exceeded"`
+	UsersOverBudget   int64            `json:"users_over_budget"`
+}
+
+// AIUsageReport breaks LLM usage in a period down by feature, model, user and day
+type AIUsageReport struct {
+	From      time.Time          `json:"from"`
+	To        time.Time          `json:"to"`
+	Totals    AIUsageBreakdown   `json:"totals"`
+	ByFeature []AIUsageBreakdown `json:"by_feature"`
+	ByModel   []AIUsageBreakdown `json:"by_model"`
+	ByUser    []AIUserUsage      `json:"by_user"`
+	Daily     []AIUsageBreakdown `json:"daily"`
+	Budget    AIBudgetStatus     `json:"budget"`
+}
+
+func NewAIUsageService(db *gorm.DB) *AIUsageService {
+	return &AIUsageService{
+		DB:       db,
+		Settings: LoadAIBudgetSettings(),
+	}
+}
+
+// Generate sends a prompt to the model and records its token usage and latency. Calls over
+// budget are recorded and rejected with ErrAIBudgetExceeded without reaching the model.
+func (s *AIUsageService) Generate(ctx context.Context, model *genai.GenerativeModel, modelName string, feature AIFeature, userID *uint, prompt string) (*genai.GenerateContentResponse, error) {
+	if model == nil {
+		return nil, fmt.Errorf("AI service not available")
+	}
+
+	return s.metered(modelName, feature, userID, func() (*genai.GenerateContentResponse, error) {
+		return model.GenerateContent(ctx, genai.Text(prompt))
+	})
+}
+
+// SendMessageStream sends the next turn of a chat session, such as function results, with the
+// same metering and budget checks as Generate. The answer is streamed: onChunk is called with
+// each chunk as it arrives. The merged response is returned even when the stream is cut off, for example by
+// cancelling ctx, together with the error that stopped it.
+func (s *AIUsageService) SendMessageStream(ctx context.Context, session *genai.ChatSession, modelName string, feature AIFeature, userID *uint, onChunk func(*genai.GenerateContentResponse), parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	if session == nil {
+		return nil, fmt.Errorf("AI service not available")
+	}
+
+	return s.metered(modelName, feature, userID, func() (*genai.GenerateContentResponse, error) {
+		stream := session.SendMessageStream(ctx, parts...)
+		var usage *genai.UsageMetadata
+		for {
+			chunk, err := stream.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return withUsage(stream.MergedResponse(), usage), err
+			}
+			// Token counts come with the last chunks; the merged response keeps the first
+			if chunk.UsageMetadata != nil {
+				usage = chunk.UsageMetadata
+			}
+			if onChunk != nil {
+				onChunk(chunk)
+			}
+		}
+		return withUsage(stream.MergedResponse(), usage), nil
+	})
+}
+
+func withUsage(resp *genai.GenerateContentResponse, usage *genai.UsageMetadata) *genai.GenerateContentResponse {
+	if resp != nil && usage != nil {
+		resp.UsageMetadata = usage
+	}
+	return resp
+}
+
+// Embed returns the embeddings of the texts, in order, with the same budget checks as Generate.
+// The embedding API reports no token counts, so the record holds an estimate of about four
+// characters per token.
+func (s *AIUsageService) Embed(ctx context.Context, model *genai.EmbeddingModel, feature AIFeature, userID *uint, texts []string) ([][]float32, error) {
+	if model == nil {
+		return nil, fmt.Errorf("AI service not available")
+	}
+	if err := s.CheckBudget(feature, userID); err != nil {
+		s.record(&models.AIUsageRecord{
+			UserID:    userID,
+			Feature:   string(feature),
+			ModelName: model.Name(),
+			Status:    AIUsageStatusOverBudget,
+			Error:     err.Error(),
+		})
+		return nil, err
+	}
+
+	batch := model.NewBatch()
+	estimatedTokens := 0
+	for _, text := range texts {
+		batch.AddContent(genai.Text(text))
+		estimatedTokens += len(text)/4 + 1
+	}
+
+	start := time.Now()
+	resp, err := model.BatchEmbedContents(ctx, batch)
+	record := &models.AIUsageRecord{
+		UserID:       userID,
+		Feature:      string(feature),
+		ModelName:    model.Name(),
+		PromptTokens: estimatedTokens,
+		TotalTokens:  estimatedTokens,
+		LatencyMs:    time.Since(start).Milliseconds(),
+		Status:       AIUsageStatusSuccess,
+	}
+	if err == nil && len(resp.Embeddings) != len(texts) {
+		err = fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+	}
+	if err != nil {
+		record.Status = AIUsageStatusError
+		record.Error = err.Error()
+	}
+	s.record(record)
+	if err != nil {
+		return nil, err
+	}
+
+	embeddings := make([][]float32, len(resp.Embeddings))
+	for i, embedding := range resp.Embeddings {
+		embeddings[i] = embedding.Values
+	}
+	return embeddings, nil
+}
+
+// metered checks the budget, makes the call and records its usage
+func (s *AIUsageService) metered(modelName string, feature AIFeature, userID *uint, call func() (*genai.GenerateContentResponse, error)) (*genai.GenerateContentResponse, error) {
+	if err := s.CheckBudget(feature, userID); err != nil {
+		s.record(&models.AIUsageRecord{
+			UserID:    userID,
+			Feature:   string(feature),
+			ModelName: modelName,
+			Status:    AIUsageStatusOverBudget,
+			Error:     err.Error(),
+		})
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := call()
+	record := &models.AIUsageRecord{
+		UserID:    userID,
+		Feature:   string(feature),
+		ModelName: modelName,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Status:    AIUsageStatusSuccess,
+	}
+	if resp != nil && resp.UsageMetadata != nil {
+		record.PromptTokens = int(resp.UsageMetadata.PromptTokenCount)
+		record.ResponseTokens = int(resp.UsageMetadata.CandidatesTokenCount)
+		record.TotalTokens = int(resp.UsageMetadata.TotalTokenCount)
+	}
+	if err != nil {
+		record.Status = AIUsageStatusError
+		record.Error = err.Error()
+	}
+	s.record(record)
+
+	return resp, err
+}
+
+// CheckBudget returns ErrAIBudgetExceeded when a call for the feature would go over this
+// month's budgets. Background features stop at BackgroundLimitPercent of the organization
+// budget so interactive features keep working until it is used up.
+func (s *AIUsageService) CheckBudget(feature AIFeature, userID *uint) error {
+	monthStart := startOfMonth(time.Now())
+
+	if s.Settings.OrgMonthlyTokens > 0 {
+		used := s.tokensSince(monthStart, nil)
+		limit := s.Settings.OrgMonthlyTokens
+		if feature.background() {
+			limit = limit * int64(s.Settings.BackgroundLimitPercent) / 100
+		}
+		if used >= limit {
+			return fmt.Errorf("%w: the organization has used %d of %d tokens this month", ErrAIBudgetExceeded, used, s.Settings.OrgMonthlyTokens)
+		}
+	}
+
+	if s.Settings.UserMonthlyTokens > 0 && userID != nil {
+		used := s.tokensSince(monthStart, userID)
+		if used >= s.Settings.UserMonthlyTokens {
+			return fmt.Errorf("%w: you have used %d of %d tokens this month", ErrAIBudgetExceeded, used, s.Settings.UserMonthlyTokens)
+		}
+	}
+
+	return nil
+}
+
+// GetBudgetStatus reports this month's usage against the budgets
+func (s *AIUsageService) GetBudgetStatus() AIBudgetStatus {
+	monthStart := startOfMonth(time.Now())
+	status := AIBudgetStatus{
+		Month:         monthStart.Format("2006-01"),
+		Settings:      s.Settings,
+		OrgUsedTokens: s.tokensSince(monthStart, nil),
+	}
+
+	if s.Settings.OrgMonthlyTokens > 0 {
+		status.OrgUsedPercent = float64(status.OrgUsedTokens) / float64(s.Settings.OrgMonthlyTokens) * 100
+		status.OrgBudgetExceeded = status.OrgUsedTokens >= s.Settings.OrgMonthlyTokens
+		status.BackgroundPaused = status.OrgUsedPercent >= float64(s.Settings.BackgroundLimitPercent)
+	}
+
+	if s.Settings.UserMonthlyTokens > 0 {
+		s.DB.Raw(`
+			SELECT COUNT(*) FROM (
+				SELECT user_id FROM ai_usage_records
+				WHERE user_id IS NOT NULL AND created_at >= ? AND deleted_at IS NULL
+				GROUP BY user_id
+				HAVING SUM(total_tokens) >= ?
+			) over_budget
+		`, monthStart, s.Settings.UserMonthlyTokens).Scan(&status.UsersOverBudget)
+	}
+
+	return status
+}
+
+// GetUsageReport breaks down the LLM calls made between two times
+func (s *AIUsageService) GetUsageReport(from, to time.Time) (*AIUsageReport, error) {
+	report := &AIUsageReport{From: from, To: to, Budget: s.GetBudgetStatus()}
+
+	totals, err := s.breakdown(from, to, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(totals) > 0 {
+		report.Totals = totals[0]
+	}
+	report.Totals.Key = "total"
+
+	if report.ByFeature, err = s.breakdown(from, to, "feature"); err != nil {
+		return nil, err
+	}
+	if report.ByModel, err = s.breakdown(from, to, "model_name"); err != nil {
+		return nil, err
+	}
+	if report.Daily, err = s.breakdown(from, to, "TO_CHAR(created_at, 'YYYY-MM-DD')"); err != nil {
+		return nil, err
+	}
+	sort.Slice(report.Daily, func(i, j int) bool { return report.Daily[i].Key < report.Daily[j].Key })
+
+	byUser, err := s.breakdown(from, to, "COALESCE(CAST(user_id AS TEXT), '')")
+	if err != nil {
+		return nil, err
+	}
+	report.ByUser = s.withUsernames(byUser)
+
+	return report, nil
+}
+
+// breakdown sums usage in a period grouped by a SQL expression, largest token use first. An
+// empty expression sums the whole period into one row.
+func (s *AIUsageService) breakdown(from, to time.Time, groupBy string) ([]AIUsageBreakdown, error) {
+	key := groupBy
+	if key == "" {
+		key = "'total'"
+	}
+
+	query := s.DB.Model(&models.AIUsageRecord{}).
+		Select(key+` AS key,
+			COUNT(*) AS calls,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS errors,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS over_budget,
+			COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens,
+			COALESCE(SUM(response_tokens), 0) AS response_tokens,
+			COALESCE(SUM(total_tokens), 0) AS total_tokens,
+			COALESCE(AVG(CASE WHEN status <> ? THEN latency_ms END), 0) AS avg_latency_ms`,
+			AIUsageStatusError, AIUsageStatusOverBudget, AIUsageStatusOverBudget).
+		Where("created_at >= ? AND created_at <= ?", from, to)
+	if groupBy != "" {
+		query = query.Group(groupBy).Order("total_tokens DESC")
+	}
+
+	var rows []AIUsageBreakdown
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range rows {
+		rows[i].EstimatedCost = s.cost(rows[i].PromptTokens, rows[i].ResponseTokens)
+	}
+	return rows, nil
+}
+
+// withUsernames converts a breakdown keyed by user ID into per-user usage. Calls made for no
+// user, such as background time analyses, are listed as "system".
+func (s *AIUsageService) withUsernames(rows []AIUsageBreakdown) []AIUserUsage {
+	var userIDs []uint
+	for _, row := range rows {
+		if id, err := strconv.ParseUint(row.Key, 10, 32); err == nil {
+			userIDs = append(userIDs, uint(id))
+		}
+	}
+
+	usernames := make(map[uint]string)
+	if len(userIDs) > 0 {
+		var users []models.User
+		s.DB.Select("id, username").Where("id IN ?", userIDs).Find(&users)
+		for _, user := range users {
+			usernames[user.ID] = user.Username
+		}
+	}
+
+	result := make([]AIUserUsage, 0, len(rows))
+	for _, row := range rows {
+		usage := AIUserUsage{Username: "system", AIUsageBreakdown: row}
+		if id, err := strconv.ParseUint(row.Key, 10, 32); err == nil {
+			userID := uint(id)
+			usage.UserID = &userID
+			usage.Username = usernames[userID]
+		}
+		result = append(result, usage)
+	}
+	return result
+}
+
+// tokensSince sums the tokens used since a time, by one user or (nil) the whole organization
+func (s *AIUsageService) tokensSince(since time.Time, userID *uint) int64 {
+	query := s.DB.Model(&models.AIUsageRecord{}).Where("created_at >= ?", since)
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	var total int64
+	query.Select("COALESCE(SUM(total_tokens), 0)").Scan(&total)
+	return total
+}
+
+// cost estimates the price of the given token counts in USD
+func (s *AIUsageService) cost(promptTokens, responseTokens int64) float64 {
+	return (float64(promptTokens)*s.Settings.InputCostPerMillion + float64(responseTokens)*s.Settings.OutputCostPerMillion) / 1e6
+}
+
+// record saves a usage record; metering failures never fail the AI call itself
+func (s *AIUsageService) record(record *models.AIUsageRecord) {
+	if err := s.DB.Create(record).Error; err != nil {
+		log.Printf("⚠️ Warning: Failed to record AI usage for %s: %v", record.Feature, err)
+	}
+}
+
+// startOfMonth returns midnight on the first day of t's month
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
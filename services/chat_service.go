@@ -1,6 +1,7 @@
 package services
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -11,6 +12,11 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	ErrProjectNotFound  = errors.New("project not found")
+	ErrNotProjectMember = errors.New("only members of the project can join its chat room")
+)
+
 type ChatService struct {
 	db               *gorm.DB
 	websocketService *WebSocketService
@@ -55,6 +61,49 @@ func (cs *ChatService) CreateTeamChat(name, description string, createdBy uint)
 	return room, nil
 }
 
+// GetOrCreateProjectChat returns the team room of a project, creating it the first time, and
+// joins the user to it. Project members, Admins and Managers can join. Weekly project digests
+// read the messages of this room.
+func (cs *ChatService) GetOrCreateProjectChat(projectID, userID uint) (*models.ChatRoom, error) {
+	var project models.Project
+	if err := cs.db.First(&project, projectID).Error; err != nil {
+		return nil, ErrProjectNotFound
+	}
+
+	var user models.User
+	if err := cs.db.First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.Role != models.RoleAdmin && user.Role != models.RoleManager {
+		var count int64
+		cs.db.Model(&models.UserProject{}).Where("project_id = ? AND user_id = ?", projectID, userID).Count(&count)
+		if count == 0 {
+			return nil, ErrNotProjectMember
+		}
+	}
+
+	var room models.ChatRoom
+	err := cs.db.Where("project_id = ?", projectID).Order("id ASC").First(&room).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		room = models.ChatRoom{
+			Name:        project.Title + " Team",
+			Description: fmt.Sprintf("Team room of the %s project", project.Title),
+			CreatedBy:   userID,
+			MaxMembers:  1000,
+			ProjectID:   &project.ID,
+		}
+		err = cs.db.Create(&room).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cs.JoinTeamChat(room.ID, userID); err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
 // JoinTeamChat joins a user to the team chat
 func (cs *ChatService) JoinTeamChat(roomID, userID uint) error {
 	// Check if user is already in the room
@@ -119,46 +168,91 @@ func (cs *ChatService) SendMessage(roomID, senderID uint, content string) (*mode
 	return message, nil
 }
 
-// processAIMessage processes AI messages asynchronously
+// processAIMessage streams the AI's answer to the room as ai_delta events and saves it once it is
+// complete, or cancelled, announcing the saved message with ai_done
 func (cs *ChatService) processAIMessage(roomID, senderID uint, content string) {
-	// Process AI message
-	aiResponse, err := cs.aiChatService.ProcessAIMessage(senderID, content, roomID)
+	roomKey := strconv.FormatUint(uint64(roomID), 10)
+	streamID := fmt.Sprintf("%d-%d", roomID, time.Now().UnixNano())
+
+	sequence := 0
+	aiResponse, err := cs.aiChatService.StreamAIMessage(senderID, content, roomID, func(delta string) {
+		if cs.websocketService == nil {
+			return
+		}
+		sequence++
+		cs.websocketService.StreamToRoom(roomKey, Notification{
+			Type: "ai_delta",
+			Data: map[string]interface{}{
+				"stream_id": streamID,
+				"room_id":   roomID,
+				"sequence":  sequence,
+				"delta":     delta,
+			},
+			Timestamp: time.Now(),
+		})
+	})
 	if err != nil {
 		log.Printf("Error processing AI message: %v", err)
+		cs.broadcastAIDone(roomKey, Notification{
+			Type:  "ai_done",
+			Title: "AI Assistant",
+			Data: map[string]interface{}{
+				"stream_id": streamID,
+				"room_id":   roomID,
+				"error":     "Failed to process AI message",
+			},
+			Timestamp: time.Now(),
+		})
 		return
 	}
 
-	// Create AI user (system user) for AI messages
-	// We'll use a special sender ID (0) or create a system user
-	// For now, we'll create a message with a special marker
-	aiMessage := &models.ChatMessage{
-		ChatRoomID: roomID,
-		SenderID:   senderID, // Keep original sender for now, can be enhanced
-		Content:    aiResponse.Message,
-		Metadata:   fmt.Sprintf(`{"ai_response":true,"action":"%s"}`, aiResponse.Action),
-	}
+	// Nothing to keep when the answer was stopped before any text arrived
+	var messageID uint
+	if aiResponse.Message != "" {
+		metadata, _ := json.Marshal(map[string]interface{}{
+			"ai_response": true,
+			"action":      aiResponse.Action,
+			"stream_id":   streamID,
+			"cancelled":   aiResponse.Cancelled,
+		})
 
-	if err := cs.db.Create(aiMessage).Error; err != nil {
-		log.Printf("Error saving AI response: %v", err)
-		return
+		// Create AI user (system user) for AI messages
+		// We'll use a special sender ID (0) or create a system user
+		// For now, we'll create a message with a special marker
+		aiMessage := &models.ChatMessage{
+			ChatRoomID: roomID,
+			SenderID:   senderID, // Keep original sender for now, can be enhanced
+			Content:    aiResponse.Message,
+			Metadata:   string(metadata),
+		}
+
+		if err := cs.db.Create(aiMessage).Error; err != nil {
+			log.Printf("Error saving AI response: %v", err)
+		}
+		messageID = aiMessage.ID
 	}
 
-	// Broadcast AI response via WebSocket
+	cs.broadcastAIDone(roomKey, Notification{
+		Type:    "ai_done",
+		Title:   "AI Assistant",
+		Message: aiResponse.Message,
+		Data: map[string]interface{}{
+			"stream_id":  streamID,
+			"message_id": messageID,
+			"room_id":    roomID,
+			"sender":     "AI Assistant",
+			"content":    aiResponse.Message,
+			"action":     aiResponse.Action,
+			"data":       aiResponse.Data,
+			"cancelled":  aiResponse.Cancelled,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+func (cs *ChatService) broadcastAIDone(roomKey string, notification Notification) {
 	if cs.websocketService != nil {
-		cs.websocketService.BroadcastToRoom(strconv.FormatUint(uint64(roomID), 10), Notification{
-			Type:    "ai_message",
-			Title:   "AI Assistant",
-			Message: aiResponse.Message,
-			Data: map[string]interface{}{
-				"message_id": aiMessage.ID,
-				"room_id":    roomID,
-				"sender":     "AI Assistant",
-				"content":    aiResponse.Message,
-				"action":     aiResponse.Action,
-				"data":       aiResponse.Data,
-			},
-			Timestamp: time.Now(),
-		})
+		cs.websocketService.BroadcastToRoom(roomKey, notification)
 	}
 }
 
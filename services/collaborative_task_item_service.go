@@ -0,0 +1,330 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"project-x/models"
+	"strings"
+	"time"
+)
+
+// GetTaskItems returns the checklist items of a collaborative task in order
+func (s *CollaborativeTaskService) GetTaskItems(taskID uint) ([]models.CollaborativeTaskItem, error) {
+	if err := s.DB.First(&models.CollaborativeTask{}, taskID).Error; err != nil {
+		return nil, errors.New("collaborative task not found")
+	}
+
+	var items []models.CollaborativeTaskItem
+	err := s.DB.Preload("Assignee").Preload("CompletedBy").
+		Where("collaborative_task_id = ?", taskID).
+		Order("sort_order ASC, id ASC").
+		Find(&items).Error
+	return items, err
+}
+
+// AddTaskItem adds a sub-task or checklist item to a collaborative task. Only the lead (or a
+// Head/Manager/Admin) can add items, and the assignee must be a non-observer participant.
+func (s *CollaborativeTaskService) AddTaskItem(taskID, actorID uint, actorRole models.Role, title, description string, assigneeID *uint, dueDate *time.Time, sortOrder int) (*models.CollaborativeTaskItem, error) {
+	task, err := s.findManageableTask(taskID, actorID, actorRole)
+	if err != nil {
+		return nil, err
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, errors.New("item title is required")
+	}
+	if err := s.validateItemAssignee(task.ID, assigneeID); err != nil {
+		return nil, err
+	}
+
+	item := &models.CollaborativeTaskItem{
+		CollaborativeTaskID: task.ID,
+		AssigneeID:          assigneeID,
+		Title:               title,
+		Description:         description,
+		SortOrder:           sortOrder,
+		DueDate:             dueDate,
+	}
+	if err := s.DB.Create(item).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.recalculateTaskProgress(task.ID, actorID); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// UpdateTaskItem changes the details and assignee of an item (lead or Head/Manager/Admin only)
+func (s *CollaborativeTaskService) UpdateTaskItem(taskID, itemID, actorID uint, actorRole models.Role, title, description string, assigneeID *uint, dueDate *time.Time, sortOrder int) (*models.CollaborativeTaskItem, error) {
+	task, err := s.findManageableTask(taskID, actorID, actorRole)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := s.findTaskItem(task.ID, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, errors.New("item title is required")
+	}
+	if err := s.validateItemAssignee(task.ID, assigneeID); err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Model(item).Updates(map[string]interface{}{
+		"title":       title,
+		"description": description,
+		"assignee_id": assigneeID,
+		"due_date":    dueDate,
+		"sort_order":  sortOrder,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Reassigning an item changes both participants' completion
+	if err := s.recalculateTaskProgress(task.ID, actorID); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// SetTaskItemCompleted marks an item completed or reopens it. The item's assignee, the lead or
+// a Head/Manager/Admin can do this; the parent progress and participant status follow.
+func (s *CollaborativeTaskService) SetTaskItemCompleted(taskID, itemID, actorID uint, actorRole models.Role, completed bool) (*models.CollaborativeTaskItem, error) {
+	var task models.CollaborativeTask
+	if err := s.DB.First(&task, taskID).Error; err != nil {
+		return nil, errors.New("collaborative task not found")
+	}
+
+	item, err := s.findTaskItem(task.ID, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	isAssignee := item.AssigneeID != nil && *item.AssigneeID == actorID
+	if !isAssignee && !canManageCollaborativeTask(&task, actorID, actorRole) {
+		return nil, errors.New("only the item's assignee or the task lead can update this item")
+	}
+
+	if item.IsCompleted == completed {
+		return item, nil
+	}
+
+	updates := map[string]interface{}{
+		"is_completed":    completed,
+		"completed_at":    nil,
+		"completed_by_id": nil,
+	}
+	if completed {
+		updates["completed_at"] = time.Now()
+		updates["completed_by_id"] = actorID
+	}
+	if err := s.DB.Model(item).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.recalculateTaskProgress(task.ID, actorID); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// DeleteTaskItem removes an item (lead or Head/Manager/Admin only)
+func (s *CollaborativeTaskService) DeleteTaskItem(taskID, itemID, actorID uint, actorRole models.Role) error {
+	task, err := s.findManageableTask(taskID, actorID, actorRole)
+	if err != nil {
+		return err
+	}
+
+	item, err := s.findTaskItem(task.ID, itemID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.DB.Delete(item).Error; err != nil {
+		return err
+	}
+
+	return s.recalculateTaskProgress(task.ID, actorID)
+}
+
+// reopenTask sends a task that is in review or completed back to in_progress, as the lead does
+// by setting its status, and tells the team why
+func (s *CollaborativeTaskService) reopenTask(task *models.CollaborativeTask, reopenedBy uint, reason string) error {
+	if task.Status != models.TaskStatusInReview && task.Status != models.TaskStatusCompleted {
+		return nil
+	}
+	if err := s.DB.Model(task).Update("status", models.TaskStatusInProgress).Error; err != nil {
+		return err
+	}
+
+	s.notifyTeam(task, reopenedBy,
+		"Task Reopened",
+		fmt.Sprintf("'%s' is back in progress: %s", task.Title, reason),
+		models.NotificationTypeTaskUpdated)
+	return nil
+}
+
+// recalculateTaskProgress derives the task's Progress from its items, and each assigned
+// participant's Status and CompletedAt from their own items. Tasks without items keep their
+// manually set progress; participants without items keep their status.
+func (s *CollaborativeTaskService) recalculateTaskProgress(taskID, actorID uint) error {
+	var items []models.CollaborativeTaskItem
+	if err := s.DB.Where("collaborative_task_id = ?", taskID).Find(&items).Error; err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	type participantItems struct {
+		total, completed int
+		lastCompletedAt  *time.Time
+	}
+	byAssignee := make(map[uint]*participantItems)
+	completed := 0
+	for _, item := range items {
+		if item.IsCompleted {
+			completed++
+		}
+		if item.AssigneeID == nil {
+			continue
+		}
+		counts := byAssignee[*item.AssigneeID]
+		if counts == nil {
+			counts = &participantItems{}
+			byAssignee[*item.AssigneeID] = counts
+		}
+		counts.total++
+		if item.IsCompleted {
+			counts.completed++
+			if item.CompletedAt != nil && (counts.lastCompletedAt == nil || item.CompletedAt.After(*counts.lastCompletedAt)) {
+				counts.lastCompletedAt = item.CompletedAt
+			}
+		}
+	}
+
+	progress := completed * 100 / len(items)
+	taskUpdates := map[string]interface{}{"progress": progress}
+
+	// Work has visibly started once any item is done
+	var task models.CollaborativeTask
+	if err := s.DB.First(&task, taskID).Error; err != nil {
+		return err
+	}
+	previousProgress := task.Progress
+	if completed > 0 && task.Status == models.TaskStatusPending {
+		task.Status = models.TaskStatusInProgress
+		taskUpdates["status"] = task.Status
+	}
+	if err := s.DB.Model(&task).Updates(taskUpdates).Error; err != nil {
+		return err
+	}
+
+	// As with manual progress, the checklist reaching 100% sends the task to its reviewers, and
+	// dropping below it again (an item reopened or added) takes it back to in_progress. Edits that
+	// leave progress where it was, such as renaming or reordering items, change neither.
+	switch {
+	case progress == 100 && previousProgress < 100 && (task.Status == models.TaskStatusPending || task.Status == models.TaskStatusInProgress):
+		if _, err := s.submitTaskForReview(&task, actorID); err != nil {
+			return err
+		}
+	case progress < 100 && previousProgress == 100:
+		if err := s.reopenTask(&task, actorID, "its checklist has open items again"); err != nil {
+			return err
+		}
+	}
+
+	var participants []models.CollaborativeTaskParticipant
+	if err := s.DB.Where("collaborative_task_id = ? AND status <> ?", taskID, "inactive").Find(&participants).Error; err != nil {
+		return err
+	}
+	for _, participant := range participants {
+		counts := byAssignee[participant.UserID]
+		if counts == nil {
+			continue
+		}
+
+		status := "active"
+		var completedAt *time.Time
+		if counts.completed == counts.total {
+			status = "completed"
+			completedAt = counts.lastCompletedAt
+		}
+		if participant.Status == status && sameTime(participant.CompletedAt, completedAt) {
+			continue
+		}
+
+		err := s.DB.Model(&participant).Updates(map[string]interface{}{
+			"status":       status,
+			"completed_at": completedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// findManageableTask loads a task the actor may manage items on
+func (s *CollaborativeTaskService) findManageableTask(taskID, actorID uint, actorRole models.Role) (*models.CollaborativeTask, error) {
+	var task models.CollaborativeTask
+	if err := s.DB.First(&task, taskID).Error; err != nil {
+		return nil, errors.New("collaborative task not found")
+	}
+	if !canManageCollaborativeTask(&task, actorID, actorRole) {
+		return nil, errors.New("only the task lead can manage checklist items")
+	}
+	return &task, nil
+}
+
+// findTaskItem loads an item belonging to a task
+func (s *CollaborativeTaskService) findTaskItem(taskID, itemID uint) (*models.CollaborativeTaskItem, error) {
+	var item models.CollaborativeTaskItem
+	if err := s.DB.Where("id = ? AND collaborative_task_id = ?", itemID, taskID).First(&item).Error; err != nil {
+		return nil, errors.New("checklist item not found")
+	}
+	return &item, nil
+}
+
+// validateItemAssignee checks that an item's assignee is a participant who can do work
+func (s *CollaborativeTaskService) validateItemAssignee(taskID uint, assigneeID *uint) error {
+	if assigneeID == nil {
+		return nil
+	}
+
+	var participant models.CollaborativeTaskParticipant
+	if err := s.DB.Where("collaborative_task_id = ? AND user_id = ?", taskID, *assigneeID).First(&participant).Error; err != nil {
+		return errors.New("assignee is not a participant in this task")
+	}
+	if participant.Role == "observer" {
+		return errors.New("observers cannot be assigned checklist items")
+	}
+	return nil
+}
+
+// canManageCollaborativeTask reports whether a user leads the task or has a management role
+func canManageCollaborativeTask(task *models.CollaborativeTask, userID uint, role models.Role) bool {
+	if task.LeadUserID == userID {
+		return true
+	}
+	return role == models.RoleAdmin || role == models.RoleManager || role == models.RoleHead
+}
+
+// sameTime compares two optional timestamps
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"project-x/models"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotTaskContributor is returned when someone who does not work on a collaborative task tries
+// to finish it
+var ErrNotTaskContributor = errors.New("only the lead and contributors of this task can do this")
+
+// SubmitForReview moves a finished collaborative task to in_review and asks its reviewers to
+// approve it. Only the lead and contributors can submit it. A task without reviewer participants
+// is completed straight away, which is reported by the returned flag.
+func (s *CollaborativeTaskService) SubmitForReview(taskID, submittedBy uint) (completed bool, err error) {
+	var task models.CollaborativeTask
+	if err := s.DB.First(&task, taskID).Error; err != nil {
+		return false, errors.New("collaborative task not found")
+	}
+	if !s.isTaskContributor(&task, submittedBy) {
+		return false, ErrNotTaskContributor
+	}
+
+	return s.submitTaskForReview(&task, submittedBy)
+}
+
+// submitTaskForReview moves a task to in_review, or completes it when it has no reviewers. The
+// caller has checked that submittedBy may submit it.
+func (s *CollaborativeTaskService) submitTaskForReview(task *models.CollaborativeTask, submittedBy uint) (completed bool, err error) {
+	switch task.Status {
+	case models.TaskStatusInReview:
+		return false, errors.New("task is already in review")
+	case models.TaskStatusCompleted:
+		return false, errors.New("task is already completed")
+	case models.TaskStatusCancelled:
+		return false, errors.New("cancelled tasks cannot be submitted for review")
+	}
+
+	reviewers, err := s.taskReviewers(s.DB, task.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if len(reviewers) == 0 {
+		if err := s.DB.Model(task).Update("status", models.TaskStatusCompleted).Error; err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	now := time.Now()
+	err = s.DB.Model(task).Updates(map[string]interface{}{
+		"status":       models.TaskStatusInReview,
+		"review_round": task.ReviewRound + 1,
+		"submitted_at": now,
+	}).Error
+	if err != nil {
+		return false, err
+	}
+
+	submitter := s.username(submittedBy)
+	for _, reviewer := range reviewers {
+		s.notify(reviewer.UserID, task,
+			"Review Requested",
+			fmt.Sprintf("%s submitted '%s' for your review", submitter, task.Title),
+			models.NotificationTypeTaskReviewRequested, submittedBy)
+	}
+
+	return false, nil
+}
+
+// ReviewTask records a reviewer's decision on the current review round. Requesting changes
+// needs a comment and sends the task back to in_progress; the task is completed once every
+// reviewer has approved the round.
+func (s *CollaborativeTaskService) ReviewTask(taskID, reviewerID uint, decision models.ReviewDecision, comment string) (*models.CollaborativeTask, error) {
+	if decision != models.ReviewDecisionApproved && decision != models.ReviewDecisionChangesRequested {
+		return nil, errors.New("decision must be 'approved' or 'changes_requested'")
+	}
+	comment = strings.TrimSpace(comment)
+	if decision == models.ReviewDecisionChangesRequested && comment == "" {
+		return nil, errors.New("a comment is required when requesting changes")
+	}
+
+	// Record the decision and settle the round under a lock on the task, so that reviewers
+	// approving at the same moment all see each other's approvals
+	var task models.CollaborativeTask
+	var approvals, reviewerCount int
+	var status models.TaskStatus
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&task, taskID).Error; err != nil {
+			return errors.New("collaborative task not found")
+		}
+		if task.Status != models.TaskStatusInReview {
+			return errors.New("task is not awaiting review")
+		}
+
+		reviewers, err := s.taskReviewers(tx, taskID)
+		if err != nil {
+			return err
+		}
+		reviewerIDs := participantUserIDs(reviewers)
+		if !slices.Contains(reviewerIDs, reviewerID) {
+			return errors.New("only the task's reviewers can review it")
+		}
+		reviewerCount = len(reviewerIDs)
+
+		var existing int64
+		tx.Model(&models.CollaborativeTaskReview{}).
+			Where("collaborative_task_id = ? AND round = ? AND reviewer_id = ?", taskID, task.ReviewRound, reviewerID).
+			Count(&existing)
+		if existing > 0 {
+			return errors.New("you have already reviewed this round")
+		}
+
+		review := &models.CollaborativeTaskReview{
+			CollaborativeTaskID: taskID,
+			ReviewerID:          reviewerID,
+			Round:               task.ReviewRound,
+			Decision:            decision,
+			Comment:             comment,
+		}
+		if err := tx.Create(review).Error; err != nil {
+			return err
+		}
+
+		var round []models.CollaborativeTaskReview
+		if err := tx.Where("collaborative_task_id = ? AND round = ?", taskID, task.ReviewRound).
+			Find(&round).Error; err != nil {
+			return err
+		}
+		approvals, status = settleReviewRound(reviewerIDs, round)
+		if status == task.Status {
+			return nil
+		}
+		return tx.Model(&task).Update("status", status).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reviewerName := s.username(reviewerID)
+
+	switch {
+	case decision == models.ReviewDecisionChangesRequested:
+		s.notifyTeam(&task, reviewerID,
+			"Changes Requested",
+			fmt.Sprintf("%s requested changes on '%s': %s", reviewerName, task.Title, comment),
+			models.NotificationTypeTaskChangesRequested)
+	case status != models.TaskStatusCompleted:
+		s.notify(task.LeadUserID, &task,
+			"Review Approved",
+			fmt.Sprintf("%s approved '%s' (%d of %d reviewers)", reviewerName, task.Title, approvals, reviewerCount),
+			models.NotificationTypeTaskApproved, reviewerID)
+	default:
+		s.notifyTeam(&task, reviewerID,
+			"Task Approved",
+			fmt.Sprintf("'%s' was approved by all reviewers and is now completed", task.Title),
+			models.NotificationTypeTaskApproved)
+	}
+
+	return &task, nil
+}
+
+// settleReviewRound works out the task status after a decision in a review round: back in
+// progress once a current reviewer requests changes, completed once every current reviewer has
+// approved, and still in review otherwise. Decisions of reviewers who have since been removed do
+// not count. It also returns the number of counted approvals.
+func settleReviewRound(reviewerIDs []uint, round []models.CollaborativeTaskReview) (int, models.TaskStatus) {
+	approvals := 0
+	for _, review := range round {
+		if !slices.Contains(reviewerIDs, review.ReviewerID) {
+			continue
+		}
+		if review.Decision == models.ReviewDecisionChangesRequested {
+			return approvals, models.TaskStatusInProgress
+		}
+		if review.Decision == models.ReviewDecisionApproved {
+			approvals++
+		}
+	}
+
+	if len(reviewerIDs) > 0 && approvals >= len(reviewerIDs) {
+		return approvals, models.TaskStatusCompleted
+	}
+	return approvals, models.TaskStatusInReview
+}
+
+// GetTaskReviews returns every review decision on a task, newest round first
+func (s *CollaborativeTaskService) GetTaskReviews(taskID uint) ([]models.CollaborativeTaskReview, error) {
+	if err := s.DB.First(&models.CollaborativeTask{}, taskID).Error; err != nil {
+		return nil, errors.New("collaborative task not found")
+	}
+
+	var reviews []models.CollaborativeTaskReview
+	err := s.DB.Preload("Reviewer").
+		Where("collaborative_task_id = ?", taskID).
+		Order("round DESC, created_at ASC").
+		Find(&reviews).Error
+	return reviews, err
+}
+
+// isTaskContributor reports whether a user leads the task or is an active lead or contributor
+// participant of it
+func (s *CollaborativeTaskService) isTaskContributor(task *models.CollaborativeTask, userID uint) bool {
+	if task.LeadUserID == userID {
+		return true
+	}
+
+	var count int64
+	s.DB.Model(&models.CollaborativeTaskParticipant{}).
+		Where("collaborative_task_id = ? AND user_id = ? AND role IN ? AND status <> ?", task.ID, userID, []string{"lead", "contributor"}, "inactive").
+		Count(&count)
+	return count > 0
+}
+
+// taskReviewers returns the active reviewer participants of a task
+func (s *CollaborativeTaskService) taskReviewers(db *gorm.DB, taskID uint) ([]models.CollaborativeTaskParticipant, error) {
+	var reviewers []models.CollaborativeTaskParticipant
+	err := db.Where("collaborative_task_id = ? AND role = ? AND status <> ?", taskID, "reviewer", "inactive").
+		Find(&reviewers).Error
+	return reviewers, err
+}
+
+// notifyTeam notifies the lead and every contributing participant except the actor
+func (s *CollaborativeTaskService) notifyTeam(task *models.CollaborativeTask, actorID uint, title, message string, notificationType models.NotificationType) {
+	var participants []models.CollaborativeTaskParticipant
+	s.DB.Where("collaborative_task_id = ? AND role IN ? AND status <> ?", task.ID, []string{"lead", "contributor"}, "inactive").
+		Find(&participants)
+
+	notified := map[uint]bool{actorID: true}
+	recipients := append([]uint{task.LeadUserID}, participantUserIDs(participants)...)
+	for _, userID := range recipients {
+		if notified[userID] {
+			continue
+		}
+		notified[userID] = true
+		s.notify(userID, task, title, message, notificationType, actorID)
+	}
+}
+
+// notify sends a review notification when a notification service is configured
+func (s *CollaborativeTaskService) notify(userID uint, task *models.CollaborativeTask, title, message string, notificationType models.NotificationType, fromUserID uint) {
+	if s.notificationService == nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"collaborative_task_id": task.ID,
+		"task_title":            task.Title,
+		"from_user_id":          fromUserID,
+		"review_round":          task.ReviewRound,
+	}
+	s.notificationService.CreateNotification(userID, title, message, string(notificationType), data)
+}
+
+// username returns a user's name for notification messages
+func (s *CollaborativeTaskService) username(userID uint) string {
+	var user models.User
+	if err := s.DB.Select("username").First(&user, userID).Error; err != nil {
+		return "Someone"
+	}
+	return user.Username
+}
+
+// participantUserIDs returns the user IDs of the given participants
+func participantUserIDs(participants []models.CollaborativeTaskParticipant) []uint {
+	ids := make([]uint, 0, len(participants))
+	for _, participant := range participants {
+		ids = append(ids, participant.UserID)
+	}
+	return ids
+}
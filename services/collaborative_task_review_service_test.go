@@ -0,0 +1,85 @@
+package services
+
+import (
+	"project-x/models"
+	"testing"
+)
+
+func reviewRound(decisions map[uint]models.ReviewDecision, order ...uint) []models.CollaborativeTaskReview {
+	reviews := make([]models.CollaborativeTaskReview, len(order))
+	for i, reviewerID := range order {
+		reviews[i] = models.CollaborativeTaskReview{ReviewerID: reviewerID, Decision: decisions[reviewerID]}
+	}
+	return reviews
+}
+
+func TestSettleReviewRound(t *testing.T) {
+	approved, changes := models.ReviewDecisionApproved, models.ReviewDecisionChangesRequested
+
+	tests := []struct {
+		name          string
+		reviewerIDs   []uint
+		round         []models.CollaborativeTaskReview
+		wantApprovals int
+		wantStatus    models.TaskStatus
+	}{
+		{
+			name:          "stays in review until every reviewer approves",
+			reviewerIDs:   []uint{1, 2, 3},
+			round:         reviewRound(map[uint]models.ReviewDecision{1: approved, 2: approved}, 1, 2),
+			wantApprovals: 2,
+			wantStatus:    models.TaskStatusInReview,
+		},
+		{
+			name:          "completes when every reviewer approves",
+			reviewerIDs:   []uint{1, 2, 3},
+			round:         reviewRound(map[uint]models.ReviewDecision{1: approved, 2: approved, 3: approved}, 3, 1, 2),
+			wantApprovals: 3,
+			wantStatus:    models.TaskStatusCompleted,
+		},
+		{
+			name:          "a single reviewer's approval completes the task",
+			reviewerIDs:   []uint{4},
+			round:         reviewRound(map[uint]models.ReviewDecision{4: approved}, 4),
+			wantApprovals: 1,
+			wantStatus:    models.TaskStatusCompleted,
+		},
+		{
+			name:          "a change request sends the task back to the team",
+			reviewerIDs:   []uint{1, 2},
+			round:         reviewRound(map[uint]models.ReviewDecision{1: approved, 2: changes}, 1, 2),
+			wantApprovals: 1,
+			wantStatus:    models.TaskStatusInProgress,
+		},
+		{
+			name:          "approvals of removed reviewers do not count",
+			reviewerIDs:   []uint{1, 2},
+			round:         reviewRound(map[uint]models.ReviewDecision{1: approved, 9: approved}, 1, 9),
+			wantApprovals: 1,
+			wantStatus:    models.TaskStatusInReview,
+		},
+		{
+			name:          "change requests of removed reviewers do not count",
+			reviewerIDs:   []uint{1},
+			round:         reviewRound(map[uint]models.ReviewDecision{9: changes, 1: approved}, 9, 1),
+			wantApprovals: 1,
+			wantStatus:    models.TaskStatusCompleted,
+		},
+		{
+			name:          "a task without reviewers is never completed by the round",
+			reviewerIDs:   nil,
+			round:         reviewRound(map[uint]models.ReviewDecision{9: approved}, 9),
+			wantApprovals: 0,
+			wantStatus:    models.TaskStatusInReview,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approvals, status := settleReviewRound(tt.reviewerIDs, tt.round)
+			if approvals != tt.wantApprovals || status != tt.wantStatus {
+				t.Errorf("settleReviewRound() = (%d, %q), want (%d, %q)", approvals, status, tt.wantApprovals, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -9,13 +9,19 @@ import (
 )
 
 type CollaborativeTaskService struct {
-	DB *gorm.DB
+	DB                  *gorm.DB
+	notificationService *NotificationService
 }
 
 func NewCollaborativeTaskService(db *gorm.DB) *CollaborativeTaskService {
 	return &CollaborativeTaskService{DB: db}
 }
 
+// SetNotificationService sets the notification service used for review notifications
+func (s *CollaborativeTaskService) SetNotificationService(notificationService *NotificationService) {
+	s.notificationService = notificationService
+}
+
 // CreateCollaborativeTask creates a new collaborative task with a lead user
 func (s *CollaborativeTaskService) CreateCollaborativeTask(title, description string, leadUserID uint, projectID *uint, dueDate *time.Time, priority, complexity string) (*models.CollaborativeTask, error) {
 	// Verify lead user exists
@@ -157,24 +163,49 @@ func (s *CollaborativeTaskService) RemoveParticipant(taskID, userID uint) error
 		return errors.New("user is not a participant in this task")
 	}
 
+	// Their checklist items go back to the lead to reassign
+	if err := s.DB.Model(&models.CollaborativeTaskItem{}).
+		Where("collaborative_task_id = ? AND assignee_id = ?", taskID, userID).
+		Update("assignee_id", nil).Error; err != nil {
+		return err
+	}
+
 	return nil
 }
 
-// UpdateTaskProgress updates the progress of a collaborative task
-func (s *CollaborativeTaskService) UpdateTaskProgress(taskID uint, progress int) error {
+// UpdateTaskProgress updates the progress of a collaborative task. Only the lead and contributors
+// can update it. Tasks with checklist items have their progress computed from the items instead.
+// Reaching 100% submits the task for review.
+func (s *CollaborativeTaskService) UpdateTaskProgress(taskID uint, progress int, updatedBy uint) error {
 	if progress < 0 || progress > 100 {
 		return errors.New("progress must be between 0 and 100")
 	}
 
+	var task models.CollaborativeTask
+	if err := s.DB.First(&task, taskID).Error; err != nil {
+		return errors.New("collaborative task not found")
+	}
+	if !s.isTaskContributor(&task, updatedBy) {
+		return ErrNotTaskContributor
+	}
+
+	var itemCount int64
+	s.DB.Model(&models.CollaborativeTaskItem{}).Where("collaborative_task_id = ?", taskID).Count(&itemCount)
+	if itemCount > 0 {
+		return errors.New("progress is computed from checklist items for this task")
+	}
+
 	// Update progress
 	if err := s.DB.Model(&models.CollaborativeTask{}).Where("id = ?", taskID).Update("progress", progress).Error; err != nil {
 		return err
 	}
 
-	// If progress is 100%, mark task as completed
+	// If progress is 100%, the task is done and goes to its reviewers
 	if progress == 100 {
-		if err := s.DB.Model(&models.CollaborativeTask{}).Where("id = ?", taskID).Update("status", models.TaskStatusCompleted).Error; err != nil {
-			return err
+		if task.Status == models.TaskStatusPending || task.Status == models.TaskStatusInProgress {
+			if _, err := s.submitTaskForReview(&task, updatedBy); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -187,6 +218,10 @@ func (s *CollaborativeTaskService) GetCollaborativeTaskWithDetails(taskID uint)
 	err := s.DB.Preload("LeadUser").
 		Preload("Project").
 		Preload("Participants.User").
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("sort_order ASC, id ASC")
+		}).
+		Preload("Items.Assignee").
 		First(&task, taskID).Error
 	return &task, err
 }
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"errors"
+	"project-x/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// VaultGrantRequest describes who a vault is shared with
+type VaultGrantRequest struct {
+	GranteeType string      `json:"grantee_type" binding:"required"` // "user", "department", "role"
+	UserID      *uint       `json:"user_id"`
+	Department  string      `json:"department"`
+	Role        models.Role `json:"role"`
+	CanView     bool        `json:"can_view"`
+	CanCopy     bool        `json:"can_copy"`
+}
+
+// resolvePermissions returns the effective view/copy permissions of a user on a credential.
+// Permissions from the creator rule, an unexpired direct share and every matching vault grant
+// are combined; vault grants are matched against the user's current department and role.
+func (s *PasswordManagerService) resolvePermissions(credential *models.Credential, userID uint) (canView, canCopy bool) {
+	if credential.CreatedByID == userID {
+		return true, true // Creator always has access
+	}
+
+	if share, err := s.findActiveShare(credential.ID, userID); err == nil {
+		canView = canView || share.CanView
+		canCopy = canCopy || share.CanCopy
+	}
+
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		return canView, canCopy
+	}
+
+	var grants []models.CredentialVaultGrant
+	s.DB.
+		Joins("JOIN credential_vault_items ON credential_vault_items.vault_id = credential_vault_grants.vault_id AND credential_vault_items.deleted_at IS NULL").
+		Joins("JOIN credential_vaults ON credential_vaults.id = credential_vault_grants.vault_id AND credential_vaults.deleted_at IS NULL").
+		Where("credential_vault_items.credential_id = ?", credential.ID).
+		Where(grantMatchesUser(s.DB, &user)).
+		Find(&grants)
+
+	for _, grant := range grants {
+		canView = canView || grant.CanView
+		canCopy = canCopy || grant.CanCopy
+	}
+
+	return canView, canCopy
+}
+
+// vaultCredentialIDsFor returns a subquery of credential IDs the user reaches through vault
+// grants that have the given permission column ("can_view" or "can_copy") set
+func (s *PasswordManagerService) vaultCredentialIDsFor(user *models.User, permission string) *gorm.DB {
+	return s.DB.Model(&models.CredentialVaultItem{}).
+		Select("credential_vault_items.credential_id").
+		Joins("JOIN credential_vault_grants ON credential_vault_grants.vault_id = credential_vault_items.vault_id AND credential_vault_grants.deleted_at IS NULL").
+		Joins("JOIN credential_vaults ON credential_vaults.id = credential_vault_items.vault_id AND credential_vaults.deleted_at IS NULL").
+		Where("credential_vault_grants."+permission+" = ?", true).
+		Where(grantMatchesUser(s.DB, user))
+}
+
+// grantMatchesUser builds the condition selecting vault grants that apply to a user
+func grantMatchesUser(db *gorm.DB, user *models.User) *gorm.DB {
+	return db.
+		Where("credential_vault_grants.grantee_type = ? AND credential_vault_grants.user_id = ?", models.VaultGranteeUser, user.ID).
+		Or("credential_vault_grants.grantee_type = ? AND credential_vault_grants.department = ?", models.VaultGranteeDepartment, user.Department).
+		Or("credential_vault_grants.grantee_type = ? AND credential_vault_grants.role = ?", models.VaultGranteeRole, user.Role)
+}
+
+// CreateVault creates a new credential vault (Admin only)
+func (s *PasswordManagerService) CreateVault(adminID uint, name, description string) (*models.CredentialVault, error) {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return nil, errors.New("only admin can create vaults")
+	}
+
+	if name == "" {
+		return nil, errors.New("vault name is required")
+	}
+
+	var count int64
+	s.DB.Model(&models.CredentialVault{}).Where("name = ?", name).Count(&count)
+	if count > 0 {
+		return nil, errors.New("a vault with this name already exists")
+	}
+
+	vault := &models.CredentialVault{
+		Name:        name,
+		Description: description,
+		CreatedByID: adminID,
+	}
+
+	if err := s.DB.Create(vault).Error; err != nil {
+		return nil, err
+	}
+
+	return vault, nil
+}
+
+// UpdateVault renames or re-describes a vault (Admin only)
+func (s *PasswordManagerService) UpdateVault(vaultID, adminID uint, updates map[string]interface{}) error {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return errors.New("only admin can update vaults")
+	}
+
+	var vault models.CredentialVault
+	if err := s.DB.First(&vault, vaultID).Error; err != nil {
+		return errors.New("vault not found")
+	}
+
+	if name, ok := updates["name"].(string); ok && name != vault.Name {
+		var count int64
+		s.DB.Model(&models.CredentialVault{}).Where("name = ? AND id <> ?", name, vaultID).Count(&count)
+		if count > 0 {
+			return errors.New("a vault with this name already exists")
+		}
+	}
+
+	return s.DB.Model(&vault).Updates(updates).Error
+}
+
+// DeleteVault deletes a vault together with its items and grants (Admin only).
+// Credentials themselves are not deleted.
+func (s *PasswordManagerService) DeleteVault(vaultID, adminID uint) error {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return errors.New("only admin can delete vaults")
+	}
+
+	var vault models.CredentialVault
+	if err := s.DB.First(&vault, vaultID).Error; err != nil {
+		return errors.New("vault not found")
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("vault_id = ?", vaultID).Delete(&models.CredentialVaultItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("vault_id = ?", vaultID).Delete(&models.CredentialVaultGrant{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&vault).Error
+	})
+}
+
+// GetVaults returns all vaults with their items and grants (Admin only)
+func (s *PasswordManagerService) GetVaults(adminID uint) ([]models.CredentialVault, error) {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return nil, errors.New("only admin can view vaults")
+	}
+
+	var vaults []models.CredentialVault
+	err := s.DB.
+		Preload("CreatedBy").
+		Preload("Items.Credential").
+		Preload("Grants.User").
+		Order("name ASC").
+		Find(&vaults).Error
+
+	return vaults, err
+}
+
+// GetVault returns a single vault with its items and grants (Admin only)
+func (s *PasswordManagerService) GetVault(vaultID, adminID uint) (*models.CredentialVault, error) {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return nil, errors.New("only admin can view vaults")
+	}
+
+	var vault models.CredentialVault
+	err := s.DB.
+		Preload("CreatedBy").
+		Preload("Items.Credential").
+		Preload("Grants.User").
+		Preload("Grants.GrantedBy").
+		First(&vault, vaultID).Error
+	if err != nil {
+		return nil, errors.New("vault not found")
+	}
+
+	return &vault, nil
+}
+
+// AddCredentialsToVault adds credentials to a vault, ignoring ones already in it (Admin only)
+func (s *PasswordManagerService) AddCredentialsToVault(vaultID, adminID uint, credentialIDs []uint) error {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return errors.New("only admin can manage vaults")
+	}
+
+	var vault models.CredentialVault
+	if err := s.DB.First(&vault, vaultID).Error; err != nil {
+		return errors.New("vault not found")
+	}
+
+	for _, credentialID := range credentialIDs {
+		// Check if credential exists
+		var credential models.Credential
+		if err := s.DB.First(&credential, credentialID).Error; err != nil {
+			continue // Skip non-existent credentials
+		}
+
+		var existing models.CredentialVaultItem
+		err := s.DB.Unscoped().Where("vault_id = ? AND credential_id = ?", vaultID, credentialID).First(&existing).Error
+		if err == nil {
+			// Restore a previously removed item (unique index spans soft-deleted rows)
+			if existing.DeletedAt.Valid {
+				s.DB.Unscoped().Model(&existing).Updates(map[string]interface{}{"deleted_at": nil, "added_by_id": adminID})
+			}
+			continue
+		}
+
+		s.DB.Create(&models.CredentialVaultItem{
+			VaultID:      vaultID,
+			CredentialID: credentialID,
+			AddedByID:    adminID,
+		})
+	}
+
+	return nil
+}
+
+// RemoveCredentialFromVault removes a credential from a vault (Admin only)
+func (s *PasswordManagerService) RemoveCredentialFromVault(vaultID, credentialID, adminID uint) error {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return errors.New("only admin can manage vaults")
+	}
+
+	return s.DB.Where("vault_id = ? AND credential_id = ?", vaultID, credentialID).Delete(&models.CredentialVaultItem{}).Error
+}
+
+// GrantVaultAccess shares a vault with a user, department or role (Admin only).
+// An existing grant for the same grantee is updated instead of duplicated.
+func (s *PasswordManagerService) GrantVaultAccess(vaultID, adminID uint, req VaultGrantRequest) (*models.CredentialVaultGrant, error) {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return nil, errors.New("only admin can share vaults")
+	}
+
+	var vault models.CredentialVault
+	if err := s.DB.First(&vault, vaultID).Error; err != nil {
+		return nil, errors.New("vault not found")
+	}
+
+	grant := models.CredentialVaultGrant{
+		VaultID:     vaultID,
+		GranteeType: req.GranteeType,
+		CanView:     req.CanView,
+		CanCopy:     req.CanCopy,
+		GrantedByID: adminID,
+		GrantedAt:   time.Now(),
+	}
+
+	query := s.DB.Where("vault_id = ? AND grantee_type = ?", vaultID, req.GranteeType)
+	switch req.GranteeType {
+	case models.VaultGranteeUser:
+		if req.UserID == nil {
+			return nil, errors.New("user_id is required for user grants")
+		}
+		var user models.User
+		if err := s.DB.First(&user, *req.UserID).Error; err != nil {
+			return nil, errors.New("user not found")
+		}
+		grant.UserID = req.UserID
+		query = query.Where("user_id = ?", *req.UserID)
+	case models.VaultGranteeDepartment:
+		if req.Department == "" {
+			return nil, errors.New("department is required for department grants")
+		}
+		grant.Department = req.Department
+		query = query.Where("department = ?", req.Department)
+	case models.VaultGranteeRole:
+		if !isKnownRole(req.Role) {
+			return nil, errors.New("invalid role")
+		}
+		grant.Role = req.Role
+		query = query.Where("role = ?", req.Role)
+	default:
+		return nil, errors.New("grantee_type must be 'user', 'department' or 'role'")
+	}
+
+	var existing models.CredentialVaultGrant
+	if err := query.First(&existing).Error; err == nil {
+		existing.CanView = req.CanView
+		existing.CanCopy = req.CanCopy
+		existing.GrantedByID = adminID
+		existing.GrantedAt = grant.GrantedAt
+		if err := s.DB.Save(&existing).Error; err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	}
+
+	if err := s.DB.Create(&grant).Error; err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// RevokeVaultGrant removes a vault grant (Admin only)
+func (s *PasswordManagerService) RevokeVaultGrant(vaultID, grantID, adminID uint) error {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return errors.New("only admin can share vaults")
+	}
+
+	result := s.DB.Where("id = ? AND vault_id = ?", grantID, vaultID).Delete(&models.CredentialVaultGrant{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("grant not found")
+	}
+	return nil
+}
+
+// GetMyVaults returns the vaults the user can currently reach through any grant
+func (s *PasswordManagerService) GetMyVaults(userID uint) ([]models.CredentialVault, error) {
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	grantedVaults := s.DB.Model(&models.CredentialVaultGrant{}).
+		Select("credential_vault_grants.vault_id").
+		Where("credential_vault_grants.can_view = ?", true).
+		Where(grantMatchesUser(s.DB, &user))
+
+	var vaults []models.CredentialVault
+	err := s.DB.
+		Where("id IN (?)", grantedVaults).
+		Preload("Items.Credential", "is_active = ?", true).
+		Order("name ASC").
+		Find(&vaults).Error
+
+	return vaults, err
+}
+
+func isKnownRole(role models.Role) bool {
+	switch role {
+	case models.RoleAdmin, models.RoleManager, models.RoleHead, models.RoleEmployee, models.RoleHR:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,448 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"project-x/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Duration estimator modes, set with DURATION_ESTIMATOR_MODE
+const (
+	DurationEstimatorCalibrate = "calibrate" // Correct the AI's estimate with a regression on past accuracy (default)
+	DurationEstimatorReplace   = "replace"   // Use the historical estimate instead of the AI's
+	DurationEstimatorOff       = "off"       // Use the AI's estimate unchanged
+)
+
+const (
+	// durationEstimatorMinSamples is how many completed predictions a segment needs before it
+	// is trusted; smaller segments fall back to broader ones
+	durationEstimatorMinSamples = 5
+	// durationModelTTL is how long fitted models are reused before the history is re-read
+	durationModelTTL = 30 * time.Minute
+	// durationIntervalLevel is the confidence level of the reported intervals
+	durationIntervalLevel = 90
+)
+
+// DurationEstimate is a duration in hours with a 90% prediction interval
+type DurationEstimate struct {
+	Hours      int    `json:"hours"`
+	Low        int    `json:"low_hours"`
+	High       int    `json:"high_hours"`
+	Confidence int    `json:"confidence_level"`
+	Method     string `json:"method"`  // calibrated: from the AI's estimate, historical: from past durations alone
+	Source     string `json:"source"`  // history the estimate came from, e.g. "user:12", "department:Sales", "global"
+	Samples    int    `json:"samples"` // completed predictions in that history
+}
+
+// DurationSegmentFit summarises the regression fitted for one segment of the history
+type DurationSegmentFit struct {
+	Segment     string  `json:"segment"`
+	Samples     int     `json:"samples"`
+	Intercept   float64 `json:"intercept"` // log(actual) = intercept + slope * log(predicted)
+	Slope       float64 `json:"slope"`
+	ResidualSD  float64 `json:"residual_sd"` // in log-hours
+	MedianHours float64 `json:"median_actual_hours"`
+}
+
+// DurationEstimatorSummary reports the fitted models and how calibration compares with the raw
+// AI estimates on completed tasks
+type DurationEstimatorSummary struct {
+	Mode              string               `json:"mode"`
+	CompletedSamples  int                  `json:"completed_samples"`
+	MinSamples        int                  `json:"min_samples_per_segment"`
+	RawMAE            float64              `json:"raw_mae_hours"` // AI estimate vs actual, all samples
+	CalibratedSamples int                  `json:"calibrated_samples"`
+	CalibratedMAE     float64              `json:"calibrated_mae_hours"`      // local estimate vs actual
+	CalibratedRawMAE  float64              `json:"calibrated_raw_mae_hours"`  // AI estimate vs actual on the same tasks
+	IntervalCoverage  float64              `json:"interval_coverage_percent"` // actuals inside the stored interval
+	Segments          []DurationSegmentFit `json:"segments"`
+	FittedAt          time.Time            `json:"fitted_at"`
+}
+
+// DurationEstimator learns from the recorded accuracy of past AI predictions. For each segment
+// of the history (the assignee, their department, the task's complexity, and everything) it
+// fits a log-linear regression of actual on predicted hours, which calibrates new AI estimates,
+// and keeps the distribution of actual hours, which estimates durations without an LLM call.
+// The most specific segment with enough samples is used.
+type DurationEstimator struct {
+	DB *gorm.DB
+}
+
+func NewDurationEstimator(db *gorm.DB) *DurationEstimator {
+	return &DurationEstimator{DB: db}
+}
+
+// durationSample is one completed prediction
+type durationSample struct {
+	PredictedDuration int
+	ActualDuration    int
+	EstimatedDuration *int
+	EstimateLow       *int
+	EstimateHigh      *int
+	UserID            uint
+	Department        string
+}
+
+// durationFit is the regression and baseline for one segment, in log-hours
+type durationFit struct {
+	samples    int
+	intercept  float64
+	slope      float64
+	meanX      float64
+	sxx        float64
+	residualSD float64
+	meanY      float64
+	sdY        float64
+}
+
+// durationModels caches the fitted segments across requests
+var durationModels struct {
+	sync.Mutex
+	fits     map[string]*durationFit
+	samples  []durationSample
+	fittedAt time.Time
+}
+
+// InvalidateDurationModels makes the next estimate refit from the latest history
+func InvalidateDurationModels() {
+	durationModels.Lock()
+	durationModels.fits = nil
+	durationModels.Unlock()
+}
+
+// DurationEstimatorMode returns the configured mode
+func DurationEstimatorMode() string {
+	switch mode := strings.ToLower(strings.TrimSpace(os.Getenv("DURATION_ESTIMATOR_MODE"))); mode {
+	case DurationEstimatorReplace, DurationEstimatorOff:
+		return mode
+	default:
+		return DurationEstimatorCalibrate
+	}
+}
+
+// Calibrate corrects an AI duration estimate for a task using how the AI's past estimates for
+// similar work turned out. It returns nil when there is not enough history.
+func (e *DurationEstimator) Calibrate(task *models.Task, department string, predictedHours int) (*DurationEstimate, error) {
+	if predictedHours <= 0 {
+		return nil, nil
+	}
+
+	fits, err := e.models()
+	if err != nil {
+		return nil, err
+	}
+
+	segments := taskSegments(task.UserID, department, durationComplexity(predictedHours))
+	segment, fit := pickSegment(fits, segments)
+	if fit == nil {
+		return nil, nil
+	}
+
+	return fit.calibrate(predictedHours, segment), nil
+}
+
+// Estimate predicts a task's duration from past actual durations alone, without an LLM call. It
+// returns nil when there is not enough history.
+func (e *DurationEstimator) Estimate(task *models.Task, department string) (*DurationEstimate, error) {
+	fits, err := e.models()
+	if err != nil {
+		return nil, err
+	}
+
+	// Complexity is derived from the AI's estimate, which is not available here
+	segment, fit := pickSegment(fits, taskSegments(task.UserID, department, ""))
+	if fit == nil {
+		return nil, nil
+	}
+
+	return fit.historical(segment), nil
+}
+
+// TaskDurationEstimates are the local estimates for one task
+type TaskDurationEstimates struct {
+	TaskID              uint              `json:"task_id"`
+	Historical          *DurationEstimate `json:"historical"`                            // From past durations alone
+	Calibrated          *DurationEstimate `json:"calibrated"`                            // From the latest saved AI estimate
+	AIEstimatedDuration int               `json:"ai_estimated_duration_hours,omitempty"` // That AI estimate
+}
+
+// EstimateTask returns a task's local estimates without calling the AI. The calibrated estimate
+// is only available once the task has a saved AI analysis.
+func (e *DurationEstimator) EstimateTask(task *models.Task) (*TaskDurationEstimates, error) {
+	var user models.User
+	if err := e.DB.Select("department").First(&user, task.UserID).Error; err != nil {
+		return nil, errors.New("task assignee not found")
+	}
+
+	estimates := &TaskDurationEstimates{TaskID: task.ID}
+
+	historical, err := e.Estimate(task, user.Department)
+	if err != nil {
+		return nil, err
+	}
+	estimates.Historical = historical
+
+	var analysis models.AIAnalysis
+	if err := e.DB.Where("task_id = ?", task.ID).Order("created_at DESC").First(&analysis).Error; err == nil {
+		estimates.AIEstimatedDuration = analysis.PredictedDuration
+		calibrated, err := e.Calibrate(task, user.Department, analysis.PredictedDuration)
+		if err != nil {
+			return nil, err
+		}
+		estimates.Calibrated = calibrated
+	}
+
+	return estimates, nil
+}
+
+// Summary returns the fitted segments and the accuracy of calibrated against raw estimates
+func (e *DurationEstimator) Summary() (*DurationEstimatorSummary, error) {
+	fits, err := e.models()
+	if err != nil {
+		return nil, err
+	}
+
+	durationModels.Lock()
+	samples := durationModels.samples
+	fittedAt := durationModels.fittedAt
+	durationModels.Unlock()
+
+	summary := &DurationEstimatorSummary{
+		Mode:             DurationEstimatorMode(),
+		CompletedSamples: len(samples),
+		MinSamples:       durationEstimatorMinSamples,
+		Segments:         []DurationSegmentFit{},
+		FittedAt:         fittedAt,
+	}
+
+	var rawError, calibratedError, calibratedRawError float64
+	covered := 0
+	withInterval := 0
+	for _, sample := range samples {
+		sampleRawError := math.Abs(float64(sample.ActualDuration - sample.PredictedDuration))
+		rawError += sampleRawError
+		if sample.EstimatedDuration == nil {
+			continue
+		}
+		summary.CalibratedSamples++
+		calibratedRawError += sampleRawError
+		calibratedError += math.Abs(float64(sample.ActualDuration - *sample.EstimatedDuration))
+		if sample.EstimateLow != nil && sample.EstimateHigh != nil {
+			withInterval++
+			if sample.ActualDuration >= *sample.EstimateLow && sample.ActualDuration <= *sample.EstimateHigh {
+				covered++
+			}
+		}
+	}
+	if len(samples) > 0 {
+		summary.RawMAE = rawError / float64(len(samples))
+	}
+	if summary.CalibratedSamples > 0 {
+		summary.CalibratedMAE = calibratedError / float64(summary.CalibratedSamples)
+		summary.CalibratedRawMAE = calibratedRawError / float64(summary.CalibratedSamples)
+	}
+	if withInterval > 0 {
+		summary.IntervalCoverage = float64(covered) / float64(withInterval) * 100
+	}
+
+	for segment, fit := range fits {
+		summary.Segments = append(summary.Segments, DurationSegmentFit{
+			Segment:     segment,
+			Samples:     fit.samples,
+			Intercept:   fit.intercept,
+			Slope:       fit.slope,
+			ResidualSD:  fit.residualSD,
+			MedianHours: math.Exp(fit.meanY),
+		})
+	}
+	sort.Slice(summary.Segments, func(i, j int) bool {
+		return summary.Segments[i].Segment < summary.Segments[j].Segment
+	})
+
+	return summary, nil
+}
+
+// models returns the fitted segments, refitting when the cache is empty or stale
+func (e *DurationEstimator) models() (map[string]*durationFit, error) {
+	durationModels.Lock()
+	defer durationModels.Unlock()
+
+	if durationModels.fits != nil && time.Since(durationModels.fittedAt) < durationModelTTL {
+		return durationModels.fits, nil
+	}
+
+	var samples []durationSample
+	err := e.DB.Table("ai_analyses").
+		Select("ai_analyses.predicted_duration, ai_analyses.actual_duration, ai_analyses.estimated_duration, ai_analyses.estimate_low, ai_analyses.estimate_high, tasks.user_id, users.department").
+		Joins("JOIN tasks ON tasks.id = ai_analyses.task_id").
+		Joins("JOIN users ON users.id = tasks.user_id").
+		Where("ai_analyses.deleted_at IS NULL AND ai_analyses.actual_duration IS NOT NULL AND ai_analyses.predicted_duration > 0").
+		Scan(&samples).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prediction history: %v", err)
+	}
+
+	grouped := make(map[string][]durationSample)
+	for _, sample := range samples {
+		if sample.ActualDuration <= 0 {
+			continue
+		}
+		for _, segment := range taskSegments(sample.UserID, sample.Department, durationComplexity(sample.PredictedDuration)) {
+			grouped[segment] = append(grouped[segment], sample)
+		}
+	}
+
+	fits := make(map[string]*durationFit)
+	for segment, segmentSamples := range grouped {
+		if len(segmentSamples) >= durationEstimatorMinSamples {
+			fits[segment] = fitDurationSegment(segmentSamples)
+		}
+	}
+
+	durationModels.fits = fits
+	durationModels.samples = samples
+	durationModels.fittedAt = time.Now()
+	return fits, nil
+}
+
+// fitDurationSegment fits log(actual) = a + b*log(predicted) by least squares. The slope is
+// limited to [0, 2] so a handful of outliers cannot invert or explode the calibration.
+func fitDurationSegment(samples []durationSample) *durationFit {
+	n := float64(len(samples))
+	fit := &durationFit{samples: len(samples)}
+
+	var sumX, sumY float64
+	for _, sample := range samples {
+		sumX += math.Log(float64(sample.PredictedDuration))
+		sumY += math.Log(float64(sample.ActualDuration))
+	}
+	fit.meanX, fit.meanY = sumX/n, sumY/n
+
+	var sxy, syy float64
+	for _, sample := range samples {
+		dx := math.Log(float64(sample.PredictedDuration)) - fit.meanX
+		dy := math.Log(float64(sample.ActualDuration)) - fit.meanY
+		fit.sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	fit.sdY = math.Sqrt(syy / (n - 1))
+
+	if fit.sxx > 1e-9 {
+		fit.slope = math.Max(0, math.Min(2, sxy/fit.sxx))
+	}
+	fit.intercept = fit.meanY - fit.slope*fit.meanX
+
+	var sse float64
+	for _, sample := range samples {
+		residual := math.Log(float64(sample.ActualDuration)) - (fit.intercept + fit.slope*math.Log(float64(sample.PredictedDuration)))
+		sse += residual * residual
+	}
+	fit.residualSD = math.Sqrt(sse / (n - 2))
+
+	return fit
+}
+
+// calibrate predicts the actual duration for an AI estimate from the segment's regression, with
+// a prediction interval that widens away from the estimates the segment was fitted on
+func (f *durationFit) calibrate(predictedHours int, segment string) *DurationEstimate {
+	x := math.Log(float64(predictedHours))
+	y := f.intercept + f.slope*x
+	spread := 1 + 1/float64(f.samples)
+	if f.sxx > 0 {
+		spread += (x - f.meanX) * (x - f.meanX) / f.sxx
+	}
+	margin := tQuantile90(f.samples-2) * f.residualSD * math.Sqrt(spread)
+
+	return newDurationEstimate(y, margin, "calibrated", segment, f.samples)
+}
+
+// historical predicts a duration from the segment's past actual durations alone
+func (f *durationFit) historical(segment string) *DurationEstimate {
+	margin := tQuantile90(f.samples-1) * f.sdY * math.Sqrt(1+1/float64(f.samples))
+	return newDurationEstimate(f.meanY, margin, "historical", segment, f.samples)
+}
+
+// taskSegments lists the history segments for a task, most specific first
+func taskSegments(userID uint, department, complexity string) []string {
+	segments := []string{fmt.Sprintf("user:%d", userID)}
+	if department != "" {
+		segments = append(segments, "department:"+department)
+	}
+	if complexity != "" {
+		segments = append(segments, "complexity:"+complexity)
+	}
+	return append(segments, "global")
+}
+
+// pickSegment returns the first segment with a fitted model
+func pickSegment(fits map[string]*durationFit, segments []string) (string, *durationFit) {
+	for _, segment := range segments {
+		if fit := fits[segment]; fit != nil {
+			return segment, fit
+		}
+	}
+	return "", nil
+}
+
+// durationComplexity buckets regular tasks, which have no complexity field, by the AI's estimate
+func durationComplexity(predictedHours int) string {
+	switch {
+	case predictedHours <= 4:
+		return "simple"
+	case predictedHours <= 16:
+		return "medium"
+	default:
+		return "complex"
+	}
+}
+
+// newDurationEstimate converts a log-hours prediction and margin to whole hours
+func newDurationEstimate(logHours, margin float64, method, segment string, samples int) *DurationEstimate {
+	return &DurationEstimate{
+		Hours:      roundHours(math.Exp(logHours)),
+		Low:        roundHours(math.Exp(logHours - margin)),
+		High:       roundHours(math.Exp(logHours + margin)),
+		Confidence: durationIntervalLevel,
+		Method:     method,
+		Source:     segment,
+		Samples:    samples,
+	}
+}
+
+// roundHours rounds to whole hours, at least one
+func roundHours(hours float64) int {
+	if hours < 1 {
+		return 1
+	}
+	return int(math.Round(hours))
+}
+
+// tQuantile90 returns the two-sided 90% Student's t critical value for the degrees of freedom
+func tQuantile90(df int) float64 {
+	table := []struct {
+		df    int
+		value float64
+	}{
+		{30, 1.697}, {25, 1.708}, {20, 1.725}, {15, 1.753}, {12, 1.782}, {10, 1.812},
+		{9, 1.833}, {8, 1.860}, {7, 1.895}, {6, 1.943}, {5, 2.015}, {4, 2.132},
+		{3, 2.353}, {2, 2.920}, {1, 6.314},
+	}
+	if df > 30 {
+		return 1.645
+	}
+	for _, row := range table {
+		if df >= row.df {
+			return row.value
+		}
+	}
+	return 6.314
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+// durationSamples pairs AI estimates with actual durations, both in hours
+func durationSamples(predicted, actual []int) []durationSample {
+	samples := make([]durationSample, len(predicted))
+	for i := range predicted {
+		samples[i] = durationSample{PredictedDuration: predicted[i], ActualDuration: actual[i]}
+	}
+	return samples
+}
+
+func meanLog(values []int) float64 {
+	sum := 0.0
+	for _, value := range values {
+		sum += math.Log(float64(value))
+	}
+	return sum / float64(len(values))
+}
+
+func TestFitDurationSegment(t *testing.T) {
+	tests := []struct {
+		name          string
+		predicted     []int
+		actual        []int
+		wantSlope     float64
+		wantIntercept float64
+	}{
+		{
+			name:          "actuals twice the estimate",
+			predicted:     []int{2, 4, 8, 16, 32},
+			actual:        []int{4, 8, 16, 32, 64},
+			wantSlope:     1,
+			wantIntercept: math.Log(2),
+		},
+		{
+			name:          "actuals unrelated to the estimate",
+			predicted:     []int{2, 4, 8, 16, 32},
+			actual:        []int{10, 10, 10, 10, 10},
+			wantSlope:     0,
+			wantIntercept: math.Log(10),
+		},
+		{
+			name:          "an inverse relation is limited to a flat slope",
+			predicted:     []int{2, 4, 8, 16},
+			actual:        []int{16, 8, 4, 2},
+			wantSlope:     0,
+			wantIntercept: meanLog([]int{16, 8, 4, 2}),
+		},
+		{
+			name:          "a steep relation is limited to a slope of 2",
+			predicted:     []int{1, 2, 4, 8},
+			actual:        []int{1, 8, 64, 512},
+			wantSlope:     2,
+			wantIntercept: meanLog([]int{1, 8, 64, 512}) - 2*meanLog([]int{1, 2, 4, 8}),
+		},
+		{
+			name:          "a single estimate value falls back to the mean actual",
+			predicted:     []int{5, 5, 5, 5, 5},
+			actual:        []int{3, 5, 7, 9, 11},
+			wantSlope:     0,
+			wantIntercept: meanLog([]int{3, 5, 7, 9, 11}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fit := fitDurationSegment(durationSamples(tt.predicted, tt.actual))
+
+			if fit.samples != len(tt.predicted) {
+				t.Errorf("samples = %d, want %d", fit.samples, len(tt.predicted))
+			}
+			if math.Abs(fit.slope-tt.wantSlope) > 1e-9 {
+				t.Errorf("slope = %f, want %f", fit.slope, tt.wantSlope)
+			}
+			if math.Abs(fit.intercept-tt.wantIntercept) > 1e-9 {
+				t.Errorf("intercept = %f, want %f", fit.intercept, tt.wantIntercept)
+			}
+		})
+	}
+}
+
+func TestDurationFitEstimates(t *testing.T) {
+	exact := fitDurationSegment(durationSamples([]int{2, 4, 8, 16, 32}, []int{4, 8, 16, 32, 64}))
+	flat := fitDurationSegment(durationSamples([]int{2, 4, 8, 16, 32}, []int{10, 10, 10, 10, 10}))
+
+	tests := []struct {
+		name     string
+		estimate *DurationEstimate
+		want     DurationEstimate
+	}{
+		{
+			name:     "calibration doubles the estimate with no spread",
+			estimate: exact.calibrate(10, "user:1"),
+			want:     DurationEstimate{Hours: 20, Low: 20, High: 20, Confidence: 90, Method: "calibrated", Source: "user:1", Samples: 5},
+		},
+		{
+			name:     "calibration ignores an estimate that never mattered",
+			estimate: flat.calibrate(40, "global"),
+			want:     DurationEstimate{Hours: 10, Low: 10, High: 10, Confidence: 90, Method: "calibrated", Source: "global", Samples: 5},
+		},
+		{
+			name:     "the historical estimate is the typical actual duration",
+			estimate: flat.historical("department:Sales"),
+			want:     DurationEstimate{Hours: 10, Low: 10, High: 10, Confidence: 90, Method: "historical", Source: "department:Sales", Samples: 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if *tt.estimate != tt.want {
+				t.Errorf("estimate = %+v, want %+v", *tt.estimate, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationFitIntervalWidensAwayFromHistory(t *testing.T) {
+	fit := fitDurationSegment(durationSamples([]int{2, 4, 8, 16, 32, 8}, []int{3, 5, 11, 20, 30, 6}))
+
+	near := fit.calibrate(8, "global")
+	far := fit.calibrate(200, "global")
+
+	for _, estimate := range []*DurationEstimate{near, far} {
+		if estimate.Low > estimate.Hours || estimate.Hours > estimate.High {
+			t.Errorf("estimate %+v is outside its interval", *estimate)
+		}
+	}
+	nearWidth := math.Log(float64(near.High)) - math.Log(float64(near.Low))
+	farWidth := math.Log(float64(far.High)) - math.Log(float64(far.Low))
+	if farWidth <= nearWidth {
+		t.Errorf("interval far from history (%d-%d) is not wider than near it (%d-%d)", far.Low, far.High, near.Low, near.High)
+	}
+}
+
+func TestPickSegment(t *testing.T) {
+	fits := map[string]*durationFit{
+		"department:Sales": {samples: 6},
+		"global":           {samples: 40},
+	}
+
+	tests := []struct {
+		name     string
+		segments []string
+		want     string
+	}{
+		{"falls back past a user without history", taskSegments(7, "Sales", "simple"), "department:Sales"},
+		{"falls back to global without a department", taskSegments(7, "", "simple"), "global"},
+		{"finds nothing when no segment is fitted", []string{"user:7", "complexity:simple"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segment, fit := pickSegment(fits, tt.segments)
+			if segment != tt.want {
+				t.Errorf("segment = %q, want %q", segment, tt.want)
+			}
+			if (fit == nil) != (tt.want == "") {
+				t.Errorf("fit = %v for segment %q", fit, segment)
+			}
+		})
+	}
+}
+
+func TestDurationComplexity(t *testing.T) {
+	tests := []struct {
+		hours int
+		want  string
+	}{
+		{1, "simple"},
+		{4, "simple"},
+		{5, "medium"},
+		{16, "medium"},
+		{17, "complex"},
+	}
+
+	for _, tt := range tests {
+		if got := durationComplexity(tt.hours); got != tt.want {
+			t.Errorf("durationComplexity(%d) = %q, want %q", tt.hours, got, tt.want)
+		}
+	}
+}
+
+func TestTQuantile90(t *testing.T) {
+	tests := []struct {
+		df   int
+		want float64
+	}{
+		{0, 6.314},
+		{1, 6.314},
+		{3, 2.353},
+		{11, 1.812},
+		{30, 1.697},
+		{31, 1.645},
+	}
+
+	for _, tt := range tests {
+		if got := tQuantile90(tt.df); got != tt.want {
+			t.Errorf("tQuantile90(%d) = %v, want %v", tt.df, got, tt.want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 )
@@ -18,20 +19,26 @@ type EncryptionService struct {
 
 // NewEncryptionService creates a new encryption service instance
 func NewEncryptionService() (*EncryptionService, error) {
-	keyStr := os.Getenv("ENCRYPTION_KEY")
+	return NewEncryptionServiceFromEnv("ENCRYPTION_KEY")
+}
+
+// NewEncryptionServiceFromEnv creates an encryption service using the key stored in the
+// given environment variable, so separate data sets can be encrypted with separate keys
+func NewEncryptionServiceFromEnv(envVar string) (*EncryptionService, error) {
+	keyStr := os.Getenv(envVar)
 	if keyStr == "" {
-		return nil, errors.New("ENCRYPTION_KEY environment variable is not set")
+		return nil, fmt.Errorf("%s environment variable is not set", envVar)
 	}
 
 	// Key should be 64 hex characters (32 bytes)
 	if len(keyStr) != 64 {
-		return nil, errors.New("ENCRYPTION_KEY must be 64 hex characters (32 bytes)")
+		return nil, fmt.Errorf("%s must be 64 hex characters (32 bytes)", envVar)
 	}
 
 	keyBytes := make([]byte, 32)
 	_, err := hex.Decode(keyBytes, []byte(keyStr))
 	if err != nil {
-		return nil, errors.New("ENCRYPTION_KEY must be valid hex string")
+		return nil, fmt.Errorf("%s must be valid hex string", envVar)
 	}
 
 	return &EncryptionService{key: keyBytes}, nil
@@ -43,22 +50,11 @@ func (e *EncryptionService) Encrypt(plaintext string) (string, error) {
 		return "", nil
 	}
 
-	block, err := aes.NewCipher(e.key)
-	if err != nil {
-		return "", err
-	}
-
-	gcm, err := cipher.NewGCM(block)
+	ciphertext, err := e.EncryptBytes([]byte(plaintext))
 	if err != nil {
 		return "", err
 	}
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
-	}
-
-	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
 	return base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
@@ -73,26 +69,50 @@ func (e *EncryptionService) Decrypt(ciphertext string) (string, error) {
 		return "", err
 	}
 
-	block, err := aes.NewCipher(e.key)
+	plaintext, err := e.DecryptBytes(data)
 	if err != nil {
 		return "", err
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	return string(plaintext), nil
+}
+
+// EncryptBytes encrypts binary data using AES-256-GCM. The nonce is prepended to the result.
+func (e *EncryptionService) EncryptBytes(plaintext []byte) ([]byte, error) {
+	gcm, err := e.newGCM()
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// DecryptBytes decrypts data produced by EncryptBytes
+func (e *EncryptionService) DecryptBytes(data []byte) ([]byte, error) {
+	gcm, err := e.newGCM()
+	if err != nil {
+		return nil, err
 	}
 
 	nonceSize := gcm.NonceSize()
 	if len(data) < nonceSize {
-		return "", errors.New("ciphertext too short")
+		return nil, errors.New("ciphertext too short")
 	}
 
 	nonce, ciphertextBytes := data[:nonceSize], data[nonceSize:]
-	plaintext, err := gcm.Open(nil, nonce, ciphertextBytes, nil)
+	return gcm.Open(nil, nonce, ciphertextBytes, nil)
+}
+
+func (e *EncryptionService) newGCM() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(e.key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return string(plaintext), nil
+	return cipher.NewGCM(block)
 }
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"project-x/models"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// HRAssignmentRuleRequest describes an assignment rule to create or update
+type HRAssignmentRuleRequest struct {
+	Name               string                      `json:"name" binding:"required"`
+	SortOrder          int                         `json:"sort_order"`
+	IsActive           *bool                       `json:"is_active"` // Defaults to true
+	Category           models.ProblemCategory      `json:"category"`
+	ReporterDepartment string                      `json:"reporter_department"`
+	Strategy           models.HRAssignmentStrategy `json:"strategy"` // Defaults to least_loaded
+	MaxOpenCases       int                         `json:"max_open_cases"`
+	AssigneeIDs        []uint                      `json:"assignee_ids"` // Empty means every HR user
+}
+
+// GetAssignmentRules returns every assignment rule in evaluation order
+func (s *HRProblemService) GetAssignmentRules() ([]models.HRAssignmentRule, error) {
+	var rules []models.HRAssignmentRule
+	err := s.db.Preload("Assignees").Order("sort_order ASC, id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// CreateAssignmentRule adds an assignment rule (Admin only)
+func (s *HRProblemService) CreateAssignmentRule(request HRAssignmentRuleRequest, adminID uint) (*models.HRAssignmentRule, error) {
+	if err := s.requireAdmin(adminID, "only admins can manage assignment rules"); err != nil {
+		return nil, err
+	}
+
+	assignees, err := s.validateAssignmentRule(&request)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &models.HRAssignmentRule{
+		Name:               request.Name,
+		SortOrder:          request.SortOrder,
+		IsActive:           true,
+		Category:           request.Category,
+		ReporterDepartment: request.ReporterDepartment,
+		Strategy:           request.Strategy,
+		MaxOpenCases:       request.MaxOpenCases,
+		UpdatedByID:        &adminID,
+		Assignees:          assignees,
+	}
+	if err := s.db.Create(rule).Error; err != nil {
+		return nil, err
+	}
+
+	// IsActive defaults to true in the database, so a disabled rule has to be written explicitly
+	if request.IsActive != nil && !*request.IsActive {
+		if err := s.db.Model(rule).Update("is_active", false).Error; err != nil {
+			return nil, err
+		}
+		rule.IsActive = false
+	}
+
+	return rule, nil
+}
+
+// UpdateAssignmentRule replaces the settings and assignees of an assignment rule (Admin only)
+func (s *HRProblemService) UpdateAssignmentRule(ruleID uint, request HRAssignmentRuleRequest, adminID uint) (*models.HRAssignmentRule, error) {
+	if err := s.requireAdmin(adminID, "only admins can manage assignment rules"); err != nil {
+		return nil, err
+	}
+
+	var rule models.HRAssignmentRule
+	if err := s.db.First(&rule, ruleID).Error; err != nil {
+		return nil, errors.New("assignment rule not found")
+	}
+
+	assignees, err := s.validateAssignmentRule(&request)
+	if err != nil {
+		return nil, err
+	}
+
+	isActive := true
+	if request.IsActive != nil {
+		isActive = *request.IsActive
+	}
+
+	err = s.db.Model(&rule).Updates(map[string]interface{}{
+		"name":                request.Name,
+		"sort_order":          request.SortOrder,
+		"is_active":           isActive,
+		"category":            request.Category,
+		"reporter_department": request.ReporterDepartment,
+		"strategy":            request.Strategy,
+		"max_open_cases":      request.MaxOpenCases,
+		"updated_by_id":       adminID,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&rule).Association("Assignees").Replace(assignees); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Preload("Assignees").First(&rule, ruleID).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteAssignmentRule removes an assignment rule (Admin only)
+func (s *HRProblemService) DeleteAssignmentRule(ruleID uint, adminID uint) error {
+	if err := s.requireAdmin(adminID, "only admins can manage assignment rules"); err != nil {
+		return err
+	}
+
+	var rule models.HRAssignmentRule
+	if err := s.db.First(&rule, ruleID).Error; err != nil {
+		return errors.New("assignment rule not found")
+	}
+
+	if err := s.db.Model(&rule).Association("Assignees").Clear(); err != nil {
+		return err
+	}
+	return s.db.Delete(&rule).Error
+}
+
+// selectAssignee picks the HR user a new problem is auto-assigned to. It must be called with
+// the plaintext problem so HR users named in the report can be excluded. Active rules are tried
+// in order; when none yields an eligible HR user the least loaded HR user is chosen. A nil user
+// means nobody is eligible and the problem stays unassigned.
+func (s *HRProblemService) selectAssignee(problem *models.HRProblem, reporterDepartment string) (*models.User, *models.HRAssignmentRule) {
+	var hrUsers []models.User
+	if err := s.db.Where("role = ?", models.RoleHR).Order("id ASC").Find(&hrUsers).Error; err != nil {
+		return nil, nil
+	}
+
+	var rules []models.HRAssignmentRule
+	s.db.Preload("Assignees").Where("is_active = ?", true).Order("sort_order ASC, id ASC").Find(&rules)
+
+	reportText := strings.Join([]string{problem.Title, problem.Description, problem.WitnessInfo, problem.Location}, "\n")
+	eligible := func(user models.User) bool {
+		if problem.ReporterID != nil && *problem.ReporterID == user.ID {
+			return false
+		}
+		return !namedInReport(reportText, user.Username)
+	}
+
+	openCases := s.openCaseCounts()
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Category != "" && rule.Category != problem.Category {
+			continue
+		}
+		if rule.ReporterDepartment != "" && !strings.EqualFold(rule.ReporterDepartment, reporterDepartment) {
+			continue
+		}
+
+		pool := rule.Assignees
+		if len(pool) == 0 {
+			pool = hrUsers
+		}
+
+		var candidates []models.User
+		for _, user := range pool {
+			if user.Role != models.RoleHR && user.Role != models.RoleAdmin {
+				continue
+			}
+			if rule.MaxOpenCases > 0 && openCases[user.ID] >= rule.MaxOpenCases {
+				continue
+			}
+			if eligible(user) {
+				candidates = append(candidates, user)
+			}
+		}
+
+		if assignee := pickAssignee(candidates, rule.Strategy, rule.LastAssignedID, openCases); assignee != nil {
+			return assignee, rule
+		}
+	}
+
+	var candidates []models.User
+	for _, user := range hrUsers {
+		if eligible(user) {
+			candidates = append(candidates, user)
+		}
+	}
+	return pickAssignee(candidates, models.HRAssignmentLeastLoaded, nil, openCases), nil
+}
+
+// recordAutoAssignment advances the rule's round-robin cursor, logs the assignment and notifies
+// the assignee
+func (s *HRProblemService) recordAutoAssignment(problem *models.HRProblem, hrUser *models.User, rule *models.HRAssignmentRule) {
+	reason := "fewest open cases"
+	if rule != nil {
+		reason = fmt.Sprintf("rule \"%s\"", rule.Name)
+		s.db.Model(&models.HRAssignmentRule{}).Where("id = ?", rule.ID).Update("last_assigned_id", hrUser.ID)
+	}
+
+	s.createStatusUpdate(problem.ID, 0, "", "", fmt.Sprintf("Problem auto-assigned to %s (%s)", hrUser.Username, reason), true)
+
+	s.notifyAssignedHR(problem, hrUser)
+}
+
+// openCaseCounts returns the number of open problems assigned to each HR user
+func (s *HRProblemService) openCaseCounts() map[uint]int {
+	var rows []struct {
+		AssignedHRID uint
+		Count        int
+	}
+	s.db.Model(&models.HRProblem{}).
+		Select("assigned_hr_id, COUNT(*) AS count").
+		Where("assigned_hr_id IS NOT NULL AND status IN ?", openProblemStatuses).
+		Group("assigned_hr_id").
+		Scan(&rows)
+
+	counts := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		counts[row.AssignedHRID] = row.Count
+	}
+	return counts
+}
+
+// validateAssignmentRule normalizes a rule request and loads its assignees
+func (s *HRProblemService) validateAssignmentRule(request *HRAssignmentRuleRequest) ([]models.User, error) {
+	request.Name = strings.TrimSpace(request.Name)
+	request.ReporterDepartment = strings.TrimSpace(request.ReporterDepartment)
+	if request.Name == "" {
+		return nil, errors.New("rule name is required")
+	}
+
+	if request.Category != "" {
+		if _, exists := models.GetProblemCategories()[request.Category]; !exists {
+			return nil, errors.New("invalid problem category")
+		}
+	}
+
+	switch request.Strategy {
+	case "":
+		request.Strategy = models.HRAssignmentLeastLoaded
+	case models.HRAssignmentRoundRobin, models.HRAssignmentLeastLoaded:
+	default:
+		return nil, errors.New("strategy must be 'round_robin' or 'least_loaded'")
+	}
+
+	if request.MaxOpenCases < 0 {
+		return nil, errors.New("max open cases cannot be negative")
+	}
+
+	var assignees []models.User
+	if len(request.AssigneeIDs) > 0 {
+		if err := s.db.Where("id IN ?", request.AssigneeIDs).Find(&assignees).Error; err != nil {
+			return nil, err
+		}
+		if len(assignees) != len(request.AssigneeIDs) {
+			return nil, errors.New("one or more assignees were not found")
+		}
+		for _, user := range assignees {
+			if user.Role != models.RoleHR && user.Role != models.RoleAdmin {
+				return nil, fmt.Errorf("%s is not an HR or Admin user", user.Username)
+			}
+		}
+	}
+
+	return assignees, nil
+}
+
+// requireAdmin returns an error with the given message unless the user is an admin
+func (s *HRProblemService) requireAdmin(userID uint, message string) error {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return errors.New("admin not found")
+	}
+	if user.Role != models.RoleAdmin {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// pickAssignee chooses between eligible candidates. Round robin takes the first user after the
+// cursor in ID order; least loaded takes the user with the fewest open cases, lowest ID first.
+func pickAssignee(candidates []models.User, strategy models.HRAssignmentStrategy, cursor *uint, openCases map[uint]int) *models.User {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+
+	if strategy == models.HRAssignmentRoundRobin {
+		if cursor != nil {
+			for i := range candidates {
+				if candidates[i].ID > *cursor {
+					return &candidates[i]
+				}
+			}
+		}
+		return &candidates[0]
+	}
+
+	best := &candidates[0]
+	for i := range candidates[1:] {
+		if openCases[candidates[i+1].ID] < openCases[best.ID] {
+			best = &candidates[i+1]
+		}
+	}
+	return best
+}
+
+// namedInReport reports whether a username appears as a whole word in the report text
+func namedInReport(text, username string) bool {
+	if username == "" {
+		return false
+	}
+	pattern := `(?i)(^|[^\p{L}\p{N}_])` + regexp.QuoteMeta(username) + `($|[^\p{L}\p{N}_])`
+	matched, err := regexp.MatchString(pattern, text)
+	return err == nil && matched
+}
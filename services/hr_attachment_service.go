@@ -0,0 +1,436 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"project-x/models"
+	"strings"
+	"time"
+)
+
+const (
+	MaxHRAttachmentSize       = 10 << 20 // 10 MB per file
+	MaxHRAttachmentsPerUpload = 10
+	defaultHRAttachmentDir    = "data/hr-attachments"
+)
+
+var errAttachmentNotFound = errors.New("attachment not found")
+
+// HRAttachmentUpload is an evidence file received from a client, before scanning and encryption
+type HRAttachmentUpload struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+// VirusScanner is the hook evidence files pass through before they are stored. Scan returns
+// clean=false and a description of the finding when a file is infected, and an error only
+// when the scan itself could not be completed.
+type VirusScanner interface {
+	Scan(fileName string, content []byte) (clean bool, result string, err error)
+}
+
+// ClamdScanner scans files with a ClamAV daemon using the INSTREAM command
+type ClamdScanner struct {
+	Address string // host:port of clamd, e.g. localhost:3310
+	Timeout time.Duration
+}
+
+// Scan streams the content to clamd and interprets its verdict
+func (c *ClamdScanner) Scan(fileName string, content []byte) (bool, string, error) {
+	conn, err := net.DialTimeout("tcp", c.Address, c.Timeout)
+	if err != nil {
+		return false, "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.Timeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return false, "", err
+	}
+
+	const chunkSize = 64 << 10
+	for offset := 0; offset < len(content); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(content) {
+			end = len(content)
+		}
+
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(end-offset))
+		if _, err := conn.Write(size[:]); err != nil {
+			return false, "", err
+		}
+		if _, err := conn.Write(content[offset:end]); err != nil {
+			return false, "", err
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return false, "", err
+	}
+
+	var reply bytes.Buffer
+	if _, err := reply.ReadFrom(conn); err != nil {
+		return false, "", err
+	}
+
+	// Replies look like "stream: OK" or "stream: Eicar-Signature FOUND"
+	verdict := strings.TrimSpace(strings.TrimRight(reply.String(), "\x00"))
+	verdict = strings.TrimPrefix(verdict, "stream: ")
+	switch {
+	case verdict == "OK":
+		return true, "clean", nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return false, strings.TrimSuffix(verdict, " FOUND"), nil
+	default:
+		return false, "", fmt.Errorf("unexpected clamd reply: %s", verdict)
+	}
+}
+
+// newVirusScannerFromEnv returns a clamd scanner when CLAMD_ADDRESS is set, otherwise nil
+func newVirusScannerFromEnv() VirusScanner {
+	address := os.Getenv("CLAMD_ADDRESS")
+	if address == "" {
+		return nil
+	}
+	return &ClamdScanner{Address: address, Timeout: 30 * time.Second}
+}
+
+// SetVirusScanner replaces the scanner used for evidence uploads. A nil scanner stores files
+// unscanned and marks them as skipped.
+func (s *HRProblemService) SetVirusScanner(scanner VirusScanner) {
+	s.scanner = scanner
+}
+
+// AddAttachments stores evidence files on a problem or on one of its comments. Reporters can
+// only attach to their own reports and their own comments; HR/Admin can attach to any.
+func (s *HRProblemService) AddAttachments(problemID uint, commentID *uint, uploaderID uint, userRole models.Role, files []HRAttachmentUpload) ([]models.HRProblemAttachment, error) {
+	var problem models.HRProblem
+	if err := s.db.First(&problem, problemID).Error; err != nil {
+		return nil, err
+	}
+
+	isHR := userRole == models.RoleHR || userRole == models.RoleAdmin
+	if !isHR && (problem.ReporterID == nil || *problem.ReporterID != uploaderID) {
+		return nil, errors.New("you can only attach evidence to your own reports")
+	}
+
+	if commentID != nil {
+		var comment models.HRProblemComment
+		if err := s.db.Where("id = ? AND problem_id = ?", *commentID, problemID).First(&comment).Error; err != nil {
+			return nil, errors.New("comment not found")
+		}
+		if !isHR && (comment.IsHROnly || comment.UserID == nil || *comment.UserID != uploaderID) {
+			return nil, errors.New("you can only attach evidence to your own comments")
+		}
+	}
+
+	attachments, err := s.storeAttachments(&problem, commentID, &uploaderID, files)
+	if err != nil {
+		return nil, err
+	}
+
+	s.createStatusUpdate(problemID, uploaderID, "", "", fmt.Sprintf("%d evidence attachment(s) added", len(attachments)), false)
+
+	return attachments, nil
+}
+
+// AddAnonymousAttachments stores evidence files from an anonymous reporter, on the case or
+// on one of their own comments
+func (s *HRProblemService) AddAnonymousAttachments(caseCode, passphrase string, commentID *uint, files []HRAttachmentUpload) ([]models.HRProblemAttachment, error) {
+	problem, err := s.findAnonymousCase(caseCode, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if commentID != nil {
+		var comment models.HRProblemComment
+		err := s.db.Where("id = ? AND problem_id = ? AND is_anonymous_reporter = ?", *commentID, problem.ID, true).First(&comment).Error
+		if err != nil {
+			return nil, errors.New("comment not found")
+		}
+	}
+
+	attachments, err := s.storeAttachments(problem, commentID, nil, files)
+	if err != nil {
+		return nil, err
+	}
+
+	s.createStatusUpdate(problem.ID, 0, "", "", fmt.Sprintf("%d evidence attachment(s) added by the anonymous reporter", len(attachments)), false)
+
+	return attachments, nil
+}
+
+// DownloadAttachment decrypts an evidence file for the reporter or HR. Every download is
+// recorded in the problem's update history.
+func (s *HRProblemService) DownloadAttachment(problemID, attachmentID, userID uint, userRole models.Role) (*models.HRProblemAttachment, []byte, error) {
+	attachment, err := s.findAttachment(problemID, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	isHR := userRole == models.RoleHR || userRole == models.RoleAdmin
+	if !isHR {
+		isReporter := attachment.Problem.ReporterID != nil && *attachment.Problem.ReporterID == userID
+		if !isReporter || (attachment.Comment != nil && attachment.Comment.IsHROnly) {
+			return nil, nil, errAttachmentNotFound
+		}
+	}
+
+	content, err := s.readAttachment(attachment)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.createStatusUpdate(problemID, userID, "", "", fmt.Sprintf("Evidence attachment #%d downloaded", attachment.ID), false)
+
+	return attachment, content, nil
+}
+
+// DownloadAnonymousAttachment decrypts an evidence file for the holder of the case credentials
+func (s *HRProblemService) DownloadAnonymousAttachment(caseCode, passphrase string, attachmentID uint) (*models.HRProblemAttachment, []byte, error) {
+	problem, err := s.findAnonymousCase(caseCode, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attachment, err := s.findAttachment(problem.ID, attachmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if attachment.Comment != nil && attachment.Comment.IsHROnly {
+		return nil, nil, errAttachmentNotFound
+	}
+
+	content, err := s.readAttachment(attachment)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.createStatusUpdate(problem.ID, 0, "", "", fmt.Sprintf("Evidence attachment #%d downloaded by the anonymous reporter", attachment.ID), false)
+
+	return attachment, content, nil
+}
+
+// storeAttachments scans, encrypts and writes evidence files, then records them. The returned
+// attachments carry plaintext file names and must not be saved back.
+func (s *HRProblemService) storeAttachments(problem *models.HRProblem, commentID *uint, uploaderID *uint, files []HRAttachmentUpload) ([]models.HRProblemAttachment, error) {
+	if s.encryption == nil {
+		return nil, errHREncryptionUnavailable
+	}
+	if len(files) == 0 {
+		return nil, errors.New("at least one file is required")
+	}
+	if len(files) > MaxHRAttachmentsPerUpload {
+		return nil, fmt.Errorf("at most %d files can be uploaded at once", MaxHRAttachmentsPerUpload)
+	}
+
+	for i := range files {
+		files[i].FileName = sanitizeAttachmentName(files[i].FileName)
+		if len(files[i].Content) == 0 {
+			return nil, fmt.Errorf("%s is empty", files[i].FileName)
+		}
+		if len(files[i].Content) > MaxHRAttachmentSize {
+			return nil, fmt.Errorf("%s exceeds the %d MB size limit", files[i].FileName, MaxHRAttachmentSize>>20)
+		}
+	}
+
+	// Scan everything before anything is written, so a rejected upload leaves no trace on disk
+	scanStatus := models.AttachmentScanSkipped
+	scanResults := make([]string, len(files))
+	if s.scanner != nil {
+		scanStatus = models.AttachmentScanClean
+		for i, file := range files {
+			clean, result, err := s.scanner.Scan(file.FileName, file.Content)
+			if err != nil {
+				return nil, fmt.Errorf("virus scan failed: %v", err)
+			}
+			if !clean {
+				s.createStatusUpdate(problem.ID, 0, "", "", "Evidence upload rejected by virus scan", true)
+				return nil, fmt.Errorf("%s was rejected by the virus scan: %s", file.FileName, result)
+			}
+			scanResults[i] = result
+		}
+	}
+
+	problemDir := filepath.Join(s.attachmentDir, fmt.Sprint(problem.ID))
+	if err := os.MkdirAll(problemDir, 0700); err != nil {
+		return nil, err
+	}
+
+	var written []string
+	cleanup := func() {
+		for _, path := range written {
+			os.Remove(path)
+		}
+	}
+
+	attachments := make([]models.HRProblemAttachment, 0, len(files))
+	for i, file := range files {
+		storageName, err := randomStorageName()
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		storagePath := filepath.Join(fmt.Sprint(problem.ID), storageName)
+
+		encryptedContent, err := s.encryption.EncryptBytes(file.Content)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		fullPath := filepath.Join(s.attachmentDir, storagePath)
+		if err := os.WriteFile(fullPath, encryptedContent, 0600); err != nil {
+			cleanup()
+			return nil, err
+		}
+		written = append(written, fullPath)
+
+		encryptedName, err := s.encryption.Encrypt(file.FileName)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+
+		sum := sha256.Sum256(file.Content)
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		attachments = append(attachments, models.HRProblemAttachment{
+			ProblemID:           problem.ID,
+			CommentID:           commentID,
+			UploadedByID:        uploaderID,
+			IsAnonymousReporter: uploaderID == nil,
+			FileName:            encryptedName,
+			ContentType:         contentType,
+			Size:                int64(len(file.Content)),
+			StoragePath:         storagePath,
+			SHA256:              hex.EncodeToString(sum[:]),
+			ScanStatus:          scanStatus,
+			ScanResult:          scanResults[i],
+		})
+	}
+
+	if err := s.db.Create(&attachments).Error; err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	var uploader *models.User
+	if uploaderID != nil {
+		var user models.User
+		if err := s.db.First(&user, *uploaderID).Error; err == nil {
+			uploader = &user
+		}
+	}
+	for i := range attachments {
+		attachments[i].FileName = files[i].FileName
+		attachments[i].UploadedBy = uploader
+	}
+
+	return attachments, nil
+}
+
+// findAttachment loads an attachment of a problem together with its problem and comment
+func (s *HRProblemService) findAttachment(problemID, attachmentID uint) (*models.HRProblemAttachment, error) {
+	var attachment models.HRProblemAttachment
+	err := s.db.Preload("Problem").Preload("Comment").
+		Where("id = ? AND problem_id = ?", attachmentID, problemID).
+		First(&attachment).Error
+	if err != nil {
+		return nil, errAttachmentNotFound
+	}
+	return &attachment, nil
+}
+
+// readAttachment decrypts an attachment's file and verifies it against the stored hash.
+// The attachment's file name is decrypted in place.
+func (s *HRProblemService) readAttachment(attachment *models.HRProblemAttachment) ([]byte, error) {
+	if s.encryption == nil {
+		return nil, errHREncryptionUnavailable
+	}
+
+	encryptedContent, err := os.ReadFile(filepath.Join(s.attachmentDir, attachment.StoragePath))
+	if err != nil {
+		return nil, errors.New("attachment file is missing")
+	}
+
+	content, err := s.encryption.DecryptBytes(encryptedContent)
+	if err != nil {
+		return nil, errors.New("failed to decrypt attachment")
+	}
+
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != attachment.SHA256 {
+		return nil, errors.New("attachment failed integrity check")
+	}
+
+	name, err := s.encryption.Decrypt(attachment.FileName)
+	if err != nil {
+		return nil, errors.New("failed to decrypt attachment name")
+	}
+	attachment.FileName = name
+
+	return content, nil
+}
+
+// decryptAttachmentNames decrypts attachment file names in place for an authorized viewer
+func (s *HRProblemService) decryptAttachmentNames(attachments []models.HRProblemAttachment) error {
+	if len(attachments) == 0 {
+		return nil
+	}
+	if s.encryption == nil {
+		return errHREncryptionUnavailable
+	}
+
+	for i := range attachments {
+		name, err := s.encryption.Decrypt(attachments[i].FileName)
+		if err != nil {
+			return errors.New("failed to decrypt attachment name")
+		}
+		attachments[i].FileName = name
+	}
+	return nil
+}
+
+// visibleAttachments drops attachments that belong to comments the viewer cannot see
+func visibleAttachments(problem *models.HRProblem) []models.HRProblemAttachment {
+	visibleComments := make(map[uint]bool)
+	for _, comment := range problem.Comments {
+		visibleComments[comment.ID] = true
+	}
+
+	var attachments []models.HRProblemAttachment
+	for _, attachment := range problem.Attachments {
+		if attachment.CommentID == nil || visibleComments[*attachment.CommentID] {
+			attachments = append(attachments, attachment)
+		}
+	}
+	return attachments
+}
+
+func sanitizeAttachmentName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(strings.TrimSpace(name), "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		return "attachment"
+	}
+	return name
+}
+
+func randomStorageName() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf) + ".bin", nil
+}
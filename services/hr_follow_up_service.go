@@ -0,0 +1,285 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project-x/models"
+	"time"
+)
+
+// ScheduleFollowUp schedules a follow-up on a problem (HR/Admin only). The note is encrypted
+// with the HR key and the problem's FollowUpDate is moved to the next pending follow-up.
+func (s *HRProblemService) ScheduleFollowUp(problemID uint, scheduledBy uint, dueDate time.Time, note string) (*models.HRProblemFollowUp, error) {
+	var problem models.HRProblem
+	if err := s.db.First(&problem, problemID).Error; err != nil {
+		return nil, err
+	}
+	if problem.Status == models.ProblemStatusRejected {
+		return nil, errors.New("cannot schedule a follow-up on a rejected problem")
+	}
+
+	now := time.Now().In(s.workSchedule.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if dueDate.Before(today) {
+		return nil, errors.New("follow-up date cannot be in the past")
+	}
+
+	encryptedNote, err := s.encryptHRContent(note)
+	if err != nil {
+		return nil, err
+	}
+
+	followUp := &models.HRProblemFollowUp{
+		ProblemID:     problemID,
+		ScheduledByID: scheduledBy,
+		DueDate:       dueDate,
+		Note:          encryptedNote,
+		Status:        models.FollowUpStatusPending,
+	}
+	if err := s.db.Create(followUp).Error; err != nil {
+		return nil, err
+	}
+
+	s.refreshFollowUpDate(problemID)
+
+	s.createStatusUpdate(problemID, scheduledBy, "", "",
+		fmt.Sprintf("Follow-up scheduled for %s", dueDate.In(s.workSchedule.Location()).Format("2006-01-02 15:04")), false)
+
+	followUp.Note = note
+	return followUp, nil
+}
+
+// GetDueFollowUps returns pending follow-ups due within the given number of days, overdue ones
+// included, oldest first. When hrUserID is set only problems assigned to that user are returned.
+// Notes stay encrypted; they are only shown when the problem itself is opened.
+func (s *HRProblemService) GetDueFollowUps(days int, hrUserID *uint) ([]models.HRProblemFollowUp, error) {
+	if days < 0 {
+		days = 0
+	}
+
+	loc := s.workSchedule.Location()
+	now := time.Now().In(loc)
+	cutoff := time.Date(now.Year(), now.Month(), now.Day()+days+1, 0, 0, 0, 0, loc)
+
+	query := s.db.Preload("Problem.AssignedHR").Preload("ScheduledBy").
+		Joins("JOIN hr_problems ON hr_problems.id = hr_problem_follow_ups.problem_id AND hr_problems.deleted_at IS NULL").
+		Where("hr_problem_follow_ups.status = ? AND hr_problem_follow_ups.due_date < ?", models.FollowUpStatusPending, cutoff)
+	if hrUserID != nil {
+		query = query.Where("hr_problems.assigned_hr_id = ?", *hrUserID)
+	}
+
+	var followUps []models.HRProblemFollowUp
+	err := query.Order("hr_problem_follow_ups.due_date ASC").Find(&followUps).Error
+	return followUps, err
+}
+
+// CompleteFollowUp records the outcome of a follow-up. When the issue persists on a resolved
+// problem, the problem is reopened automatically.
+func (s *HRProblemService) CompleteFollowUp(problemID, followUpID, completedBy uint, outcome models.FollowUpOutcome, note string) (reopened bool, err error) {
+	if outcome != models.FollowUpOutcomeResolved && outcome != models.FollowUpOutcomePersists {
+		return false, errors.New("outcome must be 'resolved' or 'persists'")
+	}
+
+	followUp, err := s.findPendingFollowUp(problemID, followUpID)
+	if err != nil {
+		return false, err
+	}
+
+	encryptedNote, err := s.encryptHRContent(note)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	err = s.db.Model(followUp).Updates(map[string]interface{}{
+		"status":          models.FollowUpStatusCompleted,
+		"completed_at":    now,
+		"completed_by_id": completedBy,
+		"outcome":         outcome,
+		"outcome_note":    encryptedNote,
+	}).Error
+	if err != nil {
+		return false, err
+	}
+
+	s.refreshFollowUpDate(problemID)
+
+	outcomeText := "issue confirmed resolved"
+	if outcome == models.FollowUpOutcomePersists {
+		outcomeText = "issue persists"
+	}
+	s.createStatusUpdate(problemID, completedBy, "", "", fmt.Sprintf("Follow-up completed: %s", outcomeText), false)
+
+	if outcome == models.FollowUpOutcomePersists {
+		var problem models.HRProblem
+		if err := s.db.First(&problem, problemID).Error; err != nil {
+			return false, err
+		}
+		if problem.Status == models.ProblemStatusResolved {
+			if err := s.reopenProblem(&problem, completedBy, "Reopened: follow-up found the issue persists"); err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// CancelFollowUp cancels a pending follow-up
+func (s *HRProblemService) CancelFollowUp(problemID, followUpID, cancelledBy uint) error {
+	followUp, err := s.findPendingFollowUp(problemID, followUpID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Model(followUp).Update("status", models.FollowUpStatusCancelled).Error; err != nil {
+		return err
+	}
+
+	s.refreshFollowUpDate(problemID)
+
+	s.createStatusUpdate(problemID, cancelledBy, "", "", "Follow-up cancelled", false)
+
+	return nil
+}
+
+// SendFollowUpReminders reminds the assigned HR user (every HR user when unassigned) about
+// follow-ups that have come due. Each follow-up is reminded about once.
+func (s *HRProblemService) SendFollowUpReminders() (int, error) {
+	var followUps []models.HRProblemFollowUp
+	err := s.db.Preload("Problem").
+		Where("status = ? AND reminder_sent_at IS NULL AND due_date <= ?", models.FollowUpStatusPending, time.Now()).
+		Find(&followUps).Error
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, followUp := range followUps {
+		if followUp.Problem.ID == 0 {
+			continue
+		}
+
+		if s.notificationService != nil {
+			for _, hrUser := range s.slaRecipients(&followUp.Problem) {
+				s.notificationService.CreateNotification(
+					hrUser.ID,
+					"HR Follow-up Due",
+					fmt.Sprintf("A follow-up is due today for: %s", followUp.Problem.Title),
+					string(models.NotificationTypeHRFollowUpDue),
+					map[string]interface{}{
+						"problem_id":   followUp.ProblemID,
+						"follow_up_id": followUp.ID,
+						"due_date":     followUp.DueDate,
+					},
+				)
+			}
+		}
+
+		if err := s.db.Model(&followUp).Update("reminder_sent_at", time.Now()).Error; err != nil {
+			log.Printf("Failed to mark follow-up %d as reminded: %v", followUp.ID, err)
+			continue
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// StartFollowUpSweep periodically sends reminders for follow-ups that have come due
+func (s *HRProblemService) StartFollowUpSweep(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			sent, err := s.SendFollowUpReminders()
+			if err != nil {
+				log.Printf("HR follow-up sweep failed: %v", err)
+				continue
+			}
+			if sent > 0 {
+				log.Printf("Sent %d HR follow-up reminders", sent)
+			}
+		}
+	}()
+}
+
+// reopenProblem moves a resolved problem back to in progress and restarts its resolution clock
+func (s *HRProblemService) reopenProblem(problem *models.HRProblem, reopenedBy uint, reason string) error {
+	oldStatus := problem.Status
+	now := time.Now()
+
+	problem.Status = models.ProblemStatusInProgress
+	problem.ResolvedAt = nil
+	// The restarted clock gets its own warning and breach, so a breach of the earlier clock
+	// does not keep the reopened case flagged
+	problem.SLAResolutionWarned = false
+	problem.SLAResolutionBreached = false
+	if problem.SLAResolutionHours > 0 {
+		resolutionDue := s.workSchedule.AddWorkingHours(now, problem.SLAResolutionHours)
+		problem.SLAResolutionDueAt = &resolutionDue
+	}
+
+	if err := s.db.Save(problem).Error; err != nil {
+		return err
+	}
+
+	s.createStatusUpdate(problem.ID, reopenedBy, oldStatus, models.ProblemStatusInProgress, reason, true)
+
+	s.notifyReporter(problem, "Your problem report has been reopened after a follow-up")
+
+	return nil
+}
+
+// findPendingFollowUp loads a pending follow-up belonging to a problem
+func (s *HRProblemService) findPendingFollowUp(problemID, followUpID uint) (*models.HRProblemFollowUp, error) {
+	var followUp models.HRProblemFollowUp
+	if err := s.db.Where("id = ? AND problem_id = ?", followUpID, problemID).First(&followUp).Error; err != nil {
+		return nil, errors.New("follow-up not found")
+	}
+	if followUp.Status != models.FollowUpStatusPending {
+		return nil, errors.New("follow-up is no longer pending")
+	}
+	return &followUp, nil
+}
+
+// refreshFollowUpDate points the problem's FollowUpDate at its earliest pending follow-up
+func (s *HRProblemService) refreshFollowUpDate(problemID uint) {
+	var next models.HRProblemFollowUp
+	err := s.db.Where("problem_id = ? AND status = ?", problemID, models.FollowUpStatusPending).
+		Order("due_date ASC").
+		First(&next).Error
+
+	var followUpDate *time.Time
+	if err == nil {
+		followUpDate = &next.DueDate
+	}
+	s.db.Model(&models.HRProblem{}).Where("id = ?", problemID).Update("follow_up_date", followUpDate)
+}
+
+// decryptFollowUpNotes decrypts follow-up notes in place for HR viewers
+func (s *HRProblemService) decryptFollowUpNotes(followUps []models.HRProblemFollowUp) error {
+	if len(followUps) == 0 {
+		return nil
+	}
+	if s.encryption == nil {
+		return errHREncryptionUnavailable
+	}
+
+	for i := range followUps {
+		note, err := s.encryption.Decrypt(followUps[i].Note)
+		if err != nil {
+			return errors.New("failed to decrypt follow-up note")
+		}
+		outcomeNote, err := s.encryption.Decrypt(followUps[i].OutcomeNote)
+		if err != nil {
+			return errors.New("failed to decrypt follow-up note")
+		}
+		followUps[i].Note = note
+		followUps[i].OutcomeNote = outcomeNote
+	}
+	return nil
+}
@@ -3,21 +3,49 @@ package services
 import (
 	"errors"
 	"fmt"
+	"os"
+	"project-x/config"
 	"project-x/models"
+	"strings"
 	"time"
 
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// caseCodeChars excludes characters that are easily confused when read aloud or written down
+const caseCodeChars = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+var errInvalidCaseCredentials = errors.New("invalid case code or passphrase")
+
+var errHREncryptionUnavailable = errors.New("HR encryption key is not configured")
+
 type HRProblemService struct {
 	db                  *gorm.DB
 	notificationService *NotificationService
+	workSchedule        *config.WorkScheduleConfig // Used to measure SLA clocks in working hours
+	encryption          *EncryptionService         // HR_ENCRYPTION_KEY, separate from the password manager key
+	attachmentDir       string                     // Where encrypted evidence files are stored
+	scanner             VirusScanner               // Optional virus scan hook for evidence uploads
 }
 
 func NewHRProblemService(db *gorm.DB, notificationService *NotificationService) *HRProblemService {
+	// Without the HR key, reports can still be listed but sensitive content cannot be written.
+	// main checks the key once at startup and warns there.
+	encryption, _ := NewEncryptionServiceFromEnv("HR_ENCRYPTION_KEY")
+
+	attachmentDir := os.Getenv("HR_ATTACHMENTS_DIR")
+	if attachmentDir == "" {
+		attachmentDir = defaultHRAttachmentDir
+	}
+
 	return &HRProblemService{
 		db:                  db,
 		notificationService: notificationService,
+		workSchedule:        config.GetDefaultWorkSchedule(),
+		encryption:          encryption,
+		attachmentDir:       attachmentDir,
+		scanner:             newVirusScannerFromEnv(),
 	}
 }
 
@@ -33,35 +61,19 @@ func (s *HRProblemService) CreateProblem(
 	incidentDate *time.Time,
 	previousReports bool,
 ) (*models.HRProblem, error) {
-	// Validate required fields
-	if title == "" || description == "" {
-		return nil, errors.New("title and description are required")
-	}
-
-	// Validate category
-	categories := models.GetProblemCategories()
-	if _, exists := categories[category]; !exists {
-		return nil, errors.New("invalid problem category")
-	}
-
-	// Validate priority
-	priorities := models.GetProblemPriorities()
-	if _, exists := priorities[priority]; !exists {
-		return nil, errors.New("invalid problem priority")
+	if err := validateProblemInput(title, description, category, priority); err != nil {
+		return nil, err
 	}
 
-	// Set urgent flag for high priority issues
-	isUrgent := priority == models.ProblemPriorityUrgent || priority == models.ProblemPritorityCritical
-
 	problem := &models.HRProblem{
 		Title:           title,
 		Description:     description,
 		Category:        category,
 		Priority:        priority,
 		Status:          models.ProblemStatusPending,
-		ReporterID:      reporterID,
+		ReporterID:      &reporterID,
 		IsAnonymous:     isAnonymous,
-		IsUrgent:        isUrgent,
+		IsUrgent:        isUrgentPriority(priority),
 		ReportedAt:      time.Now(),
 		ContactMethod:   contactMethod,
 		PhoneNumber:     phoneNumber,
@@ -71,6 +83,19 @@ func (s *HRProblemService) CreateProblem(
 		IncidentDate:    incidentDate,
 		PreviousReports: previousReports,
 	}
+	s.applySLATargets(problem)
+
+	// Pick the assignee while the report is still plaintext so named HR users can be excluded
+	var reporter models.User
+	s.db.Select("department").First(&reporter, reporterID)
+	assignee, rule := s.selectAssignee(problem, reporter.Department)
+	if assignee != nil {
+		problem.AssignedHRID = &assignee.ID
+	}
+
+	if err := s.encryptProblemContent(problem); err != nil {
+		return nil, err
+	}
 
 	if err := s.db.Create(problem).Error; err != nil {
 		return nil, err
@@ -82,19 +107,163 @@ func (s *HRProblemService) CreateProblem(
 	// Create initial update record
 	s.createStatusUpdate(problem.ID, reporterID, "", models.ProblemStatusPending, "Problem reported", true)
 
-	// Send notification to all HR users
-	s.notifyHRUsers(problem)
+	// Notify the auto-assigned HR user, or every HR user when nobody was eligible
+	if assignee != nil {
+		s.recordAutoAssignment(problem, assignee, rule)
+	} else {
+		s.notifyHRUsers(problem)
+	}
+
+	return problem, nil
+}
+
+// CreateAnonymousProblem files a report that is not linked to any user account. The reporter
+// receives a case code and a one-time passphrase which are the only way to follow up on it.
+// The passphrase is returned once and only its bcrypt hash is stored.
+func (s *HRProblemService) CreateAnonymousProblem(
+	title, description string,
+	category models.ProblemCategory,
+	priority models.ProblemPriority,
+	witnessInfo, location string,
+	incidentDate *time.Time,
+	previousReports bool,
+) (*models.HRProblem, string, error) {
+	if err := validateProblemInput(title, description, category, priority); err != nil {
+		return nil, "", err
+	}
+
+	caseCode, err := s.generateCaseCode()
+	if err != nil {
+		return nil, "", err
+	}
+	passphrase, err := randomGroupedCode(5, 4)
+	if err != nil {
+		return nil, "", err
+	}
+	passphraseHash, err := bcrypt.GenerateFromPassword([]byte(passphrase), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, "", err
+	}
+
+	problem := &models.HRProblem{
+		Title:              title,
+		Description:        description,
+		Category:           category,
+		Priority:           priority,
+		Status:             models.ProblemStatusPending,
+		IsAnonymous:        true,
+		IsUrgent:           isUrgentPriority(priority),
+		ReportedAt:         time.Now(),
+		ContactMethod:      "case_code",
+		WitnessInfo:        witnessInfo,
+		Location:           location,
+		IncidentDate:       incidentDate,
+		PreviousReports:    previousReports,
+		CaseCode:           &caseCode,
+		CasePassphraseHash: string(passphraseHash),
+	}
+	s.applySLATargets(problem)
+
+	// Anonymous reports have no reporter department, so only department-agnostic rules apply
+	assignee, rule := s.selectAssignee(problem, "")
+	if assignee != nil {
+		problem.AssignedHRID = &assignee.ID
+	}
+
+	if err := s.encryptProblemContent(problem); err != nil {
+		return nil, "", err
+	}
+
+	if err := s.db.Create(problem).Error; err != nil {
+		return nil, "", err
+	}
+
+	s.createStatusUpdate(problem.ID, 0, "", models.ProblemStatusPending, "Problem reported anonymously", true)
+
+	if assignee != nil {
+		s.recordAutoAssignment(problem, assignee, rule)
+	} else {
+		s.notifyHRUsers(problem)
+	}
+
+	return problem, passphrase, nil
+}
+
+// GetAnonymousCase lets an anonymous reporter read their case, including HR replies, using
+// the case code and passphrase. HR-only comments are never returned.
+func (s *HRProblemService) GetAnonymousCase(caseCode, passphrase string) (*models.HRProblem, error) {
+	problem, err := s.findAnonymousCase(caseCode, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Preload("AssignedHR").
+		Preload("Comments", "is_hr_only = ?", false).
+		Preload("Comments.User").
+		Preload("Updates").
+		Preload("Attachments").
+		Preload("Attachments.UploadedBy").
+		First(problem, problem.ID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// The case credentials identify the reporter, who may read their own report
+	problem.HRNotes = ""
+	problem.Attachments = visibleAttachments(problem)
+	if err := s.decryptProblemContent(problem); err != nil {
+		return nil, err
+	}
+	if err := s.decryptAttachmentNames(problem.Attachments); err != nil {
+		return nil, err
+	}
 
 	return problem, nil
 }
 
-// GetProblemByID retrieves a problem by ID
+// AddAnonymousComment adds a follow-up comment from the anonymous reporter. No user is linked
+// to the comment.
+func (s *HRProblemService) AddAnonymousComment(caseCode, passphrase, comment string) error {
+	if strings.TrimSpace(comment) == "" {
+		return errors.New("comment is required")
+	}
+
+	problem, err := s.findAnonymousCase(caseCode, passphrase)
+	if err != nil {
+		return err
+	}
+	if problem.Status == models.ProblemStatusClosed || problem.Status == models.ProblemStatusRejected {
+		return errors.New("this case is no longer accepting comments")
+	}
+
+	hrComment := &models.HRProblemComment{
+		ProblemID:           problem.ID,
+		Comment:             comment,
+		IsAnonymousReporter: true,
+	}
+	if err := s.db.Create(hrComment).Error; err != nil {
+		return err
+	}
+
+	s.notifyHRAboutReporterComment(problem)
+
+	return nil
+}
+
+// GetProblemByID retrieves a problem by ID with its sensitive content decrypted. The returned
+// problem holds plaintext and must not be saved back.
 func (s *HRProblemService) GetProblemByID(problemID uint, userID uint, userRole models.Role) (*models.HRProblem, error) {
 	var problem models.HRProblem
-	query := s.db.Preload("Reporter").Preload("AssignedHR").Preload("Comments.User").Preload("Updates.UpdatedByUser")
-
-	// Non-HR users can only see their own reports
-	if userRole != models.RoleHR && userRole != models.RoleAdmin {
+	query := s.db.Preload("Reporter").Preload("AssignedHR").Preload("Comments.User").Preload("Updates.UpdatedByUser").
+		Preload("Attachments.UploadedBy")
+
+	// Non-HR users can only see their own reports; follow-ups are internal to HR
+	isHR := userRole == models.RoleHR || userRole == models.RoleAdmin
+	if isHR {
+		query = query.Preload("FollowUps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("due_date ASC")
+		}).Preload("FollowUps.ScheduledBy").Preload("FollowUps.CompletedBy")
+	} else {
 		query = query.Where("reporter_id = ?", userID)
 	}
 
@@ -102,8 +271,8 @@ func (s *HRProblemService) GetProblemByID(problemID uint, userID uint, userRole
 		return nil, err
 	}
 
-	// Filter HR-only comments for non-HR users
-	if userRole != models.RoleHR && userRole != models.RoleAdmin {
+	// Filter HR-only comments and internal notes for non-HR users
+	if !isHR {
 		var filteredComments []models.HRProblemComment
 		for _, comment := range problem.Comments {
 			if !comment.IsHROnly {
@@ -111,6 +280,19 @@ func (s *HRProblemService) GetProblemByID(problemID uint, userID uint, userRole
 			}
 		}
 		problem.Comments = filteredComments
+		problem.Attachments = visibleAttachments(&problem)
+		problem.HRNotes = ""
+	}
+
+	// Sensitive content is only ever decrypted here, for the reporter or HR
+	if err := s.decryptProblemContent(&problem); err != nil {
+		return nil, err
+	}
+	if err := s.decryptAttachmentNames(problem.Attachments); err != nil {
+		return nil, err
+	}
+	if err := s.decryptFollowUpNotes(problem.FollowUps); err != nil {
+		return nil, err
 	}
 
 	return &problem, nil
@@ -181,11 +363,15 @@ func (s *HRProblemService) UpdateProblemStatus(problemID uint, newStatus models.
 
 	oldStatus := problem.Status
 	problem.Status = newStatus
+	now := time.Now()
+
+	// Any status change by HR counts as the first response
+	s.recordFirstResponse(&problem, now)
 
 	// Set resolved date if status is resolved or closed
 	if newStatus == models.ProblemStatusResolved || newStatus == models.ProblemStatusClosed {
-		now := time.Now()
 		problem.ResolvedAt = &now
+		s.recordResolution(&problem, now)
 	}
 
 	if err := s.db.Save(&problem).Error; err != nil {
@@ -241,7 +427,7 @@ func (s *HRProblemService) AddComment(problemID uint, userID uint, comment strin
 
 	hrComment := &models.HRProblemComment{
 		ProblemID: problemID,
-		UserID:    userID,
+		UserID:    &userID,
 		Comment:   comment,
 		IsHROnly:  isHROnly,
 	}
@@ -253,8 +439,17 @@ func (s *HRProblemService) AddComment(problemID uint, userID uint, comment strin
 	// Load user info
 	s.db.Preload("User").First(hrComment, hrComment.ID)
 
+	// A visible reply from HR stops the first response clock
+	if !isHROnly && hrComment.User != nil && (hrComment.User.Role == models.RoleHR || hrComment.User.Role == models.RoleAdmin) && problem.FirstResponseAt == nil {
+		s.recordFirstResponse(&problem, hrComment.CreatedAt)
+		s.db.Model(&problem).Updates(map[string]interface{}{
+			"first_response_at":           problem.FirstResponseAt,
+			"sla_first_response_breached": problem.SLAFirstResponseBreached,
+		})
+	}
+
 	// Notify relevant parties about new comment
-	if !isHROnly {
+	if !isHROnly && hrComment.User != nil {
 		s.notifyReporter(&problem, fmt.Sprintf("New comment added to your problem report by %s", hrComment.User.Username))
 	}
 
@@ -268,7 +463,20 @@ func (s *HRProblemService) UpdateHRNotes(problemID uint, notes string, updatedBy
 		return err
 	}
 
-	problem.HRNotes = notes
+	// Reports stored before encryption are encrypted as a whole on their first update
+	if problem.IsEncrypted {
+		encryptedNotes, err := s.encryptHRContent(notes)
+		if err != nil {
+			return err
+		}
+		problem.HRNotes = encryptedNotes
+	} else {
+		problem.HRNotes = notes
+		if err := s.encryptProblemContent(&problem); err != nil {
+			return err
+		}
+	}
+
 	if err := s.db.Save(&problem).Error; err != nil {
 		return err
 	}
@@ -279,17 +487,23 @@ func (s *HRProblemService) UpdateHRNotes(problemID uint, notes string, updatedBy
 	return nil
 }
 
-// SetResolution sets the final resolution for a problem (HR/Admin only)
-func (s *HRProblemService) SetResolution(problemID uint, resolution string, updatedBy uint) error {
+// SetResolution sets the final resolution for a problem (HR/Admin only). When publishAsPolicyAnswer
+// is set the resolution is shared with everyone as a policy answer through the knowledge base.
+func (s *HRProblemService) SetResolution(problemID uint, resolution string, publishAsPolicyAnswer bool, updatedBy uint) error {
 	var problem models.HRProblem
 	if err := s.db.First(&problem, problemID).Error; err != nil {
 		return err
 	}
+	if publishAsPolicyAnswer && problem.IsAnonymous {
+		return errors.New("resolutions of anonymous reports cannot be published as policy answers")
+	}
 
 	problem.Resolution = resolution
+	problem.PublishAsPolicyAnswer = publishAsPolicyAnswer
 	problem.Status = models.ProblemStatusResolved
 	now := time.Now()
 	problem.ResolvedAt = &now
+	s.recordResolution(&problem, now)
 
 	if err := s.db.Save(&problem).Error; err != nil {
 		return err
@@ -361,22 +575,214 @@ func (s *HRProblemService) GetProblemStatistics() (map[string]interface{}, error
 	s.db.Model(&models.HRProblem{}).Where("created_at >= ?", sevenDaysAgo).Count(&recentProblems)
 	stats["recent_problems"] = recentProblems
 
+	// SLA compliance
+	stats["sla"] = s.getSLASummary()
+
 	return stats, nil
 }
 
-// Helper function to create status update records
+// Helper function to create status update records. An updatedBy of 0 records no user,
+// which is used for anonymous reporters.
 func (s *HRProblemService) createStatusUpdate(problemID uint, updatedBy uint, oldStatus models.ProblemStatus, newStatus models.ProblemStatus, note string, isAutomatic bool) {
 	update := &models.HRProblemUpdate{
 		ProblemID:   problemID,
-		UpdatedBy:   updatedBy,
 		OldStatus:   oldStatus,
 		NewStatus:   newStatus,
 		UpdateNote:  note,
 		IsAutomatic: isAutomatic,
 	}
+	if updatedBy != 0 {
+		update.UpdatedBy = &updatedBy
+	}
 	s.db.Create(update)
 }
 
+// EncryptLegacyProblems encrypts the sensitive content of reports stored before HR content
+// encryption existed. It is safe to run repeatedly.
+func (s *HRProblemService) EncryptLegacyProblems() (int, error) {
+	if s.encryption == nil {
+		return 0, nil // Already reported once at startup
+	}
+
+	var problems []models.HRProblem
+	if err := s.db.Where("is_encrypted = ?", false).Find(&problems).Error; err != nil {
+		return 0, err
+	}
+
+	encrypted := 0
+	for i := range problems {
+		problem := &problems[i]
+		if err := s.encryptProblemContent(problem); err != nil {
+			return encrypted, err
+		}
+
+		err := s.db.Model(problem).Select("description", "witness_info", "phone_number", "hr_notes", "is_encrypted").Updates(problem).Error
+		if err != nil {
+			return encrypted, err
+		}
+		encrypted++
+	}
+
+	return encrypted, nil
+}
+
+// UnlinkLegacyAnonymousProblems removes the reporter link from anonymous reports filed before
+// anonymous intake stopped storing it: the problem's reporter, the reporter's own status
+// updates, comments and evidence uploads, and the phone number. It is safe to run repeatedly
+// and returns the number of problems unlinked.
+func (s *HRProblemService) UnlinkLegacyAnonymousProblems() (int, error) {
+	unlinked := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			`UPDATE hr_problem_updates u SET updated_by = NULL FROM hr_problems p
+				WHERE u.problem_id = p.id AND p.is_anonymous AND u.updated_by = p.reporter_id`,
+			`UPDATE hr_problem_comments c SET user_id = NULL, is_anonymous_reporter = true FROM hr_problems p
+				WHERE c.problem_id = p.id AND p.is_anonymous AND c.user_id = p.reporter_id`,
+			`UPDATE hr_problem_attachments a SET uploaded_by_id = NULL, is_anonymous_reporter = true FROM hr_problems p
+				WHERE a.problem_id = p.id AND p.is_anonymous AND a.uploaded_by_id = p.reporter_id`,
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&models.HRProblem{}).
+			Where("is_anonymous = ? AND reporter_id IS NOT NULL", true).
+			Updates(map[string]interface{}{"reporter_id": nil, "phone_number": ""})
+		unlinked = int(result.RowsAffected)
+		return result.Error
+	})
+	return unlinked, err
+}
+
+// Helper function to encrypt sensitive report content in place before it is stored
+func (s *HRProblemService) encryptProblemContent(problem *models.HRProblem) error {
+	if problem.IsEncrypted {
+		return nil
+	}
+
+	fields := []*string{&problem.Description, &problem.WitnessInfo, &problem.PhoneNumber, &problem.HRNotes}
+	for _, field := range fields {
+		encrypted, err := s.encryptHRContent(*field)
+		if err != nil {
+			return err
+		}
+		*field = encrypted
+	}
+
+	problem.IsEncrypted = true
+	return nil
+}
+
+// Helper function to decrypt sensitive report content in place for an authorized viewer
+func (s *HRProblemService) decryptProblemContent(problem *models.HRProblem) error {
+	if !problem.IsEncrypted {
+		return nil
+	}
+	if s.encryption == nil {
+		return errHREncryptionUnavailable
+	}
+
+	fields := []*string{&problem.Description, &problem.WitnessInfo, &problem.PhoneNumber, &problem.HRNotes}
+	for _, field := range fields {
+		decrypted, err := s.encryption.Decrypt(*field)
+		if err != nil {
+			return errors.New("failed to decrypt problem content")
+		}
+		*field = decrypted
+	}
+
+	problem.IsEncrypted = false
+	return nil
+}
+
+// Helper function to encrypt a single value with the HR key
+func (s *HRProblemService) encryptHRContent(value string) (string, error) {
+	if s.encryption == nil {
+		return "", errHREncryptionUnavailable
+	}
+	return s.encryption.Encrypt(value)
+}
+
+// Helper function to validate the fields shared by identified and anonymous reports
+func validateProblemInput(title, description string, category models.ProblemCategory, priority models.ProblemPriority) error {
+	if title == "" || description == "" {
+		return errors.New("title and description are required")
+	}
+
+	categories := models.GetProblemCategories()
+	if _, exists := categories[category]; !exists {
+		return errors.New("invalid problem category")
+	}
+
+	priorities := models.GetProblemPriorities()
+	if _, exists := priorities[priority]; !exists {
+		return errors.New("invalid problem priority")
+	}
+
+	return nil
+}
+
+// Helper function to flag high priority issues as urgent
+func isUrgentPriority(priority models.ProblemPriority) bool {
+	return priority == models.ProblemPriorityUrgent || priority == models.ProblemPritorityCritical
+}
+
+// Helper function to generate a case code that is not already in use
+func (s *HRProblemService) generateCaseCode() (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		code, err := randomGroupedCode(3, 4)
+		if err != nil {
+			return "", err
+		}
+		code = "HR-" + code
+
+		var count int64
+		s.db.Unscoped().Model(&models.HRProblem{}).Where("case_code = ?", code).Count(&count)
+		if count == 0 {
+			return code, nil
+		}
+	}
+	return "", errors.New("failed to generate a unique case code")
+}
+
+// Helper function to build a random code such as ABCD-EFGH-JKLM from caseCodeChars
+func randomGroupedCode(groups, groupLength int) (string, error) {
+	parts := make([]string, groups)
+	for i := range parts {
+		part := make([]byte, groupLength)
+		for j := range part {
+			ch, err := randomChar(caseCodeChars)
+			if err != nil {
+				return "", err
+			}
+			part[j] = ch
+		}
+		parts[i] = string(part)
+	}
+	return strings.Join(parts, "-"), nil
+}
+
+// Helper function to look up an anonymous case and verify its passphrase
+func (s *HRProblemService) findAnonymousCase(caseCode, passphrase string) (*models.HRProblem, error) {
+	caseCode = strings.ToUpper(strings.TrimSpace(caseCode))
+	passphrase = strings.ToUpper(strings.TrimSpace(passphrase))
+	if caseCode == "" || passphrase == "" {
+		return nil, errInvalidCaseCredentials
+	}
+
+	var problem models.HRProblem
+	if err := s.db.Where("case_code = ?", caseCode).First(&problem).Error; err != nil {
+		return nil, errInvalidCaseCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(problem.CasePassphraseHash), []byte(passphrase)) != nil {
+		return nil, errInvalidCaseCredentials
+	}
+
+	return &problem, nil
+}
+
 // Helper function to notify HR users about new problems
 func (s *HRProblemService) notifyHRUsers(problem *models.HRProblem) {
 	if s.notificationService == nil {
@@ -411,12 +817,12 @@ func (s *HRProblemService) notifyHRUsers(problem *models.HRProblem) {
 
 // Helper function to notify reporter about updates
 func (s *HRProblemService) notifyReporter(problem *models.HRProblem, message string) {
-	if s.notificationService == nil || problem.IsAnonymous {
+	if s.notificationService == nil || problem.IsAnonymous || problem.ReporterID == nil {
 		return
 	}
 
 	s.notificationService.CreateNotification(
-		problem.ReporterID,
+		*problem.ReporterID,
 		"HR Problem Report Update",
 		message,
 		"hr_problem_update",
@@ -445,3 +851,31 @@ func (s *HRProblemService) notifyAssignedHR(problem *models.HRProblem, hrUser *m
 		},
 	)
 }
+
+// Helper function to notify HR that an anonymous reporter added a comment
+func (s *HRProblemService) notifyHRAboutReporterComment(problem *models.HRProblem) {
+	if s.notificationService == nil {
+		return
+	}
+
+	var recipients []models.User
+	if problem.AssignedHRID != nil {
+		s.db.Where("id = ?", *problem.AssignedHRID).Find(&recipients)
+	} else {
+		s.db.Where("role = ?", models.RoleHR).Find(&recipients)
+	}
+
+	for _, recipient := range recipients {
+		s.notificationService.CreateNotification(
+			recipient.ID,
+			"Anonymous Reporter Replied",
+			fmt.Sprintf("The anonymous reporter added a comment to: %s", problem.Title),
+			"hr_problem",
+			map[string]interface{}{
+				"problem_id": problem.ID,
+				"category":   problem.Category,
+				"priority":   problem.Priority,
+			},
+		)
+	}
+}
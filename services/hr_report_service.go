@@ -0,0 +1,383 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"project-x/models"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// HRReportSuppressionThreshold is the smallest case count shown in trend reports. Smaller
+// non-zero figures are withheld so individual reporters cannot be re-identified.
+const HRReportSuppressionThreshold = 5
+
+// hrRepeatLookback is how far back an earlier report by the same reporter in the same
+// category marks a new report as a repeat issue
+const hrRepeatLookback = 12
+
+// anonymousDepartment groups anonymous reports, whose reporter department is never disclosed
+const anonymousDepartment = "Anonymous"
+
+// HRTrendCell holds the figures for one month and group. Nil figures are withheld because
+// they are below the suppression threshold.
+type HRTrendCell struct {
+	Month               string   `json:"month"` // "2006-01", empty for whole-period figures
+	Group               string   `json:"group"` // Department or category, empty for totals
+	Cases               *int     `json:"cases"`
+	RepeatCases         *int     `json:"repeat_cases"`          // Flagged PreviousReports or a repeat by the same reporter
+	ResolvedCases       *int     `json:"resolved_cases"`        // Of the cases reported in the month
+	MeanResolutionHours *float64 `json:"mean_resolution_hours"` // Working hours from report to resolution
+	Suppressed          bool     `json:"suppressed"`            // Whole cell withheld
+}
+
+// HRTrendReport is the monthly trend report for HR leadership
+type HRTrendReport struct {
+	From                 time.Time     `json:"from"`
+	To                   time.Time     `json:"to"`
+	Months               []string      `json:"months"`
+	SuppressionThreshold int           `json:"suppression_threshold"`
+	Overall              HRTrendCell   `json:"overall"`
+	Monthly              []HRTrendCell `json:"monthly"`
+	ByDepartment         []HRTrendCell `json:"by_department"`
+	ByCategory           []HRTrendCell `json:"by_category"`
+	GeneratedAt          time.Time     `json:"generated_at"`
+}
+
+// hrTrendCase is the per-problem data a trend report is built from
+type hrTrendCase struct {
+	ID              uint
+	Category        models.ProblemCategory
+	Status          models.ProblemStatus
+	ReporterID      *uint
+	IsAnonymous     bool
+	PreviousReports bool
+	ReportedAt      time.Time
+	ResolvedAt      *time.Time
+	Department      string
+}
+
+// hrTrendBucket accumulates raw figures before suppression
+type hrTrendBucket struct {
+	month, group    string
+	cases, repeats  int
+	resolved        int
+	resolutionHours float64
+}
+
+// GetTrendReport builds the monthly trend report for the months from..to inclusive, using the
+// month the problem was reported in. Rejected problems are excluded. Every figure below
+// HRReportSuppressionThreshold is withheld. When a single group of a month, or a single month of
+// the period, withholds a figure, the next smallest one is withheld too (or the period total when
+// there is none) so it cannot be derived from the total.
+func (s *HRProblemService) GetTrendReport(from, to time.Time) (*HRTrendReport, error) {
+	loc := s.workSchedule.Location()
+	from = time.Date(from.In(loc).Year(), from.In(loc).Month(), 1, 0, 0, 0, 0, loc)
+	end := time.Date(to.In(loc).Year(), to.In(loc).Month()+1, 1, 0, 0, 0, 0, loc)
+	if !from.Before(end) {
+		return nil, errors.New("report start must not be after its end")
+	}
+	if end.Sub(from) > 5*366*24*time.Hour {
+		return nil, errors.New("report period cannot exceed 5 years")
+	}
+
+	// Earlier reports are loaded too so repeats by the same reporter can be detected
+	var cases []hrTrendCase
+	err := s.db.Model(&models.HRProblem{}).
+		Select("hr_problems.id, hr_problems.category, hr_problems.status, hr_problems.reporter_id, hr_problems.is_anonymous, "+
+			"hr_problems.previous_reports, hr_problems.reported_at, hr_problems.resolved_at, users.department").
+		Joins("LEFT JOIN users ON users.id = hr_problems.reporter_id").
+		Where("hr_problems.reported_at >= ? AND hr_problems.reported_at < ?", from.AddDate(0, -hrRepeatLookback, 0), end).
+		Where("hr_problems.status <> ?", models.ProblemStatusRejected).
+		Order("hr_problems.reported_at ASC").
+		Scan(&cases).Error
+	if err != nil {
+		return nil, err
+	}
+
+	report := &HRTrendReport{
+		From:                 from,
+		To:                   end.AddDate(0, 0, -1),
+		SuppressionThreshold: HRReportSuppressionThreshold,
+		GeneratedAt:          time.Now(),
+	}
+	for month := from; month.Before(end); month = month.AddDate(0, 1, 0) {
+		report.Months = append(report.Months, month.Format("2006-01"))
+	}
+
+	overall := &hrTrendBucket{}
+	monthly := make(map[string]*hrTrendBucket)
+	byDepartment := make(map[string]*hrTrendBucket)
+	byCategory := make(map[string]*hrTrendBucket)
+	add := func(buckets map[string]*hrTrendBucket, month, group string) *hrTrendBucket {
+		key := month + "|" + group
+		if buckets[key] == nil {
+			buckets[key] = &hrTrendBucket{month: month, group: group}
+		}
+		return buckets[key]
+	}
+
+	lastReport := make(map[string]time.Time) // reporter|category -> latest report
+	for _, problem := range cases {
+		repeat := problem.PreviousReports
+		if problem.ReporterID != nil {
+			key := fmt.Sprintf("%d|%s", *problem.ReporterID, problem.Category)
+			if previous, seen := lastReport[key]; seen && problem.ReportedAt.Before(previous.AddDate(0, hrRepeatLookback, 0)) {
+				repeat = true
+			}
+			lastReport[key] = problem.ReportedAt
+		}
+
+		if problem.ReportedAt.Before(from) {
+			continue
+		}
+
+		department := problem.Department
+		if problem.IsAnonymous || problem.ReporterID == nil {
+			department = anonymousDepartment
+		} else if department == "" {
+			department = "Unassigned"
+		}
+		month := problem.ReportedAt.In(loc).Format("2006-01")
+
+		var resolutionHours float64
+		resolved := problem.ResolvedAt != nil &&
+			(problem.Status == models.ProblemStatusResolved || problem.Status == models.ProblemStatusClosed)
+		if resolved {
+			resolutionHours = s.workSchedule.WorkingHoursBetween(problem.ReportedAt, *problem.ResolvedAt)
+		}
+
+		for _, bucket := range []*hrTrendBucket{
+			overall,
+			add(monthly, month, ""),
+			add(byDepartment, month, department),
+			add(byCategory, month, string(problem.Category)),
+		} {
+			bucket.cases++
+			if repeat {
+				bucket.repeats++
+			}
+			if resolved {
+				bucket.resolved++
+				bucket.resolutionHours += resolutionHours
+			}
+		}
+	}
+
+	report.Overall = suppressTrendBucket(overall)
+	report.Monthly = suppressTrendGroups(monthly, report.Months)
+	complementTrendCells(report.Monthly, &report.Overall)
+	report.ByDepartment = suppressTrendGroups(byDepartment, report.Months)
+	report.ByCategory = suppressTrendGroups(byCategory, report.Months)
+
+	return report, nil
+}
+
+// ExportTrendReportCSV renders the trend report as CSV. Withheld cells read "suppressed" and
+// withheld figures inside a visible cell read "<N".
+func (s *HRProblemService) ExportTrendReportCSV(report *HRTrendReport) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff") // BOM so spreadsheet tools read department names as UTF-8
+	writer := csv.NewWriter(&buf)
+
+	writer.Write([]string{"section", "month", "group", "cases", "repeat_cases", "resolved_cases", "mean_resolution_hours"})
+	writeCell := func(section string, cell HRTrendCell) {
+		writer.Write(append([]string{section, cell.Month, cell.Group}, trendCellFigures(cell, report.SuppressionThreshold)...))
+	}
+
+	writeCell("overall", report.Overall)
+	for _, cell := range report.Monthly {
+		writeCell("monthly", cell)
+	}
+	for _, cell := range report.ByDepartment {
+		writeCell("department", cell)
+	}
+	for _, cell := range report.ByCategory {
+		writeCell("category", cell)
+	}
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// ExportTrendReportPDF renders the trend report as a PDF, marking withheld figures as in the CSV.
+// Returns ErrPDFUnsupportedText when a department or category name cannot be shown, such as an
+// Arabic one, rather than a PDF with "?" in its place.
+func (s *HRProblemService) ExportTrendReportPDF(report *HRTrendReport) ([]byte, error) {
+	doc := newPDFDocument()
+	doc.heading("HR Case Trend Report", 16)
+	doc.paragraph(fmt.Sprintf("Period: %s to %s", report.From.Format("2006-01-02"), report.To.Format("2006-01-02")))
+	doc.paragraph(fmt.Sprintf("Generated: %s", report.GeneratedAt.In(s.workSchedule.Location()).Format("2006-01-02 15:04")))
+	doc.paragraph(fmt.Sprintf("Figures below %d are withheld (shown as <%d or suppressed) to protect reporter identity.",
+		report.SuppressionThreshold, report.SuppressionThreshold))
+	doc.paragraph("Resolution times are in working hours.")
+
+	widths := []float64{70, 155, 50, 60, 60, 100}
+	header := []string{"Month", "Group", "Cases", "Repeats", "Resolved", "Mean resolution (h)"}
+
+	section := func(title string, cells []HRTrendCell, showGroup bool) {
+		doc.space(12)
+		doc.heading(title, 12)
+		doc.tableRow(header, widths, true)
+		if len(cells) == 0 {
+			doc.paragraph("No cases reported.")
+		}
+		for _, cell := range cells {
+			group := cell.Group
+			if !showGroup {
+				group = "All"
+			}
+			month := cell.Month
+			if month == "" {
+				month = "Total"
+			}
+			doc.tableRow(append([]string{month, group}, trendCellFigures(cell, report.SuppressionThreshold)...), widths, false)
+		}
+	}
+
+	section("Overall", []HRTrendCell{report.Overall}, false)
+	section("Cases per month", report.Monthly, false)
+	section("Cases by department", report.ByDepartment, true)
+	section("Cases by category", report.ByCategory, true)
+
+	if doc.lossy {
+		return nil, ErrPDFUnsupportedText
+	}
+	return doc.bytes(), nil
+}
+
+// suppressTrendGroups turns buckets into cells ordered by month then group, applying
+// complementary suppression within each month
+func suppressTrendGroups(buckets map[string]*hrTrendBucket, months []string) []HRTrendCell {
+	byMonth := make(map[string][]*hrTrendBucket)
+	for _, bucket := range buckets {
+		byMonth[bucket.month] = append(byMonth[bucket.month], bucket)
+	}
+
+	var cells []HRTrendCell
+	for _, month := range months {
+		group := byMonth[month]
+		sort.Slice(group, func(i, j int) bool { return group[i].group < group[j].group })
+
+		monthCells := make([]HRTrendCell, len(group))
+		for i, bucket := range group {
+			monthCells[i] = suppressTrendBucket(bucket)
+		}
+		complementTrendCells(monthCells, nil)
+
+		cells = append(cells, monthCells...)
+	}
+	return cells
+}
+
+// trendCellCounts are the counts of a cell that add up to the figures of their total
+var trendCellCounts = []func(cell *HRTrendCell) **int{
+	func(cell *HRTrendCell) **int { return &cell.Cases },
+	func(cell *HRTrendCell) **int { return &cell.RepeatCases },
+	func(cell *HRTrendCell) **int { return &cell.ResolvedCases },
+}
+
+// complementTrendCells applies complementary suppression to cells that add up to a total. A count
+// withheld in a single cell could be derived from the total, so the smallest visible count of
+// another cell is withheld as well, or the total's count when no other cell shows it. Cases are
+// handled first, as withholding them withholds the whole cell.
+func complementTrendCells(cells []HRTrendCell, total *HRTrendCell) {
+	for _, count := range trendCellCounts {
+		withheld := 0
+		for i := range cells {
+			if cells[i].Suppressed || *count(&cells[i]) == nil {
+				withheld++
+			}
+		}
+		if withheld != 1 {
+			continue
+		}
+
+		// Prefer a non-zero count: withholding a zero hides nothing
+		smallest := -1
+		for i := range cells {
+			value := *count(&cells[i])
+			if cells[i].Suppressed || value == nil {
+				continue
+			}
+			if smallest < 0 {
+				smallest = i
+				continue
+			}
+			current := **count(&cells[smallest])
+			if (current == 0 && *value > 0) || (*value > 0 && *value < current) {
+				smallest = i
+			}
+		}
+		switch {
+		case smallest >= 0:
+			withholdTrendCount(&cells[smallest], count)
+		case total != nil && !total.Suppressed:
+			withholdTrendCount(total, count)
+		}
+	}
+}
+
+// withholdTrendCount withholds one count of a cell, and the whole cell for its cases
+func withholdTrendCount(cell *HRTrendCell, count func(cell *HRTrendCell) **int) {
+	if count(cell) == &cell.Cases {
+		*cell = HRTrendCell{Month: cell.Month, Group: cell.Group, Suppressed: true}
+		return
+	}
+	*count(cell) = nil
+	if count(cell) == &cell.ResolvedCases {
+		cell.MeanResolutionHours = nil
+	}
+}
+
+// suppressTrendBucket converts a bucket to a cell, withholding every figure below the threshold
+func suppressTrendBucket(bucket *hrTrendBucket) HRTrendCell {
+	cell := HRTrendCell{Month: bucket.month, Group: bucket.group}
+	if bucket.cases > 0 && bucket.cases < HRReportSuppressionThreshold {
+		cell.Suppressed = true
+		return cell
+	}
+
+	visible := func(count int) *int {
+		if count > 0 && count < HRReportSuppressionThreshold {
+			return nil
+		}
+		return &count
+	}
+
+	cell.Cases = visible(bucket.cases)
+	cell.RepeatCases = visible(bucket.repeats)
+	cell.ResolvedCases = visible(bucket.resolved)
+	if cell.ResolvedCases != nil && bucket.resolved > 0 {
+		mean := bucket.resolutionHours / float64(bucket.resolved)
+		cell.MeanResolutionHours = &mean
+	}
+	return cell
+}
+
+// trendCellFigures formats a cell's figures for export
+func trendCellFigures(cell HRTrendCell, threshold int) []string {
+	if cell.Suppressed {
+		return []string{"suppressed", "suppressed", "suppressed", "suppressed"}
+	}
+
+	withheld := fmt.Sprintf("<%d", threshold)
+	count := func(value *int) string {
+		if value == nil {
+			return withheld
+		}
+		return strconv.Itoa(*value)
+	}
+
+	hours := ""
+	if cell.MeanResolutionHours != nil {
+		hours = strconv.FormatFloat(*cell.MeanResolutionHours, 'f', 1, 64)
+	} else if cell.ResolvedCases == nil {
+		hours = withheld
+	}
+
+	return []string{count(cell.Cases), count(cell.RepeatCases), count(cell.ResolvedCases), hours}
+}
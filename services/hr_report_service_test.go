@@ -0,0 +1,169 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// trendFigures formats cells the way the CSV export does, one "group: figures" entry per cell
+func trendFigures(cells []HRTrendCell) []string {
+	figures := make([]string, len(cells))
+	for i, cell := range cells {
+		figures[i] = cell.Month + " " + cell.Group + ": " + strings.Join(trendCellFigures(cell, HRReportSuppressionThreshold), ",")
+	}
+	return figures
+}
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func TestSuppressTrendGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		buckets []hrTrendBucket
+		months  []string
+		want    []string
+	}{
+		{
+			name: "all figures at or above the threshold are shown",
+			buckets: []hrTrendBucket{
+				{month: "2026-01", group: "Sales", cases: 8, repeats: 5, resolved: 6, resolutionHours: 30},
+				{month: "2026-01", group: "Finance", cases: 5, repeats: 0, resolved: 5, resolutionHours: 10},
+			},
+			months: []string{"2026-01"},
+			want: []string{
+				"2026-01 Finance: 5,0,5,2.0",
+				"2026-01 Sales: 8,5,6,5.0",
+			},
+		},
+		{
+			name: "a single small group also withholds the next smallest group",
+			buckets: []hrTrendBucket{
+				{month: "2026-01", group: "Finance", cases: 3},
+				{month: "2026-01", group: "IT", cases: 7},
+				{month: "2026-01", group: "Sales", cases: 10},
+			},
+			months: []string{"2026-01"},
+			want: []string{
+				"2026-01 Finance: suppressed,suppressed,suppressed,suppressed",
+				"2026-01 IT: suppressed,suppressed,suppressed,suppressed",
+				"2026-01 Sales: 10,0,0,",
+			},
+		},
+		{
+			name: "two small groups need no complementary suppression",
+			buckets: []hrTrendBucket{
+				{month: "2026-01", group: "Finance", cases: 2},
+				{month: "2026-01", group: "IT", cases: 4},
+				{month: "2026-01", group: "Sales", cases: 6},
+			},
+			months: []string{"2026-01"},
+			want: []string{
+				"2026-01 Finance: suppressed,suppressed,suppressed,suppressed",
+				"2026-01 IT: suppressed,suppressed,suppressed,suppressed",
+				"2026-01 Sales: 6,0,0,",
+			},
+		},
+		{
+			name: "the complementary count skips zeros and withholds the mean with resolved cases",
+			buckets: []hrTrendBucket{
+				{month: "2026-01", group: "Finance", cases: 10, repeats: 2, resolved: 9, resolutionHours: 18},
+				{month: "2026-01", group: "IT", cases: 6, repeats: 0, resolved: 6, resolutionHours: 6},
+				{month: "2026-01", group: "Sales", cases: 9, repeats: 7, resolved: 3, resolutionHours: 12},
+			},
+			months: []string{"2026-01"},
+			want: []string{
+				"2026-01 Finance: 10,<5,9,2.0",
+				"2026-01 IT: 6,0,<5,<5",
+				"2026-01 Sales: 9,<5,<5,<5",
+			},
+		},
+		{
+			name: "months are settled separately and listed in the given order",
+			buckets: []hrTrendBucket{
+				{month: "2026-02", group: "Sales", cases: 3},
+				{month: "2026-02", group: "IT", cases: 5},
+				{month: "2026-01", group: "Sales", cases: 5},
+			},
+			months: []string{"2026-01", "2026-02"},
+			want: []string{
+				"2026-01 Sales: 5,0,0,",
+				"2026-02 IT: suppressed,suppressed,suppressed,suppressed",
+				"2026-02 Sales: suppressed,suppressed,suppressed,suppressed",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buckets := make(map[string]*hrTrendBucket)
+			for i := range tt.buckets {
+				bucket := tt.buckets[i]
+				buckets[bucket.month+"|"+bucket.group] = &bucket
+			}
+
+			got := trendFigures(suppressTrendGroups(buckets, tt.months))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("suppressTrendGroups() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestComplementTrendCellsWithholdsFromTotal(t *testing.T) {
+	tests := []struct {
+		name      string
+		cells     []HRTrendCell
+		total     HRTrendCell
+		wantCells []string
+		wantTotal string
+	}{
+		{
+			name:      "a single withheld month withholds the period total instead",
+			cells:     []HRTrendCell{{Month: "2026-01", Cases: intPtr(12), RepeatCases: nil, ResolvedCases: intPtr(0)}},
+			total:     HRTrendCell{Cases: intPtr(12), RepeatCases: intPtr(3), ResolvedCases: intPtr(0)},
+			wantCells: []string{"2026-01 : 12,<5,0,"},
+			wantTotal: " : 12,<5,0,",
+		},
+		{
+			name: "a zero count of another month is withheld before the period total",
+			cells: []HRTrendCell{
+				{Month: "2026-01", Suppressed: true},
+				{Month: "2026-02", Cases: intPtr(0), RepeatCases: intPtr(0), ResolvedCases: intPtr(0)},
+			},
+			total: HRTrendCell{Cases: intPtr(4), RepeatCases: intPtr(0), ResolvedCases: intPtr(0)},
+			wantCells: []string{
+				"2026-01 : suppressed,suppressed,suppressed,suppressed",
+				"2026-02 : suppressed,suppressed,suppressed,suppressed",
+			},
+			wantTotal: " : 4,0,0,",
+		},
+		{
+			name: "nothing is withheld when every count is visible",
+			cells: []HRTrendCell{
+				{Month: "2026-01", Cases: intPtr(6), RepeatCases: intPtr(0), ResolvedCases: intPtr(6)},
+			},
+			total:     HRTrendCell{Cases: intPtr(6), RepeatCases: intPtr(0), ResolvedCases: intPtr(6)},
+			wantCells: []string{"2026-01 : 6,0,6,"},
+			wantTotal: " : 6,0,6,",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cells := append([]HRTrendCell(nil), tt.cells...)
+			total := tt.total
+
+			complementTrendCells(cells, &total)
+
+			if got := trendFigures(cells); !reflect.DeepEqual(got, tt.wantCells) {
+				t.Errorf("cells = %v, want %v", got, tt.wantCells)
+			}
+			if got := trendFigures([]HRTrendCell{total})[0]; got != tt.wantTotal {
+				t.Errorf("total = %q, want %q", got, tt.wantTotal)
+			}
+		})
+	}
+}
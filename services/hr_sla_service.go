@@ -0,0 +1,444 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"project-x/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SLA clock states
+const (
+	SLAStateNotTracked = "not_tracked"
+	SLAStateOnTrack    = "on_track"
+	SLAStateAtRisk     = "at_risk"
+	SLAStateBreached   = "breached" // Still open and past the target
+	SLAStateMet        = "met"
+	SLAStateMissed     = "missed" // Completed, but after the target
+	SLAStateStopped    = "stopped"
+)
+
+// openProblemStatuses are the statuses for which SLA clocks keep running
+var openProblemStatuses = []models.ProblemStatus{
+	models.ProblemStatusPending,
+	models.ProblemStatusReviewing,
+	models.ProblemStatusInProgress,
+}
+
+// HRSLAClock describes progress against a single SLA target, measured in working hours
+type HRSLAClock struct {
+	TargetHours    float64    `json:"target_hours"`
+	DueAt          *time.Time `json:"due_at"`
+	CompletedAt    *time.Time `json:"completed_at"`
+	ElapsedHours   float64    `json:"elapsed_working_hours"`
+	RemainingHours float64    `json:"remaining_working_hours"`
+	State          string     `json:"state"`
+}
+
+// HRProblemSLA is the SLA status of a problem
+type HRProblemSLA struct {
+	FirstResponse HRSLAClock `json:"first_response"`
+	Resolution    HRSLAClock `json:"resolution"`
+}
+
+// GetSLAPolicies returns the policy for every priority, using defaults where none is configured
+func (s *HRProblemService) GetSLAPolicies() ([]models.HRSLAPolicy, error) {
+	var configured []models.HRSLAPolicy
+	if err := s.db.Preload("UpdatedBy").Find(&configured).Error; err != nil {
+		return nil, err
+	}
+
+	byPriority := make(map[models.ProblemPriority]models.HRSLAPolicy)
+	for _, policy := range configured {
+		byPriority[policy.Priority] = policy
+	}
+
+	defaults := models.GetDefaultSLAPolicies()
+	var policies []models.HRSLAPolicy
+	for _, priority := range []models.ProblemPriority{
+		models.ProblemPritorityCritical,
+		models.ProblemPriorityUrgent,
+		models.ProblemPriorityHigh,
+		models.ProblemPriorityMedium,
+		models.ProblemPriorityLow,
+	} {
+		if policy, exists := byPriority[priority]; exists {
+			policies = append(policies, policy)
+		} else {
+			policies = append(policies, defaults[priority])
+		}
+	}
+
+	return policies, nil
+}
+
+// UpdateSLAPolicy sets the SLA targets for a priority (Admin only). Existing problems keep
+// the targets they were reported with.
+func (s *HRProblemService) UpdateSLAPolicy(priority models.ProblemPriority, firstResponseHours, resolutionHours, warningThreshold float64, adminID uint) (*models.HRSLAPolicy, error) {
+	var admin models.User
+	if err := s.db.First(&admin, adminID).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return nil, errors.New("only admins can change SLA policies")
+	}
+
+	if _, exists := models.GetProblemPriorities()[priority]; !exists {
+		return nil, errors.New("invalid problem priority")
+	}
+	if firstResponseHours <= 0 || resolutionHours <= 0 {
+		return nil, errors.New("SLA targets must be greater than zero")
+	}
+	if resolutionHours < firstResponseHours {
+		return nil, errors.New("resolution target cannot be shorter than the first response target")
+	}
+	if warningThreshold == 0 {
+		warningThreshold = models.GetDefaultSLAPolicies()[priority].WarningThreshold
+	}
+	if warningThreshold <= 0 || warningThreshold >= 1 {
+		return nil, errors.New("warning threshold must be between 0 and 1")
+	}
+
+	var policy models.HRSLAPolicy
+	err := s.db.Where("priority = ?", priority).
+		Assign(models.HRSLAPolicy{
+			FirstResponseHours: firstResponseHours,
+			ResolutionHours:    resolutionHours,
+			WarningThreshold:   warningThreshold,
+			UpdatedByID:        &adminID,
+		}).
+		FirstOrCreate(&policy, models.HRSLAPolicy{Priority: priority}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// GetProblemSLA computes the current SLA status of a problem
+func (s *HRProblemService) GetProblemSLA(problem *models.HRProblem) HRProblemSLA {
+	now := time.Now()
+	isOpen := isOpenProblemStatus(problem.Status)
+	threshold := s.getSLAPolicy(problem.Priority).WarningThreshold
+
+	firstResponse := s.slaClock(problem.SLAFirstResponseHours, problem.SLAFirstResponseDueAt,
+		problem.FirstResponseAt, problem.SLAFirstResponseBreached, threshold, now)
+	if firstResponse.CompletedAt == nil && !isOpen {
+		firstResponse.State = SLAStateStopped
+	}
+
+	var resolvedAt *time.Time
+	if problem.Status == models.ProblemStatusResolved || problem.Status == models.ProblemStatusClosed {
+		resolvedAt = problem.ResolvedAt
+	}
+	resolution := s.slaClock(problem.SLAResolutionHours, problem.SLAResolutionDueAt,
+		resolvedAt, problem.SLAResolutionBreached, threshold, now)
+	if resolvedAt == nil && !isOpen {
+		resolution.State = SLAStateStopped
+	}
+
+	return HRProblemSLA{FirstResponse: firstResponse, Resolution: resolution}
+}
+
+// CheckSLAs warns assigned HR users about problems nearing their SLA targets and escalates
+// breaches to admins. Each warning and breach is only sent once per problem.
+func (s *HRProblemService) CheckSLAs() (warned int, breached int, err error) {
+	var problems []models.HRProblem
+	if err := s.db.Where("status IN ?", openProblemStatuses).Find(&problems).Error; err != nil {
+		return 0, 0, err
+	}
+
+	now := time.Now()
+	for i := range problems {
+		problem := &problems[i]
+		updates := make(map[string]interface{})
+
+		// Problems reported before SLA tracking existed get targets on first sight
+		if problem.SLAResolutionDueAt == nil {
+			s.applySLATargets(problem)
+			updates["sla_first_response_hours"] = problem.SLAFirstResponseHours
+			updates["sla_resolution_hours"] = problem.SLAResolutionHours
+			updates["sla_first_response_due_at"] = problem.SLAFirstResponseDueAt
+			updates["sla_resolution_due_at"] = problem.SLAResolutionDueAt
+		}
+
+		threshold := s.getSLAPolicy(problem.Priority).WarningThreshold
+
+		if problem.FirstResponseAt == nil && !problem.SLAFirstResponseBreached {
+			elapsed := s.elapsedSLAHours(problem.SLAFirstResponseHours, *problem.SLAFirstResponseDueAt, now)
+			if elapsed >= problem.SLAFirstResponseHours {
+				updates["sla_first_response_breached"] = true
+				updates["sla_first_response_warned"] = true
+				s.escalateSLABreach(problem, "first response", problem.SLAFirstResponseHours)
+				breached++
+			} else if !problem.SLAFirstResponseWarned && elapsed >= problem.SLAFirstResponseHours*threshold {
+				updates["sla_first_response_warned"] = true
+				s.warnSLA(problem, "first response", problem.SLAFirstResponseDueAt)
+				warned++
+			}
+		}
+
+		if !problem.SLAResolutionBreached {
+			elapsed := s.elapsedSLAHours(problem.SLAResolutionHours, *problem.SLAResolutionDueAt, now)
+			if elapsed >= problem.SLAResolutionHours {
+				updates["sla_resolution_breached"] = true
+				updates["sla_resolution_warned"] = true
+				s.escalateSLABreach(problem, "resolution", problem.SLAResolutionHours)
+				breached++
+			} else if !problem.SLAResolutionWarned && elapsed >= problem.SLAResolutionHours*threshold {
+				updates["sla_resolution_warned"] = true
+				s.warnSLA(problem, "resolution", problem.SLAResolutionDueAt)
+				warned++
+			}
+		}
+
+		if len(updates) > 0 {
+			if err := s.db.Model(problem).Updates(updates).Error; err != nil {
+				log.Printf("Failed to update SLA state for HR problem %d: %v", problem.ID, err)
+			}
+		}
+	}
+
+	return warned, breached, nil
+}
+
+// StartSLASweep periodically checks open problems against their SLA targets in the background
+func (s *HRProblemService) StartSLASweep(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			warned, breached, err := s.CheckSLAs()
+			if err != nil {
+				log.Printf("HR SLA sweep failed: %v", err)
+				continue
+			}
+			if warned > 0 || breached > 0 {
+				log.Printf("HR SLA sweep: %d warnings, %d breaches escalated", warned, breached)
+			}
+		}
+	}()
+}
+
+// getSLASummary returns SLA compliance figures for the statistics dashboard
+func (s *HRProblemService) getSLASummary() map[string]interface{} {
+	tracked := func() *gorm.DB {
+		return s.db.Model(&models.HRProblem{}).Where("sla_resolution_due_at IS NOT NULL")
+	}
+
+	var responseOnTime, responseBreached, responsePending int64
+	tracked().Where("first_response_at IS NOT NULL AND sla_first_response_breached = ?", false).Count(&responseOnTime)
+	tracked().Where("sla_first_response_breached = ?", true).Count(&responseBreached)
+	tracked().Where("first_response_at IS NULL AND sla_first_response_breached = ? AND status IN ?", false, openProblemStatuses).Count(&responsePending)
+
+	var resolutionOnTime, resolutionBreached, resolutionPending int64
+	tracked().Where("status IN ? AND sla_resolution_breached = ?", []models.ProblemStatus{models.ProblemStatusResolved, models.ProblemStatusClosed}, false).Count(&resolutionOnTime)
+	tracked().Where("sla_resolution_breached = ?", true).Count(&resolutionBreached)
+	tracked().Where("status IN ? AND sla_resolution_breached = ?", openProblemStatuses, false).Count(&resolutionPending)
+
+	var openBreached int64
+	tracked().Where("status IN ?", openProblemStatuses).
+		Where("sla_first_response_breached = ? OR sla_resolution_breached = ?", true, true).
+		Count(&openBreached)
+
+	return map[string]interface{}{
+		"first_response": map[string]interface{}{
+			"on_time":         responseOnTime,
+			"breached":        responseBreached,
+			"pending":         responsePending,
+			"compliance_rate": complianceRate(responseOnTime, responseBreached),
+		},
+		"resolution": map[string]interface{}{
+			"on_time":         resolutionOnTime,
+			"breached":        resolutionBreached,
+			"pending":         resolutionPending,
+			"compliance_rate": complianceRate(resolutionOnTime, resolutionBreached),
+		},
+		"open_breached": openBreached,
+	}
+}
+
+// getSLAPolicy returns the configured policy for a priority, or the default one
+func (s *HRProblemService) getSLAPolicy(priority models.ProblemPriority) models.HRSLAPolicy {
+	var policy models.HRSLAPolicy
+	if err := s.db.Where("priority = ?", priority).First(&policy).Error; err == nil {
+		return policy
+	}
+	if policy, exists := models.GetDefaultSLAPolicies()[priority]; exists {
+		return policy
+	}
+	return models.GetDefaultSLAPolicies()[models.ProblemPriorityMedium]
+}
+
+// applySLATargets snapshots the current policy onto a problem and computes its due dates
+func (s *HRProblemService) applySLATargets(problem *models.HRProblem) {
+	policy := s.getSLAPolicy(problem.Priority)
+
+	firstResponseDue := s.workSchedule.AddWorkingHours(problem.ReportedAt, policy.FirstResponseHours)
+	resolutionDue := s.workSchedule.AddWorkingHours(problem.ReportedAt, policy.ResolutionHours)
+
+	problem.SLAFirstResponseHours = policy.FirstResponseHours
+	problem.SLAResolutionHours = policy.ResolutionHours
+	problem.SLAFirstResponseDueAt = &firstResponseDue
+	problem.SLAResolutionDueAt = &resolutionDue
+}
+
+// recordFirstResponse stops the first response clock the first time HR acts on a problem
+func (s *HRProblemService) recordFirstResponse(problem *models.HRProblem, at time.Time) {
+	if problem.FirstResponseAt != nil {
+		return
+	}
+	problem.FirstResponseAt = &at
+	if problem.SLAFirstResponseDueAt != nil && at.After(*problem.SLAFirstResponseDueAt) {
+		problem.SLAFirstResponseBreached = true
+	}
+}
+
+// recordResolution stops the resolution clock when a problem is resolved or closed
+func (s *HRProblemService) recordResolution(problem *models.HRProblem, at time.Time) {
+	s.recordFirstResponse(problem, at)
+	if problem.SLAResolutionDueAt != nil && at.After(*problem.SLAResolutionDueAt) {
+		problem.SLAResolutionBreached = true
+	}
+}
+
+// slaClock builds the status of one SLA target
+func (s *HRProblemService) slaClock(targetHours float64, dueAt, completedAt *time.Time, breached bool, threshold float64, now time.Time) HRSLAClock {
+	clock := HRSLAClock{
+		TargetHours: targetHours,
+		DueAt:       dueAt,
+		CompletedAt: completedAt,
+	}
+	if targetHours == 0 || dueAt == nil {
+		clock.State = SLAStateNotTracked
+		return clock
+	}
+
+	end := now
+	if completedAt != nil {
+		end = *completedAt
+	}
+	clock.ElapsedHours = s.elapsedSLAHours(targetHours, *dueAt, end)
+	if clock.ElapsedHours < targetHours {
+		clock.RemainingHours = targetHours - clock.ElapsedHours
+	}
+
+	switch {
+	case completedAt != nil && (breached || completedAt.After(*dueAt)):
+		clock.State = SLAStateMissed
+	case completedAt != nil:
+		clock.State = SLAStateMet
+	case breached || clock.ElapsedHours >= targetHours:
+		clock.State = SLAStateBreached
+	case clock.ElapsedHours >= targetHours*threshold:
+		clock.State = SLAStateAtRisk
+	default:
+		clock.State = SLAStateOnTrack
+	}
+
+	return clock
+}
+
+// elapsedSLAHours measures working hours used against a target from its due date rather than
+// from the report date, so clocks restarted on reopening are measured correctly
+func (s *HRProblemService) elapsedSLAHours(targetHours float64, dueAt, at time.Time) float64 {
+	if at.Before(dueAt) {
+		return targetHours - s.workSchedule.WorkingHoursBetween(at, dueAt)
+	}
+	return targetHours + s.workSchedule.WorkingHoursBetween(dueAt, at)
+}
+
+// warnSLA tells the assigned HR user (or every HR user when unassigned) that a target is close
+func (s *HRProblemService) warnSLA(problem *models.HRProblem, target string, dueAt *time.Time) {
+	if s.notificationService == nil {
+		return
+	}
+
+	due := ""
+	if dueAt != nil {
+		due = dueAt.In(s.workSchedule.Location()).Format("2006-01-02 15:04")
+	}
+
+	for _, hrUser := range s.slaRecipients(problem) {
+		s.notificationService.CreateNotification(
+			hrUser.ID,
+			"HR Problem SLA Warning",
+			fmt.Sprintf("The %s target for \"%s\" is due at %s", target, problem.Title, due),
+			string(models.NotificationTypeHRSLAWarning),
+			map[string]interface{}{
+				"problem_id": problem.ID,
+				"priority":   problem.Priority,
+				"target":     target,
+				"due_at":     dueAt,
+			},
+		)
+	}
+}
+
+// escalateSLABreach records a breach on the problem's trail and notifies HR and every admin
+func (s *HRProblemService) escalateSLABreach(problem *models.HRProblem, target string, targetHours float64) {
+	s.createStatusUpdate(problem.ID, 0, "", "",
+		fmt.Sprintf("SLA breached: %s target of %.1f working hours exceeded, escalated to admins", target, targetHours), true)
+
+	if s.notificationService == nil {
+		return
+	}
+
+	recipients := s.slaRecipients(problem)
+	var admins []models.User
+	s.db.Where("role = ?", models.RoleAdmin).Find(&admins)
+	recipients = append(recipients, admins...)
+
+	notified := make(map[uint]bool)
+	for _, recipient := range recipients {
+		if notified[recipient.ID] {
+			continue
+		}
+		notified[recipient.ID] = true
+
+		s.notificationService.CreateNotification(
+			recipient.ID,
+			"HR Problem SLA Breached",
+			fmt.Sprintf("The %s target for %s problem \"%s\" has been breached", target, problem.Priority, problem.Title),
+			string(models.NotificationTypeHRSLABreached),
+			map[string]interface{}{
+				"problem_id":   problem.ID,
+				"priority":     problem.Priority,
+				"target":       target,
+				"target_hours": targetHours,
+				"assigned_hr":  problem.AssignedHRID,
+			},
+		)
+	}
+}
+
+// slaRecipients returns the assigned HR user, or every HR user if nobody is assigned yet
+func (s *HRProblemService) slaRecipients(problem *models.HRProblem) []models.User {
+	var recipients []models.User
+	if problem.AssignedHRID != nil {
+		s.db.Where("id = ?", *problem.AssignedHRID).Find(&recipients)
+	} else {
+		s.db.Where("role = ?", models.RoleHR).Find(&recipients)
+	}
+	return recipients
+}
+
+func isOpenProblemStatus(status models.ProblemStatus) bool {
+	for _, open := range openProblemStatuses {
+		if status == open {
+			return true
+		}
+	}
+	return false
+}
+
+func complianceRate(onTime, breached int64) float64 {
+	if onTime+breached == 0 {
+		return 100
+	}
+	return float64(onTime) / float64(onTime+breached) * 100
+}
@@ -0,0 +1,606 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"project-x/models"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+	"gorm.io/gorm"
+)
+
+// embeddingModelName is the model knowledge passages and questions are embedded with
+const embeddingModelName = "text-embedding-004"
+
+const (
+	// knowledgeChunkChars is the longest passage embedded as one chunk
+	knowledgeChunkChars = 1500
+	// knowledgeChatWindow is how many chat messages make up one passage at most
+	knowledgeChatWindow = 12
+	// knowledgeChatGap starts a new chat passage after a pause in the conversation
+	knowledgeChatGap = 6 * time.Hour
+	// knowledgeIndexBatch is how many sources of each kind one sweep indexes
+	knowledgeIndexBatch = 200
+	// knowledgeEmbedBatch is how many passages are embedded per API call
+	knowledgeEmbedBatch = 100
+	// knowledgeMaxDistance is the largest cosine distance a passage can have and still be used
+	knowledgeMaxDistance = 0.6
+)
+
+// hrPolicyCategories are the HR problem categories whose resolutions answer general policy
+// questions. Reports about people, such as harassment or conflicts, are never indexed.
+var hrPolicyCategories = []models.ProblemCategory{
+	models.ProblemCategoryPayroll,
+	models.ProblemCategoryBenefits,
+	models.ProblemCategoryEquipment,
+	models.ProblemCategoryTraining,
+	models.ProblemCategoryWorkEnvironment,
+}
+
+// KnowledgeService indexes tasks, projects, chat and HR policy answers into a pgvector table and
+// finds the passages relevant to a question that the asking user is allowed to see
+type KnowledgeService struct {
+	DB      *gorm.DB
+	client  *genai.Client
+	model   *genai.EmbeddingModel
+	enabled bool
+}
+
+// KnowledgeCitation is a passage given to the AI, numbered so its answer can cite it as [Ref]
+type KnowledgeCitation struct {
+	Ref          int                        `json:"ref"`
+	SourceType   models.KnowledgeSourceType `json:"source_type"`
+	SourceID     uint                       `json:"source_id"`
+	LastSourceID uint                       `json:"last_source_id,omitempty"`
+	Title        string                     `json:"title"`
+	Excerpt      string                     `json:"excerpt"`
+	ProjectID    *uint                      `json:"project_id,omitempty"`
+	ChatRoomID   *uint                      `json:"chat_room_id,omitempty"`
+	Date         time.Time                  `json:"date"`
+	Score        float64                    `json:"score"` // Cosine similarity, 1 is identical
+	Content      string                     `json:"-"`
+}
+
+// knowledgePassage is a piece of a source ready to be indexed
+type knowledgePassage struct {
+	Title      string
+	Content    string
+	OwnerID    *uint
+	ProjectID  *uint
+	ChatRoomID *uint
+	Date       time.Time
+	LastID     uint
+}
+
+// NewKnowledgeService creates the service and prepares its table. Without GEMINI_API_KEY or the
+// pgvector extension, indexing and search are turned off and the assistant answers without them.
+func NewKnowledgeService(db *gorm.DB) *KnowledgeService {
+	service := &KnowledgeService{DB: db}
+
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		log.Printf("Warning: GEMINI_API_KEY not set, AI knowledge search will be disabled")
+		return service
+	}
+
+	client, err := genai.NewClient(context.Background(), option.WithAPIKey(apiKey))
+	if err != nil {
+		log.Printf("Error creating Gemini client for knowledge search: %v", err)
+		return service
+	}
+	service.client = client
+	service.model = client.EmbeddingModel(embeddingModelName)
+
+	// The table is migrated here rather than with the others so a database without pgvector
+	// only loses knowledge search instead of failing to start
+	if err := service.migrate(); err != nil {
+		log.Printf("Warning: AI knowledge search disabled: %v", err)
+		return service
+	}
+	service.enabled = true
+	return service
+}
+
+func (s *KnowledgeService) migrate() error {
+	if err := s.DB.Exec("CREATE EXTENSION IF NOT EXISTS vector").Error; err != nil {
+		return fmt.Errorf("pgvector extension is not available: %v", err)
+	}
+	if err := s.DB.AutoMigrate(&models.KnowledgeChunk{}); err != nil {
+		return err
+	}
+	return s.DB.Exec("CREATE INDEX IF NOT EXISTS idx_knowledge_chunks_embedding ON knowledge_chunks USING hnsw (embedding vector_cosine_ops)").Error
+}
+
+// Enabled reports whether knowledge search is available
+func (s *KnowledgeService) Enabled() bool {
+	return s != nil && s.enabled
+}
+
+// StartIndexSweep indexes new and changed sources now and then periodically in the background
+func (s *KnowledgeService) StartIndexSweep(interval time.Duration) {
+	if !s.Enabled() {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			indexed, err := s.IndexPending(context.Background())
+			if err != nil {
+				log.Printf("Knowledge index sweep failed: %v", err)
+			} else if indexed > 0 {
+				log.Printf("Knowledge index sweep: %d sources indexed", indexed)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// IndexPending indexes the sources changed since the last sweep and drops passages whose source
+// was deleted. It returns how many sources were (re)indexed.
+func (s *KnowledgeService) IndexPending(ctx context.Context) (int, error) {
+	if !s.Enabled() {
+		return 0, errors.New("knowledge search is not available")
+	}
+
+	s.removeDeletedSources()
+
+	total := 0
+	for _, index := range []func(context.Context) (int, error){
+		s.indexTasks, s.indexProjects, s.indexHRAnswers, s.indexChat,
+	} {
+		indexed, err := index(ctx)
+		total += indexed
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// watermark is the last source update indexed for a source type
+func (s *KnowledgeService) watermark(sourceType models.KnowledgeSourceType) time.Time {
+	var latest sql.NullTime
+	s.DB.Model(&models.KnowledgeChunk{}).
+		Where("source_type = ?", sourceType).
+		Select("MAX(source_updated_at)").
+		Row().
+		Scan(&latest)
+	return latest.Time
+}
+
+func (s *KnowledgeService) indexTasks(ctx context.Context) (int, error) {
+	var tasks []models.Task
+	if err := s.DB.Preload("Project").Preload("User").
+		Where("updated_at > ?", s.watermark(models.KnowledgeSourceTask)).
+		Order("updated_at ASC").
+		Limit(knowledgeIndexBatch).
+		Find(&tasks).Error; err != nil {
+		return 0, err
+	}
+
+	for i, task := range tasks {
+		var header strings.Builder
+		header.WriteString(fmt.Sprintf("Task #%d: %s\nStatus: %s\nAssignee: %s\n", task.ID, task.Title, task.Status, task.User.Username))
+		if task.ProjectID != nil {
+			header.WriteString(fmt.Sprintf("Project: %s\n", task.Project.Title))
+		}
+		if task.DueDate != nil {
+			header.WriteString(fmt.Sprintf("Due: %s\n", task.DueDate.Format(aiDateFormat)))
+		}
+
+		ownerID := task.UserID
+		passages := splitKnowledgeText(header.String(), task.Description, knowledgePassage{
+			Title:     task.Title,
+			OwnerID:   &ownerID,
+			ProjectID: task.ProjectID,
+			Date:      task.CreatedAt,
+		})
+		if err := s.indexSource(ctx, models.KnowledgeSourceTask, task.ID, task.UpdatedAt, passages); err != nil {
+			return i, err
+		}
+	}
+	return len(tasks), nil
+}
+
+func (s *KnowledgeService) indexProjects(ctx context.Context) (int, error) {
+	var projects []models.Project
+	if err := s.DB.Where("updated_at > ?", s.watermark(models.KnowledgeSourceProject)).
+		Order("updated_at ASC").
+		Limit(knowledgeIndexBatch).
+		Find(&projects).Error; err != nil {
+		return 0, err
+	}
+
+	for i, project := range projects {
+		projectID := project.ID
+		header := fmt.Sprintf("Project: %s\nStatus: %s\n", project.Title, project.Status)
+		passages := splitKnowledgeText(header, project.Description, knowledgePassage{
+			Title:     project.Title,
+			ProjectID: &projectID,
+			Date:      project.StartDate,
+		})
+		if err := s.indexSource(ctx, models.KnowledgeSourceProject, project.ID, project.UpdatedAt, passages); err != nil {
+			return i, err
+		}
+	}
+	return len(projects), nil
+}
+
+// indexHRAnswers indexes the resolutions HR published as policy answers. Only HR's answer and the
+// category are used: the report itself, who filed it and its title stay out of the index.
+func (s *KnowledgeService) indexHRAnswers(ctx context.Context) (int, error) {
+	// Reports that never qualified leave no passage behind to move the watermark, so only those
+	// that qualify now or were indexed before are looked at
+	indexed := s.DB.Model(&models.KnowledgeChunk{}).Select("source_id").Where("source_type = ?", models.KnowledgeSourceHRAnswer)
+	var problems []models.HRProblem
+	if err := s.DB.Where("updated_at > ?", s.watermark(models.KnowledgeSourceHRAnswer)).
+		Where(s.DB.Where("category IN ? AND status IN ? AND resolution <> '' AND publish_as_policy_answer = ? AND is_anonymous = ?",
+			hrPolicyCategories, []models.ProblemStatus{models.ProblemStatusResolved, models.ProblemStatusClosed}, true, false).
+			Or("id IN (?)", indexed)).
+		Order("updated_at ASC").
+		Limit(knowledgeIndexBatch).
+		Find(&problems).Error; err != nil {
+		return 0, err
+	}
+
+	categories := models.GetProblemCategories()
+	for i, problem := range problems {
+		var passages []knowledgePassage
+		if isHRPolicyAnswer(&problem) {
+			title := "HR answer: " + categories[problem.Category]
+			date := problem.UpdatedAt
+			if problem.ResolvedAt != nil {
+				date = *problem.ResolvedAt
+			}
+			passages = splitKnowledgeText(title+"\n", problem.Resolution, knowledgePassage{Title: title, Date: date})
+		}
+		// A report that no longer qualifies loses its passages
+		if err := s.indexSource(ctx, models.KnowledgeSourceHRAnswer, problem.ID, problem.UpdatedAt, passages); err != nil {
+			return i, err
+		}
+	}
+	return len(problems), nil
+}
+
+func isHRPolicyAnswer(problem *models.HRProblem) bool {
+	// Only answers HR chose to share, and never those of anonymous reports, whose details could
+	// point back to the reporter
+	if !problem.PublishAsPolicyAnswer || problem.IsAnonymous {
+		return false
+	}
+	if problem.Status != models.ProblemStatusResolved && problem.Status != models.ProblemStatusClosed {
+		return false
+	}
+	if strings.TrimSpace(problem.Resolution) == "" {
+		return false
+	}
+	for _, category := range hrPolicyCategories {
+		if problem.Category == category {
+			return true
+		}
+	}
+	return false
+}
+
+// indexChat indexes new messages of team rooms as passages of consecutive messages. Private AI
+// assistant rooms and the AI's own answers are left out.
+func (s *KnowledgeService) indexChat(ctx context.Context) (int, error) {
+	var lastIndexed uint
+	s.DB.Model(&models.KnowledgeChunk{}).
+		Where("source_type = ?", models.KnowledgeSourceChat).
+		Select("COALESCE(MAX(last_source_id), 0)").
+		Scan(&lastIndexed)
+
+	var messages []models.ChatMessage
+	if err := s.DB.Preload("Sender").Preload("ChatRoom").
+		Joins("JOIN chat_rooms ON chat_rooms.id = chat_messages.chat_room_id").
+		Where("chat_messages.id > ? AND chat_rooms.name NOT LIKE ?", lastIndexed, "AI Assistant%").
+		Where("COALESCE(chat_messages.metadata, '') NOT LIKE ?", `%"ai_response":true%`).
+		Order("chat_messages.id ASC").
+		Limit(knowledgeIndexBatch * knowledgeChatWindow).
+		Find(&messages).Error; err != nil {
+		return 0, err
+	}
+
+	// Group each room's messages into passages
+	var order []uint
+	windows := map[uint][][]models.ChatMessage{}
+	for _, message := range messages {
+		roomWindows := windows[message.ChatRoomID]
+		if len(roomWindows) == 0 {
+			order = append(order, message.ChatRoomID)
+		}
+		last := len(roomWindows) - 1
+		if last < 0 || len(roomWindows[last]) == knowledgeChatWindow ||
+			message.CreatedAt.Sub(roomWindows[last][len(roomWindows[last])-1].CreatedAt) > knowledgeChatGap {
+			roomWindows = append(roomWindows, nil)
+			last++
+		}
+		roomWindows[last] = append(roomWindows[last], message)
+		windows[message.ChatRoomID] = roomWindows
+	}
+
+	indexed := 0
+	for _, roomID := range order {
+		for _, window := range windows[roomID] {
+			var content strings.Builder
+			for _, message := range window {
+				content.WriteString(fmt.Sprintf("[%s] %s: %s\n", message.CreatedAt.Format("2006-01-02 15:04"), message.Sender.Username, message.Content))
+			}
+
+			first, last := window[0], window[len(window)-1]
+			chatRoomID := roomID
+			passage := knowledgePassage{
+				Title:      first.ChatRoom.Name,
+				Content:    truncateRunes(content.String(), knowledgeChunkChars*2),
+				ChatRoomID: &chatRoomID,
+				Date:       first.CreatedAt,
+				LastID:     last.ID,
+			}
+			if err := s.indexSource(ctx, models.KnowledgeSourceChat, first.ID, last.CreatedAt, []knowledgePassage{passage}); err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+	}
+	return indexed, nil
+}
+
+// indexSource replaces the passages of a source. Passages whose content has not changed keep
+// their embeddings and only get their visibility and title refreshed.
+func (s *KnowledgeService) indexSource(ctx context.Context, sourceType models.KnowledgeSourceType, sourceID uint, updatedAt time.Time, passages []knowledgePassage) error {
+	var existing []models.KnowledgeChunk
+	s.DB.Select("id", "chunk_index", "content_hash").
+		Where("source_type = ? AND source_id = ?", sourceType, sourceID).
+		Order("chunk_index ASC").
+		Find(&existing)
+
+	hashes := make([]string, len(passages))
+	unchanged := len(existing) == len(passages) && len(passages) > 0
+	for i, passage := range passages {
+		hashes[i] = contentHash(passage.Content)
+		if unchanged && existing[i].ContentHash != hashes[i] {
+			unchanged = false
+		}
+	}
+
+	if unchanged {
+		for i, passage := range passages {
+			if err := s.DB.Model(&models.KnowledgeChunk{}).Where("id = ?", existing[i].ID).Updates(map[string]interface{}{
+				"title":             passage.Title,
+				"owner_id":          passage.OwnerID,
+				"project_id":        passage.ProjectID,
+				"chat_room_id":      passage.ChatRoomID,
+				"source_updated_at": updatedAt,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var chunks []models.KnowledgeChunk
+	for start := 0; start < len(passages); start += knowledgeEmbedBatch {
+		end := min(start+knowledgeEmbedBatch, len(passages))
+		texts := make([]string, 0, end-start)
+		for _, passage := range passages[start:end] {
+			texts = append(texts, passage.Content)
+		}
+
+		embeddings, err := NewAIUsageService(s.DB).Embed(ctx, s.documentModel(), AIFeatureKnowledgeIndex, nil, texts)
+		if err != nil {
+			return fmt.Errorf("failed to embed %s %d: %v", sourceType, sourceID, err)
+		}
+		for i, passage := range passages[start:end] {
+			chunks = append(chunks, models.KnowledgeChunk{
+				SourceType:      sourceType,
+				SourceID:        sourceID,
+				LastSourceID:    passage.LastID,
+				ChunkIndex:      start + i,
+				Title:           truncateRunes(passage.Title, 500),
+				Content:         passage.Content,
+				ContentHash:     hashes[start+i],
+				OwnerID:         passage.OwnerID,
+				ProjectID:       passage.ProjectID,
+				ChatRoomID:      passage.ChatRoomID,
+				SourceDate:      passage.Date,
+				SourceUpdatedAt: updatedAt,
+				Embedding:       vectorLiteral(embeddings[i]),
+			})
+		}
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("source_type = ? AND source_id = ?", sourceType, sourceID).Delete(&models.KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		return tx.Create(&chunks).Error
+	})
+}
+
+// removeDeletedSources drops passages whose task, project, report or first message is gone, and
+// HR answers that are no longer published
+func (s *KnowledgeService) removeDeletedSources() {
+	sources := map[models.KnowledgeSourceType]string{
+		models.KnowledgeSourceTask:     "tasks",
+		models.KnowledgeSourceProject:  "projects",
+		models.KnowledgeSourceHRAnswer: "hr_problems",
+		models.KnowledgeSourceChat:     "chat_messages",
+	}
+	for sourceType, table := range sources {
+		err := s.DB.Exec(fmt.Sprintf(`
+			DELETE FROM knowledge_chunks kc
+			WHERE kc.source_type = ? AND NOT EXISTS (
+				SELECT 1 FROM %s s WHERE s.id = kc.source_id AND s.deleted_at IS NULL
+			)`, table), sourceType).Error
+		if err != nil {
+			log.Printf("Failed to remove deleted %s from the knowledge index: %v", sourceType, err)
+		}
+	}
+
+	// HR answers indexed before they had to be published, or of anonymous reports, are dropped too
+	err := s.DB.Exec(`
+		DELETE FROM knowledge_chunks kc
+		WHERE kc.source_type = ? AND EXISTS (
+			SELECT 1 FROM hr_problems p WHERE p.id = kc.source_id AND (NOT p.publish_as_policy_answer OR p.is_anonymous)
+		)`, models.KnowledgeSourceHRAnswer).Error
+	if err != nil {
+		log.Printf("Failed to remove unpublished HR answers from the knowledge index: %v", err)
+	}
+}
+
+// Search returns the passages most relevant to the question among those the user may see:
+// tasks they are assigned or whose project they belong to, projects they belong to (all tasks
+// and projects for Admins and Managers), chat rooms they take part in, and HR policy answers.
+func (s *KnowledgeService) Search(ctx context.Context, userID uint, role models.Role, question string, limit int) ([]KnowledgeCitation, error) {
+	if !s.Enabled() || strings.TrimSpace(question) == "" {
+		return nil, nil
+	}
+
+	embeddings, err := NewAIUsageService(s.DB).Embed(ctx, s.queryModel(), AIFeatureKnowledgeSearch, &userID, []string{question})
+	if err != nil {
+		return nil, err
+	}
+	vector := vectorLiteral(embeddings[0])
+
+	seesAllProjects := role == models.RoleAdmin || role == models.RoleManager
+	memberProjects := s.DB.Model(&models.UserProject{}).Select("project_id").Where("user_id = ?", userID)
+	chatRooms := s.DB.Model(&models.ChatParticipant{}).Select("chat_room_id").Where("user_id = ? AND is_blocked = ?", userID, false)
+
+	var matches []struct {
+		models.KnowledgeChunk
+		Distance float64
+	}
+	err = s.DB.Model(&models.KnowledgeChunk{}).
+		Select("id, source_type, source_id, last_source_id, title, content, project_id, chat_room_id, source_date, embedding <=> ?::vector AS distance", vector).
+		Where(s.DB.Where("source_type = ?", models.KnowledgeSourceHRAnswer).
+			Or("source_type IN ? AND (? OR owner_id = ? OR project_id IN (?))",
+				[]models.KnowledgeSourceType{models.KnowledgeSourceTask, models.KnowledgeSourceProject}, seesAllProjects, userID, memberProjects).
+			Or("source_type = ? AND chat_room_id IN (?)", models.KnowledgeSourceChat, chatRooms)).
+		Where("embedding <=> ?::vector < ?", vector, knowledgeMaxDistance).
+		Order("distance ASC").
+		Limit(limit).
+		Find(&matches).Error
+	if err != nil {
+		return nil, err
+	}
+
+	citations := make([]KnowledgeCitation, len(matches))
+	for i, match := range matches {
+		citations[i] = KnowledgeCitation{
+			Ref:          i + 1,
+			SourceType:   match.SourceType,
+			SourceID:     match.SourceID,
+			LastSourceID: match.LastSourceID,
+			Title:        match.Title,
+			Excerpt:      truncateRunes(match.Content, 200),
+			ProjectID:    match.ProjectID,
+			ChatRoomID:   match.ChatRoomID,
+			Date:         match.SourceDate,
+			Score:        1 - match.Distance,
+			Content:      match.Content,
+		}
+	}
+	return citations, nil
+}
+
+// documentModel and queryModel embed for the two sides of retrieval, which the embedding model
+// handles differently
+func (s *KnowledgeService) documentModel() *genai.EmbeddingModel {
+	model := *s.model
+	model.TaskType = genai.TaskTypeRetrievalDocument
+	return &model
+}
+
+func (s *KnowledgeService) queryModel() *genai.EmbeddingModel {
+	model := *s.model
+	model.TaskType = genai.TaskTypeRetrievalQuery
+	return &model
+}
+
+// splitKnowledgeText turns a header and body into passages of at most knowledgeChunkChars,
+// breaking at paragraphs where possible. Every passage starts with the header so it can be
+// understood on its own.
+func splitKnowledgeText(header, body string, base knowledgePassage) []knowledgePassage {
+	body = strings.TrimSpace(body)
+	limit := max(knowledgeChunkChars-utf8.RuneCountInString(header), knowledgeChunkChars/2)
+
+	var pieces []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			pieces = append(pieces, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	for _, paragraph := range strings.Split(body, "\n\n") {
+		for utf8.RuneCountInString(paragraph) > limit {
+			flush()
+			runes := []rune(paragraph)
+			pieces = append(pieces, string(runes[:limit]))
+			paragraph = string(runes[limit:])
+		}
+		if utf8.RuneCountInString(current.String())+utf8.RuneCountInString(paragraph) > limit {
+			flush()
+		}
+		current.WriteString(paragraph + "\n\n")
+	}
+	flush()
+
+	if len(pieces) == 0 {
+		pieces = []string{""}
+	}
+	passages := make([]knowledgePassage, len(pieces))
+	for i, piece := range pieces {
+		passage := base
+		passage.Content = strings.TrimSpace(header + "\n" + piece)
+		passages[i] = passage
+	}
+	return passages
+}
+
+func vectorLiteral(values []float32) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = strconv.FormatFloat(float64(value), 'f', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
+
+// Close closes the AI client
+func (s *KnowledgeService) Close() error {
+	if s.client != nil {
+		return s.client.Close()
+	}
+	return nil
+}
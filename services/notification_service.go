@@ -496,6 +496,9 @@ func (ns *NotificationService) shouldSendNotification(userID uint, notificationT
 			EmailNotifications: false,
 			PushNotifications:  true,
 			InAppNotifications: true,
+			DailyStandup:       true,
+			WeeklyDigest:       true,
+			DigestDelivery:     models.AIDigestDeliveryNotification,
 		}
 		ns.db.Create(&preference)
 		return true
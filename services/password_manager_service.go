@@ -1,27 +1,42 @@
 package services
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"log"
 	"project-x/models"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// MaxCredentialAccessHours caps how long a just-in-time access grant can last
+const MaxCredentialAccessHours = 72
+
+// DefaultStalePasswordDays is how old a password can be before the health report flags it
+const DefaultStalePasswordDays = 90
+
 type PasswordManagerService struct {
-	DB         *gorm.DB
-	Encryption *EncryptionService
+	DB                  *gorm.DB
+	Encryption          *EncryptionService
+	Policy              *PasswordPolicyService
+	notificationService *NotificationService
 }
 
-func NewPasswordManagerService(db *gorm.DB) (*PasswordManagerService, error) {
+func NewPasswordManagerService(db *gorm.DB, notificationService *NotificationService) (*PasswordManagerService, error) {
 	encryption, err := NewEncryptionService()
 	if err != nil {
 		return nil, err
 	}
 
 	return &PasswordManagerService{
-		DB:         db,
-		Encryption: encryption,
+		DB:                  db,
+		Encryption:          encryption,
+		Policy:              NewPasswordPolicyService(),
+		notificationService: notificationService,
 	}, nil
 }
 
@@ -36,6 +51,12 @@ func (s *PasswordManagerService) CreateCredential(adminID uint, platform, email,
 		return nil, errors.New("only admin can create credentials")
 	}
 
+	// Screen the password before storing it
+	if breached, _ := s.Policy.CheckBreached(password); breached {
+		return nil, errors.New("password appears in a known data breach; choose a different password")
+	}
+	strength := s.Policy.EvaluatePasswordStrength(password, platform, email, username)
+
 	// Encrypt sensitive data
 	encryptedEmail, err := s.Encryption.Encrypt(email)
 	if err != nil {
@@ -56,15 +77,18 @@ func (s *PasswordManagerService) CreateCredential(adminID uint, platform, email,
 	}
 
 	// Create credential
+	now := time.Now()
 	credential := &models.Credential{
-		Platform:    platform,
-		Email:       encryptedEmail,
-		Username:    encryptedUsername,
-		Password:    encryptedPassword,
-		URL:         url,
-		Notes:       notes,
-		CreatedByID: adminID,
-		IsActive:    true,
+		Platform:          platform,
+		Email:             encryptedEmail,
+		Username:          encryptedUsername,
+		Password:          encryptedPassword,
+		URL:               url,
+		Notes:             notes,
+		CreatedByID:       adminID,
+		IsActive:          true,
+		PasswordScore:     strength.Score,
+		PasswordChangedAt: &now,
 	}
 
 	if err := s.DB.Create(credential).Error; err != nil {
@@ -91,6 +115,21 @@ func (s *PasswordManagerService) UpdateCredential(credentialID, adminID uint, up
 		return errors.New("credential not found")
 	}
 
+	// Judge a new password against the platform, email and username it will be stored with,
+	// as CreateCredential does
+	platform, _ := updates["platform"].(string)
+	if platform == "" {
+		platform = credential.Platform
+	}
+	email, ok := updates["email"].(string)
+	if !ok {
+		email, _ = s.Encryption.Decrypt(credential.Email)
+	}
+	username, ok := updates["username"].(string)
+	if !ok {
+		username, _ = s.Encryption.Decrypt(credential.Username)
+	}
+
 	// Encrypt sensitive fields if they're being updated
 	if email, ok := updates["email"].(string); ok && email != "" {
 		encryptedEmail, err := s.Encryption.Encrypt(email)
@@ -101,11 +140,18 @@ func (s *PasswordManagerService) UpdateCredential(credentialID, adminID uint, up
 	}
 
 	if password, ok := updates["password"].(string); ok && password != "" {
+		if breached, _ := s.Policy.CheckBreached(password); breached {
+			return errors.New("password appears in a known data breach; choose a different password")
+		}
+		strength := s.Policy.EvaluatePasswordStrength(password, platform, email, username)
+
 		encryptedPassword, err := s.Encryption.Encrypt(password)
 		if err != nil {
 			return errors.New("failed to encrypt password")
 		}
 		updates["password"] = encryptedPassword
+		updates["password_score"] = strength.Score
+		updates["password_changed_at"] = time.Now()
 	}
 
 	if username, ok := updates["username"].(string); ok {
@@ -145,8 +191,9 @@ func (s *PasswordManagerService) DeleteCredential(credentialID, adminID uint) er
 	return s.DB.Delete(&credential).Error
 }
 
-// ShareCredential shares a credential with users (Admin only)
-func (s *PasswordManagerService) ShareCredential(credentialID, adminID uint, userIDs []uint, canView, canCopy bool, notes string) error {
+// ShareCredential shares a credential with users (Admin only).
+// A nil expiresAt creates a permanent share.
+func (s *PasswordManagerService) ShareCredential(credentialID, adminID uint, userIDs []uint, canView, canCopy bool, notes string, expiresAt *time.Time) error {
 	// Verify admin role
 	var admin models.User
 	if err := s.DB.First(&admin, adminID).Error; err != nil {
@@ -162,6 +209,10 @@ func (s *PasswordManagerService) ShareCredential(credentialID, adminID uint, use
 		return errors.New("credential not found")
 	}
 
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return errors.New("expiration must be in the future")
+	}
+
 	// Share with each user
 	for _, userID := range userIDs {
 		// Check if user exists
@@ -180,6 +231,8 @@ func (s *PasswordManagerService) ShareCredential(credentialID, adminID uint, use
 			existingShare.Notes = notes
 			existingShare.SharedByID = adminID
 			existingShare.SharedAt = time.Now()
+			existingShare.ExpiresAt = expiresAt
+			existingShare.RequestID = nil
 			s.DB.Save(&existingShare)
 		} else {
 			// Create new share
@@ -191,6 +244,7 @@ func (s *PasswordManagerService) ShareCredential(credentialID, adminID uint, use
 				CanView:      canView,
 				CanCopy:      canCopy,
 				Notes:        notes,
+				ExpiresAt:    expiresAt,
 			}
 			s.DB.Create(share)
 		}
@@ -214,14 +268,24 @@ func (s *PasswordManagerService) UnshareCredential(credentialID, userID, adminID
 	return s.DB.Where("credential_id = ? AND user_id = ?", credentialID, userID).Delete(&models.CredentialShare{}).Error
 }
 
-// GetMyCredentials returns credentials shared with the current user
+// GetMyCredentials returns credentials shared with the current user, directly or through a vault
 func (s *PasswordManagerService) GetMyCredentials(userID uint) ([]models.Credential, error) {
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
 	var credentials []models.Credential
 
-	// Get credentials shared with this user
+	// Credentials with an unexpired direct share
+	directShares := s.DB.Model(&models.CredentialShare{}).
+		Select("credential_id").
+		Where("user_id = ?", userID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
+
 	err := s.DB.
-		Joins("JOIN credential_shares ON credentials.id = credential_shares.credential_id").
-		Where("credential_shares.user_id = ? AND credentials.is_active = ?", userID, true).
+		Where("is_active = ?", true).
+		Where("id IN (?) OR id IN (?)", directShares, s.vaultCredentialIDsFor(&user, "can_view")).
 		Preload("CreatedBy").
 		Preload("SharedWith").
 		Find(&credentials).Error
@@ -237,17 +301,8 @@ func (s *PasswordManagerService) GetCredentialDetails(credentialID, userID uint,
 		return nil, errors.New("credential not found")
 	}
 
-	// Check if user has access
-	var hasAccess bool
-	if credential.CreatedByID == userID {
-		hasAccess = true // Creator always has access
-	} else {
-		// Check if shared with user
-		var share models.CredentialShare
-		err := s.DB.Where("credential_id = ? AND user_id = ?", credentialID, userID).First(&share).Error
-		hasAccess = (err == nil && share.CanView)
-	}
-
+	// Check if user has access (creator, direct share or vault grant)
+	hasAccess, _ := s.resolvePermissions(&credential, userID)
 	if !hasAccess {
 		return nil, errors.New("access denied")
 	}
@@ -304,6 +359,7 @@ func (s *PasswordManagerService) GetCredentialDetails(credentialID, userID uint,
 		CreatedAt:      credential.CreatedAt,
 		LastAccessedAt: &now,
 		IsActive:       credential.IsActive,
+		HasTOTP:        credential.TOTPSecret != "",
 		SharedWith:     sharedUserIDs,
 	}, nil
 }
@@ -316,15 +372,7 @@ func (s *PasswordManagerService) CopyPassword(credentialID, userID uint, ipAddre
 		return errors.New("credential not found")
 	}
 
-	var hasCopyPermission bool
-	if credential.CreatedByID == userID {
-		hasCopyPermission = true // Creator always has permission
-	} else {
-		var share models.CredentialShare
-		err := s.DB.Where("credential_id = ? AND user_id = ?", credentialID, userID).First(&share).Error
-		hasCopyPermission = (err == nil && share.CanCopy)
-	}
-
+	_, hasCopyPermission := s.resolvePermissions(&credential, userID)
 	if !hasCopyPermission {
 		return errors.New("copy permission denied")
 	}
@@ -382,3 +430,584 @@ func (s *PasswordManagerService) GetAccessLogs(credentialID, adminID uint) ([]mo
 
 	return logs, err
 }
+
+// findActiveShare returns the user's share for a credential if it exists and has not expired
+func (s *PasswordManagerService) findActiveShare(credentialID, userID uint) (*models.CredentialShare, error) {
+	var share models.CredentialShare
+	err := s.DB.
+		Where("credential_id = ? AND user_id = ?", credentialID, userID).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		First(&share).Error
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// GetRequestableCredentials lists active credentials (without secrets) that a user can request access to
+func (s *PasswordManagerService) GetRequestableCredentials() ([]models.Credential, error) {
+	var credentials []models.Credential
+	err := s.DB.
+		Select("id", "platform", "url", "created_at").
+		Where("is_active = ?", true).
+		Order("platform ASC").
+		Find(&credentials).Error
+
+	return credentials, err
+}
+
+// RequestAccess creates a just-in-time access request for a credential
+func (s *PasswordManagerService) RequestAccess(credentialID, userID uint, hours int, reason string) (*models.CredentialAccessRequest, error) {
+	if hours <= 0 || hours > MaxCredentialAccessHours {
+		return nil, fmt.Errorf("requested hours must be between 1 and %d", MaxCredentialAccessHours)
+	}
+
+	var credential models.Credential
+	if err := s.DB.First(&credential, credentialID).Error; err != nil {
+		return nil, errors.New("credential not found")
+	}
+	if !credential.IsActive {
+		return nil, errors.New("credential is not active")
+	}
+
+	// No need to request access the user already has
+	if canView, _ := s.resolvePermissions(&credential, userID); canView {
+		return nil, errors.New("you already have access to this credential")
+	}
+
+	// Only one pending request per user and credential
+	var pendingCount int64
+	s.DB.Model(&models.CredentialAccessRequest{}).
+		Where("credential_id = ? AND requester_id = ? AND status = ?", credentialID, userID, models.CredentialAccessRequestPending).
+		Count(&pendingCount)
+	if pendingCount > 0 {
+		return nil, errors.New("an access request for this credential is already pending")
+	}
+
+	request := &models.CredentialAccessRequest{
+		CredentialID:   credentialID,
+		RequesterID:    userID,
+		Reason:         reason,
+		RequestedHours: hours,
+		Status:         models.CredentialAccessRequestPending,
+	}
+
+	if err := s.DB.Create(request).Error; err != nil {
+		return nil, err
+	}
+
+	s.DB.Preload("Requester").Preload("Credential").First(request, request.ID)
+
+	// Notify all admins
+	s.notifyAdminsOfAccessRequest(request)
+
+	return request, nil
+}
+
+// GetMyAccessRequests returns access requests made by a user
+func (s *PasswordManagerService) GetMyAccessRequests(userID uint) ([]models.CredentialAccessRequest, error) {
+	var requests []models.CredentialAccessRequest
+	err := s.DB.
+		Where("requester_id = ?", userID).
+		Preload("Credential").
+		Preload("ReviewedBy").
+		Order("created_at DESC").
+		Find(&requests).Error
+
+	return requests, err
+}
+
+// GetAccessRequests returns access requests, optionally filtered by status (Admin only)
+func (s *PasswordManagerService) GetAccessRequests(adminID uint, status string) ([]models.CredentialAccessRequest, error) {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return nil, errors.New("only admin can view access requests")
+	}
+
+	query := s.DB.Preload("Credential").Preload("Requester").Preload("ReviewedBy")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var requests []models.CredentialAccessRequest
+	err := query.Order("created_at DESC").Find(&requests).Error
+
+	return requests, err
+}
+
+// ApproveAccessRequest grants a time-limited share for a pending request (Admin only).
+// If hours is 0 the requested duration is used.
+func (s *PasswordManagerService) ApproveAccessRequest(requestID, adminID uint, hours int, canCopy bool, note string) (*models.CredentialAccessRequest, error) {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return nil, errors.New("only admin can approve access requests")
+	}
+
+	// Check and approve the request under a row lock, so two admins reviewing it at the same
+	// moment cannot both grant (or one grant and one deny) it
+	var request models.CredentialAccessRequest
+	var expiresAt time.Time
+	now := time.Now()
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, requestID).Error; err != nil {
+			return errors.New("access request not found")
+		}
+		if request.Status != models.CredentialAccessRequestPending {
+			return errors.New("access request is not pending")
+		}
+
+		if hours == 0 {
+			hours = request.RequestedHours
+		}
+		if hours <= 0 || hours > MaxCredentialAccessHours {
+			return fmt.Errorf("approved hours must be between 1 and %d", MaxCredentialAccessHours)
+		}
+		expiresAt = now.Add(time.Duration(hours) * time.Hour)
+
+		request.Status = models.CredentialAccessRequestApproved
+		request.ReviewedByID = &adminID
+		request.ReviewedAt = &now
+		request.ReviewNote = note
+		request.ExpiresAt = &expiresAt
+		if err := tx.Save(&request).Error; err != nil {
+			return err
+		}
+
+		// Replace any existing share with the time-limited grant
+		var share models.CredentialShare
+		err := tx.Where("credential_id = ? AND user_id = ?", request.CredentialID, request.RequesterID).First(&share).Error
+		if err == nil {
+			share.CanView = true
+			share.CanCopy = canCopy
+			share.SharedByID = adminID
+			share.SharedAt = now
+			share.ExpiresAt = &expiresAt
+			share.RequestID = &request.ID
+			share.Notes = note
+			return tx.Save(&share).Error
+		}
+
+		return tx.Create(&models.CredentialShare{
+			CredentialID: request.CredentialID,
+			UserID:       request.RequesterID,
+			SharedByID:   adminID,
+			SharedAt:     now,
+			CanView:      true,
+			CanCopy:      canCopy,
+			Notes:        note,
+			ExpiresAt:    &expiresAt,
+			RequestID:    &request.ID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.DB.First(&request.Credential, request.CredentialID)
+
+	s.notifyUser(
+		request.RequesterID,
+		"Credential Access Approved",
+		fmt.Sprintf("Your access to %s was approved until %s", request.Credential.Platform, expiresAt.Format("Jan 2, 2006 at 3:04 PM")),
+		models.NotificationTypeCredentialAccessApproved,
+		map[string]interface{}{
+			"request_id":    request.ID,
+			"credential_id": request.CredentialID,
+			"platform":      request.Credential.Platform,
+			"expires_at":    expiresAt,
+		},
+	)
+
+	return &request, nil
+}
+
+// DenyAccessRequest rejects a pending access request (Admin only)
+func (s *PasswordManagerService) DenyAccessRequest(requestID, adminID uint, note string) (*models.CredentialAccessRequest, error) {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return nil, errors.New("only admin can deny access requests")
+	}
+
+	// Lock the request like ApproveAccessRequest so a concurrent approval cannot be overwritten
+	var request models.CredentialAccessRequest
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, requestID).Error; err != nil {
+			return errors.New("access request not found")
+		}
+		if request.Status != models.CredentialAccessRequestPending {
+			return errors.New("access request is not pending")
+		}
+
+		now := time.Now()
+		request.Status = models.CredentialAccessRequestDenied
+		request.ReviewedByID = &adminID
+		request.ReviewedAt = &now
+		request.ReviewNote = note
+		return tx.Save(&request).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.DB.First(&request.Credential, request.CredentialID)
+
+	message := fmt.Sprintf("Your access request for %s was denied", request.Credential.Platform)
+	if note != "" {
+		message += ": " + note
+	}
+	s.notifyUser(
+		request.RequesterID,
+		"Credential Access Denied",
+		message,
+		models.NotificationTypeCredentialAccessDenied,
+		map[string]interface{}{
+			"request_id":    request.ID,
+			"credential_id": request.CredentialID,
+			"platform":      request.Credential.Platform,
+		},
+	)
+
+	return &request, nil
+}
+
+// RevokeExpiredShares deletes shares whose expiry has passed and notifies the affected users.
+// It returns the number of revoked shares.
+func (s *PasswordManagerService) RevokeExpiredShares() (int, error) {
+	now := time.Now()
+
+	var expired []models.CredentialShare
+	if err := s.DB.Preload("Credential").
+		Where("expires_at IS NOT NULL AND expires_at <= ?", now).
+		Find(&expired).Error; err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, share := range expired {
+		if err := s.DB.Delete(&share).Error; err != nil {
+			log.Printf("Failed to revoke expired credential share %d: %v", share.ID, err)
+			continue
+		}
+		revoked++
+
+		if share.RequestID != nil {
+			s.DB.Model(&models.CredentialAccessRequest{}).
+				Where("id = ? AND status = ?", *share.RequestID, models.CredentialAccessRequestApproved).
+				Update("status", models.CredentialAccessRequestExpired)
+		}
+
+		s.notifyUser(
+			share.UserID,
+			"Credential Access Expired",
+			fmt.Sprintf("Your access to %s has expired", share.Credential.Platform),
+			models.NotificationTypeCredentialAccessExpired,
+			map[string]interface{}{
+				"credential_id": share.CredentialID,
+				"platform":      share.Credential.Platform,
+			},
+		)
+	}
+
+	return revoked, nil
+}
+
+// StartExpirySweep periodically revokes expired shares in the background
+func (s *PasswordManagerService) StartExpirySweep(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			revoked, err := s.RevokeExpiredShares()
+			if err != nil {
+				log.Printf("Credential share expiry sweep failed: %v", err)
+				continue
+			}
+			if revoked > 0 {
+				log.Printf("Revoked %d expired credential shares", revoked)
+			}
+		}
+	}()
+}
+
+// notifyAdminsOfAccessRequest notifies every admin about a new access request
+func (s *PasswordManagerService) notifyAdminsOfAccessRequest(request *models.CredentialAccessRequest) {
+	var admins []models.User
+	s.DB.Where("role = ?", models.RoleAdmin).Find(&admins)
+
+	for _, admin := range admins {
+		s.notifyUser(
+			admin.ID,
+			"Credential Access Requested",
+			fmt.Sprintf("%s requested %d hour(s) of access to %s", request.Requester.Username, request.RequestedHours, request.Credential.Platform),
+			models.NotificationTypeCredentialAccessRequested,
+			map[string]interface{}{
+				"request_id":    request.ID,
+				"credential_id": request.CredentialID,
+				"platform":      request.Credential.Platform,
+				"requester_id":  request.RequesterID,
+				"reason":        request.Reason,
+			},
+		)
+	}
+}
+
+// notifyUser sends a password manager notification if notifications are configured
+func (s *PasswordManagerService) notifyUser(userID uint, title, message string, notificationType models.NotificationType, data map[string]interface{}) {
+	if s.notificationService == nil {
+		return
+	}
+
+	if err := s.notificationService.CreateNotification(userID, title, message, string(notificationType), data); err != nil {
+		log.Printf("Failed to send password manager notification to user %d: %v", userID, err)
+	}
+}
+
+// CredentialHealth describes the password hygiene of a single credential
+type CredentialHealth struct {
+	CredentialID    uint     `json:"credential_id"`
+	Platform        string   `json:"platform"`
+	Score           int      `json:"score"`
+	Label           string   `json:"label"`
+	Weak            bool     `json:"weak"`
+	Breached        bool     `json:"breached"`
+	BreachCount     int      `json:"breach_count,omitempty"`
+	ReusedWith      []uint   `json:"reused_with,omitempty"` // Other credential IDs with the same password
+	Stale           bool     `json:"stale"`
+	PasswordAgeDays int      `json:"password_age_days"`
+	Issues          []string `json:"issues"`
+}
+
+// PasswordHealthReport summarises weak, breached, reused and stale credentials
+type PasswordHealthReport struct {
+	GeneratedAt      time.Time          `json:"generated_at"`
+	TotalCredentials int                `json:"total_credentials"`
+	WeakCount        int                `json:"weak_count"`
+	BreachedCount    int                `json:"breached_count"`
+	ReusedCount      int                `json:"reused_count"`
+	StaleCount       int                `json:"stale_count"`
+	StaleAfterDays   int                `json:"stale_after_days"`
+	BreachListSize   int                `json:"breach_list_size"`
+	Credentials      []CredentialHealth `json:"credentials"` // Only credentials with at least one issue
+}
+
+// GetPasswordHealthReport decrypts every active credential and reports hygiene problems (Admin only)
+func (s *PasswordManagerService) GetPasswordHealthReport(adminID uint, staleDays int) (*PasswordHealthReport, error) {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return nil, errors.New("only admin can view password health reports")
+	}
+
+	if staleDays <= 0 {
+		staleDays = DefaultStalePasswordDays
+	}
+
+	var credentials []models.Credential
+	if err := s.DB.Where("is_active = ?", true).Order("platform ASC").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	report := &PasswordHealthReport{
+		GeneratedAt:      now,
+		TotalCredentials: len(credentials),
+		StaleAfterDays:   staleDays,
+		BreachListSize:   s.Policy.BreachedHashCount(),
+	}
+
+	// First pass: evaluate each password and group identical ones by fingerprint
+	health := make([]CredentialHealth, 0, len(credentials))
+	byFingerprint := make(map[string][]int)
+	for _, credential := range credentials {
+		password, err := s.Encryption.Decrypt(credential.Password)
+		if err != nil {
+			log.Printf("Skipping credential %d in health report: failed to decrypt password", credential.ID)
+			continue
+		}
+		email, _ := s.Encryption.Decrypt(credential.Email)
+		username, _ := s.Encryption.Decrypt(credential.Username)
+
+		strength := s.Policy.EvaluatePasswordStrength(password, credential.Platform, email, username)
+		breached, breachCount := s.Policy.CheckBreached(password)
+
+		changedAt := credential.CreatedAt
+		if credential.PasswordChangedAt != nil {
+			changedAt = *credential.PasswordChangedAt
+		}
+		ageDays := int(now.Sub(changedAt).Hours() / 24)
+
+		entry := CredentialHealth{
+			CredentialID:    credential.ID,
+			Platform:        credential.Platform,
+			Score:           strength.Score,
+			Label:           strength.Label,
+			Weak:            strength.Score < MinAcceptablePasswordScore,
+			Breached:        breached,
+			BreachCount:     breachCount,
+			Stale:           ageDays >= staleDays,
+			PasswordAgeDays: ageDays,
+		}
+
+		sum := sha256.Sum256([]byte(password))
+		fingerprint := hex.EncodeToString(sum[:])
+		byFingerprint[fingerprint] = append(byFingerprint[fingerprint], len(health))
+		health = append(health, entry)
+	}
+
+	// Second pass: mark reused passwords
+	for _, indexes := range byFingerprint {
+		if len(indexes) < 2 {
+			continue
+		}
+		for _, i := range indexes {
+			for _, j := range indexes {
+				if i != j {
+					health[i].ReusedWith = append(health[i].ReusedWith, health[j].CredentialID)
+				}
+			}
+		}
+	}
+
+	for _, entry := range health {
+		if entry.Weak {
+			entry.Issues = append(entry.Issues, "weak")
+			report.WeakCount++
+		}
+		if entry.Breached {
+			entry.Issues = append(entry.Issues, "breached")
+			report.BreachedCount++
+		}
+		if len(entry.ReusedWith) > 0 {
+			entry.Issues = append(entry.Issues, "reused")
+			report.ReusedCount++
+		}
+		if entry.Stale {
+			entry.Issues = append(entry.Issues, "stale")
+			report.StaleCount++
+		}
+		if len(entry.Issues) > 0 {
+			report.Credentials = append(report.Credentials, entry)
+		}
+	}
+
+	return report, nil
+}
+
+// SetCredentialTOTP stores an encrypted TOTP seed on a credential (Admin only)
+func (s *PasswordManagerService) SetCredentialTOTP(credentialID, adminID uint, config TOTPConfig) error {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return errors.New("only admin can manage TOTP secrets")
+	}
+
+	var credential models.Credential
+	if err := s.DB.First(&credential, credentialID).Error; err != nil {
+		return errors.New("credential not found")
+	}
+
+	config, err := NormalizeTOTPConfig(config)
+	if err != nil {
+		return err
+	}
+
+	encryptedSecret, err := s.Encryption.Encrypt(config.Secret)
+	if err != nil {
+		return errors.New("failed to encrypt TOTP secret")
+	}
+
+	return s.DB.Model(&credential).Updates(map[string]interface{}{
+		"totp_secret":    encryptedSecret,
+		"totp_digits":    config.Digits,
+		"totp_period":    config.Period,
+		"totp_algorithm": config.Algorithm,
+	}).Error
+}
+
+// RemoveCredentialTOTP deletes the TOTP seed from a credential (Admin only)
+func (s *PasswordManagerService) RemoveCredentialTOTP(credentialID, adminID uint) error {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return errors.New("only admin can manage TOTP secrets")
+	}
+
+	var credential models.Credential
+	if err := s.DB.First(&credential, credentialID).Error; err != nil {
+		return errors.New("credential not found")
+	}
+
+	return s.DB.Model(&credential).Update("totp_secret", "").Error
+}
+
+// GetTOTPCode returns the current 2FA code for a credential (with access logging)
+func (s *PasswordManagerService) GetTOTPCode(credentialID, userID uint, ipAddress, userAgent string) (*TOTPCode, error) {
+	var credential models.Credential
+	if err := s.DB.First(&credential, credentialID).Error; err != nil {
+		return nil, errors.New("credential not found")
+	}
+
+	// Same rule as viewing the credential
+	hasAccess, _ := s.resolvePermissions(&credential, userID)
+	if !hasAccess {
+		return nil, errors.New("access denied")
+	}
+	if !credential.IsActive {
+		return nil, errors.New("credential is not active")
+	}
+	if credential.TOTPSecret == "" {
+		return nil, errors.New("no TOTP secret configured for this credential")
+	}
+
+	secret, err := s.Encryption.Decrypt(credential.TOTPSecret)
+	if err != nil {
+		return nil, errors.New("failed to decrypt TOTP secret")
+	}
+
+	now := time.Now()
+	code, err := GenerateTOTPCode(TOTPConfig{
+		Secret:    secret,
+		Digits:    credential.TOTPDigits,
+		Period:    credential.TOTPPeriod,
+		Algorithm: credential.TOTPAlgorithm,
+	}, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Log access
+	accessLog := &models.CredentialAccessLog{
+		CredentialID: credentialID,
+		UserID:       userID,
+		Action:       "totp",
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		AccessedAt:   now,
+	}
+	s.DB.Create(accessLog)
+
+	// Update last accessed time
+	s.DB.Model(&credential).Update("last_accessed_at", now)
+
+	return code, nil
+}
@@ -0,0 +1,613 @@
+package services
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	lowercaseChars = "abcdefghijklmnopqrstuvwxyz"
+	uppercaseChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars     = "0123456789"
+	symbolChars    = "!@#$%^&*()-_=+[]{};:,.?/"
+	ambiguousChars = "Il1O0o"
+
+	pronounceableConsonants = "bcdfghjklmnprstvz"
+	pronounceableVowels     = "aeiou"
+
+	// MinGeneratedPasswordLength and MaxGeneratedPasswordLength bound the generator
+	MinGeneratedPasswordLength = 8
+	MaxGeneratedPasswordLength = 128
+
+	// MinAcceptablePasswordScore is the lowest strength score not reported as weak
+	MinAcceptablePasswordScore = 3
+)
+
+// commonPasswordWords is a small built-in dictionary used to detect guessable passwords
+var commonPasswordWords = []string{
+	"password", "passw0rd", "qwerty", "letmein", "welcome", "admin", "login", "master",
+	"dragon", "monkey", "football", "baseball", "iloveyou", "sunshine", "princess",
+	"shadow", "superman", "trustno1", "secret", "company", "summer", "winter", "spring",
+	"autumn", "instagram", "facebook", "google", "stripe", "github", "twitter", "linkedin",
+	"changeme", "default", "test", "guest", "user", "root", "abc", "hello", "freedom",
+}
+
+// keyboardRows are used to detect keyboard walks such as "qwerty" or "asdf"
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+}
+
+// PasswordGeneratorOptions controls how passwords are generated
+type PasswordGeneratorOptions struct {
+	Length           int  `json:"length"`
+	Lowercase        bool `json:"lowercase"`
+	Uppercase        bool `json:"uppercase"`
+	Digits           bool `json:"digits"`
+	Symbols          bool `json:"symbols"`
+	ExcludeAmbiguous bool `json:"exclude_ambiguous"`
+	Pronounceable    bool `json:"pronounceable"`
+}
+
+// PasswordStrength is a zxcvbn-style strength estimate
+type PasswordStrength struct {
+	Score       int      `json:"score"` // 0 (very weak) to 4 (very strong)
+	Label       string   `json:"label"`
+	Guesses     float64  `json:"guesses"`
+	EntropyBits float64  `json:"entropy_bits"`
+	CrackTime   string   `json:"crack_time"` // Offline, slow hash (10k guesses/second)
+	Warnings    []string `json:"warnings"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// PasswordPolicyService generates passwords, estimates strength and screens against breached hashes
+type PasswordPolicyService struct {
+	breachedMutex sync.RWMutex
+	breached      map[string]map[string]int // SHA-1 prefix (5 hex chars) -> suffix -> breach count
+	breachedCount int
+}
+
+// NewPasswordPolicyService creates a policy service and loads the breached password list from
+// BREACHED_PASSWORDS_FILE if it is set. The file uses the "SHA1HASH[:COUNT]" per-line format
+// of the Have I Been Pwned downloads; nothing is ever fetched over the network.
+func NewPasswordPolicyService() *PasswordPolicyService {
+	s := &PasswordPolicyService{breached: make(map[string]map[string]int)}
+
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		if err := s.LoadBreachedHashes(path); err != nil {
+			log.Printf("Warning: could not load breached password list: %v", err)
+		}
+	}
+
+	return s
+}
+
+// LoadBreachedHashes replaces the in-memory breached hash list with the contents of a file
+func (s *PasswordPolicyService) LoadBreachedHashes(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	breached := make(map[string]map[string]int)
+	count := 0
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, occurrences := line, 1
+		if idx := strings.Index(line, ":"); idx >= 0 {
+			hash = line[:idx]
+			if n, err := strconv.Atoi(strings.TrimSpace(line[idx+1:])); err == nil {
+				occurrences = n
+			}
+		}
+
+		hash = strings.ToUpper(hash)
+		if len(hash) != 40 {
+			continue
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			continue
+		}
+
+		prefix, suffix := hash[:5], hash[5:]
+		if breached[prefix] == nil {
+			breached[prefix] = make(map[string]int)
+		}
+		breached[prefix][suffix] = occurrences
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	s.breachedMutex.Lock()
+	s.breached = breached
+	s.breachedCount = count
+	s.breachedMutex.Unlock()
+
+	log.Printf("Loaded %d breached password hashes", count)
+	return nil
+}
+
+// BreachedHashCount returns how many breached hashes are loaded
+func (s *PasswordPolicyService) BreachedHashCount() int {
+	s.breachedMutex.RLock()
+	defer s.breachedMutex.RUnlock()
+	return s.breachedCount
+}
+
+// CheckBreached reports whether a password appears in the breached list and how often
+func (s *PasswordPolicyService) CheckBreached(password string) (bool, int) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	s.breachedMutex.RLock()
+	defer s.breachedMutex.RUnlock()
+
+	occurrences, found := s.breached[hash[:5]][hash[5:]]
+	return found, occurrences
+}
+
+// LookupBreachedRange returns all breached hash suffixes for a 5-character SHA-1 prefix,
+// so clients can check a password without ever sending it (or its full hash) to the server
+func (s *PasswordPolicyService) LookupBreachedRange(prefix string) (map[string]int, error) {
+	prefix = strings.ToUpper(prefix)
+	if len(prefix) != 5 {
+		return nil, errors.New("prefix must be exactly 5 hex characters")
+	}
+	if _, err := hex.DecodeString(prefix + "0"); err != nil {
+		return nil, errors.New("prefix must be exactly 5 hex characters")
+	}
+
+	s.breachedMutex.RLock()
+	defer s.breachedMutex.RUnlock()
+
+	result := make(map[string]int, len(s.breached[prefix]))
+	for suffix, occurrences := range s.breached[prefix] {
+		result[suffix] = occurrences
+	}
+	return result, nil
+}
+
+// DefaultPasswordGeneratorOptions returns a strong general-purpose configuration
+func DefaultPasswordGeneratorOptions() PasswordGeneratorOptions {
+	return PasswordGeneratorOptions{
+		Length:    20,
+		Lowercase: true,
+		Uppercase: true,
+		Digits:    true,
+		Symbols:   true,
+	}
+}
+
+// GeneratePassword creates a random password using a cryptographically secure source
+func (s *PasswordPolicyService) GeneratePassword(opts PasswordGeneratorOptions) (string, error) {
+	if opts.Length == 0 {
+		opts.Length = DefaultPasswordGeneratorOptions().Length
+	}
+	if opts.Length < MinGeneratedPasswordLength || opts.Length > MaxGeneratedPasswordLength {
+		return "", fmt.Errorf("length must be between %d and %d", MinGeneratedPasswordLength, MaxGeneratedPasswordLength)
+	}
+
+	if opts.Pronounceable {
+		return generatePronounceablePassword(opts)
+	}
+
+	var sets []string
+	if opts.Lowercase {
+		sets = append(sets, lowercaseChars)
+	}
+	if opts.Uppercase {
+		sets = append(sets, uppercaseChars)
+	}
+	if opts.Digits {
+		sets = append(sets, digitChars)
+	}
+	if opts.Symbols {
+		sets = append(sets, symbolChars)
+	}
+	if len(sets) == 0 {
+		return "", errors.New("at least one character set must be enabled")
+	}
+
+	if opts.ExcludeAmbiguous {
+		for i, set := range sets {
+			sets[i] = removeChars(set, ambiguousChars)
+		}
+	}
+
+	// Guarantee at least one character from every enabled set
+	password := make([]byte, 0, opts.Length)
+	for _, set := range sets {
+		ch, err := randomChar(set)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, ch)
+	}
+
+	all := strings.Join(sets, "")
+	for len(password) < opts.Length {
+		ch, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, ch)
+	}
+
+	if err := shuffleBytes(password); err != nil {
+		return "", err
+	}
+
+	return string(password), nil
+}
+
+// generatePronounceablePassword builds consonant-vowel syllables, optionally mixed with digits and symbols
+func generatePronounceablePassword(opts PasswordGeneratorOptions) (string, error) {
+	var suffix []byte
+	if opts.Digits {
+		for i := 0; i < 2; i++ {
+			ch, err := randomChar(digitChars)
+			if err != nil {
+				return "", err
+			}
+			suffix = append(suffix, ch)
+		}
+	}
+	if opts.Symbols {
+		ch, err := randomChar(symbolChars)
+		if err != nil {
+			return "", err
+		}
+		suffix = append(suffix, ch)
+	}
+
+	wordLength := opts.Length - len(suffix)
+	word := make([]byte, 0, wordLength)
+	for len(word) < wordLength {
+		set := pronounceableConsonants
+		if len(word)%2 == 1 {
+			set = pronounceableVowels
+		}
+		ch, err := randomChar(set)
+		if err != nil {
+			return "", err
+		}
+		word = append(word, ch)
+	}
+
+	// Capitalise the start of some syllables when uppercase is enabled
+	if opts.Uppercase {
+		word[0] = byte(unicode.ToUpper(rune(word[0])))
+		for i := 4; i < len(word); i += 4 {
+			flip, err := rand.Int(rand.Reader, big.NewInt(2))
+			if err != nil {
+				return "", err
+			}
+			if flip.Int64() == 1 {
+				word[i] = byte(unicode.ToUpper(rune(word[i])))
+			}
+		}
+	}
+
+	return string(word) + string(suffix), nil
+}
+
+// EvaluatePasswordStrength estimates how hard a password is to guess. userInputs are
+// context words (platform name, email, username) that make a password easier to guess.
+func (s *PasswordPolicyService) EvaluatePasswordStrength(password string, userInputs ...string) PasswordStrength {
+	strength := PasswordStrength{}
+	if password == "" {
+		strength.Label = strengthLabel(0)
+		strength.CrackTime = "instant"
+		strength.Warnings = []string{"Password is empty"}
+		strength.Suggestions = []string{"Use the password generator"}
+		return strength
+	}
+
+	lower := strings.ToLower(password)
+	length := len([]rune(password))
+
+	// Brute-force baseline from the character classes used
+	charset := 0
+	var hasLower, hasUpper, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+	if hasLower {
+		charset += 26
+	}
+	if hasUpper {
+		charset += 26
+	}
+	if hasDigit {
+		charset += 10
+	}
+	if hasSymbol {
+		charset += 33
+	}
+
+	// Characters covered by a guessable pattern contribute almost nothing
+	covered := make([]bool, len(lower))
+	patternBits := 0.0
+
+	markCovered := func(start, end int) int {
+		newly := 0
+		for i := start; i < end && i < len(covered); i++ {
+			if !covered[i] {
+				covered[i] = true
+				newly++
+			}
+		}
+		return newly
+	}
+
+	words := append([]string{}, commonPasswordWords...)
+	for _, input := range userInputs {
+		for _, part := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len(part) >= 3 {
+				words = append(words, part)
+			}
+		}
+	}
+
+	dictionaryHit, userInputHit := false, false
+	for i, word := range words {
+		for _, candidate := range []string{word, reverseString(word), deLeet(word)} {
+			idx := strings.Index(deLeet(lower), candidate)
+			if idx < 0 || len(candidate) < 3 {
+				continue
+			}
+			if markCovered(idx, idx+len(candidate)) > 0 {
+				patternBits += math.Log2(float64(len(words))) + 1
+				if i < len(commonPasswordWords) {
+					dictionaryHit = true
+				} else {
+					userInputHit = true
+				}
+			}
+		}
+	}
+
+	sequenceHit := false
+	for start := 0; start < len(lower); {
+		end := sequenceEnd(lower, start)
+		if end-start >= 3 {
+			if markCovered(start, end) > 0 {
+				patternBits += math.Log2(26 * 2 * float64(end-start))
+				sequenceHit = true
+			}
+			start = end
+			continue
+		}
+		start++
+	}
+
+	repeatHit := false
+	for start := 0; start < len(lower); {
+		end := start + 1
+		for end < len(lower) && lower[end] == lower[start] {
+			end++
+		}
+		if end-start >= 3 {
+			if markCovered(start, end) > 0 {
+				patternBits += math.Log2(float64(charset) * float64(end-start))
+				repeatHit = true
+			}
+		}
+		start = end
+	}
+
+	keyboardHit := false
+	for _, row := range keyboardRows {
+		for size := len(row); size >= 4; size-- {
+			for i := 0; i+size <= len(row); i++ {
+				walk := row[i : i+size]
+				if idx := strings.Index(lower, walk); idx >= 0 && markCovered(idx, idx+size) > 0 {
+					patternBits += math.Log2(float64(len(keyboardRows) * len(row) * size))
+					keyboardHit = true
+				}
+			}
+		}
+	}
+
+	dateHit := false
+	for i := 0; i+4 <= len(lower); i++ {
+		if year, err := strconv.Atoi(lower[i : i+4]); err == nil && year >= 1900 && year <= 2099 {
+			if markCovered(i, i+4) > 0 {
+				patternBits += math.Log2(200)
+				dateHit = true
+			}
+		}
+	}
+
+	uncovered := 0
+	for _, c := range covered {
+		if !c {
+			uncovered++
+		}
+	}
+
+	entropy := patternBits + float64(uncovered)*math.Log2(float64(max(charset, 2)))
+	if hasUpper && hasLower && (dictionaryHit || userInputHit) {
+		entropy += 1 // Capitalisation of a known word only adds a little
+	}
+
+	guesses := math.Pow(2, entropy)
+	strength.EntropyBits = math.Round(entropy*10) / 10
+	strength.Guesses = guesses
+	strength.Score = scoreFromGuesses(guesses)
+	strength.Label = strengthLabel(strength.Score)
+	strength.CrackTime = crackTimeDisplay(guesses / 1e4)
+
+	if length < 12 {
+		strength.Warnings = append(strength.Warnings, "Password is short")
+		strength.Suggestions = append(strength.Suggestions, "Use at least 12 characters")
+	}
+	if dictionaryHit {
+		strength.Warnings = append(strength.Warnings, "Contains a common password or word")
+	}
+	if userInputHit {
+		strength.Warnings = append(strength.Warnings, "Contains the platform name, email or username")
+	}
+	if sequenceHit {
+		strength.Warnings = append(strength.Warnings, "Contains a sequence like 'abc' or '123'")
+	}
+	if repeatHit {
+		strength.Warnings = append(strength.Warnings, "Contains repeated characters")
+	}
+	if keyboardHit {
+		strength.Warnings = append(strength.Warnings, "Contains a keyboard pattern")
+	}
+	if dateHit {
+		strength.Warnings = append(strength.Warnings, "Contains a year or date")
+	}
+	if charset < 62 {
+		strength.Suggestions = append(strength.Suggestions, "Mix upper and lower case letters, digits and symbols")
+	}
+	if strength.Score < MinAcceptablePasswordScore {
+		strength.Suggestions = append(strength.Suggestions, "Use the password generator for a random password")
+	}
+
+	return strength
+}
+
+// sequenceEnd returns the end index of an ascending or descending run starting at start
+func sequenceEnd(s string, start int) int {
+	if start+1 >= len(s) {
+		return start + 1
+	}
+	delta := int(s[start+1]) - int(s[start])
+	if delta != 1 && delta != -1 {
+		return start + 1
+	}
+	end := start + 1
+	for end < len(s) && int(s[end])-int(s[end-1]) == delta {
+		end++
+	}
+	return end
+}
+
+// scoreFromGuesses maps a guess estimate to the 0-4 zxcvbn score scale
+func scoreFromGuesses(guesses float64) int {
+	switch {
+	case guesses < 1e3:
+		return 0
+	case guesses < 1e6:
+		return 1
+	case guesses < 1e8:
+		return 2
+	case guesses < 1e10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func strengthLabel(score int) string {
+	return []string{"very_weak", "weak", "fair", "strong", "very_strong"}[score]
+}
+
+// crackTimeDisplay formats a duration in seconds into a human-readable estimate
+func crackTimeDisplay(seconds float64) string {
+	const (
+		minute = 60.0
+		hour   = minute * 60
+		day    = hour * 24
+		month  = day * 31
+		year   = month * 12
+	)
+
+	switch {
+	case seconds < 1:
+		return "less than a second"
+	case seconds < minute:
+		return fmt.Sprintf("%.0f seconds", seconds)
+	case seconds < hour:
+		return fmt.Sprintf("%.0f minutes", seconds/minute)
+	case seconds < day:
+		return fmt.Sprintf("%.0f hours", seconds/hour)
+	case seconds < month:
+		return fmt.Sprintf("%.0f days", seconds/day)
+	case seconds < year:
+		return fmt.Sprintf("%.0f months", seconds/month)
+	case seconds < year*100:
+		return fmt.Sprintf("%.0f years", seconds/year)
+	default:
+		return "centuries"
+	}
+}
+
+// deLeet undoes common character substitutions (p@ssw0rd -> password)
+func deLeet(s string) string {
+	return strings.NewReplacer("@", "a", "4", "a", "3", "e", "0", "o", "1", "i", "!", "i", "$", "s", "5", "s", "7", "t").Replace(s)
+}
+
+func reverseString(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func removeChars(set, remove string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(remove, r) {
+			return -1
+		}
+		return r
+	}, set)
+}
+
+func randomChar(set string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+	if err != nil {
+		return 0, err
+	}
+	return set[n.Int64()], nil
+}
+
+func shuffleBytes(b []byte) error {
+	for i := len(b) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return err
+		}
+		j := n.Int64()
+		b[i], b[j] = b[j], b[i]
+	}
+	return nil
+}
@@ -0,0 +1,854 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"project-x/models"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Supported import/export formats
+const (
+	TransferFormatBitwardenCSV      = "bitwarden_csv"
+	TransferFormatBitwardenJSON     = "bitwarden_json"
+	TransferFormatKeePassCSV        = "keepass_csv"
+	TransferFormatProjectXEncrypted = "projectx_encrypted"
+
+	// MinExportPassphraseLength is the shortest passphrase accepted for encrypted exports
+	MinExportPassphraseLength = 12
+
+	exportArchiveType    = "project-x-credentials"
+	exportArchiveVersion = 1
+
+	// Argon2id parameters used to derive the archive key from the passphrase
+	exportKDFTime    = 3
+	exportKDFMemory  = 64 * 1024
+	exportKDFThreads = 4
+)
+
+// ImportOptions controls how an import is processed
+type ImportOptions struct {
+	Format      string
+	Content     string
+	Passphrase  string // Only for projectx_encrypted archives
+	DryRun      bool
+	OnDuplicate string // "skip" (default) or "update"
+	IPAddress   string
+	UserAgent   string
+}
+
+// ImportItemResult is the outcome for one entry of an import file (never includes secrets)
+type ImportItemResult struct {
+	Row          int      `json:"row"`
+	Platform     string   `json:"platform"`
+	Email        string   `json:"email"`
+	Status       string   `json:"status"` // "create", "update", "skip", "invalid" (dry run) / "created", "updated", "skipped", "failed"
+	CredentialID uint     `json:"credential_id,omitempty"`
+	Errors       []string `json:"errors,omitempty"`
+	Warnings     []string `json:"warnings,omitempty"`
+}
+
+// ImportResult summarises an import run
+type ImportResult struct {
+	OperationID uint               `json:"operation_id"`
+	Format      string             `json:"format"`
+	DryRun      bool               `json:"dry_run"`
+	Total       int                `json:"total"`
+	Created     int                `json:"created"`
+	Updated     int                `json:"updated"`
+	Skipped     int                `json:"skipped"`
+	Failed      int                `json:"failed"`
+	Items       []ImportItemResult `json:"items"`
+}
+
+// importedCredential is a normalised entry parsed from any supported format
+type importedCredential struct {
+	Row      int
+	Platform string
+	Email    string
+	Username string
+	Password string
+	URL      string
+	Notes    string
+	TOTP     string // Base32 seed or otpauth:// URI
+}
+
+// exportedCredential is the plaintext shape stored inside encrypted archives
+type exportedCredential struct {
+	Platform string      `json:"platform"`
+	Email    string      `json:"email"`
+	Username string      `json:"username,omitempty"`
+	Password string      `json:"password"`
+	URL      string      `json:"url,omitempty"`
+	Notes    string      `json:"notes,omitempty"`
+	IsActive bool        `json:"is_active"`
+	TOTP     *TOTPConfig `json:"totp,omitempty"`
+}
+
+type exportPayload struct {
+	ExportedAt  time.Time            `json:"exported_at"`
+	Credentials []exportedCredential `json:"credentials"`
+}
+
+// ExportArchive is the passphrase-protected envelope returned by exports
+type ExportArchive struct {
+	Type       string    `json:"type"`
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	Count      int       `json:"count"`
+	KDF        string    `json:"kdf"`
+	KDFTime    uint32    `json:"kdf_time"`
+	KDFMemory  uint32    `json:"kdf_memory"`
+	KDFThreads uint8     `json:"kdf_threads"`
+	Salt       string    `json:"salt"`
+	Nonce      string    `json:"nonce"`
+	Ciphertext string    `json:"ciphertext"` // AES-256-GCM over gzip-compressed JSON
+}
+
+// ImportCredentials imports credentials from a password manager export (Admin only)
+func (s *PasswordManagerService) ImportCredentials(adminID uint, opts ImportOptions) (*ImportResult, error) {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return nil, errors.New("only admin can import credentials")
+	}
+
+	if opts.OnDuplicate == "" {
+		opts.OnDuplicate = "skip"
+	}
+	if opts.OnDuplicate != "skip" && opts.OnDuplicate != "update" {
+		return nil, errors.New("on_duplicate must be 'skip' or 'update'")
+	}
+
+	entries, err := parseImportContent(opts.Format, opts.Content, opts.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.credentialsByPlatformAndEmail()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{
+		Format: opts.Format,
+		DryRun: opts.DryRun,
+		Total:  len(entries),
+	}
+	seenInFile := make(map[string]int)
+
+	for _, entry := range entries {
+		item := ImportItemResult{
+			Row:      entry.Row,
+			Platform: entry.Platform,
+			Email:    entry.Email,
+		}
+
+		item.Errors, item.Warnings = s.validateImportedCredential(&entry)
+
+		// Only valid rows are remembered: an invalid row is never imported, so a later valid row
+		// for the same account is not a duplicate of it
+		key := duplicateKey(entry.Platform, entry.Email)
+		if len(item.Errors) == 0 {
+			if firstRow, seen := seenInFile[key]; seen {
+				item.Errors = append(item.Errors, fmt.Sprintf("duplicate of row %d in the same file", firstRow))
+			} else {
+				seenInFile[key] = entry.Row
+			}
+		}
+
+		existingCredential, isDuplicate := existing[key]
+
+		switch {
+		case len(item.Errors) > 0:
+			item.Status = "invalid"
+			result.Failed++
+		case isDuplicate && opts.OnDuplicate == "skip":
+			item.Status = "skip"
+			item.CredentialID = existingCredential.ID
+			item.Warnings = append(item.Warnings, "credential with the same platform and email already exists")
+			result.Skipped++
+		case isDuplicate:
+			item.Status = "update"
+			item.CredentialID = existingCredential.ID
+			result.Updated++
+		default:
+			item.Status = "create"
+			result.Created++
+		}
+
+		if !opts.DryRun && (item.Status == "create" || item.Status == "update") {
+			id, err := s.saveImportedCredential(adminID, &entry, existingCredential)
+			if err != nil {
+				log.Printf("Failed to import credential row %d: %v", entry.Row, err)
+				item.Errors = append(item.Errors, "failed to save credential")
+				if item.Status == "create" {
+					result.Created--
+				} else {
+					result.Updated--
+				}
+				result.Failed++
+				item.Status = "invalid"
+			} else {
+				item.CredentialID = id
+			}
+		}
+
+		if !opts.DryRun {
+			item.Status = map[string]string{"create": "created", "update": "updated", "skip": "skipped", "invalid": "failed"}[item.Status]
+		}
+
+		result.Items = append(result.Items, item)
+	}
+
+	result.OperationID = s.recordBulkOperation(adminID, "import", opts.Format, opts.DryRun, result.Total,
+		result.Created, result.Updated, result.Skipped, result.Failed, result.Items, opts.IPAddress, opts.UserAgent)
+
+	return result, nil
+}
+
+// ExportCredentials produces a passphrase-protected archive of credentials (Admin only).
+// An empty credentialIDs exports every credential.
+func (s *PasswordManagerService) ExportCredentials(adminID uint, passphrase string, credentialIDs []uint, ipAddress, userAgent string) (*ExportArchive, error) {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return nil, errors.New("only admin can export credentials")
+	}
+
+	if len(passphrase) < MinExportPassphraseLength {
+		return nil, fmt.Errorf("passphrase must be at least %d characters", MinExportPassphraseLength)
+	}
+
+	query := s.DB.Order("platform ASC")
+	if len(credentialIDs) > 0 {
+		query = query.Where("id IN ?", credentialIDs)
+	}
+
+	var credentials []models.Credential
+	if err := query.Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+
+	payload := exportPayload{ExportedAt: time.Now()}
+	var summary []ImportItemResult
+	for _, credential := range credentials {
+		exported, err := s.decryptForExport(&credential)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt credential %d", credential.ID)
+		}
+		payload.Credentials = append(payload.Credentials, *exported)
+		summary = append(summary, ImportItemResult{
+			Platform:     credential.Platform,
+			Status:       "exported",
+			CredentialID: credential.ID,
+		})
+	}
+
+	archive, err := sealExportArchive(payload, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordBulkOperation(adminID, "export", TransferFormatProjectXEncrypted, false, len(credentials),
+		0, 0, 0, 0, summary, ipAddress, userAgent)
+
+	return archive, nil
+}
+
+// GetBulkOperations returns the import/export audit trail (Admin only)
+func (s *PasswordManagerService) GetBulkOperations(adminID uint) ([]models.CredentialBulkOperation, error) {
+	// Verify admin role
+	var admin models.User
+	if err := s.DB.First(&admin, adminID).Error; err != nil {
+		return nil, errors.New("admin not found")
+	}
+	if admin.Role != models.RoleAdmin {
+		return nil, errors.New("only admin can view bulk operations")
+	}
+
+	var operations []models.CredentialBulkOperation
+	err := s.DB.Preload("Admin").Order("performed_at DESC").Find(&operations).Error
+
+	return operations, err
+}
+
+// validateImportedCredential returns blocking errors and non-blocking warnings for an entry
+func (s *PasswordManagerService) validateImportedCredential(entry *importedCredential) ([]string, []string) {
+	var errs, warnings []string
+
+	if entry.Platform == "" {
+		errs = append(errs, "platform (name/title) is required")
+	}
+	if entry.Email == "" {
+		errs = append(errs, "email or username is required")
+	}
+	if entry.Password == "" {
+		errs = append(errs, "password is required")
+	} else {
+		if breached, _ := s.Policy.CheckBreached(entry.Password); breached {
+			errs = append(errs, "password appears in a known data breach")
+		}
+		if strength := s.Policy.EvaluatePasswordStrength(entry.Password, entry.Platform, entry.Email); strength.Score < MinAcceptablePasswordScore {
+			warnings = append(warnings, fmt.Sprintf("weak password (%s)", strength.Label))
+		}
+	}
+
+	if entry.TOTP != "" {
+		if _, err := importedTOTPConfig(entry.TOTP); err != nil {
+			warnings = append(warnings, "TOTP value ignored: "+err.Error())
+			entry.TOTP = ""
+		}
+	}
+
+	return errs, warnings
+}
+
+// saveImportedCredential creates a credential or overwrites an existing duplicate
+func (s *PasswordManagerService) saveImportedCredential(adminID uint, entry *importedCredential, existing *models.Credential) (uint, error) {
+	encryptedEmail, err := s.Encryption.Encrypt(entry.Email)
+	if err != nil {
+		return 0, err
+	}
+	encryptedPassword, err := s.Encryption.Encrypt(entry.Password)
+	if err != nil {
+		return 0, err
+	}
+	encryptedUsername, err := s.Encryption.Encrypt(entry.Username)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	strength := s.Policy.EvaluatePasswordStrength(entry.Password, entry.Platform, entry.Email)
+
+	credential := models.Credential{
+		Platform:          entry.Platform,
+		Email:             encryptedEmail,
+		Username:          encryptedUsername,
+		Password:          encryptedPassword,
+		URL:               entry.URL,
+		Notes:             entry.Notes,
+		CreatedByID:       adminID,
+		IsActive:          true,
+		PasswordScore:     strength.Score,
+		PasswordChangedAt: &now,
+	}
+
+	if entry.TOTP != "" {
+		config, _ := importedTOTPConfig(entry.TOTP)
+		encryptedSecret, err := s.Encryption.Encrypt(config.Secret)
+		if err != nil {
+			return 0, err
+		}
+		credential.TOTPSecret = encryptedSecret
+		credential.TOTPDigits = config.Digits
+		credential.TOTPPeriod = config.Period
+		credential.TOTPAlgorithm = config.Algorithm
+	}
+
+	if existing == nil {
+		if err := s.DB.Create(&credential).Error; err != nil {
+			return 0, err
+		}
+		return credential.ID, nil
+	}
+
+	updates := map[string]interface{}{
+		"username":            credential.Username,
+		"password":            credential.Password,
+		"password_score":      credential.PasswordScore,
+		"password_changed_at": credential.PasswordChangedAt,
+	}
+	if entry.URL != "" {
+		updates["url"] = entry.URL
+	}
+	if entry.Notes != "" {
+		updates["notes"] = entry.Notes
+	}
+	if credential.TOTPSecret != "" {
+		updates["totp_secret"] = credential.TOTPSecret
+		updates["totp_digits"] = credential.TOTPDigits
+		updates["totp_period"] = credential.TOTPPeriod
+		updates["totp_algorithm"] = credential.TOTPAlgorithm
+	}
+
+	if err := s.DB.Model(existing).Updates(updates).Error; err != nil {
+		return 0, err
+	}
+	return existing.ID, nil
+}
+
+// credentialsByPlatformAndEmail indexes existing credentials for duplicate detection.
+// Emails are encrypted with random nonces, so they have to be decrypted to compare.
+func (s *PasswordManagerService) credentialsByPlatformAndEmail() (map[string]*models.Credential, error) {
+	var credentials []models.Credential
+	if err := s.DB.Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]*models.Credential, len(credentials))
+	for i := range credentials {
+		email, err := s.Encryption.Decrypt(credentials[i].Email)
+		if err != nil {
+			continue
+		}
+		index[duplicateKey(credentials[i].Platform, email)] = &credentials[i]
+	}
+	return index, nil
+}
+
+// decryptForExport converts a stored credential into its plaintext export form
+func (s *PasswordManagerService) decryptForExport(credential *models.Credential) (*exportedCredential, error) {
+	email, err := s.Encryption.Decrypt(credential.Email)
+	if err != nil {
+		return nil, err
+	}
+	password, err := s.Encryption.Decrypt(credential.Password)
+	if err != nil {
+		return nil, err
+	}
+	username, err := s.Encryption.Decrypt(credential.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	exported := &exportedCredential{
+		Platform: credential.Platform,
+		Email:    email,
+		Username: username,
+		Password: password,
+		URL:      credential.URL,
+		Notes:    credential.Notes,
+		IsActive: credential.IsActive,
+	}
+
+	if credential.TOTPSecret != "" {
+		secret, err := s.Encryption.Decrypt(credential.TOTPSecret)
+		if err != nil {
+			return nil, err
+		}
+		exported.TOTP = &TOTPConfig{
+			Secret:    secret,
+			Digits:    credential.TOTPDigits,
+			Period:    credential.TOTPPeriod,
+			Algorithm: credential.TOTPAlgorithm,
+		}
+	}
+
+	return exported, nil
+}
+
+// bulkOperationItem is the per-item summary kept in the audit trail. Account emails are left out
+// so the trail does not list whose credentials were moved.
+type bulkOperationItem struct {
+	Row          int      `json:"row,omitempty"`
+	Platform     string   `json:"platform"`
+	Status       string   `json:"status"`
+	CredentialID uint     `json:"credential_id,omitempty"`
+	Errors       []string `json:"errors,omitempty"`
+}
+
+// RedactBulkOperationEmails removes account emails from audit entries written before they were
+// left out of the summary. It is safe to run repeatedly.
+func (s *PasswordManagerService) RedactBulkOperationEmails() error {
+	return s.DB.Exec(`
+		UPDATE credential_bulk_operations
+		SET details = (SELECT json_agg(item::jsonb - 'email') FROM json_array_elements(details) item)
+		WHERE json_typeof(details) = 'array' AND json_array_length(details) > 0 AND details::text LIKE '%"email"%'`).Error
+}
+
+// recordBulkOperation writes the audit entry for an import or export and returns its ID
+func (s *PasswordManagerService) recordBulkOperation(adminID uint, operation, format string, dryRun bool, total, created, updated, skipped, failed int, items []ImportItemResult, ipAddress, userAgent string) uint {
+	summary := make([]bulkOperationItem, len(items))
+	for i, item := range items {
+		summary[i] = bulkOperationItem{
+			Row:          item.Row,
+			Platform:     item.Platform,
+			Status:       item.Status,
+			CredentialID: item.CredentialID,
+			Errors:       item.Errors,
+		}
+	}
+	details, _ := json.Marshal(summary)
+
+	entry := &models.CredentialBulkOperation{
+		AdminID:      adminID,
+		Operation:    operation,
+		Format:       format,
+		DryRun:       dryRun,
+		TotalItems:   total,
+		CreatedCount: created,
+		UpdatedCount: updated,
+		SkippedCount: skipped,
+		FailedCount:  failed,
+		Details:      string(details),
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		PerformedAt:  time.Now(),
+	}
+	if err := s.DB.Create(entry).Error; err != nil {
+		log.Printf("Failed to record credential %s audit entry: %v", operation, err)
+		return 0
+	}
+	return entry.ID
+}
+
+func duplicateKey(platform, email string) string {
+	return strings.ToLower(strings.TrimSpace(platform)) + "|" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// importedTOTPConfig accepts either an otpauth:// URI or a bare base32 seed
+func importedTOTPConfig(value string) (TOTPConfig, error) {
+	config := TOTPConfig{Secret: value}
+	if strings.HasPrefix(strings.ToLower(value), "otpauth://") {
+		parsed, err := ParseOTPAuthURI(value)
+		if err != nil {
+			return config, err
+		}
+		config = *parsed
+	}
+	return NormalizeTOTPConfig(config)
+}
+
+// parseImportContent dispatches to the parser for the given format
+func parseImportContent(format, content, passphrase string) ([]importedCredential, error) {
+	if strings.TrimSpace(content) == "" {
+		return nil, errors.New("import content is empty")
+	}
+
+	switch format {
+	case TransferFormatBitwardenCSV:
+		return parseBitwardenCSV(content)
+	case TransferFormatBitwardenJSON:
+		return parseBitwardenJSON(content)
+	case TransferFormatKeePassCSV:
+		return parseKeePassCSV(content)
+	case TransferFormatProjectXEncrypted:
+		return parseEncryptedArchive(content, passphrase)
+	default:
+		return nil, fmt.Errorf("unsupported import format: %s", format)
+	}
+}
+
+// readCSVWithHeader returns rows as maps keyed by lower-cased header names
+func readCSVWithHeader(content string) ([]map[string]string, error) {
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(content, "\ufeff")))
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+	if len(records) < 1 {
+		return nil, errors.New("CSV has no header row")
+	}
+
+	header := make([]string, len(records[0]))
+	for i, name := range records[0] {
+		header[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+
+	var rows []map[string]string
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// firstField returns the first non-empty value among several possible column names
+func firstField(row map[string]string, names ...string) string {
+	for _, name := range names {
+		if value := row[name]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// splitLogin maps a single login field onto the email/username pair used by Credential
+func splitLogin(login string) (email, username string) {
+	if strings.Contains(login, "@") {
+		return login, ""
+	}
+	return login, login
+}
+
+// parseBitwardenCSV reads Bitwarden's CSV export (login items only)
+func parseBitwardenCSV(content string) ([]importedCredential, error) {
+	rows, err := readCSVWithHeader(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []importedCredential
+	for i, row := range rows {
+		if itemType := row["type"]; itemType != "" && itemType != "login" {
+			continue // Secure notes, cards and identities are not credentials
+		}
+		email, username := splitLogin(row["login_username"])
+		entries = append(entries, importedCredential{
+			Row:      i + 2, // Header is row 1
+			Platform: row["name"],
+			Email:    email,
+			Username: username,
+			Password: row["login_password"],
+			URL:      strings.Split(row["login_uri"], ",")[0],
+			Notes:    row["notes"],
+			TOTP:     row["login_totp"],
+		})
+	}
+	return entries, nil
+}
+
+// parseBitwardenJSON reads Bitwarden's unencrypted JSON export
+func parseBitwardenJSON(content string) ([]importedCredential, error) {
+	var export struct {
+		Encrypted bool `json:"encrypted"`
+		Items     []struct {
+			Type  int    `json:"type"`
+			Name  string `json:"name"`
+			Notes string `json:"notes"`
+			Login *struct {
+				Username string `json:"username"`
+				Password string `json:"password"`
+				TOTP     string `json:"totp"`
+				URIs     []struct {
+					URI string `json:"uri"`
+				} `json:"uris"`
+			} `json:"login"`
+		} `json:"items"`
+	}
+
+	if err := json.Unmarshal([]byte(content), &export); err != nil {
+		return nil, fmt.Errorf("invalid Bitwarden JSON: %v", err)
+	}
+	if export.Encrypted {
+		return nil, errors.New("encrypted Bitwarden exports are not supported; export as unencrypted JSON")
+	}
+
+	var entries []importedCredential
+	for i, item := range export.Items {
+		if item.Type != 1 || item.Login == nil {
+			continue // Only login items are credentials
+		}
+		email, username := splitLogin(item.Login.Username)
+		entry := importedCredential{
+			Row:      i + 1,
+			Platform: strings.TrimSpace(item.Name),
+			Email:    email,
+			Username: username,
+			Password: item.Login.Password,
+			Notes:    item.Notes,
+			TOTP:     item.Login.TOTP,
+		}
+		if len(item.Login.URIs) > 0 {
+			entry.URL = item.Login.URIs[0].URI
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// parseKeePassCSV reads KeePassXC and KeePass 2 CSV exports
+func parseKeePassCSV(content string) ([]importedCredential, error) {
+	rows, err := readCSVWithHeader(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []importedCredential
+	for i, row := range rows {
+		email, username := splitLogin(firstField(row, "username", "login name", "user name"))
+		entries = append(entries, importedCredential{
+			Row:      i + 2, // Header is row 1
+			Platform: firstField(row, "title", "account"),
+			Email:    email,
+			Username: username,
+			Password: row["password"],
+			URL:      firstField(row, "url", "web site"),
+			Notes:    firstField(row, "notes", "comments"),
+			TOTP:     row["totp"],
+		})
+	}
+	return entries, nil
+}
+
+// parseEncryptedArchive reads an archive produced by ExportCredentials
+func parseEncryptedArchive(content, passphrase string) ([]importedCredential, error) {
+	payload, err := openExportArchive(content, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []importedCredential
+	for i, credential := range payload.Credentials {
+		entry := importedCredential{
+			Row:      i + 1,
+			Platform: credential.Platform,
+			Email:    credential.Email,
+			Username: credential.Username,
+			Password: credential.Password,
+			URL:      credential.URL,
+			Notes:    credential.Notes,
+		}
+		if credential.TOTP != nil {
+			entry.TOTP = fmt.Sprintf("otpauth://totp/%s?secret=%s&digits=%d&period=%d&algorithm=%s",
+				url.PathEscape(credential.Platform), credential.TOTP.Secret,
+				credential.TOTP.Digits, credential.TOTP.Period, credential.TOTP.Algorithm)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// sealExportArchive compresses and encrypts the payload with a passphrase-derived key
+func sealExportArchive(payload exportPayload, passphrase string) (*ExportArchive, error) {
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(passphrase), salt, exportKDFTime, exportKDFMemory, exportKDFThreads, 32)
+	gcm, err := newArchiveGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	archive := &ExportArchive{
+		Type:       exportArchiveType,
+		Version:    exportArchiveVersion,
+		CreatedAt:  payload.ExportedAt,
+		Count:      len(payload.Credentials),
+		KDF:        "argon2id",
+		KDFTime:    exportKDFTime,
+		KDFMemory:  exportKDFMemory,
+		KDFThreads: exportKDFThreads,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+	}
+
+	// Bind the header to the ciphertext so it cannot be altered
+	ciphertext := gcm.Seal(nil, nonce, compressed.Bytes(), archiveAdditionalData(archive))
+	archive.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
+
+	return archive, nil
+}
+
+// openExportArchive decrypts and decompresses an archive produced by sealExportArchive
+func openExportArchive(content, passphrase string) (*exportPayload, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase is required for encrypted archives")
+	}
+
+	var archive ExportArchive
+	if err := json.Unmarshal([]byte(content), &archive); err != nil {
+		return nil, errors.New("invalid archive")
+	}
+	if archive.Type != exportArchiveType || archive.Version != exportArchiveVersion || archive.KDF != "argon2id" {
+		return nil, errors.New("unsupported archive type or version")
+	}
+	// The KDF parameters come from the file: accept only the ones exports are written with, so an
+	// archive cannot make key derivation panic or exhaust memory
+	if archive.KDFTime != exportKDFTime || archive.KDFMemory != exportKDFMemory || archive.KDFThreads != exportKDFThreads {
+		return nil, errors.New("unsupported archive key derivation parameters")
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(archive.Salt)
+	if err != nil {
+		return nil, errors.New("invalid archive salt")
+	}
+	nonce, err := base64.StdEncoding.DecodeString(archive.Nonce)
+	if err != nil {
+		return nil, errors.New("invalid archive nonce")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(archive.Ciphertext)
+	if err != nil {
+		return nil, errors.New("invalid archive ciphertext")
+	}
+
+	key := argon2.IDKey([]byte(passphrase), salt, archive.KDFTime, archive.KDFMemory, archive.KDFThreads, 32)
+	gcm, err := newArchiveGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid archive nonce")
+	}
+
+	compressed, err := gcm.Open(nil, nonce, ciphertext, archiveAdditionalData(&archive))
+	if err != nil {
+		return nil, errors.New("wrong passphrase or corrupted archive")
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, errors.New("corrupted archive")
+	}
+	defer reader.Close()
+
+	var payload exportPayload
+	if err := json.NewDecoder(reader).Decode(&payload); err != nil {
+		return nil, errors.New("corrupted archive")
+	}
+	return &payload, nil
+}
+
+func newArchiveGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func archiveAdditionalData(archive *ExportArchive) []byte {
+	return []byte(fmt.Sprintf("%s|%d|%d|%s|%d|%d|%d|%s",
+		archive.Type, archive.Version, archive.Count, archive.KDF,
+		archive.KDFTime, archive.KDFMemory, archive.KDFThreads, archive.Salt))
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPDFUnsupportedText is returned when a document has text the built-in fonts cannot show
+var ErrPDFUnsupportedText = errors.New("the PDF fonts cannot show some of the text")
+
+// A4 portrait in PDF points
+const (
+	pdfPageWidth  = 595.28
+	pdfPageHeight = 841.89
+	pdfMargin     = 50.0
+)
+
+// pdfDocument is a minimal text-only PDF writer for tabular reports. It uses the standard
+// Helvetica fonts, so no font files are embedded; characters outside Latin-1 are written as "?"
+// and mark the document as lossy.
+type pdfDocument struct {
+	pages []*bytes.Buffer
+	y     float64 // Baseline of the next line on the current page
+	lossy bool    // Some text could not be shown
+}
+
+func newPDFDocument() *pdfDocument {
+	d := &pdfDocument{}
+	d.addPage()
+	return d
+}
+
+// heading writes a bold line of the given size
+func (d *pdfDocument) heading(text string, size float64) {
+	d.writeLine([]string{text}, []float64{pdfPageWidth - 2*pdfMargin}, size, true)
+}
+
+// paragraph writes a regular line
+func (d *pdfDocument) paragraph(text string) {
+	d.writeLine([]string{text}, []float64{pdfPageWidth - 2*pdfMargin}, 10, false)
+}
+
+// tableRow writes one row of columns with the given widths
+func (d *pdfDocument) tableRow(columns []string, widths []float64, bold bool) {
+	d.writeLine(columns, widths, 9, bold)
+}
+
+// space advances the cursor by the given number of points
+func (d *pdfDocument) space(points float64) {
+	d.y -= points
+}
+
+func (d *pdfDocument) writeLine(columns []string, widths []float64, size float64, bold bool) {
+	lineHeight := size * 1.4
+	if d.y-lineHeight < pdfMargin {
+		d.addPage()
+	}
+	d.y -= lineHeight
+
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+
+	page := d.pages[len(d.pages)-1]
+	x := pdfMargin
+	for i, column := range columns {
+		// Helvetica averages about half an em per character; clip so columns do not overlap
+		// Cut by runes so a multi-byte character is never split
+		if runes, maxChars := []rune(column), int(widths[i]/(size*0.5)); len(runes) > maxChars && maxChars > 3 {
+			column = string(runes[:maxChars-3]) + "..."
+		}
+		if !pdfCanShow(column) {
+			d.lossy = true
+		}
+		fmt.Fprintf(page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, d.y, pdfEscape(column))
+		x += widths[i]
+	}
+}
+
+func (d *pdfDocument) addPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = pdfPageHeight - pdfMargin
+}
+
+// bytes assembles the document: catalog, page tree, the two fonts, then a page and content
+// stream object per page, followed by the cross-reference table
+func (d *pdfDocument) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+
+	writeObject := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	var kids []string
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	writeObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	writeObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range d.pages {
+		writeObject(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i,
+		))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// pdfEscape converts text to a Latin-1 PDF string literal body
+func pdfEscape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r < 32:
+			b.WriteByte(' ')
+		case r < 256:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// pdfCanShow reports whether every character of text is in Latin-1, which pdfEscape keeps
+func pdfCanShow(text string) bool {
+	for _, r := range text {
+		if r >= 256 {
+			return false
+		}
+	}
+	return true
+}
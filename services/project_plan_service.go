@@ -0,0 +1,1025 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"project-x/models"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPlanProjectNotFound  = errors.New("project not found")
+	ErrProjectHasNoMembers  = errors.New("project has no team members, add team members first")
+	ErrPlanDraftNotFound    = errors.New("plan draft not found")
+	ErrPlanDraftClosed      = errors.New("plan draft was already confirmed or discarded")
+	ErrPlanVersionConflict  = errors.New("plan draft has changed since that version, reload it and try again")
+	ErrPlanVersionNotFound  = errors.New("plan version not found")
+	ErrPlanTaskNotFound     = errors.New("task not found in the plan")
+	ErrInvalidPlanTask      = errors.New("invalid plan task")
+	ErrPlanFeedbackRequired = errors.New("feedback is required to regenerate tasks")
+	ErrPlanNothingToCompare = errors.New("nothing to compare: the draft has a single version")
+)
+
+// ProjectPlanService keeps AI-generated task plans as drafts that managers edit task by task,
+// partly regenerate with feedback, compare and finally confirm into tasks
+type ProjectPlanService struct {
+	DB        *gorm.DB
+	Generator *AIProjectTaskGenerator // Needed to regenerate and confirm
+}
+
+func NewProjectPlanService(db *gorm.DB, generator *AIProjectTaskGenerator) *ProjectPlanService {
+	return &ProjectPlanService{
+		DB:        db,
+		Generator: generator,
+	}
+}
+
+// PlanTask is a task of a plan draft. Its key stays the same across versions, so edits address
+// tasks by key and versions can be compared task by task.
+type PlanTask struct {
+	Key            string     `json:"key"`
+	Title          string     `json:"title"`
+	Description    string     `json:"description"`
+	AssignedToID   uint       `json:"assigned_to_user_id"`
+	EstimatedHours int        `json:"estimated_hours"`
+	Priority       string     `json:"priority"`   // high, medium, low
+	DependsOn      []string   `json:"depends_on"` // Keys of the tasks this depends on
+	StartTime      *time.Time `json:"start_time,omitempty"`
+	EndTime        *time.Time `json:"end_time,omitempty"`
+}
+
+// PlanTaskChanges are the fields a manager changes on one plan task; nil fields stay as they are.
+// The times can be cleared with an explicit null.
+type PlanTaskChanges struct {
+	Title          *string      `json:"title"`
+	Description    *string      `json:"description"`
+	AssignedToID   *uint        `json:"assigned_to_user_id"`
+	EstimatedHours *int         `json:"estimated_hours"`
+	Priority       *string      `json:"priority"`
+	DependsOn      *[]string    `json:"depends_on"`
+	StartTime      OptionalTime `json:"start_time"`
+	EndTime        OptionalTime `json:"end_time"`
+}
+
+// OptionalTime is a time of a partial update. Set tells a field that was sent, with Time nil for
+// null, from one that was left out.
+type OptionalTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+func (o *OptionalTime) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Time = nil
+		return nil
+	}
+
+	var value time.Time
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	o.Time = &value
+	return nil
+}
+
+// PlanVersion is one version of a plan draft with its tasks
+type PlanVersion struct {
+	Version          int                      `json:"version"`
+	Change           models.ProjectPlanChange `json:"change"`
+	Note             string                   `json:"note,omitempty"`
+	Summary          string                   `json:"summary"`
+	Tasks            []PlanTask               `json:"tasks"`
+	ValidationIssues []AIValidationIssue      `json:"validation_issues,omitempty"`
+	RejectedTasks    []RejectedTask           `json:"rejected_tasks,omitempty"`
+	CreatedBy        uint                     `json:"created_by"`
+	CreatedAt        time.Time                `json:"created_at"`
+}
+
+// PlanVersionInfo describes a version of a plan draft without its tasks
+type PlanVersionInfo struct {
+	Version   int                      `json:"version"`
+	Change    models.ProjectPlanChange `json:"change"`
+	Note      string                   `json:"note,omitempty"`
+	TaskCount int                      `json:"task_count"`
+	CreatedBy uint                     `json:"created_by"`
+	CreatedAt time.Time                `json:"created_at"`
+}
+
+// PlanDraft is a plan draft with the version asked for and the list of all its versions
+type PlanDraft struct {
+	ID               uint                          `json:"id"`
+	ProjectID        uint                          `json:"project_id"`
+	Status           models.ProjectPlanDraftStatus `json:"status"`
+	CurrentVersion   int                           `json:"current_version"`
+	CreatedBy        uint                          `json:"created_by"`
+	ConfirmedVersion *int                          `json:"confirmed_version,omitempty"`
+	ConfirmedBy      *uint                         `json:"confirmed_by,omitempty"`
+	ConfirmedAt      *time.Time                    `json:"confirmed_at,omitempty"`
+	CreatedTaskIDs   []uint                        `json:"created_task_ids,omitempty"`
+	CreatedAt        time.Time                     `json:"created_at"`
+	UpdatedAt        time.Time                     `json:"updated_at"`
+
+	Version  *PlanVersion      `json:"version,omitempty"`
+	Versions []PlanVersionInfo `json:"versions,omitempty"`
+}
+
+// PlanFieldChange is one field of a task that differs between two versions
+type PlanFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// PlanTaskChange is a task present in both versions with the fields that differ
+type PlanTaskChange struct {
+	Key     string            `json:"key"`
+	Title   string            `json:"title"`
+	Changes []PlanFieldChange `json:"changes"`
+}
+
+// PlanDiff compares two versions of a plan draft by task key
+type PlanDiff struct {
+	DraftID   uint             `json:"draft_id"`
+	From      int              `json:"from"`
+	To        int              `json:"to"`
+	Added     []PlanTask       `json:"added"`
+	Removed   []PlanTask       `json:"removed"`
+	Changed   []PlanTaskChange `json:"changed"`
+	Unchanged int              `json:"unchanged"`
+	Reordered bool             `json:"reordered"` // Tasks in both versions are in a different order
+}
+
+// GenerationRequest builds the AI generation request for a project from its details and team
+func (s *ProjectPlanService) GenerationRequest(projectID, requestedBy uint) (ProjectTaskGenerationRequest, error) {
+	projectService := NewProjectService(s.DB)
+	project, err := projectService.GetProjectWithDetails(projectID)
+	if err != nil {
+		return ProjectTaskGenerationRequest{}, ErrPlanProjectNotFound
+	}
+
+	// Get project members with their job roles
+	members, userProjects, err := projectService.GetProjectMembers(projectID)
+	if err != nil {
+		return ProjectTaskGenerationRequest{}, fmt.Errorf("failed to fetch project members: %v", err)
+	}
+	if len(members) == 0 {
+		return ProjectTaskGenerationRequest{}, ErrProjectHasNoMembers
+	}
+
+	userProjectMap := make(map[uint]models.UserProject)
+	for _, up := range userProjects {
+		userProjectMap[up.UserID] = up
+	}
+
+	var teamMembers []TeamMemberInfo
+	for _, member := range members {
+		userProject := userProjectMap[member.ID]
+		teamMembers = append(teamMembers, TeamMemberInfo{
+			UserID:     member.ID,
+			Username:   member.Username,
+			JobRole:    userProject.JobRole,
+			Role:       userProject.Role,
+			Department: member.Department,
+			Skills:     member.Skills,
+		})
+	}
+
+	return ProjectTaskGenerationRequest{
+		ProjectID:    project.ID,
+		ProjectTitle: project.Title,
+		Description:  project.Description,
+		TeamMembers:  teamMembers,
+		StartDate:    project.StartDate,
+		EndDate:      project.EndDate,
+		RequestedBy:  requestedBy,
+	}, nil
+}
+
+// CreateDraft saves a generated plan as version 1 of a new draft, keying its tasks t1, t2, ...
+func (s *ProjectPlanService) CreateDraft(projectID, userID uint, generation *TaskGenerationResponse) (*PlanDraft, error) {
+	keys := make([]string, len(generation.Tasks))
+	for i := range keys {
+		keys[i] = planTaskKey(i + 1)
+	}
+
+	draft := models.ProjectPlanDraft{
+		ProjectID:      projectID,
+		CreatedBy:      userID,
+		Status:         models.ProjectPlanDraftOpen,
+		CurrentVersion: 1,
+		NextTaskKey:    len(keys) + 1,
+	}
+	version := PlanVersion{
+		Version:          1,
+		Change:           models.ProjectPlanChangeGenerated,
+		Summary:          generation.Summary,
+		Tasks:            planTasksFromGenerated(generation.Tasks, keys),
+		ValidationIssues: generation.ValidationIssues,
+		RejectedTasks:    generation.RejectedTasks,
+		CreatedBy:        userID,
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&draft).Error; err != nil {
+			return err
+		}
+		record, err := versionRecord(draft.ID, version)
+		if err != nil {
+			return err
+		}
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save plan draft: %v", err)
+	}
+
+	return s.GetDraft(projectID, draft.ID, 0)
+}
+
+// GetProjectDrafts lists a project's plan drafts, newest first, optionally with one status
+func (s *ProjectPlanService) GetProjectDrafts(projectID uint, status models.ProjectPlanDraftStatus) ([]PlanDraft, error) {
+	query := s.DB.Where("project_id = ?", projectID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var drafts []models.ProjectPlanDraft
+	if err := query.Order("created_at DESC").Find(&drafts).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]PlanDraft, len(drafts))
+	for i, draft := range drafts {
+		result[i] = draftView(draft)
+	}
+	return result, nil
+}
+
+// GetDraft returns a draft with one of its versions, the current one when version is 0
+func (s *ProjectPlanService) GetDraft(projectID, draftID uint, version int) (*PlanDraft, error) {
+	draft, err := s.loadDraft(projectID, draftID)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		version = draft.CurrentVersion
+	}
+
+	var records []models.ProjectPlanVersion
+	if err := s.DB.Where("draft_id = ?", draft.ID).Order("version ASC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	view := draftView(*draft)
+	for _, record := range records {
+		decoded, err := decodeVersion(record)
+		if err != nil {
+			return nil, err
+		}
+		view.Versions = append(view.Versions, PlanVersionInfo{
+			Version:   decoded.Version,
+			Change:    decoded.Change,
+			Note:      decoded.Note,
+			TaskCount: len(decoded.Tasks),
+			CreatedBy: decoded.CreatedBy,
+			CreatedAt: decoded.CreatedAt,
+		})
+		if decoded.Version == version {
+			view.Version = decoded
+		}
+	}
+	if view.Version == nil {
+		return nil, ErrPlanVersionNotFound
+	}
+	return &view, nil
+}
+
+// UpdateTask changes fields of one task and saves the result as a new version. baseVersion is the
+// version the manager edited, 0 for the current one.
+func (s *ProjectPlanService) UpdateTask(projectID, draftID uint, key string, changes PlanTaskChanges, baseVersion int, userID uint) (*PlanDraft, error) {
+	draft, current, err := s.editableDraft(projectID, draftID, baseVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	index := slices.IndexFunc(current.Tasks, func(task PlanTask) bool { return task.Key == key })
+	if index < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPlanTaskNotFound, key)
+	}
+
+	tasks := slices.Clone(current.Tasks)
+	task := tasks[index]
+	if changes.Title != nil {
+		task.Title = strings.TrimSpace(*changes.Title)
+	}
+	if changes.Description != nil {
+		task.Description = *changes.Description
+	}
+	if changes.AssignedToID != nil {
+		task.AssignedToID = *changes.AssignedToID
+	}
+	if changes.EstimatedHours != nil {
+		task.EstimatedHours = *changes.EstimatedHours
+	}
+	if changes.Priority != nil {
+		task.Priority = strings.ToLower(strings.TrimSpace(*changes.Priority))
+	}
+	if changes.DependsOn != nil {
+		task.DependsOn = *changes.DependsOn
+	}
+	if changes.StartTime.Set {
+		task.StartTime = changes.StartTime.Time
+	}
+	if changes.EndTime.Set {
+		task.EndTime = changes.EndTime.Time
+	}
+	tasks[index] = task
+
+	if err := s.validatePlanTask(projectID, task, tasks); err != nil {
+		return nil, err
+	}
+
+	return s.saveVersion(draft, PlanVersion{
+		Change:  models.ProjectPlanChangeTaskEdited,
+		Note:    key,
+		Summary: current.Summary,
+		Tasks:   tasks,
+	}, draft.NextTaskKey, userID)
+}
+
+// AddTask adds a task after the task with the key after, or at the end when after is empty
+func (s *ProjectPlanService) AddTask(projectID, draftID uint, task PlanTask, after string, baseVersion int, userID uint) (*PlanDraft, error) {
+	draft, current, err := s.editableDraft(projectID, draftID, baseVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	position := len(current.Tasks)
+	if after != "" {
+		index := slices.IndexFunc(current.Tasks, func(task PlanTask) bool { return task.Key == after })
+		if index < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrPlanTaskNotFound, after)
+		}
+		position = index + 1
+	}
+
+	task.Key = planTaskKey(draft.NextTaskKey)
+	task.Title = strings.TrimSpace(task.Title)
+	task.Priority = strings.ToLower(strings.TrimSpace(task.Priority))
+	if task.Priority == "" {
+		task.Priority = "medium"
+	}
+	tasks := slices.Insert(slices.Clone(current.Tasks), position, task)
+
+	if err := s.validatePlanTask(projectID, task, tasks); err != nil {
+		return nil, err
+	}
+
+	return s.saveVersion(draft, PlanVersion{
+		Change:  models.ProjectPlanChangeTaskAdded,
+		Note:    task.Key,
+		Summary: current.Summary,
+		Tasks:   tasks,
+	}, draft.NextTaskKey+1, userID)
+}
+
+// RemoveTask removes a task and the dependencies other tasks have on it
+func (s *ProjectPlanService) RemoveTask(projectID, draftID uint, key string, baseVersion int, userID uint) (*PlanDraft, error) {
+	draft, current, err := s.editableDraft(projectID, draftID, baseVersion)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(current.Tasks, func(task PlanTask) bool { return task.Key == key }) {
+		return nil, fmt.Errorf("%w: %s", ErrPlanTaskNotFound, key)
+	}
+
+	var tasks []PlanTask
+	for _, task := range current.Tasks {
+		if task.Key == key {
+			continue
+		}
+		task.DependsOn = slices.DeleteFunc(slices.Clone(task.DependsOn), func(dependency string) bool { return dependency == key })
+		tasks = append(tasks, task)
+	}
+
+	return s.saveVersion(draft, PlanVersion{
+		Change:  models.ProjectPlanChangeTaskRemoved,
+		Note:    key,
+		Summary: current.Summary,
+		Tasks:   tasks,
+	}, draft.NextTaskKey, userID)
+}
+
+// RegenerateTasks has the AI rework the tasks with the given keys, or the whole plan when keys is
+// empty, following the manager's feedback. Tasks that were not listed are kept exactly as they
+// were, even when the AI changed or dropped them.
+func (s *ProjectPlanService) RegenerateTasks(projectID, draftID uint, keys []string, feedback string, baseVersion int, userID uint) (*PlanDraft, error) {
+	feedback = strings.TrimSpace(feedback)
+	if feedback == "" {
+		return nil, ErrPlanFeedbackRequired
+	}
+
+	draft, current, err := s.editableDraft(projectID, draftID, baseVersion)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if !slices.ContainsFunc(current.Tasks, func(task PlanTask) bool { return task.Key == key }) {
+			return nil, fmt.Errorf("%w: %s", ErrPlanTaskNotFound, key)
+		}
+	}
+
+	req, err := s.GenerationRequest(projectID, userID)
+	if err != nil {
+		return nil, err
+	}
+	revision, err := s.Generator.RevisePlan(PlanRevisionRequest{
+		ProjectTaskGenerationRequest: req,
+		Tasks:                        current.Tasks,
+		Revise:                       keys,
+		Feedback:                     feedback,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// New tasks get fresh keys
+	nextTaskKey := draft.NextTaskKey
+	revisedKeys := slices.Clone(revision.Keys)
+	for i, key := range revisedKeys {
+		if key == "" {
+			revisedKeys[i] = planTaskKey(nextTaskKey)
+			nextTaskKey++
+		}
+	}
+	tasks := planTasksFromGenerated(revision.Tasks, revisedKeys)
+	issues := revision.ValidationIssues
+
+	// Put back the tasks that were not listed for revision as they were, in their place
+	if len(keys) > 0 {
+		for i, original := range current.Tasks {
+			if slices.Contains(keys, original.Key) {
+				continue
+			}
+			if index := slices.IndexFunc(tasks, func(task PlanTask) bool { return task.Key == original.Key }); index >= 0 {
+				tasks[index] = original
+				continue
+			}
+
+			position := 0
+			for j := i - 1; j >= 0; j-- {
+				previous := current.Tasks[j].Key
+				if index := slices.IndexFunc(tasks, func(task PlanTask) bool { return task.Key == previous }); index >= 0 {
+					position = index + 1
+					break
+				}
+			}
+			tasks = slices.Insert(tasks, position, original)
+			issues = append(issues, AIValidationIssue{
+				Field:    "tasks",
+				Message:  fmt.Sprintf("restored task %s, which was not listed for revision", original.Key),
+				Repaired: true,
+			})
+		}
+	}
+	tasks = cleanPlanDependencies(tasks)
+
+	return s.saveVersion(draft, PlanVersion{
+		Change:           models.ProjectPlanChangeRegenerated,
+		Note:             feedback,
+		Summary:          revision.Summary,
+		Tasks:            tasks,
+		ValidationIssues: issues,
+		RejectedTasks:    revision.RejectedTasks,
+	}, nextTaskKey, userID)
+}
+
+// DiffVersions compares two versions of a draft by task key
+func (s *ProjectPlanService) DiffVersions(projectID, draftID uint, from, to int) (*PlanDiff, error) {
+	draft, err := s.loadDraft(projectID, draftID)
+	if err != nil {
+		return nil, err
+	}
+	if to == 0 {
+		to = draft.CurrentVersion
+	}
+	if from == 0 {
+		if to <= 1 {
+			return nil, ErrPlanNothingToCompare
+		}
+		from = to - 1
+	}
+
+	fromVersion, err := s.loadVersion(draft.ID, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := s.loadVersion(draft.ID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &PlanDiff{
+		DraftID: draft.ID,
+		From:    from,
+		To:      to,
+		Added:   []PlanTask{},
+		Removed: []PlanTask{},
+		Changed: []PlanTaskChange{},
+	}
+
+	fromTasks := make(map[string]PlanTask)
+	var fromOrder []string
+	for _, task := range fromVersion.Tasks {
+		fromTasks[task.Key] = task
+	}
+	toKeys := make(map[string]bool)
+	var toOrder []string
+	for _, task := range toVersion.Tasks {
+		toKeys[task.Key] = true
+		previous, ok := fromTasks[task.Key]
+		if !ok {
+			diff.Added = append(diff.Added, task)
+			continue
+		}
+		toOrder = append(toOrder, task.Key)
+		if changes := diffPlanTask(previous, task); len(changes) > 0 {
+			diff.Changed = append(diff.Changed, PlanTaskChange{Key: task.Key, Title: task.Title, Changes: changes})
+		} else {
+			diff.Unchanged++
+		}
+	}
+	for _, task := range fromVersion.Tasks {
+		if !toKeys[task.Key] {
+			diff.Removed = append(diff.Removed, task)
+			continue
+		}
+		fromOrder = append(fromOrder, task.Key)
+	}
+	diff.Reordered = !slices.Equal(fromOrder, toOrder)
+
+	return diff, nil
+}
+
+// ConfirmDraft creates the tasks of one version of a draft, the current one when version is 0,
+// and closes the draft
+func (s *ProjectPlanService) ConfirmDraft(projectID, draftID uint, version int, userID uint) (*PlanDraft, []models.Task, error) {
+	draft, err := s.loadDraft(projectID, draftID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if draft.Status != models.ProjectPlanDraftOpen {
+		return nil, nil, ErrPlanDraftClosed
+	}
+	if version == 0 {
+		version = draft.CurrentVersion
+	}
+	confirmed, err := s.loadVersion(draft.ID, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(confirmed.Tasks) == 0 {
+		return nil, nil, fmt.Errorf("%w: the plan has no tasks to create", ErrInvalidPlanTask)
+	}
+
+	members, err := s.projectMemberIDs(projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, task := range confirmed.Tasks {
+		if task.Title == "" {
+			return nil, nil, fmt.Errorf("%w: task %s: title is required", ErrInvalidPlanTask, task.Key)
+		}
+		if !members[task.AssignedToID] {
+			return nil, nil, fmt.Errorf("%w: task %s: assigned user (ID: %d) is not a member of this project", ErrInvalidPlanTask, task.Key, task.AssignedToID)
+		}
+	}
+
+	// Close the draft first so that it cannot be confirmed twice
+	now := time.Now()
+	result := s.DB.Model(&models.ProjectPlanDraft{}).
+		Where("id = ? AND status = ?", draft.ID, models.ProjectPlanDraftOpen).
+		Updates(map[string]interface{}{
+			"status":            models.ProjectPlanDraftConfirmed,
+			"confirmed_version": version,
+			"confirmed_by":      userID,
+			"confirmed_at":      now,
+		})
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrPlanDraftClosed
+	}
+
+	createdTasks, err := s.Generator.CreateTasksFromGeneration(projectID, generatedFromPlanTasks(confirmed.Tasks))
+	if err == nil && len(createdTasks) == 0 {
+		err = errors.New("no task could be created")
+	}
+	if err != nil {
+		// Reopen the draft so the manager can fix it and try again
+		s.DB.Model(&models.ProjectPlanDraft{}).Where("id = ?", draft.ID).Updates(map[string]interface{}{
+			"status":            models.ProjectPlanDraftOpen,
+			"confirmed_version": nil,
+			"confirmed_by":      nil,
+			"confirmed_at":      nil,
+		})
+		return nil, nil, fmt.Errorf("failed to create tasks: %v", err)
+	}
+
+	taskIDs := make([]uint, len(createdTasks))
+	for i, task := range createdTasks {
+		taskIDs[i] = task.ID
+	}
+	idsJSON, _ := json.Marshal(taskIDs)
+	if err := s.DB.Model(&models.ProjectPlanDraft{}).Where("id = ?", draft.ID).Update("created_task_ids", string(idsJSON)).Error; err != nil {
+		log.Printf("Failed to record the tasks created from plan draft %d: %v", draft.ID, err)
+	}
+
+	view, err := s.GetDraft(projectID, draft.ID, version)
+	if err != nil {
+		return nil, createdTasks, err
+	}
+	return view, createdTasks, nil
+}
+
+// DiscardDraft closes a draft without creating its tasks
+func (s *ProjectPlanService) DiscardDraft(projectID, draftID uint) error {
+	draft, err := s.loadDraft(projectID, draftID)
+	if err != nil {
+		return err
+	}
+
+	result := s.DB.Model(&models.ProjectPlanDraft{}).
+		Where("id = ? AND status = ?", draft.ID, models.ProjectPlanDraftOpen).
+		Update("status", models.ProjectPlanDraftDiscarded)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPlanDraftClosed
+	}
+	return nil
+}
+
+// loadDraft loads a draft of the project
+func (s *ProjectPlanService) loadDraft(projectID, draftID uint) (*models.ProjectPlanDraft, error) {
+	var draft models.ProjectPlanDraft
+	err := s.DB.Where("id = ? AND project_id = ?", draftID, projectID).First(&draft).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPlanDraftNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// loadVersion loads and decodes one version of a draft
+func (s *ProjectPlanService) loadVersion(draftID uint, version int) (*PlanVersion, error) {
+	var record models.ProjectPlanVersion
+	err := s.DB.Where("draft_id = ? AND version = ?", draftID, version).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPlanVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeVersion(record)
+}
+
+// editableDraft loads a draft that is still open together with its current version, checking that
+// the version the manager worked from (0 for the current one) is still current
+func (s *ProjectPlanService) editableDraft(projectID, draftID uint, baseVersion int) (*models.ProjectPlanDraft, *PlanVersion, error) {
+	draft, err := s.loadDraft(projectID, draftID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if draft.Status != models.ProjectPlanDraftOpen {
+		return nil, nil, ErrPlanDraftClosed
+	}
+	if baseVersion != 0 && baseVersion != draft.CurrentVersion {
+		return nil, nil, ErrPlanVersionConflict
+	}
+
+	current, err := s.loadVersion(draft.ID, draft.CurrentVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	return draft, current, nil
+}
+
+// saveVersion adds the next version to a draft. The draft only moves on when nobody saved a
+// version since it was loaded.
+func (s *ProjectPlanService) saveVersion(draft *models.ProjectPlanDraft, version PlanVersion, nextTaskKey int, userID uint) (*PlanDraft, error) {
+	version.Version = draft.CurrentVersion + 1
+	version.CreatedBy = userID
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ProjectPlanDraft{}).
+			Where("id = ? AND current_version = ? AND status = ?", draft.ID, draft.CurrentVersion, models.ProjectPlanDraftOpen).
+			Updates(map[string]interface{}{
+				"current_version": version.Version,
+				"next_task_key":   nextTaskKey,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPlanVersionConflict
+		}
+
+		record, err := versionRecord(draft.ID, version)
+		if err != nil {
+			return err
+		}
+		return tx.Create(&record).Error
+	})
+	if errors.Is(err, ErrPlanVersionConflict) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save plan version: %v", err)
+	}
+
+	return s.GetDraft(draft.ProjectID, draft.ID, 0)
+}
+
+// validatePlanTask checks a task a manager edited or added: a title, an assignee from the project
+// team, a known priority, hours above zero, its start before its end, and dependencies on other
+// tasks of the plan that do not lead back to it
+func (s *ProjectPlanService) validatePlanTask(projectID uint, task PlanTask, tasks []PlanTask) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: task %s: %s", ErrInvalidPlanTask, task.Key, fmt.Sprintf(format, args...))
+	}
+
+	if task.Title == "" {
+		return invalid("title is required")
+	}
+	members, err := s.projectMemberIDs(projectID)
+	if err != nil {
+		return err
+	}
+	if !members[task.AssignedToID] {
+		return invalid("assigned user (ID: %d) is not a member of this project", task.AssignedToID)
+	}
+	if task.EstimatedHours <= 0 {
+		return invalid("estimated_hours must be greater than 0")
+	}
+	if !slices.Contains(taskPriorities, task.Priority) {
+		return invalid("priority must be one of %s", strings.Join(taskPriorities, ", "))
+	}
+	if task.StartTime != nil && task.EndTime != nil && !task.StartTime.Before(*task.EndTime) {
+		return invalid("end_time must be after start_time")
+	}
+
+	for _, dependency := range task.DependsOn {
+		if dependency == task.Key {
+			return invalid("a task cannot depend on itself")
+		}
+		if !slices.ContainsFunc(tasks, func(other PlanTask) bool { return other.Key == dependency }) {
+			return invalid("depends on unknown task %s", dependency)
+		}
+		if planTaskDependsOn(tasks, dependency, task.Key) {
+			return invalid("depending on %s would create a dependency cycle", dependency)
+		}
+	}
+	return nil
+}
+
+// projectMemberIDs returns the IDs of the project's members
+func (s *ProjectPlanService) projectMemberIDs(projectID uint) (map[uint]bool, error) {
+	var userIDs []uint
+	if err := s.DB.Model(&models.UserProject{}).Where("project_id = ?", projectID).Pluck("user_id", &userIDs).Error; err != nil {
+		return nil, err
+	}
+
+	members := make(map[uint]bool, len(userIDs))
+	for _, id := range userIDs {
+		members[id] = true
+	}
+	return members, nil
+}
+
+// planTaskKey is the key of the n-th task added to a draft
+func planTaskKey(n int) string {
+	return fmt.Sprintf("t%d", n)
+}
+
+// planTaskDependsOn reports whether the task with key from depends on target, directly or through
+// other tasks
+func planTaskDependsOn(tasks []PlanTask, from, target string) bool {
+	dependencies := make(map[string][]string, len(tasks))
+	for _, task := range tasks {
+		dependencies[task.Key] = task.DependsOn
+	}
+
+	visited := make(map[string]bool)
+	pending := []string{from}
+	for len(pending) > 0 {
+		key := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if key == target {
+			return true
+		}
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+		pending = append(pending, dependencies[key]...)
+	}
+	return false
+}
+
+// cleanPlanDependencies drops dependencies on tasks that are no longer in the plan, duplicates,
+// and dependencies that would close a cycle
+func cleanPlanDependencies(tasks []PlanTask) []PlanTask {
+	for i := range tasks {
+		original := tasks[i].DependsOn
+		tasks[i].DependsOn = []string{}
+		for _, dependency := range original {
+			if dependency == tasks[i].Key || slices.Contains(tasks[i].DependsOn, dependency) {
+				continue
+			}
+			if !slices.ContainsFunc(tasks, func(task PlanTask) bool { return task.Key == dependency }) {
+				continue
+			}
+			if planTaskDependsOn(tasks, dependency, tasks[i].Key) {
+				continue
+			}
+			tasks[i].DependsOn = append(tasks[i].DependsOn, dependency)
+		}
+	}
+	return tasks
+}
+
+// planTasksFromGenerated keys generated tasks and turns their dependency positions into keys
+func planTasksFromGenerated(generated []GeneratedTask, keys []string) []PlanTask {
+	tasks := make([]PlanTask, len(generated))
+	for i, task := range generated {
+		tasks[i] = PlanTask{
+			Key:            keys[i],
+			Title:          task.Title,
+			Description:    task.Description,
+			AssignedToID:   task.AssignedToID,
+			EstimatedHours: task.EstimatedHours,
+			Priority:       task.Priority,
+			DependsOn:      []string{},
+			StartTime:      task.StartTime,
+			EndTime:        task.EndTime,
+		}
+		for _, position := range task.Dependencies {
+			if position >= 1 && int(position) <= len(keys) {
+				tasks[i].DependsOn = append(tasks[i].DependsOn, keys[position-1])
+			}
+		}
+	}
+	return tasks
+}
+
+// generatedFromPlanTasks turns plan tasks back into generated tasks with dependency positions
+func generatedFromPlanTasks(tasks []PlanTask) []GeneratedTask {
+	positions := make(map[string]uint, len(tasks))
+	for i, task := range tasks {
+		positions[task.Key] = uint(i + 1)
+	}
+
+	generated := make([]GeneratedTask, len(tasks))
+	for i, task := range tasks {
+		generated[i] = GeneratedTask{
+			Title:          task.Title,
+			Description:    task.Description,
+			AssignedToID:   task.AssignedToID,
+			EstimatedHours: task.EstimatedHours,
+			Priority:       task.Priority,
+			Dependencies:   []uint{},
+			StartTime:      task.StartTime,
+			EndTime:        task.EndTime,
+		}
+		for _, dependency := range task.DependsOn {
+			if position, ok := positions[dependency]; ok {
+				generated[i].Dependencies = append(generated[i].Dependencies, position)
+			}
+		}
+	}
+	return generated
+}
+
+// diffPlanTask lists the fields that differ between two versions of a task
+func diffPlanTask(from, to PlanTask) []PlanFieldChange {
+	var changes []PlanFieldChange
+	add := func(field string, fromValue, toValue interface{}) {
+		changes = append(changes, PlanFieldChange{Field: field, From: fromValue, To: toValue})
+	}
+
+	if from.Title != to.Title {
+		add("title", from.Title, to.Title)
+	}
+	if from.Description != to.Description {
+		add("description", from.Description, to.Description)
+	}
+	if from.AssignedToID != to.AssignedToID {
+		add("assigned_to_user_id", from.AssignedToID, to.AssignedToID)
+	}
+	if from.EstimatedHours != to.EstimatedHours {
+		add("estimated_hours", from.EstimatedHours, to.EstimatedHours)
+	}
+	if from.Priority != to.Priority {
+		add("priority", from.Priority, to.Priority)
+	}
+	fromDependencies, toDependencies := slices.Clone(from.DependsOn), slices.Clone(to.DependsOn)
+	slices.Sort(fromDependencies)
+	slices.Sort(toDependencies)
+	if !slices.Equal(fromDependencies, toDependencies) {
+		add("depends_on", from.DependsOn, to.DependsOn)
+	}
+	if !sameTime(from.StartTime, to.StartTime) {
+		add("start_time", from.StartTime, to.StartTime)
+	}
+	if !sameTime(from.EndTime, to.EndTime) {
+		add("end_time", from.EndTime, to.EndTime)
+	}
+	return changes
+}
+
+func draftView(draft models.ProjectPlanDraft) PlanDraft {
+	view := PlanDraft{
+		ID:               draft.ID,
+		ProjectID:        draft.ProjectID,
+		Status:           draft.Status,
+		CurrentVersion:   draft.CurrentVersion,
+		CreatedBy:        draft.CreatedBy,
+		ConfirmedVersion: draft.ConfirmedVersion,
+		ConfirmedBy:      draft.ConfirmedBy,
+		ConfirmedAt:      draft.ConfirmedAt,
+		CreatedAt:        draft.CreatedAt,
+		UpdatedAt:        draft.UpdatedAt,
+	}
+	if draft.CreatedTaskIDs != nil {
+		json.Unmarshal([]byte(*draft.CreatedTaskIDs), &view.CreatedTaskIDs)
+	}
+	return view
+}
+
+func versionRecord(draftID uint, version PlanVersion) (models.ProjectPlanVersion, error) {
+	if version.Tasks == nil {
+		version.Tasks = []PlanTask{}
+	}
+	for i := range version.Tasks {
+		if version.Tasks[i].DependsOn == nil {
+			version.Tasks[i].DependsOn = []string{}
+		}
+	}
+	tasksJSON, err := json.Marshal(version.Tasks)
+	if err != nil {
+		return models.ProjectPlanVersion{}, err
+	}
+	if version.ValidationIssues == nil {
+		version.ValidationIssues = []AIValidationIssue{}
+	}
+	if version.RejectedTasks == nil {
+		version.RejectedTasks = []RejectedTask{}
+	}
+	issuesJSON, _ := json.Marshal(version.ValidationIssues)
+	rejectedJSON, _ := json.Marshal(version.RejectedTasks)
+
+	return models.ProjectPlanVersion{
+		DraftID:          draftID,
+		Version:          version.Version,
+		Change:           version.Change,
+		Note:             version.Note,
+		Summary:          version.Summary,
+		Tasks:            string(tasksJSON),
+		ValidationIssues: string(issuesJSON),
+		RejectedTasks:    string(rejectedJSON),
+		CreatedBy:        version.CreatedBy,
+	}, nil
+}
+
+func decodeVersion(record models.ProjectPlanVersion) (*PlanVersion, error) {
+	version := &PlanVersion{
+		Version:   record.Version,
+		Change:    record.Change,
+		Note:      record.Note,
+		Summary:   record.Summary,
+		CreatedBy: record.CreatedBy,
+		CreatedAt: record.CreatedAt,
+	}
+	if err := json.Unmarshal([]byte(record.Tasks), &version.Tasks); err != nil {
+		return nil, fmt.Errorf("failed to decode plan version %d: %v", record.Version, err)
+	}
+	if record.ValidationIssues != "" {
+		json.Unmarshal([]byte(record.ValidationIssues), &version.ValidationIssues)
+	}
+	if record.RejectedTasks != "" {
+		json.Unmarshal([]byte(record.RejectedTasks), &version.RejectedTasks)
+	}
+	return version, nil
+}
@@ -216,7 +216,8 @@ func (s *ProjectService) GetProjectProgress(projectID uint) (map[string]interfac
 
 	var totalTasks, completedTasks, inProgressTasks, pendingTasks int
 
-	// Count regular tasks
+	// Count regular tasks. A task's status is the category of its workflow state, so every state
+	// in the completed category counts towards progress.
 	for _, task := range tasks {
 		totalTasks++
 		switch task.Status {
@@ -247,11 +248,21 @@ func (s *ProjectService) GetProjectProgress(projectID uint) (map[string]interfac
 		progress = float64(completedTasks) / float64(totalTasks) * 100
 	}
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"total_tasks":       totalTasks,
 		"completed_tasks":   completedTasks,
 		"in_progress_tasks": inProgressTasks,
 		"pending_tasks":     pendingTasks,
 		"progress":          progress,
-	}, nil
+	}
+
+	workflow, err := s.GetProjectWorkflow(&projectID)
+	if err != nil {
+		return nil, err
+	}
+	if workflow.Custom {
+		result["workflow_states"] = workflow.StateCounts(tasks)
+	}
+
+	return result, nil
 }
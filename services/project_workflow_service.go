@@ -0,0 +1,333 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"project-x/models"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// WorkflowStateRequest describes one state when configuring a project workflow
+type WorkflowStateRequest struct {
+	Key      string            `json:"key" binding:"required"`
+	Name     string            `json:"name"`
+	Category models.TaskStatus `json:"category" binding:"required"`
+}
+
+// WorkflowTransitionRequest allows tasks to move from one state to another
+type WorkflowTransitionRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
+
+// ProjectWorkflow is the set of states and allowed transitions tasks in a project follow.
+// Projects without a custom workflow, and tasks without a project, use the built-in statuses
+// with every move allowed.
+type ProjectWorkflow struct {
+	ProjectID   uint                               `json:"project_id"`
+	Custom      bool                               `json:"custom"`
+	States      []models.ProjectWorkflowState      `json:"states"`
+	Transitions []models.ProjectWorkflowTransition `json:"transitions"`
+}
+
+// TaskTransition describes a task's move between workflow states
+type TaskTransition struct {
+	Task      *models.Task
+	FromState string
+	ToState   string
+}
+
+var workflowStateKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// defaultWorkflowStates mirrors the built-in task statuses
+var defaultWorkflowStates = []models.ProjectWorkflowState{
+	{Key: string(models.TaskStatusPending), Name: "Pending", Category: models.TaskStatusPending, SortOrder: 0},
+	{Key: string(models.TaskStatusInProgress), Name: "In Progress", Category: models.TaskStatusInProgress, SortOrder: 1},
+	{Key: string(models.TaskStatusCompleted), Name: "Completed", Category: models.TaskStatusCompleted, SortOrder: 2},
+	{Key: string(models.TaskStatusCancelled), Name: "Cancelled", Category: models.TaskStatusCancelled, SortOrder: 3},
+}
+
+// GetProjectWorkflow returns a project's workflow, or the built-in one when none is configured.
+// A nil project ID (a task outside any project) always gets the built-in workflow.
+func (s *ProjectService) GetProjectWorkflow(projectID *uint) (*ProjectWorkflow, error) {
+	if projectID == nil {
+		return &ProjectWorkflow{States: defaultWorkflowStates, Transitions: []models.ProjectWorkflowTransition{}}, nil
+	}
+
+	workflow := &ProjectWorkflow{ProjectID: *projectID}
+	if err := s.DB.Where("project_id = ?", *projectID).Order("sort_order ASC, id ASC").Find(&workflow.States).Error; err != nil {
+		return nil, err
+	}
+	if err := s.DB.Where("project_id = ?", *projectID).Order("id ASC").Find(&workflow.Transitions).Error; err != nil {
+		return nil, err
+	}
+
+	if len(workflow.States) == 0 {
+		workflow.States = defaultWorkflowStates
+		workflow.Transitions = []models.ProjectWorkflowTransition{}
+		return workflow, nil
+	}
+
+	workflow.Custom = true
+	return workflow, nil
+}
+
+// SetProjectWorkflow replaces a project's workflow. Every state maps onto a built-in status
+// category; the workflow needs a pending state for new tasks and a completed state to count as
+// done. Tasks in removed states fall back to the first state of their category.
+func (s *ProjectService) SetProjectWorkflow(projectID uint, states []WorkflowStateRequest, transitions []WorkflowTransitionRequest) (*ProjectWorkflow, error) {
+	if err := s.DB.First(&models.Project{}, projectID).Error; err != nil {
+		return nil, errors.New("project not found")
+	}
+
+	if len(states) == 0 {
+		return nil, errors.New("a workflow needs at least one state")
+	}
+
+	newStates := make([]models.ProjectWorkflowState, 0, len(states))
+	keys := make(map[string]bool)
+	categories := make(map[models.TaskStatus]bool)
+	for i, state := range states {
+		key := strings.TrimSpace(state.Key)
+		if !workflowStateKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid state key '%s': use lowercase letters, digits and underscores", state.Key)
+		}
+		if keys[key] {
+			return nil, fmt.Errorf("duplicate state key '%s'", key)
+		}
+		switch state.Category {
+		case models.TaskStatusPending, models.TaskStatusInProgress, models.TaskStatusCompleted, models.TaskStatusCancelled:
+		default:
+			return nil, fmt.Errorf("state '%s' has an invalid category: use pending, in_progress, completed or cancelled", key)
+		}
+
+		name := strings.TrimSpace(state.Name)
+		if name == "" {
+			name = key
+		}
+		if len(name) > 100 {
+			return nil, fmt.Errorf("state name for '%s' is too long", key)
+		}
+
+		keys[key] = true
+		categories[state.Category] = true
+		newStates = append(newStates, models.ProjectWorkflowState{
+			ProjectID: projectID,
+			Key:       key,
+			Name:      name,
+			Category:  state.Category,
+			SortOrder: i,
+		})
+	}
+	if !categories[models.TaskStatusPending] {
+		return nil, errors.New("a workflow needs a state in the pending category for new tasks")
+	}
+	if !categories[models.TaskStatusCompleted] {
+		return nil, errors.New("a workflow needs at least one state in the completed category")
+	}
+
+	newTransitions := make([]models.ProjectWorkflowTransition, 0, len(transitions))
+	seen := make(map[string]bool)
+	for _, transition := range transitions {
+		from, to := strings.TrimSpace(transition.From), strings.TrimSpace(transition.To)
+		if !keys[from] || !keys[to] {
+			return nil, fmt.Errorf("transition %s -> %s refers to an unknown state", transition.From, transition.To)
+		}
+		if from == to || seen[from+"->"+to] {
+			continue
+		}
+		seen[from+"->"+to] = true
+		newTransitions = append(newTransitions, models.ProjectWorkflowTransition{
+			ProjectID: projectID,
+			FromState: from,
+			ToState:   to,
+		})
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("project_id = ?", projectID).Delete(&models.ProjectWorkflowState{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("project_id = ?", projectID).Delete(&models.ProjectWorkflowTransition{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&newStates).Error; err != nil {
+			return err
+		}
+		if len(newTransitions) > 0 {
+			if err := tx.Create(&newTransitions).Error; err != nil {
+				return err
+			}
+		}
+
+		keyList := make([]string, 0, len(newStates))
+		for _, state := range newStates {
+			keyList = append(keyList, state.Key)
+		}
+		if err := tx.Model(&models.Task{}).
+			Where("project_id = ? AND workflow_state NOT IN ?", projectID, keyList).
+			Update("workflow_state", "").Error; err != nil {
+			return err
+		}
+
+		// Place tasks without a state in the first state of their category
+		placed := make(map[models.TaskStatus]bool)
+		for _, state := range newStates {
+			if placed[state.Category] {
+				continue
+			}
+			placed[state.Category] = true
+			if err := tx.Model(&models.Task{}).
+				Where("project_id = ? AND workflow_state = ? AND status = ?", projectID, "", state.Category).
+				Update("workflow_state", state.Key).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetProjectWorkflow(&projectID)
+}
+
+// ResetProjectWorkflow removes a project's custom workflow so its tasks use the built-in
+// statuses again. Each task keeps the status of the category it was in.
+func (s *ProjectService) ResetProjectWorkflow(projectID uint) error {
+	if err := s.DB.First(&models.Project{}, projectID).Error; err != nil {
+		return errors.New("project not found")
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("project_id = ?", projectID).Delete(&models.ProjectWorkflowState{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("project_id = ?", projectID).Delete(&models.ProjectWorkflowTransition{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Task{}).Where("project_id = ?", projectID).Update("workflow_state", "").Error
+	})
+}
+
+// State returns the state with the given key, or nil
+func (w *ProjectWorkflow) State(key string) *models.ProjectWorkflowState {
+	for i := range w.States {
+		if w.States[i].Key == key {
+			return &w.States[i]
+		}
+	}
+	return nil
+}
+
+// StateOf returns the state a task is in. Tasks that have not moved since the workflow was set,
+// or whose state was removed, are in the first state of their status category; nil means the
+// workflow has no state for that category.
+func (w *ProjectWorkflow) StateOf(task *models.Task) *models.ProjectWorkflowState {
+	if !w.Custom {
+		return w.State(string(task.Status))
+	}
+	if state := w.State(task.WorkflowState); state != nil {
+		return state
+	}
+	for i := range w.States {
+		if w.States[i].Category == task.Status {
+			return &w.States[i]
+		}
+	}
+	return nil
+}
+
+// CanTransition reports whether a task may move between two states. A workflow without
+// transitions allows every move, as does a task outside every state.
+func (w *ProjectWorkflow) CanTransition(from *models.ProjectWorkflowState, to *models.ProjectWorkflowState) bool {
+	if len(w.Transitions) == 0 || from == nil || from.Key == to.Key {
+		return true
+	}
+	for _, transition := range w.Transitions {
+		if transition.FromState == from.Key && transition.ToState == to.Key {
+			return true
+		}
+	}
+	return false
+}
+
+// planTransition validates a move of a task to a state and returns the transition with the
+// column updates that apply it. The task's status becomes the category of the new state.
+func (w *ProjectWorkflow) planTransition(task *models.Task, state string) (*TaskTransition, map[string]interface{}, error) {
+	to := w.State(state)
+	if to == nil {
+		if w.Custom {
+			return nil, nil, fmt.Errorf("'%s' is not a state in this project's workflow", state)
+		}
+		return nil, nil, errors.New("invalid status")
+	}
+
+	from := w.StateOf(task)
+	if !w.CanTransition(from, to) {
+		return nil, nil, fmt.Errorf("tasks cannot move from '%s' to '%s' in this project's workflow", from.Name, to.Name)
+	}
+
+	updates := map[string]interface{}{"status": to.Category}
+	if w.Custom {
+		updates["workflow_state"] = to.Key
+	}
+	return &TaskTransition{Task: task, FromState: w.stateKey(task), ToState: to.Key}, updates, nil
+}
+
+// StateCounts counts the tasks in each workflow state, in workflow order
+func (w *ProjectWorkflow) StateCounts(tasks []models.Task) []map[string]interface{} {
+	counts := make(map[string]int)
+	for i := range tasks {
+		if state := w.StateOf(&tasks[i]); state != nil {
+			counts[state.Key]++
+		}
+	}
+
+	result := make([]map[string]interface{}, 0, len(w.States))
+	for _, state := range w.States {
+		result = append(result, map[string]interface{}{
+			"key":      state.Key,
+			"name":     state.Name,
+			"category": state.Category,
+			"is_done":  state.Category == models.TaskStatusCompleted,
+			"count":    counts[state.Key],
+		})
+	}
+	return result
+}
+
+// stateKey returns the key of the state a task is in, falling back to its status
+func (w *ProjectWorkflow) stateKey(task *models.Task) string {
+	if state := w.StateOf(task); state != nil {
+		return state.Key
+	}
+	return string(task.Status)
+}
+
+// WorkflowStateKeys returns the workflow state each task is in, keyed by task ID. Tasks
+// outside a custom workflow report their built-in status.
+func (s *ProjectService) WorkflowStateKeys(tasks []models.Task) (map[uint]string, error) {
+	workflows := make(map[uint]*ProjectWorkflow)
+	keys := make(map[uint]string, len(tasks))
+	for i := range tasks {
+		if tasks[i].ProjectID == nil {
+			keys[tasks[i].ID] = string(tasks[i].Status)
+			continue
+		}
+
+		workflow, ok := workflows[*tasks[i].ProjectID]
+		if !ok {
+			var err error
+			if workflow, err = s.GetProjectWorkflow(tasks[i].ProjectID); err != nil {
+				return nil, err
+			}
+			workflows[*tasks[i].ProjectID] = workflow
+		}
+		keys[tasks[i].ID] = workflow.stateKey(&tasks[i])
+	}
+	return keys, nil
+}
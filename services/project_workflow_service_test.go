@@ -0,0 +1,133 @@
+package services
+
+import (
+	"project-x/models"
+	"reflect"
+	"testing"
+)
+
+// reviewWorkflow is a custom workflow: todo -> doing -> review -> done, review can go back to doing
+func reviewWorkflow() *ProjectWorkflow {
+	return &ProjectWorkflow{
+		ProjectID: 1,
+		Custom:    true,
+		States: []models.ProjectWorkflowState{
+			{Key: "todo", Name: "To Do", Category: models.TaskStatusPending},
+			{Key: "doing", Name: "Doing", Category: models.TaskStatusInProgress},
+			{Key: "review", Name: "Review", Category: models.TaskStatusInProgress},
+			{Key: "done", Name: "Done", Category: models.TaskStatusCompleted},
+		},
+		Transitions: []models.ProjectWorkflowTransition{
+			{FromState: "todo", ToState: "doing"},
+			{FromState: "doing", ToState: "review"},
+			{FromState: "review", ToState: "doing"},
+			{FromState: "review", ToState: "done"},
+		},
+	}
+}
+
+func TestPlanTransition(t *testing.T) {
+	builtIn := &ProjectWorkflow{States: defaultWorkflowStates}
+
+	tests := []struct {
+		name        string
+		workflow    *ProjectWorkflow
+		task        models.Task
+		state       string
+		wantErr     string
+		wantFrom    string
+		wantUpdates map[string]interface{}
+	}{
+		{
+			name:        "the built-in workflow allows any move between statuses",
+			workflow:    builtIn,
+			task:        models.Task{Status: models.TaskStatusPending},
+			state:       "completed",
+			wantFrom:    "pending",
+			wantUpdates: map[string]interface{}{"status": models.TaskStatusCompleted},
+		},
+		{
+			name:     "the built-in workflow rejects unknown statuses",
+			workflow: builtIn,
+			task:     models.Task{Status: models.TaskStatusPending},
+			state:    "review",
+			wantErr:  "invalid status",
+		},
+		{
+			name:        "an allowed move sets the state and its category",
+			workflow:    reviewWorkflow(),
+			task:        models.Task{Status: models.TaskStatusInProgress, WorkflowState: "doing"},
+			state:       "review",
+			wantFrom:    "doing",
+			wantUpdates: map[string]interface{}{"status": models.TaskStatusInProgress, "workflow_state": "review"},
+		},
+		{
+			name:     "a move without a transition is rejected",
+			workflow: reviewWorkflow(),
+			task:     models.Task{Status: models.TaskStatusInProgress, WorkflowState: "doing"},
+			state:    "done",
+			wantErr:  "tasks cannot move from 'Doing' to 'Done' in this project's workflow",
+		},
+		{
+			name:     "states outside the project's workflow are rejected",
+			workflow: reviewWorkflow(),
+			task:     models.Task{Status: models.TaskStatusPending, WorkflowState: "todo"},
+			state:    "completed",
+			wantErr:  "'completed' is not a state in this project's workflow",
+		},
+		{
+			name:        "a task without a state starts in the first state of its status",
+			workflow:    reviewWorkflow(),
+			task:        models.Task{Status: models.TaskStatusPending},
+			state:       "doing",
+			wantFrom:    "todo",
+			wantUpdates: map[string]interface{}{"status": models.TaskStatusInProgress, "workflow_state": "doing"},
+		},
+		{
+			name:        "a task in a removed state falls back to its status category",
+			workflow:    reviewWorkflow(),
+			task:        models.Task{Status: models.TaskStatusInProgress, WorkflowState: "qa"},
+			state:       "review",
+			wantFrom:    "doing",
+			wantUpdates: map[string]interface{}{"status": models.TaskStatusInProgress, "workflow_state": "review"},
+		},
+		{
+			name:        "staying in the same state is always allowed",
+			workflow:    reviewWorkflow(),
+			task:        models.Task{Status: models.TaskStatusCompleted, WorkflowState: "done"},
+			state:       "done",
+			wantFrom:    "done",
+			wantUpdates: map[string]interface{}{"status": models.TaskStatusCompleted, "workflow_state": "done"},
+		},
+		{
+			name:        "a task whose category has no state may move anywhere",
+			workflow:    reviewWorkflow(),
+			task:        models.Task{Status: models.TaskStatusCancelled},
+			state:       "todo",
+			wantFrom:    "cancelled",
+			wantUpdates: map[string]interface{}{"status": models.TaskStatusPending, "workflow_state": "todo"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transition, updates, err := tt.workflow.planTransition(&tt.task, tt.state)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("planTransition() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planTransition() error = %v", err)
+			}
+
+			if transition.FromState != tt.wantFrom || transition.ToState != tt.state {
+				t.Errorf("transition = %s -> %s, want %s -> %s", transition.FromState, transition.ToState, tt.wantFrom, tt.state)
+			}
+			if !reflect.DeepEqual(updates, tt.wantUpdates) {
+				t.Errorf("updates = %v, want %v", updates, tt.wantUpdates)
+			}
+		})
+	}
+}
@@ -265,9 +265,33 @@ func (s *TaskService) GetProjectCollaborativeTasks(projectID uint) ([]models.Col
 	return tasks, err
 }
 
-// UpdateTaskStatus updates task status
-func (s *TaskService) UpdateTaskStatus(taskID uint, status models.TaskStatus) error {
-	return s.DB.Model(&models.Task{}).Where("id = ?", taskID).Update("status", status).Error
+// UpdateTaskStatus moves a task to a state of its project's workflow. Tasks outside a project,
+// or in a project without a custom workflow, take one of the built-in statuses. The task's
+// Status becomes the category of the new state.
+func (s *TaskService) UpdateTaskStatus(taskID uint, state string) (*TaskTransition, error) {
+	var task models.Task
+	if err := s.DB.First(&task, taskID).Error; err != nil {
+		return nil, errors.New("task not found")
+	}
+
+	workflow, err := NewProjectService(s.DB).GetProjectWorkflow(task.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	return s.transitionTask(&task, workflow, state)
+}
+
+// transitionTask validates and applies a move of a task within the given workflow
+func (s *TaskService) transitionTask(task *models.Task, workflow *ProjectWorkflow, state string) (*TaskTransition, error) {
+	transition, updates, err := workflow.planTransition(task, state)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.DB.Model(task).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	return transition, nil
 }
 
 // UpdateAIAnalysisOnTaskCompletion updates AI analysis with actual results when task completes
@@ -372,10 +396,58 @@ func (s *TaskService) GetCollaborativeTasksByDepartment(department string) ([]mo
 	return tasks, err
 }
 
-// BulkUpdateTaskStatus updates multiple task statuses at once
-func (s *TaskService) BulkUpdateTaskStatus(taskIDs []uint, status models.TaskStatus) (int64, error) {
-	result := s.DB.Model(&models.Task{}).Where("id IN ?", taskIDs).Update("status", status)
-	return result.RowsAffected, result.Error
+// BulkTaskStatusFailure is a task a bulk status update did not move, and why
+type BulkTaskStatusFailure struct {
+	TaskID uint   `json:"task_id"`
+	Error  string `json:"error"`
+}
+
+// BulkUpdateTaskStatus updates multiple task statuses at once. Tasks that are missing, or whose
+// project's workflow does not have the state or allow the move, are left as they are and returned
+// as failures.
+func (s *TaskService) BulkUpdateTaskStatus(taskIDs []uint, state string) (int64, []BulkTaskStatusFailure, error) {
+	var tasks []models.Task
+	if err := s.DB.Where("id IN ?", taskIDs).Find(&tasks).Error; err != nil {
+		return 0, nil, err
+	}
+
+	var failures []BulkTaskStatusFailure
+	found := make(map[uint]bool, len(tasks))
+	for _, task := range tasks {
+		found[task.ID] = true
+	}
+	for _, taskID := range taskIDs {
+		if !found[taskID] {
+			failures = append(failures, BulkTaskStatusFailure{TaskID: taskID, Error: "task not found"})
+		}
+	}
+
+	// Tasks are moved within their own project's workflow
+	projectService := NewProjectService(s.DB)
+	workflows := make(map[uint]*ProjectWorkflow) // Keyed by project ID, 0 for tasks without one
+	var updated int64
+	for i := range tasks {
+		var projectKey uint
+		if tasks[i].ProjectID != nil {
+			projectKey = *tasks[i].ProjectID
+		}
+		workflow, ok := workflows[projectKey]
+		if !ok {
+			var err error
+			if workflow, err = projectService.GetProjectWorkflow(tasks[i].ProjectID); err != nil {
+				return updated, failures, err
+			}
+			workflows[projectKey] = workflow
+		}
+
+		if _, err := s.transitionTask(&tasks[i], workflow, state); err != nil {
+			failures = append(failures, BulkTaskStatusFailure{TaskID: tasks[i].ID, Error: err.Error()})
+			continue
+		}
+		updated++
+	}
+
+	return updated, failures, nil
 }
 
 // GetTaskStatistics returns overall task statistics
@@ -22,6 +22,8 @@ type WebSocketService struct {
 	rooms     map[string]*Room
 	mutex     sync.RWMutex
 	broadcast chan Notification
+
+	aiChatService *AIChatService
 }
 
 // Client represents a connected user
@@ -81,6 +83,11 @@ func NewWebSocketService(db *gorm.DB) *WebSocketService {
 	}
 }
 
+// SetAIChatService sets the AI chat service, so clients can stop an answer being streamed
+func (ws *WebSocketService) SetAIChatService(aiChatService *AIChatService) {
+	ws.aiChatService = aiChatService
+}
+
 // HandleWebSocket handles WebSocket connections with authentication
 func (ws *WebSocketService) HandleWebSocket(c *gin.Context) {
 	// Get user info from middleware
@@ -240,6 +247,8 @@ func (ws *WebSocketService) handleWebSocketMessage(client *Client, wsMessage *We
 		ws.handleTypingIndicator(client, wsMessage)
 	case "ping":
 		ws.handlePing(client)
+	case "ai_cancel":
+		ws.handleAICancel(client, wsMessage)
 	default:
 		log.Printf("Unknown WebSocket message type: %s", wsMessage.Type)
 	}
@@ -388,6 +397,13 @@ func (ws *WebSocketService) handleTypingIndicator(client *Client, wsMessage *Web
 	})
 }
 
+// handleAICancel stops the AI answer being streamed to the client in a room
+func (ws *WebSocketService) handleAICancel(client *Client, wsMessage *WebSocketChatMessage) {
+	if ws.aiChatService == nil || !ws.aiChatService.CancelGeneration(client.ID, wsMessage.RoomID) {
+		ws.sendErrorMessage(client, "No AI response is being generated in this room")
+	}
+}
+
 // handlePing handles ping messages
 func (ws *WebSocketService) handlePing(client *Client) {
 	ws.sendMessage(client, map[string]interface{}{
@@ -617,3 +633,32 @@ func (ws *WebSocketService) BroadcastToRoom(roomName string, notification Notifi
 		}
 	}
 }
+
+// StreamToRoom sends a streaming event, such as a piece of an AI answer, to all users in a room.
+// Unlike BroadcastToRoom it does not log each delivery; an event for a client whose buffer is full
+// is dropped, and the client catches up from the final event.
+func (ws *WebSocketService) StreamToRoom(roomName string, notification Notification) {
+	ws.mutex.RLock()
+	room, exists := ws.rooms[roomName]
+	ws.mutex.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	message, err := json.Marshal(notification)
+	if err != nil {
+		log.Printf("Failed to marshal stream event: %v", err)
+		return
+	}
+
+	room.mutex.RLock()
+	defer room.mutex.RUnlock()
+
+	for _, client := range room.Clients {
+		select {
+		case client.Send <- message:
+		default:
+		}
+	}
+}